@@ -8,6 +8,7 @@ import (
 	"os"
 	"os/signal"
 	"os/user"
+	"path/filepath"
 	"runtime"
 	"time"
 
@@ -18,6 +19,7 @@ import (
 	runnerserver "github.com/kolide/launcher/ee/desktop/runner/server"
 	"github.com/kolide/launcher/ee/desktop/user/menu"
 	"github.com/kolide/launcher/ee/desktop/user/notify"
+	"github.com/kolide/launcher/ee/desktop/user/prompt"
 	userserver "github.com/kolide/launcher/ee/desktop/user/server"
 	"github.com/kolide/launcher/pkg/agent"
 	"github.com/kolide/launcher/pkg/authedclient"
@@ -113,8 +115,10 @@ func runDesktop(args []string) error {
 		return nil
 	}, func(error) {})
 
-	// Set up notification sending and listening
-	notifier := notify.NewDesktopNotifier(logger, *flIconPath)
+	// Set up notification sending and listening. Clicks on notifications are reported back to the runner server.
+	notificationAcknowledgedUrl := fmt.Sprintf("%s%s", *flRunnerServerUrl, runnerserver.NotificationAcknowledgedEndpoint)
+	acknowledger := notify.NewAcknowledger(logger, notificationAcknowledgedUrl, *flRunnerServerAuthToken)
+	notifier := notify.NewDesktopNotifier(logger, *flIconPath, acknowledger)
 	runGroup.Add(notifier.Listen, notifier.Interrupt)
 
 	// monitor parent
@@ -123,13 +127,21 @@ func runDesktop(args []string) error {
 		return nil
 	}, func(error) {})
 
+	// Prompts are answered asynchronously, the answers are posted back to the runner server
+	promptResponseUrl := fmt.Sprintf("%s%s", *flRunnerServerUrl, runnerserver.PromptResponseEndpoint)
+	asker := prompt.NewAsker(logger, promptResponseUrl, *flRunnerServerAuthToken)
+
 	shutdownChan := make(chan struct{})
-	server, err := userserver.New(logger, *flUserServerAuthToken, *flUserServerSocketPath, shutdownChan, notifier)
+	server, err := userserver.New(logger, *flUserServerAuthToken, *flUserServerSocketPath, shutdownChan, notifier, asker)
 	if err != nil {
 		return err
 	}
 
-	m := menu.New(logger, *flhostname, *flmenupath)
+	m := menu.New(logger, *flhostname, *flmenupath,
+		menu.WithRunnerServer(*flRunnerServerUrl, *flRunnerServerAuthToken),
+		menu.WithNotifier(notifier),
+		menu.WithStatusFilePath(statusFilePath(*flUserServerSocketPath)),
+	)
 	refreshMenu := func() {
 		m.Build()
 	}
@@ -277,6 +289,19 @@ func monitorParentProcess(logger log.Logger, runnerServerUrl, runnerServerAuthTo
 	}
 }
 
+// statusFilePath returns where the show-status menu action writes launcher's status: a single
+// file in the folder the desktop runner created for this user, next to the socket. On Windows,
+// the socket is a named pipe, so the user's own temp directory is used instead.
+func statusFilePath(socketPath string) string {
+	const statusFileName = "kolide-status.txt"
+
+	if runtime.GOOS == "windows" {
+		return filepath.Join(os.TempDir(), statusFileName)
+	}
+
+	return filepath.Join(filepath.Dir(socketPath), statusFileName)
+}
+
 func defaultUserServerSocketPath() string {
 	const socketBaseName = "kolide_desktop.sock"
 
@@ -13,6 +13,7 @@ import (
 	"github.com/kolide/launcher/cmd/launcher/internal"
 	"github.com/kolide/launcher/pkg/agent/types"
 	"github.com/kolide/launcher/pkg/augeas"
+	"github.com/kolide/launcher/pkg/autoupdate/tuf"
 	"github.com/kolide/launcher/pkg/contexts/ctxlog"
 	kolidelog "github.com/kolide/launcher/pkg/log"
 	"github.com/kolide/launcher/pkg/osquery"
@@ -30,15 +31,21 @@ import (
 type actorQuerier struct {
 	actor.Actor
 	querier func(query string) ([]map[string]string, error)
+	healthy func() error
 }
 
 func (aq actorQuerier) Query(query string) ([]map[string]string, error) {
 	return aq.querier(query)
 }
 
+// Healthy checks the health of the osquery instance
+func (aq actorQuerier) Healthy() error {
+	return aq.healthy()
+}
+
 // TODO: the extension, runtime, and client are all kind of entangled
 // here. Untangle the underlying libraries and separate into units
-func createExtensionRuntime(ctx context.Context, k types.Knapsack, launcherClient service.KolideService) (
+func createExtensionRuntime(ctx context.Context, k types.Knapsack, launcherClient service.KolideService, extensionLookup *tuf.ExtensionLookup) (
 	run *actorQuerier,
 	restart func() error, // restart osqueryd runner
 	shutdown func() error, // shutdown osqueryd runner
@@ -99,12 +106,12 @@ func createExtensionRuntime(ctx context.Context, k types.Knapsack, launcherClien
 
 	if k.Transport() == "osquery" {
 		var err error
-		runnerOptions, err = osqueryRunnerOptions(logger, k)
+		runnerOptions, err = osqueryRunnerOptions(logger, k, extensionLookup)
 		if err != nil {
 			return nil, nil, nil, fmt.Errorf("creating osquery runner options: %w", err)
 		}
 	} else {
-		runnerOptions = grpcRunnerOptions(logger, k, ext)
+		runnerOptions = grpcRunnerOptions(logger, k, ext, extensionLookup)
 	}
 
 	runner := runtime.LaunchUnstartedInstance(runnerOptions...)
@@ -185,6 +192,7 @@ func createExtensionRuntime(ctx context.Context, k types.Knapsack, launcherClien
 				},
 			},
 			querier: runner.Query,
+			healthy: runner.Healthy,
 		},
 		restartFunc,
 		runner.Shutdown,
@@ -192,7 +200,7 @@ func createExtensionRuntime(ctx context.Context, k types.Knapsack, launcherClien
 }
 
 // commonRunnerOptions returns osquery runtime options common to all transports
-func commonRunnerOptions(logger log.Logger, k types.Knapsack) []runtime.OsqueryInstanceOption {
+func commonRunnerOptions(logger log.Logger, k types.Knapsack, extensionLookup *tuf.ExtensionLookup) []runtime.OsqueryInstanceOption {
 	// create the logging adapters for osquery
 	osqueryStderrLogger := kolidelog.NewOsqueryLogAdapter(
 		logger,
@@ -209,22 +217,28 @@ func commonRunnerOptions(logger log.Logger, k types.Knapsack) []runtime.OsqueryI
 		kolidelog.WithKeyValue("osqlevel", "stdout"),
 	)
 
+	// If autoupdates are enabled, prefer any versions of our extensions that are in the update library
+	autoloadedExtensions := k.AutoloadedExtensions()
+	if k.Autoupdate() {
+		autoloadedExtensions = extensionLookup.CheckOutLatest(autoloadedExtensions, k.RootDirectory(), k.UpdateDirectory(), k.UpdateChannel(), logger)
+	}
+
 	return []runtime.OsqueryInstanceOption{
 		runtime.WithOsquerydBinary(k.OsquerydPath()),
 		runtime.WithRootDirectory(k.RootDirectory()),
-		runtime.WithOsqueryExtensionPlugins(ktable.LauncherTables(k)...),
+		runtime.WithOsqueryExtensionPlugins(ktable.LauncherTables(k, logger)...),
 		runtime.WithStdout(osqueryStdoutLogger),
 		runtime.WithStderr(osqueryStderrLogger),
 		runtime.WithLogger(logger),
 		runtime.WithOsqueryVerbose(k.OsqueryVerbose()),
 		runtime.WithOsqueryFlags(k.OsqueryFlags()),
 		runtime.WithAugeasLensFunction(augeas.InstallLenses),
-		runtime.WithAutoloadedExtensions(k.AutoloadedExtensions()...),
+		runtime.WithAutoloadedExtensions(autoloadedExtensions...),
 	}
 }
 
 // osqueryRunnerOptions returns the osquery runtime options when using native osquery transport
-func osqueryRunnerOptions(logger log.Logger, k types.Knapsack) ([]runtime.OsqueryInstanceOption, error) {
+func osqueryRunnerOptions(logger log.Logger, k types.Knapsack, extensionLookup *tuf.ExtensionLookup) ([]runtime.OsqueryInstanceOption, error) {
 	// As osquery requires TLS server certs, we'll  use our embedded defaults if not specified
 	caCertFile := k.RootPEM()
 	if caCertFile == "" {
@@ -236,7 +250,7 @@ func osqueryRunnerOptions(logger log.Logger, k types.Knapsack) ([]runtime.Osquer
 	}
 
 	runtimeOptions := append(
-		commonRunnerOptions(logger, k),
+		commonRunnerOptions(logger, k, extensionLookup),
 		runtime.WithConfigPluginFlag("tls"),
 		runtime.WithDistributedPluginFlag("tls"),
 		runtime.WithLoggerPluginFlag("tls"),
@@ -263,9 +277,9 @@ func osqueryRunnerOptions(logger log.Logger, k types.Knapsack) ([]runtime.Osquer
 }
 
 // grpcRunnerOptions returns the osquery runtime options when using launcher transports. (Eg: grpc or jsonrpc)
-func grpcRunnerOptions(logger log.Logger, k types.Knapsack, ext *osquery.Extension) []runtime.OsqueryInstanceOption {
+func grpcRunnerOptions(logger log.Logger, k types.Knapsack, ext *osquery.Extension, extensionLookup *tuf.ExtensionLookup) []runtime.OsqueryInstanceOption {
 	return append(
-		commonRunnerOptions(logger, k),
+		commonRunnerOptions(logger, k, extensionLookup),
 		runtime.WithConfigPluginFlag("kolide_grpc"),
 		runtime.WithLoggerPluginFlag("kolide_grpc"),
 		runtime.WithDistributedPluginFlag("kolide_grpc"),
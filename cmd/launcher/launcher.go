@@ -26,6 +26,7 @@ import (
 	"github.com/kolide/launcher/cmd/launcher/internal/updater"
 	"github.com/kolide/launcher/ee/control/consumers/keyvalueconsumer"
 	"github.com/kolide/launcher/ee/control/consumers/notificationconsumer"
+	"github.com/kolide/launcher/ee/control/consumers/promptconsumer"
 	desktopRunner "github.com/kolide/launcher/ee/desktop/runner"
 	"github.com/kolide/launcher/ee/localserver"
 	"github.com/kolide/launcher/pkg/agent"
@@ -42,8 +43,10 @@ import (
 	"github.com/kolide/launcher/pkg/log/checkpoint"
 	"github.com/kolide/launcher/pkg/log/logshipper"
 	"github.com/kolide/launcher/pkg/log/teelogger"
+	metricsexporter "github.com/kolide/launcher/pkg/metrics/exporter"
 	"github.com/kolide/launcher/pkg/osquery"
 	osqueryInstanceHistory "github.com/kolide/launcher/pkg/osquery/runtime/history"
+	"github.com/kolide/launcher/pkg/sdnotify"
 	"github.com/kolide/launcher/pkg/service"
 	"github.com/kolide/launcher/pkg/traces/exporter"
 	"github.com/kolide/launcher/pkg/windows/powereventwatcher"
@@ -54,10 +57,12 @@ import (
 
 const (
 	// Subsystems that launcher listens for control server updates on
-	agentFlagsSubsystemName  = "agent_flags"
-	serverDataSubsystemName  = "kolide_server_data"
-	desktopMenuSubsystemName = "kolide_desktop_menu"
-	authTokensSubsystemName  = "auth_tokens"
+	agentFlagsSubsystemName         = "agent_flags"
+	serverDataSubsystemName         = "kolide_server_data"
+	desktopMenuSubsystemName        = "kolide_desktop_menu"
+	authTokensSubsystemName         = "auth_tokens"
+	localServerQueriesSubsystemName = "localserver_queries"
+	traceSamplingRulesSubsystemName = "trace_sampling_rules"
 )
 
 // runLauncher is the entry point into running launcher. It creates a
@@ -254,12 +259,22 @@ func runLauncher(ctx context.Context, cancel func(), opts *launcher.Options) err
 	}
 
 	// create the osquery extension for launcher. This is where osquery itself is launched.
-	extension, runnerRestart, runnerShutdown, err := createExtensionRuntime(ctx, k, client)
+	// The extension lookup records which autoloaded extension versions osquery is given, for the autoupdater.
+	extensionLookup := tuf.NewExtensionLookup()
+	extension, runnerRestart, runnerShutdown, err := createExtensionRuntime(ctx, k, client, extensionLookup)
 	if err != nil {
 		return fmt.Errorf("create extension with runtime: %w", err)
 	}
 	runGroup.Add(extension.Execute, extension.Interrupt)
 
+	// If systemd is watching us, let it know we're still alive -- as long as osquery is healthy
+	systemdWatchdog, err := sdnotify.NewWatchdog(log.With(logger, "component", "systemd_watchdog"), extension.Healthy)
+	if err != nil {
+		level.Debug(logger).Log("msg", "not pinging systemd watchdog", "err", err)
+	} else {
+		runGroup.Add(systemdWatchdog.Execute, systemdWatchdog.Interrupt)
+	}
+
 	versionInfo := version.Version()
 	level.Info(logger).Log(
 		"msg", "started kolide launcher",
@@ -273,6 +288,18 @@ func runLauncher(ctx context.Context, cancel func(), opts *launcher.Options) err
 		checkpointer.SetQuerier(extension)
 	}()
 
+	// Set up our metrics instrumentation. This runs without a control server too, since
+	// metrics may be served locally for Prometheus.
+	metricsExporter, err := metricsexporter.NewMetricsExporter(ctx, k, logger)
+	if err != nil {
+		level.Debug(logger).Log(
+			"msg", "could not set up metrics exporter",
+			"err", err,
+		)
+	} else {
+		runGroup.Add(metricsExporter.Execute, metricsExporter.Interrupt)
+	}
+
 	// Create the control service and services that depend on it
 	var runner *desktopRunner.DesktopUsersProcessesRunner
 	if k.ControlServerURL() == "" {
@@ -290,12 +317,18 @@ func runLauncher(ctx context.Context, cancel func(), opts *launcher.Options) err
 		// agentFlagConsumer handles agent flags pushed from the control server
 		agentFlagsConsumer := keyvalueconsumer.New(flagController)
 		controlService.RegisterConsumer(agentFlagsSubsystemName, agentFlagsConsumer)
+		// localServerQueriesConsumer handles the catalog of named queries the local server may run
+		localServerQueriesConsumer := keyvalueconsumer.New(k.LocalServerQueriesStore())
+		controlService.RegisterConsumer(localServerQueriesSubsystemName, localServerQueriesConsumer)
 
 		runner, err = desktopRunner.New(
 			k,
 			desktopRunner.WithLogger(logger),
 			desktopRunner.WithAuthToken(ulid.New()),
 			desktopRunner.WithUsersFilesRoot(rootDirectory),
+			desktopRunner.WithPromptResponseStore(k.DesktopPromptResponsesStore()),
+			desktopRunner.WithControlStore(k.ControlStore()),
+			desktopRunner.WithServerProvidedDataStore(k.ServerProvidedDataStore()),
 		)
 		if err != nil {
 			return fmt.Errorf("failed to create desktop runner: %w", err)
@@ -303,6 +336,8 @@ func runLauncher(ctx context.Context, cancel func(), opts *launcher.Options) err
 
 		runGroup.Add(runner.Execute, runner.Interrupt)
 		controlService.RegisterConsumer(desktopMenuSubsystemName, runner)
+		// Device state in the menu comes from osquery, once it's up
+		go runner.SetQuerier(extension)
 		// Run the notification service
 		notificationConsumer, err := notificationconsumer.NewNotifyConsumer(
 			k.SentNotificationsStore(),
@@ -313,18 +348,42 @@ func runLauncher(ctx context.Context, cancel func(), opts *launcher.Options) err
 		if err != nil {
 			return fmt.Errorf("failed to set up notifier: %w", err)
 		}
-		// Runs the cleanup routine for old notification records
+		// Runs the cleanup routine for old notification records, and sends scheduled notifications
 		runGroup.Add(notificationConsumer.Execute, notificationConsumer.Interrupt)
+		// Users' clicks on notifications arrive via the runner, and stop repeating notifications
+		runner.SetNotificationAcknowledgementRecorder(notificationConsumer)
 
 		if err := controlService.RegisterConsumer(notificationconsumer.NotificationSubsystem, notificationConsumer); err != nil {
 			return fmt.Errorf("failed to register notify consumer: %w", err)
 		}
 
+		// Run the prompt service, users' answers are stored by the runner
+		promptConsumer, err := promptconsumer.NewPromptConsumer(
+			k.DesktopPromptResponsesStore(),
+			runner,
+			ctx,
+			promptconsumer.WithLogger(logger),
+		)
+		if err != nil {
+			return fmt.Errorf("failed to set up prompter: %w", err)
+		}
+		// Runs the cleanup routine for old prompt responses
+		runGroup.Add(promptConsumer.Execute, promptConsumer.Interrupt)
+
+		if err := controlService.RegisterConsumer(promptconsumer.PromptSubsystem, promptConsumer); err != nil {
+			return fmt.Errorf("failed to register prompt consumer: %w", err)
+		}
+
 		// Set up our tracing instrumentation
 		authTokenConsumer := keyvalueconsumer.New(k.TokenStore())
 		if err := controlService.RegisterConsumer(authTokensSubsystemName, authTokenConsumer); err != nil {
 			return fmt.Errorf("failed to register auth token consumer: %w", err)
 		}
+		// traceSamplingRulesConsumer handles the rules deciding which traces are exported
+		traceSamplingRulesConsumer := keyvalueconsumer.New(k.TraceSamplingRulesStore())
+		if err := controlService.RegisterConsumer(traceSamplingRulesSubsystemName, traceSamplingRulesConsumer); err != nil {
+			return fmt.Errorf("failed to register trace sampling rules consumer: %w", err)
+		}
 
 		if exp, err := exporter.NewTraceExporter(ctx, k, extension, logger); err != nil {
 			level.Debug(logger).Log(
@@ -334,6 +393,11 @@ func runLauncher(ctx context.Context, cancel func(), opts *launcher.Options) err
 		} else {
 			runGroup.Add(exp.Execute, exp.Interrupt)
 			controlService.RegisterSubscriber(authTokensSubsystemName, exp)
+			controlService.RegisterSubscriber(traceSamplingRulesSubsystemName, exp)
+		}
+
+		if metricsExporter != nil {
+			controlService.RegisterSubscriber(authTokensSubsystemName, metricsExporter)
 		}
 
 		// begin log shipping and subsribe to token updates
@@ -350,10 +414,15 @@ func runLauncher(ctx context.Context, cancel func(), opts *launcher.Options) err
 	// at this moment, these values are the same. This variable is here to help humans parse what's happening
 	runLocalServer := runEECode
 	if runLocalServer {
-		ls, err := localserver.New(
-			k,
+		localServerOpts := []localserver.LocalServerOption{
 			localserver.WithLogger(logger),
-		)
+			localserver.WithChallengeStore(k.LocalServerChallengesStore()),
+		}
+		if k.LocalApiEnabled() {
+			localServerOpts = append(localServerOpts, localserver.WithLocalApiPath(localserver.DefaultLocalApiPath(rootDirectory)))
+		}
+
+		ls, err := localserver.New(k, localServerOpts...)
 
 		if err != nil {
 			// For now, log this and move on. It might be a fatal error
@@ -432,6 +501,7 @@ func runLauncher(ctx context.Context, cancel func(), opts *launcher.Options) err
 			mirrorClient,
 			extension,
 			tuf.WithLogger(logger),
+			tuf.WithExtensionLookup(extensionLookup),
 		)
 		if err != nil {
 			// Log the error, but don't return it -- the new TUF autoupdater is not critical yet
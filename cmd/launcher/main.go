@@ -92,7 +92,13 @@ func main() {
 
 	// Create a local logger. This logs to a known path, and aims to help diagnostics
 	if opts.RootDirectory != "" {
-		logger = teelogger.New(logger, locallogger.NewKitLogger(filepath.Join(opts.RootDirectory, "debug.json")))
+		logger = teelogger.New(logger, locallogger.NewKitLogger(
+			filepath.Join(opts.RootDirectory, "debug.json"),
+			locallogger.WithLevel(opts.DebugLogLevel),
+			locallogger.WithMaxSize(opts.DebugLogMaxSizeMB),
+			locallogger.WithMaxBackups(opts.DebugLogMaxBackups),
+			locallogger.WithMaxAge(opts.DebugLogMaxAgeDays),
+		))
 		locallogger.CleanUpRenamedDebugLogs(opts.RootDirectory, logger)
 	}
 
@@ -141,6 +147,8 @@ func runSubcommands() error {
 		run = runDownloadOsquery
 	case "uninstall":
 		run = runUninstall
+	case "update":
+		run = runUpdate
 	default:
 		return fmt.Errorf("Unknown subcommand %s", os.Args[1])
 	}
@@ -20,8 +20,9 @@ import (
 )
 
 const (
-	defaultRootDirectory = "launcher-root"
-	skipEnvParse         = runtime.GOOS == "windows" // skip environmental variable parsing on windows
+	defaultRootDirectory             = "launcher-root"
+	defaultLocalServerAllowedOrigins = "https://*.kolide.com"
+	skipEnvParse                     = runtime.GOOS == "windows" // skip environmental variable parsing on windows
 )
 
 var (
@@ -83,10 +84,16 @@ func parseOptions(subcommandName string, args []string) (*launcher.Options, erro
 		flCompactDbMaxTx         = flagset.Int64("compactdb-max-tx", 65536, "Maximum transaction size used when compacting the internal DB")
 		flConfigFilePath         = flagset.String("config", defaultConfigFilePath, "config file to parse options from (optional)")
 		flExportTraces           = flagset.Bool("export_traces", false, "Whether to export traces")
+		flExportLogsOtlp         = flagset.Bool("export_logs_otlp", false, "Whether to ship logs as OTLP to the trace ingest server, instead of as JSON to the log ingest server")
+		flExportMetrics          = flagset.Bool("export_metrics", false, "Whether to export metrics to the trace ingest server")
+		flMetricsPrometheusPort  = flagset.Int("metrics_prometheus_port", 0, "Localhost port to serve metrics on in the Prometheus format (default: disabled)")
 		flTraceSamplingRate      = flagset.Float64("trace_sampling_rate", 0.0, "What fraction of traces should be sampled")
 		flLogIngestServerURL     = flagset.String("log_ingest_url", "", "Where to export logs")
 		flTraceIngestServerURL   = flagset.String("trace_ingest_url", "", "Where to export traces")
 		flDisableIngestTLS       = flagset.Bool("disable_trace_ingest_tls", false, "Disable TLS for observability ingest server communication")
+		flLocalServerOrigins     = flagset.String("localserver_allowed_origins", defaultLocalServerAllowedOrigins, "Comma separated web origins allowed to make requests to the local server")
+		flLocalApiAllowedGroups  = flagset.String("local_api_allowed_groups", "", "Comma separated groups whose members, in addition to root, may use the local API socket")
+		flLocalApiEnabled        = flagset.Bool("local_api", false, "Serve the local API on a Unix socket in the root directory, or a named pipe on Windows")
 
 		// osquery TLS endpoints
 		flOsqTlsConfig    = flagset.String("config_tls_endpoint", "", "Config endpoint for the osquery tls transport")
@@ -114,6 +121,10 @@ func parseOptions(subcommandName string, args []string) (*launcher.Options, erro
 		flInsecureTLS          = flagset.Bool("insecure", false, "Do not verify TLS certs for outgoing connections (default: false)")
 		flIAmBreakingEELicense = flagset.Bool("i-am-breaking-ee-license", false, "Skip license check before running localserver (default: false)")
 		flDelayStart           = flagset.Duration("delay_start", 0*time.Second, "How much time to wait before starting launcher")
+		flDebugLogLevel        = flagset.String("debug_log_level", "debug", "Minimum level written to the local debug log: debug, info, warn or error")
+		flDebugLogMaxSizeMB    = flagset.Int("debug_log_max_size_mb", 3, "Size, in megabytes, at which the local debug log is rotated")
+		flDebugLogMaxBackups   = flagset.Int("debug_log_max_backups", 5, "Number of rotated local debug logs to keep")
+		flDebugLogMaxAgeDays   = flagset.Int("debug_log_max_age_days", 0, "Number of days to keep rotated local debug logs (default: no limit)")
 
 		// deprecated options, kept for any kind of config file compatibility
 		_ = flagset.String("debug_log_file", "", "DEPRECATED")
@@ -242,6 +253,10 @@ func parseOptions(subcommandName string, args []string) (*launcher.Options, erro
 		ControlServerURL:                   controlServerURL,
 		ControlRequestInterval:             *flControlRequestInterval,
 		Debug:                              *flDebug,
+		DebugLogLevel:                      *flDebugLogLevel,
+		DebugLogMaxSizeMB:                  *flDebugLogMaxSizeMB,
+		DebugLogMaxBackups:                 *flDebugLogMaxBackups,
+		DebugLogMaxAgeDays:                 *flDebugLogMaxAgeDays,
 		DelayStart:                         *flDelayStart,
 		DisableControlTLS:                  disableControlTLS,
 		InsecureControlTLS:                 insecureControlTLS,
@@ -249,9 +264,15 @@ func parseOptions(subcommandName string, args []string) (*launcher.Options, erro
 		EnrollSecret:                       *flEnrollSecret,
 		EnrollSecretPath:                   *flEnrollSecretPath,
 		ExportTraces:                       *flExportTraces,
+		ExportLogsOtlp:                     *flExportLogsOtlp,
+		ExportMetrics:                      *flExportMetrics,
+		MetricsPrometheusPort:              *flMetricsPrometheusPort,
 		LogIngestServerURL:                 *flLogIngestServerURL,
 		TraceIngestServerURL:               *flTraceIngestServerURL,
 		DisableTraceIngestTLS:              *flDisableIngestTLS,
+		LocalServerAllowedOrigins:          *flLocalServerOrigins,
+		LocalApiAllowedGroups:              *flLocalApiAllowedGroups,
+		LocalApiEnabled:                    *flLocalApiEnabled,
 		AutoloadedExtensions:               flAutoloadedExtensions,
 		IAmBreakingEELicense:               *flIAmBreakingEELicense,
 		InsecureTLS:                        *flInsecureTLS,
@@ -355,7 +376,10 @@ func developerUsage(flagset *flag.FlagSet) {
 	fmt.Fprintf(os.Stderr, "\n")
 	printOpt("debug")
 	printOpt("osquery_verbose")
-	printOpt("debug_log_file")
+	printOpt("debug_log_level")
+	printOpt("debug_log_max_size_mb")
+	printOpt("debug_log_max_backups")
+	printOpt("debug_log_max_age_days")
 	fmt.Fprintf(os.Stderr, "\n")
 	printOpt("insecure")
 	printOpt("insecure_transport")
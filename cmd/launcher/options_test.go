@@ -185,27 +185,33 @@ func getArgsAndResponse() (map[string]string, *launcher.Options) {
 	}
 
 	opts := &launcher.Options{
-		AutoupdateInitialDelay: 1 * time.Hour,
-		AutoupdateInterval:     48 * time.Hour,
-		CompactDbMaxTx:         int64(65536),
-		Control:                false,
-		ControlServerURL:       "",
-		ControlRequestInterval: 60 * time.Second,
-		ExportTraces:           false,
-		TraceSamplingRate:      0.0,
-		LogIngestServerURL:     "",
-		DisableTraceIngestTLS:  false,
-		KolideServerURL:        randomHostname,
-		LoggingInterval:        time.Duration(randomInt) * time.Second,
-		MirrorServerURL:        "https://dl.kolide.co",
-		NotaryPrefix:           "kolide",
-		NotaryServerURL:        "https://notary.kolide.co",
-		TufServerURL:           "https://tuf.kolide.com",
-		OsquerydPath:           windowsAddExe("/dev/null"),
-		Transport:              "grpc",
-		UpdateChannel:          "stable",
-		AutoloadedExtensions:   []string{"some-extension.ext"},
-		DelayStart:             0 * time.Second,
+		AutoupdateInitialDelay:    1 * time.Hour,
+		AutoupdateInterval:        48 * time.Hour,
+		CompactDbMaxTx:            int64(65536),
+		DebugLogLevel:             "debug",
+		DebugLogMaxSizeMB:         3,
+		DebugLogMaxBackups:        5,
+		Control:                   false,
+		ControlServerURL:          "",
+		ControlRequestInterval:    60 * time.Second,
+		ExportTraces:              false,
+		ExportLogsOtlp:            false,
+		ExportMetrics:             false,
+		TraceSamplingRate:         0.0,
+		LogIngestServerURL:        "",
+		DisableTraceIngestTLS:     false,
+		LocalServerAllowedOrigins: "https://*.kolide.com",
+		KolideServerURL:           randomHostname,
+		LoggingInterval:           time.Duration(randomInt) * time.Second,
+		MirrorServerURL:           "https://dl.kolide.co",
+		NotaryPrefix:              "kolide",
+		NotaryServerURL:           "https://notary.kolide.co",
+		TufServerURL:              "https://tuf.kolide.com",
+		OsquerydPath:              windowsAddExe("/dev/null"),
+		Transport:                 "grpc",
+		UpdateChannel:             "stable",
+		AutoloadedExtensions:      []string{"some-extension.ext"},
+		DelayStart:                0 * time.Second,
 	}
 
 	return args, opts
@@ -53,7 +53,13 @@ func runWindowsSvc(args []string) error {
 
 	// Create a local logger. This logs to a known path, and aims to help diagnostics
 	if opts.RootDirectory != "" {
-		logger = teelogger.New(logger, locallogger.NewKitLogger(filepath.Join(opts.RootDirectory, "debug.json")))
+		logger = teelogger.New(logger, locallogger.NewKitLogger(
+			filepath.Join(opts.RootDirectory, "debug.json"),
+			locallogger.WithLevel(opts.DebugLogLevel),
+			locallogger.WithMaxSize(opts.DebugLogMaxSizeMB),
+			locallogger.WithMaxBackups(opts.DebugLogMaxBackups),
+			locallogger.WithMaxAge(opts.DebugLogMaxAgeDays),
+		))
 		locallogger.CleanUpRenamedDebugLogs(opts.RootDirectory, logger)
 	}
 
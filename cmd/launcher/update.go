@@ -0,0 +1,104 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"runtime"
+	"time"
+
+	"github.com/kolide/kit/logutil"
+	"github.com/kolide/launcher/pkg/autoupdate"
+	"github.com/kolide/launcher/pkg/autoupdate/tuf"
+)
+
+// runUpdate handles offline update bundles. `launcher update export <bundle>` builds a bundle
+// on a machine with network access, and `launcher update import <bundle>` installs it on a device
+// that cannot reach the TUF server or mirror.
+func runUpdate(args []string) error {
+	if len(args) < 1 {
+		return errors.New("expected subcommand: import or export")
+	}
+
+	switch args[0] {
+	case "import":
+		return runUpdateImport(args[1:])
+	case "export":
+		return runUpdateExport(args[1:])
+	default:
+		return fmt.Errorf("unknown update subcommand %s", args[0])
+	}
+}
+
+// runUpdateImport verifies the given bundle and installs it into the update library. It is invoked as
+// `launcher update import <bundle> [launcher flags]`, so that the root directory, update directory, and
+// update channel are read from the launcher's usual configuration.
+func runUpdateImport(args []string) error {
+	if len(args) < 1 {
+		return errors.New("usage: launcher update import <bundle> [flags]")
+	}
+	bundlePath := args[0]
+
+	// Import assumes a launcher installation exists, so default to the installation's paths
+	setDefaultPaths()
+
+	opts, err := parseOptions("update import", args[1:])
+	if err != nil {
+		return err
+	}
+
+	if opts.RootDirectory == "" {
+		return errors.New("No root directory specified")
+	}
+
+	logger := logutil.NewServerLogger(opts.Debug)
+
+	imported, err := tuf.ImportBundle(bundlePath, opts.RootDirectory, opts.UpdateDirectory, string(opts.UpdateChannel), logger)
+	if err != nil {
+		return fmt.Errorf("importing bundle %s: %w", bundlePath, err)
+	}
+
+	for _, update := range imported {
+		fmt.Printf("Imported version %s: %s\n", update.Version, update.Path)
+	}
+
+	return nil
+}
+
+// runUpdateExport downloads the latest release for the given channel and platform, along with the
+// TUF metadata needed to verify it, and writes them to an update bundle.
+func runUpdateExport(args []string) error {
+	fs := flag.NewFlagSet("launcher update export", flag.ExitOnError)
+
+	var (
+		flTufServerURL = fs.String("tuf_url", tuf.DefaultTufServer, "TUF update server")
+		flMirrorURL    = fs.String("mirror_url", autoupdate.DefaultMirror, "The mirror server for autoupdates")
+		flChannel      = fs.String("update_channel", "stable", "The channel to export the release for")
+		flOs           = fs.String("os", runtime.GOOS, "The OS of the devices the bundle is for")
+		flArch         = fs.String("arch", tuf.PlatformArch(), "The arch of the devices the bundle is for (darwin uses universal)")
+	)
+	fs.Usage = commandUsage(fs, "launcher update export [flags] <bundle>")
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return errors.New("expected exactly one bundle path")
+	}
+	bundlePath := fs.Arg(0)
+
+	httpClient := &http.Client{
+		Timeout: 8 * time.Minute, // gives us extra time to avoid a timeout on download
+	}
+
+	if err := tuf.ExportBundle(bundlePath, *flTufServerURL, *flMirrorURL, httpClient, *flChannel, *flOs, *flArch); err != nil {
+		return fmt.Errorf("exporting bundle: %w", err)
+	}
+
+	fmt.Printf("Exported bundle to: %s\n", bundlePath)
+
+	return nil
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+	"github.com/kolide/kit/env"
+	"github.com/kolide/launcher/pkg/contexts/ctxlog"
+	"github.com/kolide/launcher/pkg/packaging"
+)
+
+func runBuild(args []string) error {
+	flagset := flag.NewFlagSet("build", flag.ExitOnError)
+	var (
+		flDebug = flagset.Bool(
+			"debug",
+			false,
+			"enable debug logging",
+		)
+		flManifest = flagset.String(
+			"f",
+			env.String("MANIFEST", ""),
+			"path to the YAML or JSON manifest describing the packages to build",
+		)
+		flOutputDir = flagset.String(
+			"output_dir",
+			env.String("OUTPUT_DIR", ""),
+			"overrides the manifest's output_dir",
+		)
+		flValidate = flagset.Bool(
+			"validate",
+			false,
+			"validate the manifest, then exit without building",
+		)
+	)
+
+	flagset.Usage = usageFor(flagset, "package-builder build -f manifest.yaml [flags]")
+	if err := flagset.Parse(args); err != nil {
+		return err
+	}
+
+	logger := log.NewJSONLogger(os.Stderr)
+	logger = log.With(logger, "ts", log.DefaultTimestampUTC)
+	logger = log.With(logger, "caller", log.DefaultCaller)
+
+	if *flDebug {
+		logger = level.NewFilter(logger, level.AllowDebug())
+	} else {
+		logger = level.NewFilter(logger, level.AllowInfo())
+	}
+
+	ctx := context.Background()
+	ctx = ctxlog.NewContext(ctx, logger)
+
+	if *flManifest == "" {
+		return errors.New("manifest undefined, use -f")
+	}
+
+	manifest, err := packaging.LoadManifest(*flManifest)
+	if err != nil {
+		return err
+	}
+
+	if *flValidate {
+		fmt.Printf("Manifest %s is valid\n", *flManifest)
+		return nil
+	}
+
+	// If we have a cacheDir, use it. Otherwise. set something random.
+	// Builds share the cache, since they often use the same binaries.
+	cacheDir := manifest.CacheDir
+	if cacheDir == "" {
+		cacheDir, err = os.MkdirTemp("", "download_cache")
+		if err != nil {
+			return fmt.Errorf("could not create temp dir for caching files: %w", err)
+		}
+		defer os.RemoveAll(cacheDir)
+	}
+
+	outputDir := manifest.OutputDir
+	if *flOutputDir != "" {
+		outputDir = *flOutputDir
+	}
+	if outputDir == "" {
+		outputDir, err = os.MkdirTemp("", "launcher-package")
+		if err != nil {
+			return fmt.Errorf("making output dir: %w", err)
+		}
+	}
+
+	for _, build := range manifest.Builds {
+		buildDir := filepath.Join(outputDir, build.Name)
+		if err := os.MkdirAll(buildDir, 0755); err != nil {
+			return fmt.Errorf("mkdir: %w", err)
+		}
+
+		for _, targetString := range build.Targets {
+			target := packaging.Target{}
+			if err := target.Parse(targetString); err != nil {
+				return fmt.Errorf("parsing target %s: %w", targetString, err)
+			}
+
+			packageOptions, err := build.PackageOptionsFor(targetString, cacheDir)
+			if err != nil {
+				return fmt.Errorf("build %s: %w", build.Name, err)
+			}
+
+			level.Info(logger).Log("msg", "building package", "build", build.Name, "target", target.String())
+
+			outputFileName := fmt.Sprintf("launcher.%s.%s", target.String(), target.PkgExtension())
+			if err := buildToFile(ctx, packageOptions, target, filepath.Join(buildDir, outputFileName)); err != nil {
+				return fmt.Errorf("build %s, target %s: %w", build.Name, target.String(), err)
+			}
+		}
+	}
+
+	fmt.Printf("Built packages in %s\n", outputDir)
+	return nil
+}
+
+func buildToFile(ctx context.Context, packageOptions *packaging.PackageOptions, target packaging.Target, outputPath string) error {
+	outputFile, err := os.Create(outputPath)
+	if err != nil {
+		return fmt.Errorf("Failed to make package output file: %w", err)
+	}
+	defer outputFile.Close()
+
+	if err := packageOptions.Build(ctx, outputFile, target); err != nil {
+		return fmt.Errorf("could not generate packages: %w", err)
+	}
+
+	if err := outputFile.Close(); err != nil {
+		return fmt.Errorf("closing package output file: %w", err)
+	}
+
+	if err := packageOptions.WriteAttestations(outputPath); err != nil {
+		return fmt.Errorf("writing attestations: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/kolide/kit/env"
+	"github.com/kolide/launcher/pkg/packaging"
+)
+
+func runDaemonSet(args []string) error {
+	flagset := flag.NewFlagSet("daemonset", flag.ExitOnError)
+	var (
+		flHostname = flagset.String(
+			"hostname",
+			env.String("HOSTNAME", ""),
+			"the hostname the image was built with",
+		)
+		flIdentifier = flagset.String(
+			"identifier",
+			env.String("IDENTIFIER", "launcher"),
+			"the identifier the image was built with",
+		)
+		flImage = flagset.String(
+			"image",
+			env.String("IMAGE", ""),
+			"the image reference, as pushed to your registry (eg: registry.example.com/launcher:1.2.3)",
+		)
+		flNamespace = flagset.String(
+			"namespace",
+			env.String("NAMESPACE", "default"),
+			"the Kubernetes namespace to deploy to",
+		)
+		flEnrollSecretName = flagset.String(
+			"enroll_secret_name",
+			env.String("ENROLL_SECRET_NAME", ""),
+			"name of a Kubernetes secret, with the key `secret`, to mount as the enroll secret. Build the image with --omit_secret",
+		)
+		flMemoryLimit = flagset.String(
+			"memory_limit",
+			env.String("MEMORY_LIMIT", ""),
+			"optional memory limit for the launcher container (eg: 512Mi)",
+		)
+		flCPULimit = flagset.String(
+			"cpu_limit",
+			env.String("CPU_LIMIT", ""),
+			"optional cpu limit for the launcher container (eg: 500m)",
+		)
+		flOutput = flagset.String(
+			"o",
+			"",
+			"file to write the manifest to. Defaults to stdout",
+		)
+	)
+
+	flagset.Usage = usageFor(flagset, "package-builder daemonset --hostname=<hostname> --image=<image> [flags]")
+	if err := flagset.Parse(args); err != nil {
+		return err
+	}
+
+	if *flImage == "" {
+		return errors.New("image undefined, use --image")
+	}
+
+	packageOptions := &packaging.PackageOptions{
+		Hostname:   *flHostname,
+		Identifier: *flIdentifier,
+	}
+
+	var out io.Writer = os.Stdout
+	if *flOutput != "" {
+		outputFile, err := os.Create(*flOutput)
+		if err != nil {
+			return fmt.Errorf("creating output file: %w", err)
+		}
+		defer outputFile.Close()
+		out = outputFile
+	}
+
+	return packageOptions.RenderDaemonSet(context.Background(), out, packaging.DaemonSetOptions{
+		Image:            *flImage,
+		Namespace:        *flNamespace,
+		EnrollSecretName: *flEnrollSecretName,
+		MemoryLimit:      *flMemoryLimit,
+		CPULimit:         *flCPULimit,
+	})
+}
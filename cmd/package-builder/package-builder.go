@@ -174,6 +174,46 @@ func runMake(args []string) error {
 			false,
 			"Create persistence service in a disabled state",
 		)
+		flNativePackaging = flagset.Bool(
+			"native_packaging",
+			env.Bool("NATIVE_PACKAGING", false),
+			"build deb and rpm packages natively, instead of with fpm and docker",
+		)
+		flSystemdHardening = flagset.Bool(
+			"systemd_hardening",
+			env.Bool("SYSTEMD_HARDENING", false),
+			"sandbox launcher's systemd unit with ProtectSystem, NoNewPrivileges and a capability bounding set",
+		)
+		flSystemdMemoryMax = flagset.String(
+			"systemd_memory_max",
+			env.String("SYSTEMD_MEMORY_MAX", ""),
+			"MemoryMax for launcher's systemd unit (eg: 512M)",
+		)
+		flSystemdCPUQuota = flagset.String(
+			"systemd_cpu_quota",
+			env.String("SYSTEMD_CPU_QUOTA", ""),
+			"CPUQuota for launcher's systemd unit (eg: 20%)",
+		)
+		flSystemdIOWeight = flagset.Int(
+			"systemd_io_weight",
+			env.Int("SYSTEMD_IO_WEIGHT", 0),
+			"IOWeight for launcher's systemd unit, between 1 and 10000. 0 uses the systemd default",
+		)
+		flSystemdWatchdogSec = flagset.Int(
+			"systemd_watchdog_sec",
+			env.Int("SYSTEMD_WATCHDOG_SEC", 0),
+			"restart launcher if osquery is unhealthy, and it stops pinging the systemd watchdog, for this many seconds",
+		)
+		flSystemdDropInDir = flagset.String(
+			"systemd_drop_in_dir",
+			env.String("SYSTEMD_DROP_IN_DIR", ""),
+			"write the systemd tuning options to a drop-in in this directory, instead of the unit",
+		)
+		flProvenanceSigningKey = flagset.String(
+			"provenance_signing_key",
+			env.String("PROVENANCE_SIGNING_KEY", ""),
+			"PEM private key to sign build provenance with. If unset, only an SBOM is written",
+		)
 		flOsqueryFlags arrayFlags // set below with flagset.Var
 	)
 	flagset.Var(&flOsqueryFlags, "osquery_flag", "Flags to pass to osquery (possibly overriding Launcher defaults)")
@@ -219,30 +259,40 @@ func runMake(args []string) error {
 	}
 
 	packageOptions := packaging.PackageOptions{
-		PackageVersion:    *flPackageVersion,
-		OsqueryVersion:    *flOsqueryVersion,
-		OsqueryFlags:      flOsqueryFlags,
-		LauncherVersion:   *flLauncherVersion,
-		ExtensionVersion:  *flExtensionVersion,
-		Hostname:          *flHostname,
-		Secret:            *flEnrollSecret,
-		AppleSigningKey:   *flSigningKey,
-		Transport:         *flTransport,
-		Insecure:          *flInsecure,
-		InsecureTransport: *flInsecureTransport,
-		UpdateChannel:     *flUpdateChannel,
-		InitialRunner:     *flInitialRunner,
-		Identifier:        *flIdentifier,
-		OmitSecret:        *flOmitSecret,
-		CertPins:          *flCertPins,
-		RootPEM:           *flRootPEM,
-		CacheDir:          cacheDir,
-		NotaryURL:         *flNotaryURL,
-		MirrorURL:         *flMirrorURL,
-		NotaryPrefix:      *flNotaryPrefix,
-		WixPath:           *flWixPath,
-		WixSkipCleanup:    *flWixSkipCleanup,
-		DisableService:    *flDisableService,
+		PackageVersion:       *flPackageVersion,
+		OsqueryVersion:       *flOsqueryVersion,
+		OsqueryFlags:         flOsqueryFlags,
+		LauncherVersion:      *flLauncherVersion,
+		ExtensionVersion:     *flExtensionVersion,
+		Hostname:             *flHostname,
+		Secret:               *flEnrollSecret,
+		AppleSigningKey:      *flSigningKey,
+		Transport:            *flTransport,
+		Insecure:             *flInsecure,
+		InsecureTransport:    *flInsecureTransport,
+		UpdateChannel:        *flUpdateChannel,
+		InitialRunner:        *flInitialRunner,
+		Identifier:           *flIdentifier,
+		OmitSecret:           *flOmitSecret,
+		CertPins:             *flCertPins,
+		RootPEM:              *flRootPEM,
+		CacheDir:             cacheDir,
+		NotaryURL:            *flNotaryURL,
+		MirrorURL:            *flMirrorURL,
+		NotaryPrefix:         *flNotaryPrefix,
+		WixPath:              *flWixPath,
+		WixSkipCleanup:       *flWixSkipCleanup,
+		DisableService:       *flDisableService,
+		NativePackaging:      *flNativePackaging,
+		ProvenanceSigningKey: *flProvenanceSigningKey,
+		Systemd: packaging.SystemdOptions{
+			Hardening:   *flSystemdHardening,
+			MemoryMax:   *flSystemdMemoryMax,
+			CPUQuota:    *flSystemdCPUQuota,
+			IOWeight:    *flSystemdIOWeight,
+			WatchdogSec: *flSystemdWatchdogSec,
+			DropInDir:   *flSystemdDropInDir,
+		},
 	}
 
 	outputDir := *flOutputDir
@@ -266,14 +316,8 @@ func runMake(args []string) error {
 
 	for _, target := range targets {
 		outputFileName := fmt.Sprintf("launcher.%s.%s", target.String(), target.PkgExtension())
-		outputFile, err := os.Create(filepath.Join(outputDir, outputFileName))
-		if err != nil {
-			return fmt.Errorf("Failed to make package output file: %w", err)
-		}
-		defer outputFile.Close()
-
-		if err := packageOptions.Build(ctx, outputFile, target); err != nil {
-			return fmt.Errorf("could not generate packages: %w", err)
+		if err := buildToFile(ctx, &packageOptions, target, filepath.Join(outputDir, outputFileName)); err != nil {
+			return err
 		}
 	}
 
@@ -302,7 +346,10 @@ func usage() {
 	fmt.Fprintf(os.Stderr, "\n")
 	fmt.Fprintf(os.Stderr, "MODES\n")
 	fmt.Fprintf(os.Stderr, "  make          Generate a single launcher package for each platform\n")
+	fmt.Fprintf(os.Stderr, "  build         Generate the launcher packages described by a manifest\n")
+	fmt.Fprintf(os.Stderr, "  daemonset     Generate a Kubernetes DaemonSet for a launcher image\n")
 	fmt.Fprintf(os.Stderr, "  list-targets  List all known build targets\n")
+	fmt.Fprintf(os.Stderr, "  repo          Generate a signed apt or yum repository from built packages\n")
 	fmt.Fprintf(os.Stderr, "  version       Print full version information\n")
 	fmt.Fprintf(os.Stderr, "\n")
 	fmt.Fprintf(os.Stderr, "VERSION\n")
@@ -322,8 +369,14 @@ func main() {
 		run = runVersion
 	case "make":
 		run = runMake
+	case "build":
+		run = runBuild
+	case "daemonset":
+		run = runDaemonSet
 	case "list-targets":
 		run = runListTargets
+	case "repo":
+		run = runRepo
 	default:
 		usage()
 		os.Exit(1)
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+	"github.com/kolide/kit/env"
+	"github.com/kolide/launcher/pkg/contexts/ctxlog"
+	"github.com/kolide/launcher/pkg/packagekit/linuxrepo"
+)
+
+func runRepo(args []string) error {
+	flagset := flag.NewFlagSet("repo", flag.ExitOnError)
+	var (
+		flDebug = flagset.Bool(
+			"debug",
+			false,
+			"enable debug logging",
+		)
+		flType = flagset.String(
+			"type",
+			env.String("REPO_TYPE", ""),
+			"the type of repository to build: apt or yum",
+		)
+		flOutputDir = flagset.String(
+			"output_dir",
+			env.String("OUTPUT_DIR", ""),
+			"the directory to write the repository to. Packages already in the repository are kept and indexed along with the new ones",
+		)
+		flSigningKey = flagset.String(
+			"signing_key",
+			env.String("REPO_SIGNING_KEY", ""),
+			"path to the GPG private key used to sign the repository metadata",
+		)
+		flSigningKeyPassphrase = flagset.String(
+			"signing_key_passphrase",
+			env.String("REPO_SIGNING_KEY_PASSPHRASE", ""),
+			"passphrase for the GPG private key, if it is encrypted. Prefer setting this via the environment",
+		)
+		flSuite = flagset.String(
+			"suite",
+			env.String("REPO_SUITE", "stable"),
+			"apt only: the suite (distribution) to publish the packages to",
+		)
+		flComponent = flagset.String(
+			"component",
+			env.String("REPO_COMPONENT", "main"),
+			"apt only: the component within the suite",
+		)
+		flOrigin = flagset.String(
+			"origin",
+			env.String("REPO_ORIGIN", "Kolide"),
+			"apt only: the Origin field of the Release file",
+		)
+		flLabel = flagset.String(
+			"label",
+			env.String("REPO_LABEL", ""),
+			"apt only: the Label field of the Release file",
+		)
+	)
+
+	flagset.Usage = usageFor(flagset, "package-builder repo [flags] <package>...")
+	if err := flagset.Parse(args); err != nil {
+		return err
+	}
+
+	logger := log.NewJSONLogger(os.Stderr)
+	logger = log.With(logger, "ts", log.DefaultTimestampUTC)
+	logger = log.With(logger, "caller", log.DefaultCaller)
+
+	if *flDebug {
+		logger = level.NewFilter(logger, level.AllowDebug())
+	} else {
+		logger = level.NewFilter(logger, level.AllowInfo())
+	}
+
+	ctx := context.Background()
+	ctx = ctxlog.NewContext(ctx, logger)
+
+	packages := flagset.Args()
+	if len(packages) == 0 {
+		return errors.New("no packages specified")
+	}
+	if *flOutputDir == "" {
+		return errors.New("output_dir undefined")
+	}
+	if *flSigningKey == "" {
+		return errors.New("signing_key undefined")
+	}
+
+	signer, err := linuxrepo.LoadSigningKey(*flSigningKey, *flSigningKeyPassphrase)
+	if err != nil {
+		return fmt.Errorf("loading signing key: %w", err)
+	}
+
+	if err := os.MkdirAll(*flOutputDir, 0755); err != nil {
+		return fmt.Errorf("mkdir: %w", err)
+	}
+
+	switch *flType {
+	case "apt":
+		err = linuxrepo.BuildAptRepo(ctx, *flOutputDir, packages, signer, linuxrepo.AptOptions{
+			Suite:     *flSuite,
+			Component: *flComponent,
+			Origin:    *flOrigin,
+			Label:     *flLabel,
+		})
+	case "yum":
+		err = linuxrepo.BuildYumRepo(ctx, *flOutputDir, packages, signer)
+	default:
+		return fmt.Errorf("unknown repository type %q, expected apt or yum", *flType)
+	}
+	if err != nil {
+		return fmt.Errorf("building %s repository: %w", *flType, err)
+	}
+
+	fmt.Printf("Built %s repository in %s\n", *flType, *flOutputDir)
+	return nil
+}
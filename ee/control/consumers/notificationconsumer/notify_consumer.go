@@ -7,6 +7,9 @@ import (
 	"fmt"
 	"io"
 	"net/url"
+	"os/user"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/go-kit/kit/log"
@@ -16,20 +19,38 @@ import (
 	"github.com/kolide/launcher/pkg/agent/types"
 )
 
-// Consumes notifications from control server, tracks when notifications are sent to end user
+// Consumes notifications from control server, and sends them to console users when they're due.
+// Delivery to each user is tracked separately, so that notifications can be targeted at, and
+// acknowledged by, individual users.
 type NotificationConsumer struct {
 	store                       types.KVStore
 	runner                      userProcessesRunner
 	logger                      log.Logger
 	notificationRetentionPeriod time.Duration
 	cleanupInterval             time.Duration
+	scheduleInterval            time.Duration
+	lookupUid                   func(username string) (string, error)
 	ctx                         context.Context
 	cancel                      context.CancelFunc
+	// sendLock ensures that a notification isn't sent to the same user twice, when it's due both
+	// on a control server update and on the schedule ticker
+	sendLock sync.Mutex
 }
 
 // The desktop runner fullfils this interface -- it exists for testing purposes.
 type userProcessesRunner interface {
-	SendNotification(n notify.Notification) error
+	SendNotificationToUser(uid string, n notify.Notification) error
+	DesktopProcessUids() []string
+}
+
+// userDelivery records the delivery of a notification to a single console user
+type userDelivery struct {
+	NotificationID string    `json:"notification_id"`
+	UID            string    `json:"uid"`
+	SentAt         time.Time `json:"sent_at"`
+	LastSentAt     time.Time `json:"last_sent_at"`
+	SendCount      int       `json:"send_count"`
+	AcknowledgedAt time.Time `json:"acknowledged_at,omitempty"`
 }
 
 const (
@@ -41,6 +62,15 @@ const (
 
 	// How frequently to check for old notifications
 	defaultCleanupInterval = time.Hour * 12
+
+	// How frequently to check for notifications that have become due
+	defaultScheduleInterval = time.Minute
+
+	// Repeating notifications can't be sent more often than this
+	minimumSnoozeInterval = 5 * time.Minute
+
+	// Notifications waiting to be sent, or to be repeated, are stored under this prefix
+	scheduledKeyPrefix = "scheduled:"
 )
 
 type notificationConsumerOption func(*NotificationConsumer)
@@ -65,6 +95,12 @@ func WithCleanupInterval(cleanupInterval time.Duration) notificationConsumerOpti
 	}
 }
 
+func WithScheduleInterval(scheduleInterval time.Duration) notificationConsumerOption {
+	return func(nc *NotificationConsumer) {
+		nc.scheduleInterval = scheduleInterval
+	}
+}
+
 func NewNotifyConsumer(store types.KVStore, runner *desktopRunner.DesktopUsersProcessesRunner, ctx context.Context, opts ...notificationConsumerOption) (*NotificationConsumer, error) {
 	nc := &NotificationConsumer{
 		store:                       store,
@@ -72,6 +108,8 @@ func NewNotifyConsumer(store types.KVStore, runner *desktopRunner.DesktopUsersPr
 		logger:                      log.NewNopLogger(),
 		notificationRetentionPeriod: defaultRetentionPeriod,
 		cleanupInterval:             defaultCleanupInterval,
+		scheduleInterval:            defaultScheduleInterval,
+		lookupUid:                   lookupUid,
 		ctx:                         ctx,
 	}
 
@@ -94,34 +132,102 @@ func (nc *NotificationConsumer) Update(data io.Reader) error {
 		return fmt.Errorf("failed to decode notification data: %w", err)
 	}
 
+	scheduledIds := make(map[string]bool)
 	for _, rawNotification := range rawNotificationsToProcess {
 		var notificationToProcess notify.Notification
 		if err := json.Unmarshal(rawNotification, &notificationToProcess); err != nil {
 			level.Debug(nc.logger).Log("msg", "received notification in unexpected format from K2, discarding", "err", err)
 			continue
 		}
+
+		if !nc.notificationIsValid(notificationToProcess) {
+			continue
+		}
+
+		// Remember the notification, so it can be sent later if it isn't due yet, or needs to repeat
+		nc.schedule(notificationToProcess)
+		scheduledIds[notificationToProcess.ID] = true
+
 		nc.notify(notificationToProcess)
 	}
 
+	// Notifications no longer sent by the control server shouldn't be sent to users who haven't seen them yet
+	nc.unscheduleExcept(scheduledIds)
+
 	return nil
 }
 
+// notify sends the notification to each of its target users that it's due for
 func (nc *NotificationConsumer) notify(notificationToSend notify.Notification) {
-	if !nc.notificationIsValid(notificationToSend) {
+	nc.sendLock.Lock()
+	defer nc.sendLock.Unlock()
+
+	now := time.Now()
+
+	// Not due yet
+	if time.Unix(notificationToSend.NotBefore, 0).After(now) {
+		return
+	}
+
+	// Expired since it was scheduled
+	if time.Unix(notificationToSend.ValidUntil, 0).Before(now) {
 		return
 	}
 
-	if nc.notificationAlreadySent(notificationToSend) {
+	if notificationToSend.QuietHours != nil && notificationToSend.QuietHours.Contains(now) {
 		return
 	}
 
-	if err := nc.runner.SendNotification(notificationToSend); err != nil {
-		// Already logged on desktop side, no need to log again
+	if nc.notificationSentToAllUsers(notificationToSend) {
 		return
 	}
 
-	notificationToSend.SentAt = time.Now()
-	nc.markNotificationSent(notificationToSend)
+	for _, uid := range nc.targetUids(notificationToSend) {
+		delivery, err := nc.userDelivery(notificationToSend.ID, uid)
+		if err != nil {
+			level.Error(nc.logger).Log("msg", "could not read notification delivery", "notification_id", notificationToSend.ID, "uid", uid, "err", err)
+			continue
+		}
+
+		if !notificationDue(notificationToSend, delivery, now) {
+			continue
+		}
+
+		if err := nc.runner.SendNotificationToUser(uid, notificationToSend); err != nil {
+			// Already logged on desktop side, no need to log again
+			continue
+		}
+
+		if delivery == nil {
+			delivery = &userDelivery{
+				NotificationID: notificationToSend.ID,
+				UID:            uid,
+				SentAt:         now,
+			}
+		}
+		delivery.LastSentAt = now
+		delivery.SendCount += 1
+		nc.setUserDelivery(*delivery)
+	}
+}
+
+// notificationDue reports whether a notification should be sent to a user, given its previous delivery
+func notificationDue(n notify.Notification, delivery *userDelivery, now time.Time) bool {
+	// Never sent to this user
+	if delivery == nil {
+		return true
+	}
+
+	if !delivery.AcknowledgedAt.IsZero() {
+		return false
+	}
+
+	// Only repeating notifications are sent more than once
+	if n.SnoozeInterval <= 0 {
+		return false
+	}
+
+	return !delivery.LastSentAt.Add(time.Duration(n.SnoozeInterval) * time.Second).After(now)
 }
 
 func (nc *NotificationConsumer) notificationIsValid(notificationToCheck notify.Notification) bool {
@@ -146,37 +252,191 @@ func (nc *NotificationConsumer) notificationIsValid(notificationToCheck notify.N
 		}
 	}
 
+	// Repeating notifications must not nag the user too often
+	if notificationToCheck.SnoozeInterval < 0 ||
+		(notificationToCheck.SnoozeInterval > 0 && time.Duration(notificationToCheck.SnoozeInterval)*time.Second < minimumSnoozeInterval) {
+		level.Debug(nc.logger).Log(
+			"msg", "received invalid snooze_interval from K2",
+			"notification_id", notificationToCheck.ID,
+			"snooze_interval", notificationToCheck.SnoozeInterval,
+		)
+		return false
+	}
+
+	if notificationToCheck.QuietHours != nil {
+		if err := notificationToCheck.QuietHours.Validate(); err != nil {
+			level.Debug(nc.logger).Log(
+				"msg", "received invalid quiet_hours from K2",
+				"notification_id", notificationToCheck.ID,
+				"err", err,
+			)
+			return false
+		}
+	}
+
 	// Notification must not be blank
-	return notificationToCheck.Title != "" && notificationToCheck.Body != ""
+	return notificationToCheck.ID != "" && notificationToCheck.Title != "" && notificationToCheck.Body != ""
+}
+
+// targetUids returns the uids of the console users with desktop processes that the notification
+// should be sent to.
+func (nc *NotificationConsumer) targetUids(n notify.Notification) []string {
+	desktopUids := nc.runner.DesktopProcessUids()
+	if len(n.TargetUids) == 0 && len(n.TargetUsernames) == 0 {
+		return desktopUids
+	}
+
+	targets := make(map[string]bool)
+	for _, uid := range n.TargetUids {
+		targets[uid] = true
+	}
+	for _, username := range n.TargetUsernames {
+		uid, err := nc.lookupUid(username)
+		if err != nil {
+			// The user may not exist on this device
+			level.Debug(nc.logger).Log("msg", "could not look up notification target", "notification_id", n.ID, "username", username, "err", err)
+			continue
+		}
+		targets[uid] = true
+	}
+
+	uids := make([]string, 0)
+	for _, uid := range desktopUids {
+		if targets[uid] {
+			uids = append(uids, uid)
+		}
+	}
+
+	return uids
 }
 
-func (nc *NotificationConsumer) notificationAlreadySent(notificationToCheck notify.Notification) bool {
+func lookupUid(username string) (string, error) {
+	u, err := user.Lookup(username)
+	if err != nil {
+		return "", err
+	}
+	return u.Uid, nil
+}
+
+// notificationSentToAllUsers checks for a record of the notification keyed only by its ID. These
+// were written before delivery was tracked per-user, and mean the notification was already sent
+// to everyone.
+func (nc *NotificationConsumer) notificationSentToAllUsers(notificationToCheck notify.Notification) bool {
 	sentNotificationRaw, err := nc.store.Get([]byte(notificationToCheck.ID))
 	if err != nil {
 		level.Error(nc.logger).Log("msg", "could not read sent notifications from bucket", "err", err)
 	}
 
-	if sentNotificationRaw == nil {
-		// No previous record -- notification has not been sent before
-		return false
+	return sentNotificationRaw != nil
+}
+
+func userDeliveryKey(notificationID, uid string) []byte {
+	return []byte(fmt.Sprintf("%s:%s", notificationID, uid))
+}
+
+// userDelivery returns the record of the notification's delivery to uid, or nil if it hasn't been sent to them
+func (nc *NotificationConsumer) userDelivery(notificationID, uid string) (*userDelivery, error) {
+	raw, err := nc.store.Get(userDeliveryKey(notificationID, uid))
+	if err != nil {
+		return nil, fmt.Errorf("reading delivery: %w", err)
+	}
+
+	if raw == nil {
+		return nil, nil
+	}
+
+	var delivery userDelivery
+	if err := json.Unmarshal(raw, &delivery); err != nil {
+		return nil, fmt.Errorf("unmarshaling delivery: %w", err)
+	}
+
+	return &delivery, nil
+}
+
+func (nc *NotificationConsumer) setUserDelivery(delivery userDelivery) {
+	raw, err := json.Marshal(delivery)
+	if err != nil {
+		level.Error(nc.logger).Log("msg", "could not marshal notification delivery", "notification_id", delivery.NotificationID, "err", err)
+		return
+	}
+
+	if err := nc.store.Set(userDeliveryKey(delivery.NotificationID, delivery.UID), raw); err != nil {
+		level.Debug(nc.logger).Log("msg", "could not mark notification sent", "notification_id", delivery.NotificationID, "uid", delivery.UID, "err", err)
+	}
+}
+
+// RecordNotificationAcknowledgement records that uid clicked on the notification, so that it
+// won't be repeated. It's called by the desktop runner server.
+func (nc *NotificationConsumer) RecordNotificationAcknowledgement(uid string, notificationID string) error {
+	nc.sendLock.Lock()
+	defer nc.sendLock.Unlock()
+
+	delivery, err := nc.userDelivery(notificationID, uid)
+	if err != nil {
+		return err
+	}
+
+	if delivery == nil {
+		return fmt.Errorf("notification %s was not sent to uid %s", notificationID, uid)
 	}
 
-	return true
+	if delivery.AcknowledgedAt.IsZero() {
+		delivery.AcknowledgedAt = time.Now()
+		nc.setUserDelivery(*delivery)
+	}
+
+	return nil
 }
 
-func (nc *NotificationConsumer) markNotificationSent(sentNotification notify.Notification) {
-	rawNotification, err := json.Marshal(sentNotification)
+func (nc *NotificationConsumer) schedule(n notify.Notification) {
+	rawNotification, err := json.Marshal(n)
 	if err != nil {
-		level.Error(nc.logger).Log("msg", "could not marshal sent notification", "title", sentNotification.Title, "err", err)
+		level.Error(nc.logger).Log("msg", "could not marshal notification", "notification_id", n.ID, "err", err)
 		return
 	}
 
-	if err := nc.store.Set([]byte(sentNotification.ID), rawNotification); err != nil {
-		level.Debug(nc.logger).Log("msg", "could not mark notification sent", "title", sentNotification.Title, "err", err)
+	if err := nc.store.Set([]byte(scheduledKeyPrefix+n.ID), rawNotification); err != nil {
+		level.Debug(nc.logger).Log("msg", "could not schedule notification", "notification_id", n.ID, "err", err)
+	}
+}
+
+// scheduledNotifications returns the notifications that may still be sent
+func (nc *NotificationConsumer) scheduledNotifications() []notify.Notification {
+	scheduled := make([]notify.Notification, 0)
+	if err := nc.store.ForEach(func(k, v []byte) error {
+		if !strings.HasPrefix(string(k), scheduledKeyPrefix) {
+			return nil
+		}
+
+		var n notify.Notification
+		if err := json.Unmarshal(v, &n); err != nil {
+			return fmt.Errorf("error processing %s: %w", string(k), err)
+		}
+		scheduled = append(scheduled, n)
+
+		return nil
+	}); err != nil {
+		level.Debug(nc.logger).Log("msg", "could not iterate over scheduled notifications", "err", err)
+	}
+
+	return scheduled
+}
+
+func (nc *NotificationConsumer) unscheduleExcept(notificationIds map[string]bool) {
+	keysToDelete := make([][]byte, 0)
+	for _, n := range nc.scheduledNotifications() {
+		if !notificationIds[n.ID] {
+			keysToDelete = append(keysToDelete, []byte(scheduledKeyPrefix+n.ID))
+		}
+	}
+
+	if err := nc.store.Delete(keysToDelete...); err != nil {
+		level.Debug(nc.logger).Log("msg", "could not unschedule notifications", "err", err)
 	}
 }
 
-// Runs cleanup job to periodically check for notifications we no longer need to retain and delete them
+// Runs cleanup job to periodically check for notifications we no longer need to retain and delete them,
+// and sends scheduled notifications as they become due
 func (nc *NotificationConsumer) Execute() error {
 	nc.runCleanup(nc.ctx)
 	return nil
@@ -191,6 +451,8 @@ func (nc *NotificationConsumer) runCleanup(ctx context.Context) {
 	ctx, nc.cancel = context.WithCancel(ctx)
 	t := time.NewTicker(nc.cleanupInterval)
 	defer t.Stop()
+	scheduleTicker := time.NewTicker(nc.scheduleInterval)
+	defer scheduleTicker.Stop()
 
 	for {
 		select {
@@ -198,20 +460,36 @@ func (nc *NotificationConsumer) runCleanup(ctx context.Context) {
 			return
 		case <-t.C:
 			nc.cleanup()
+		case <-scheduleTicker.C:
+			for _, n := range nc.scheduledNotifications() {
+				nc.notify(n)
+			}
 		}
 	}
 }
 
 func (nc *NotificationConsumer) cleanup() {
-	// Read through all keys in bucket to determine which ones are old enough to be deleted
+	// Read through all keys in bucket to determine which ones are old enough to be deleted.
+	// Scheduled notifications are deleted once they expire; deliveries are retained for the
+	// retention period.
 	keysToDelete := make([][]byte, 0)
 	if err := nc.store.ForEach(func(k, v []byte) error {
-		var sentNotification notify.Notification
-		if err := json.Unmarshal(v, &sentNotification); err != nil {
+		var record struct {
+			SentAt     time.Time `json:"sent_at"`
+			ValidUntil int64     `json:"valid_until"`
+		}
+		if err := json.Unmarshal(v, &record); err != nil {
 			return fmt.Errorf("error processing %s: %w", string(k), err)
 		}
 
-		if sentNotification.SentAt.Add(nc.notificationRetentionPeriod).Before(time.Now()) {
+		if strings.HasPrefix(string(k), scheduledKeyPrefix) {
+			if time.Unix(record.ValidUntil, 0).Before(time.Now()) {
+				keysToDelete = append(keysToDelete, k)
+			}
+			return nil
+		}
+
+		if record.SentAt.Add(nc.notificationRetentionPeriod).Before(time.Now()) {
 			keysToDelete = append(keysToDelete, k)
 		}
 
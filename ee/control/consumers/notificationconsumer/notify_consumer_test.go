@@ -18,15 +18,24 @@ import (
 	"github.com/stretchr/testify/require"
 )
 
-type notifierMock struct{ mock.Mock }
+const testUid = "501"
 
-func newNotifierMock() *notifierMock { return &notifierMock{} }
+type notifierMock struct {
+	mock.Mock
+	uids []string
+}
+
+func newNotifierMock() *notifierMock { return &notifierMock{uids: []string{testUid}} }
 
-func (nm *notifierMock) SendNotification(n notify.Notification) error {
-	args := nm.Called(n)
+func (nm *notifierMock) SendNotificationToUser(uid string, n notify.Notification) error {
+	args := nm.Called(uid, n)
 	return args.Error(0)
 }
 
+func (nm *notifierMock) DesktopProcessUids() []string {
+	return nm.uids
+}
+
 func TestUpdate_HappyPath(t *testing.T) {
 	t.Parallel()
 
@@ -58,12 +67,12 @@ func TestUpdate_HappyPath(t *testing.T) {
 	testNotificationsData := bytes.NewReader(testNotificationsRaw)
 
 	// Expect that the notifier is called once to send the one notification successfully
-	mockNotifier.On("SendNotification", mock.Anything).Return(nil)
+	mockNotifier.On("SendNotificationToUser", mock.Anything, mock.Anything).Return(nil)
 
 	// Call update and assert our expectations about sent notifications
 	err = testNc.Update(testNotificationsData)
 	require.NoError(t, err)
-	mockNotifier.AssertNumberOfCalls(t, "SendNotification", 1)
+	mockNotifier.AssertNumberOfCalls(t, "SendNotificationToUser", 1)
 }
 
 func TestUpdate_HappyPath_NoAction(t *testing.T) {
@@ -95,12 +104,12 @@ func TestUpdate_HappyPath_NoAction(t *testing.T) {
 	testNotificationsData := bytes.NewReader(testNotificationsRaw)
 
 	// Expect that the notifier is called once to send the one notification successfully
-	mockNotifier.On("SendNotification", mock.Anything).Return(nil)
+	mockNotifier.On("SendNotificationToUser", mock.Anything, mock.Anything).Return(nil)
 
 	// Call update and assert our expectations about sent notifications
 	err = testNc.Update(testNotificationsData)
 	require.NoError(t, err)
-	mockNotifier.AssertNumberOfCalls(t, "SendNotification", 1)
+	mockNotifier.AssertNumberOfCalls(t, "SendNotificationToUser", 1)
 }
 
 func TestUpdate_ValidatesNotifications(t *testing.T) {
@@ -147,6 +156,26 @@ func TestUpdate_ValidatesNotifications(t *testing.T) {
 				ActionUri:  "some_thing:foo/bar",
 			},
 		},
+		{
+			name: "Invalid because the snooze interval is too short",
+			testNotification: notify.Notification{
+				Title:          "Test notification",
+				Body:           "This notification would repeat every minute",
+				ID:             ulid.New(),
+				ValidUntil:     getValidUntil(),
+				SnoozeInterval: 60,
+			},
+		},
+		{
+			name: "Invalid because the quiet hours are malformed",
+			testNotification: notify.Notification{
+				Title:      "Test notification",
+				Body:       "This notification has quiet hours that can't be parsed",
+				ID:         ulid.New(),
+				ValidUntil: getValidUntil(),
+				QuietHours: &notify.QuietHours{Start: "10pm", End: "7am"},
+			},
+		},
 	}
 
 	for _, tt := range tests {
@@ -162,7 +191,7 @@ func TestUpdate_ValidatesNotifications(t *testing.T) {
 			// Call update and assert our expectations about sent notifications
 			err = testNc.Update(testNotificationsData)
 			require.NoError(t, err)
-			mockNotifier.AssertNumberOfCalls(t, "SendNotification", 0)
+			mockNotifier.AssertNumberOfCalls(t, "SendNotificationToUser", 0)
 		})
 	}
 }
@@ -202,12 +231,12 @@ func TestUpdate_HandlesDuplicates(t *testing.T) {
 	testNotificationsData := bytes.NewReader(testNotificationsRaw)
 
 	// Expect that the notifier is called only once, to send the first notification
-	mockNotifier.On("SendNotification", mock.Anything).Return(nil)
+	mockNotifier.On("SendNotificationToUser", mock.Anything, mock.Anything).Return(nil)
 
 	// Call update and assert our expectations about sent notifications
 	err = testNc.Update(testNotificationsData)
 	require.NoError(t, err)
-	mockNotifier.AssertNumberOfCalls(t, "SendNotification", 1)
+	mockNotifier.AssertNumberOfCalls(t, "SendNotificationToUser", 1)
 }
 
 func TestUpdate_HandlesDuplicatesWhenFirstNotificationCouldNotBeSent(t *testing.T) {
@@ -245,13 +274,13 @@ func TestUpdate_HandlesDuplicatesWhenFirstNotificationCouldNotBeSent(t *testing.
 	testNotificationsData := bytes.NewReader(testNotificationsRaw)
 
 	// Expect that the notifier is called twice: once to unsuccessfully send the first notification, and again to send the duplicate successfully
-	errorCall := mockNotifier.On("SendNotification", mock.Anything).Return(errors.New("test error"))
-	mockNotifier.On("SendNotification", mock.Anything).Return(nil).NotBefore(errorCall)
+	errorCall := mockNotifier.On("SendNotificationToUser", mock.Anything, mock.Anything).Return(errors.New("test error"))
+	mockNotifier.On("SendNotificationToUser", mock.Anything, mock.Anything).Return(nil).NotBefore(errorCall)
 
 	// Call update and assert our expectations about sent notifications
 	err = testNc.Update(testNotificationsData)
 	require.NoError(t, err)
-	mockNotifier.AssertNumberOfCalls(t, "SendNotification", 2)
+	mockNotifier.AssertNumberOfCalls(t, "SendNotificationToUser", 2)
 }
 
 func TestCleanup(t *testing.T) {
@@ -265,42 +294,58 @@ func TestCleanup(t *testing.T) {
 		notificationRetentionPeriod: defaultRetentionPeriod,
 	}
 
-	// Save two entries in the db -- one sent a year ago, and one sent now.
-	notificationIdToDelete := ulid.New()
-	testNc.markNotificationSent(notify.Notification{
+	// Save entries in the db -- deliveries sent a year ago and now, a notification sent before
+	// deliveries were tracked per-user, and scheduled notifications that have and haven't expired.
+	oldDeliveryId := ulid.New()
+	testNc.setUserDelivery(userDelivery{
+		NotificationID: oldDeliveryId,
+		UID:            testUid,
+		SentAt:         time.Now().Add(-365 * 24 * time.Hour),
+	})
+	newDeliveryId := ulid.New()
+	testNc.setUserDelivery(userDelivery{
+		NotificationID: newDeliveryId,
+		UID:            testUid,
+		SentAt:         time.Now(),
+	})
+	oldLegacyKey := []byte(ulid.New())
+	oldLegacyRaw, err := json.Marshal(notify.Notification{
 		Title:  "Some old test title",
 		Body:   "Some old test body",
-		ID:     notificationIdToDelete,
+		ID:     string(oldLegacyKey),
 		SentAt: time.Now().Add(-365 * 24 * time.Hour),
 	})
-	notificationIdToRetain := ulid.New()
-	testNc.markNotificationSent(notify.Notification{
-		Title:  "Some new test title",
-		Body:   "Some new test body",
-		ID:     notificationIdToRetain,
-		SentAt: time.Now(),
-	})
-
-	// Confirm we have both entries in the db.
-	oldNotificationRecord, err := store.Get([]byte(notificationIdToDelete))
-	require.NotNil(t, oldNotificationRecord, "old notification was not seeded in db")
-	require.NoError(t, err)
-
-	newNotificationRecord, err := store.Get([]byte(notificationIdToRetain))
-	require.NotNil(t, newNotificationRecord, "new notification was not seeded in db")
 	require.NoError(t, err)
+	require.NoError(t, store.Set(oldLegacyKey, oldLegacyRaw))
+	expiredScheduled := notify.Notification{Title: "Expired", Body: "Expired", ID: ulid.New(), ValidUntil: time.Now().Add(-1 * time.Hour).Unix()}
+	testNc.schedule(expiredScheduled)
+	validScheduled := notify.Notification{Title: "Valid", Body: "Valid", ID: ulid.New(), ValidUntil: getValidUntil()}
+	testNc.schedule(validScheduled)
+
+	keysToDelete := [][]byte{userDeliveryKey(oldDeliveryId, testUid), oldLegacyKey, []byte(scheduledKeyPrefix + expiredScheduled.ID)}
+	keysToRetain := [][]byte{userDeliveryKey(newDeliveryId, testUid), []byte(scheduledKeyPrefix + validScheduled.ID)}
+
+	// Confirm we have all entries in the db.
+	for _, k := range append(keysToDelete, keysToRetain...) {
+		record, err := store.Get(k)
+		require.NoError(t, err)
+		require.NotNil(t, record, "%s was not seeded in db", string(k))
+	}
 
 	// Now, run cleanup.
 	testNc.cleanup()
 
-	// Confirm that the old notification record was deleted, and the new one was not.
-	oldNotificationRecord, err = store.Get([]byte(notificationIdToDelete))
-	require.Nil(t, oldNotificationRecord, "old notification was not cleaned up but should have been")
-	require.NoError(t, err)
-
-	newNotificationRecord, err = store.Get([]byte(notificationIdToRetain))
-	require.NotNil(t, newNotificationRecord, "new notification was cleaned up but should not have been")
-	require.NoError(t, err)
+	// Confirm that the old records were deleted, and the new ones were not.
+	for _, k := range keysToDelete {
+		record, err := store.Get(k)
+		require.NoError(t, err)
+		require.Nil(t, record, "%s was not cleaned up but should have been", string(k))
+	}
+	for _, k := range keysToRetain {
+		record, err := store.Get(k)
+		require.NoError(t, err)
+		require.NotNil(t, record, "%s was cleaned up but should not have been", string(k))
+	}
 }
 
 func TestUpdate_HandlesMalformedNotifications(t *testing.T) {
@@ -340,13 +385,181 @@ func TestUpdate_HandlesMalformedNotifications(t *testing.T) {
 	testNotificationsData := bytes.NewReader(testNotificationsRaw)
 
 	// Expect that the notifier is still called once, to send the good notification
-	mockNotifier.On("SendNotification", goodNotification).Return(nil)
+	mockNotifier.On("SendNotificationToUser", testUid, goodNotification).Return(nil)
 
 	// Call update and assert our expectations about sent notifications
 	err = testNc.Update(testNotificationsData)
 	require.NoError(t, err)
 	mockNotifier.AssertExpectations(t)
-	mockNotifier.AssertNumberOfCalls(t, "SendNotification", 1)
+	mockNotifier.AssertNumberOfCalls(t, "SendNotificationToUser", 1)
+}
+
+func TestUpdate_TargetsUsers(t *testing.T) {
+	t.Parallel()
+
+	store := setupStorage(t)
+	mockNotifier := newNotifierMock()
+	mockNotifier.uids = []string{"501", "502", "503"}
+	testNc := &NotificationConsumer{
+		store:                       store,
+		runner:                      mockNotifier,
+		logger:                      log.NewNopLogger(),
+		notificationRetentionPeriod: defaultRetentionPeriod,
+		lookupUid: func(username string) (string, error) {
+			if username == "jo" {
+				return "503", nil
+			}
+			return "", fmt.Errorf("unknown user %s", username)
+		},
+	}
+
+	testNotification := notify.Notification{
+		Title:           "Targeted title",
+		Body:            "Targeted body",
+		ID:              ulid.New(),
+		ValidUntil:      getValidUntil(),
+		TargetUids:      []string{"501", "600"},
+		TargetUsernames: []string{"jo", "nobody"},
+	}
+	testNotificationsRaw, err := json.Marshal([]notify.Notification{testNotification})
+	require.NoError(t, err)
+
+	// Only the targeted users with desktop processes are sent the notification
+	mockNotifier.On("SendNotificationToUser", "501", testNotification).Return(nil)
+	mockNotifier.On("SendNotificationToUser", "503", testNotification).Return(nil)
+
+	require.NoError(t, testNc.Update(bytes.NewReader(testNotificationsRaw)))
+	mockNotifier.AssertExpectations(t)
+	mockNotifier.AssertNumberOfCalls(t, "SendNotificationToUser", 2)
+
+	// Each user's delivery is tracked separately
+	for _, uid := range []string{"501", "503"} {
+		delivery, err := testNc.userDelivery(testNotification.ID, uid)
+		require.NoError(t, err)
+		require.NotNil(t, delivery)
+		require.Equal(t, 1, delivery.SendCount)
+	}
+	delivery, err := testNc.userDelivery(testNotification.ID, "502")
+	require.NoError(t, err)
+	require.Nil(t, delivery)
+}
+
+func TestUpdate_SchedulesNotifications(t *testing.T) {
+	t.Parallel()
+
+	store := setupStorage(t)
+	mockNotifier := newNotifierMock()
+	testNc := &NotificationConsumer{
+		store:                       store,
+		runner:                      mockNotifier,
+		logger:                      log.NewNopLogger(),
+		notificationRetentionPeriod: defaultRetentionPeriod,
+	}
+
+	// Quiet hours that cover the current time
+	now := time.Now()
+	quietHours := &notify.QuietHours{
+		Start: now.Add(-1 * time.Hour).Format("15:04"),
+		End:   now.Add(1 * time.Hour).Format("15:04"),
+	}
+
+	notBeforeNotification := notify.Notification{Title: "Later", Body: "Later", ID: ulid.New(), ValidUntil: getValidUntil(), NotBefore: now.Add(30 * time.Minute).Unix()}
+	quietNotification := notify.Notification{Title: "Quiet", Body: "Quiet", ID: ulid.New(), ValidUntil: getValidUntil(), QuietHours: quietHours}
+	testNotificationsRaw, err := json.Marshal([]notify.Notification{notBeforeNotification, quietNotification})
+	require.NoError(t, err)
+
+	// Neither notification is due yet, but both are scheduled
+	require.NoError(t, testNc.Update(bytes.NewReader(testNotificationsRaw)))
+	mockNotifier.AssertNumberOfCalls(t, "SendNotificationToUser", 0)
+	require.Len(t, testNc.scheduledNotifications(), 2)
+
+	// Once due, the scheduled notification is sent
+	notBeforeNotification.NotBefore = now.Add(-1 * time.Minute).Unix()
+	mockNotifier.On("SendNotificationToUser", testUid, notBeforeNotification).Return(nil)
+	testNc.notify(notBeforeNotification)
+	mockNotifier.AssertNumberOfCalls(t, "SendNotificationToUser", 1)
+
+	// A later update without the quiet notification unschedules it
+	testNotificationsRaw, err = json.Marshal([]notify.Notification{notBeforeNotification})
+	require.NoError(t, err)
+	require.NoError(t, testNc.Update(bytes.NewReader(testNotificationsRaw)))
+	scheduled := testNc.scheduledNotifications()
+	require.Len(t, scheduled, 1)
+	require.Equal(t, notBeforeNotification.ID, scheduled[0].ID)
+	mockNotifier.AssertNumberOfCalls(t, "SendNotificationToUser", 1)
+}
+
+func TestUpdate_RepeatsUntilAcknowledged(t *testing.T) {
+	t.Parallel()
+
+	store := setupStorage(t)
+	mockNotifier := newNotifierMock()
+	testNc := &NotificationConsumer{
+		store:                       store,
+		runner:                      mockNotifier,
+		logger:                      log.NewNopLogger(),
+		notificationRetentionPeriod: defaultRetentionPeriod,
+	}
+
+	testNotification := notify.Notification{
+		Title:          "Repeating title",
+		Body:           "Repeating body",
+		ID:             ulid.New(),
+		ValidUntil:     getValidUntil(),
+		SnoozeInterval: int64(minimumSnoozeInterval.Seconds()),
+	}
+	mockNotifier.On("SendNotificationToUser", testUid, testNotification).Return(nil)
+
+	// Acknowledging a notification that was never sent is an error
+	require.Error(t, testNc.RecordNotificationAcknowledgement(testUid, testNotification.ID))
+
+	testNc.notify(testNotification)
+	mockNotifier.AssertNumberOfCalls(t, "SendNotificationToUser", 1)
+
+	// Not resent before the snooze interval has passed
+	testNc.notify(testNotification)
+	mockNotifier.AssertNumberOfCalls(t, "SendNotificationToUser", 1)
+
+	// Resent once the snooze interval has passed
+	delivery, err := testNc.userDelivery(testNotification.ID, testUid)
+	require.NoError(t, err)
+	delivery.LastSentAt = time.Now().Add(-1 * minimumSnoozeInterval)
+	testNc.setUserDelivery(*delivery)
+	testNc.notify(testNotification)
+	mockNotifier.AssertNumberOfCalls(t, "SendNotificationToUser", 2)
+
+	// Not resent after being acknowledged
+	require.NoError(t, testNc.RecordNotificationAcknowledgement(testUid, testNotification.ID))
+	delivery, err = testNc.userDelivery(testNotification.ID, testUid)
+	require.NoError(t, err)
+	require.Equal(t, 2, delivery.SendCount)
+	require.False(t, delivery.AcknowledgedAt.IsZero())
+	delivery.LastSentAt = time.Now().Add(-1 * minimumSnoozeInterval)
+	testNc.setUserDelivery(*delivery)
+	testNc.notify(testNotification)
+	mockNotifier.AssertNumberOfCalls(t, "SendNotificationToUser", 2)
+}
+
+func TestUpdate_SkipsNotificationsSentBeforePerUserDelivery(t *testing.T) {
+	t.Parallel()
+
+	store := setupStorage(t)
+	mockNotifier := newNotifierMock()
+	testNc := &NotificationConsumer{
+		store:                       store,
+		runner:                      mockNotifier,
+		logger:                      log.NewNopLogger(),
+		notificationRetentionPeriod: defaultRetentionPeriod,
+	}
+
+	// A record keyed only by ID means the notification was already sent to everyone
+	testNotification := notify.Notification{Title: "Old title", Body: "Old body", ID: ulid.New(), ValidUntil: getValidUntil()}
+	require.NoError(t, store.Set([]byte(testNotification.ID), []byte(`{"sent_at":"2023-01-01T00:00:00Z"}`)))
+
+	testNotificationsRaw, err := json.Marshal([]notify.Notification{testNotification})
+	require.NoError(t, err)
+	require.NoError(t, testNc.Update(bytes.NewReader(testNotificationsRaw)))
+	mockNotifier.AssertNumberOfCalls(t, "SendNotificationToUser", 0)
 }
 
 func setupStorage(t *testing.T) types.KVStore {
@@ -0,0 +1,165 @@
+package promptconsumer
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+	desktopRunner "github.com/kolide/launcher/ee/desktop/runner"
+	"github.com/kolide/launcher/ee/desktop/user/prompt"
+	"github.com/kolide/launcher/pkg/agent/types"
+)
+
+// Consumes prompts from control server, and forwards them to the console users. Users' answers are
+// stored in the prompt response store, by the desktop runner.
+type PromptConsumer struct {
+	store                   types.KVStore
+	runner                  userProcessesRunner
+	logger                  log.Logger
+	responseRetentionPeriod time.Duration
+	cleanupInterval         time.Duration
+	ctx                     context.Context
+	cancel                  context.CancelFunc
+}
+
+// The desktop runner fullfils this interface -- it exists for testing purposes.
+type userProcessesRunner interface {
+	SendPrompt(p prompt.Prompt) error
+}
+
+const (
+	// Identifier for this consumer.
+	PromptSubsystem = "desktop_prompts"
+
+	// Approximately 6 months, the same as notifications
+	defaultRetentionPeriod = time.Hour * 24 * 30 * 6
+
+	// How frequently to check for old prompt responses
+	defaultCleanupInterval = time.Hour * 12
+)
+
+type promptConsumerOption func(*PromptConsumer)
+
+func WithLogger(logger log.Logger) promptConsumerOption {
+	return func(pc *PromptConsumer) {
+		pc.logger = log.With(logger,
+			"component", PromptSubsystem,
+		)
+	}
+}
+
+func WithResponseRetentionPeriod(ttl time.Duration) promptConsumerOption {
+	return func(pc *PromptConsumer) {
+		pc.responseRetentionPeriod = ttl
+	}
+}
+
+func WithCleanupInterval(cleanupInterval time.Duration) promptConsumerOption {
+	return func(pc *PromptConsumer) {
+		pc.cleanupInterval = cleanupInterval
+	}
+}
+
+func NewPromptConsumer(store types.KVStore, runner *desktopRunner.DesktopUsersProcessesRunner, ctx context.Context, opts ...promptConsumerOption) (*PromptConsumer, error) {
+	pc := &PromptConsumer{
+		store:                   store,
+		runner:                  runner,
+		logger:                  log.NewNopLogger(),
+		responseRetentionPeriod: defaultRetentionPeriod,
+		cleanupInterval:         defaultCleanupInterval,
+		ctx:                     ctx,
+	}
+
+	for _, opt := range opts {
+		opt(pc)
+	}
+
+	return pc, nil
+}
+
+func (pc *PromptConsumer) Update(data io.Reader) error {
+	if pc == nil {
+		return errors.New("PromptConsumer is nil")
+	}
+
+	// We want to unmarshal each prompt separately, so that we don't fail to send all prompts
+	// if only some are malformed.
+	var rawPromptsToProcess []json.RawMessage
+	if err := json.NewDecoder(data).Decode(&rawPromptsToProcess); err != nil {
+		return fmt.Errorf("failed to decode prompt data: %w", err)
+	}
+
+	for _, rawPrompt := range rawPromptsToProcess {
+		var promptToProcess prompt.Prompt
+		if err := json.Unmarshal(rawPrompt, &promptToProcess); err != nil {
+			level.Debug(pc.logger).Log("msg", "received prompt in unexpected format from K2, discarding", "err", err)
+			continue
+		}
+
+		if err := promptToProcess.Validate(); err != nil {
+			level.Debug(pc.logger).Log("msg", "received invalid prompt from K2, discarding", "prompt_id", promptToProcess.ID, "err", err)
+			continue
+		}
+
+		if err := pc.runner.SendPrompt(promptToProcess); err != nil {
+			level.Debug(pc.logger).Log("msg", "could not send prompt", "prompt_id", promptToProcess.ID, "err", err)
+		}
+	}
+
+	return nil
+}
+
+// Runs cleanup job to periodically check for prompt responses we no longer need to retain and delete them
+func (pc *PromptConsumer) Execute() error {
+	pc.runCleanup(pc.ctx)
+	return nil
+}
+
+// Stops cleanup job
+func (pc *PromptConsumer) Interrupt(_ error) {
+	pc.cancel()
+}
+
+func (pc *PromptConsumer) runCleanup(ctx context.Context) {
+	ctx, pc.cancel = context.WithCancel(ctx)
+	t := time.NewTicker(pc.cleanupInterval)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+			pc.cleanup()
+		}
+	}
+}
+
+func (pc *PromptConsumer) cleanup() {
+	// Read through all keys in bucket to determine which ones are old enough to be deleted
+	keysToDelete := make([][]byte, 0)
+	if err := pc.store.ForEach(func(k, v []byte) error {
+		var response prompt.Response
+		if err := json.Unmarshal(v, &response); err != nil {
+			return fmt.Errorf("error processing %s: %w", string(k), err)
+		}
+
+		if response.SentAt.Add(pc.responseRetentionPeriod).Before(time.Now()) {
+			keysToDelete = append(keysToDelete, k)
+		}
+
+		return nil
+	}); err != nil {
+		level.Debug(pc.logger).Log("msg", "could not iterate over bucket items to determine which are expired", "err", err)
+	}
+
+	// Delete all old keys
+	if err := pc.store.Delete(keysToDelete...); err != nil {
+		level.Debug(pc.logger).Log("msg", "could not delete old prompt responses from bucket", "err", err)
+	}
+}
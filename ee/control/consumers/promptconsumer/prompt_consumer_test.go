@@ -0,0 +1,111 @@
+package promptconsumer
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/kolide/kit/ulid"
+	"github.com/kolide/launcher/ee/desktop/user/prompt"
+	"github.com/kolide/launcher/pkg/agent/storage"
+	storageci "github.com/kolide/launcher/pkg/agent/storage/ci"
+	"github.com/kolide/launcher/pkg/agent/types"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+type prompterMock struct{ mock.Mock }
+
+func (pm *prompterMock) SendPrompt(p prompt.Prompt) error {
+	args := pm.Called(p)
+	return args.Error(0)
+}
+
+func TestUpdate(t *testing.T) {
+	t.Parallel()
+
+	mockPrompter := &prompterMock{}
+	testPc := &PromptConsumer{
+		store:                   setupStorage(t),
+		runner:                  mockPrompter,
+		logger:                  log.NewNopLogger(),
+		responseRetentionPeriod: defaultRetentionPeriod,
+	}
+
+	goodPrompt := prompt.Prompt{
+		ID:         ulid.New(),
+		Title:      "Acceptable use policy",
+		Body:       "Do you accept the acceptable use policy?",
+		Choices:    []string{"No", "Yes"},
+		ValidUntil: time.Now().Add(time.Hour).Unix(),
+	}
+	expiredPrompt := prompt.Prompt{
+		ID:         ulid.New(),
+		Title:      "Too late",
+		Body:       "This prompt has expired",
+		ValidUntil: time.Now().Add(-time.Hour).Unix(),
+	}
+	tooManyChoices := prompt.Prompt{
+		ID:         ulid.New(),
+		Title:      "Choose",
+		Body:       "Pick one",
+		Choices:    []string{"1", "2", "3", "4"},
+		ValidUntil: time.Now().Add(time.Hour).Unix(),
+	}
+
+	raw := []json.RawMessage{}
+	for _, p := range []interface{}{goodPrompt, expiredPrompt, tooManyChoices, map[string]bool{"id": true}} {
+		b, err := json.Marshal(p)
+		require.NoError(t, err)
+		raw = append(raw, b)
+	}
+	data, err := json.Marshal(raw)
+	require.NoError(t, err)
+
+	// Only the valid prompt should be sent
+	mockPrompter.On("SendPrompt", goodPrompt).Return(nil)
+
+	require.NoError(t, testPc.Update(bytes.NewReader(data)))
+	mockPrompter.AssertExpectations(t)
+	mockPrompter.AssertNumberOfCalls(t, "SendPrompt", 1)
+}
+
+func TestCleanup(t *testing.T) {
+	t.Parallel()
+
+	store := setupStorage(t)
+	testPc := &PromptConsumer{
+		store:                   store,
+		runner:                  &prompterMock{},
+		logger:                  log.NewNopLogger(),
+		responseRetentionPeriod: defaultRetentionPeriod,
+	}
+
+	// Save two responses in the db -- one sent a year ago, and one sent now.
+	for key, sentAt := range map[string]time.Time{
+		"old:501": time.Now().Add(-365 * 24 * time.Hour),
+		"new:501": time.Now(),
+	} {
+		raw, err := json.Marshal(prompt.Response{Status: prompt.StatusAnswered, SentAt: sentAt})
+		require.NoError(t, err)
+		require.NoError(t, store.Set([]byte(key), raw))
+	}
+
+	testPc.cleanup()
+
+	oldRecord, err := store.Get([]byte("old:501"))
+	require.NoError(t, err)
+	require.Nil(t, oldRecord, "old prompt response was not cleaned up but should have been")
+
+	newRecord, err := store.Get([]byte("new:501"))
+	require.NoError(t, err)
+	require.NotNil(t, newRecord, "new prompt response was cleaned up but should not have been")
+}
+
+func setupStorage(t *testing.T) types.KVStore {
+	s, err := storageci.NewStore(t, log.NewNopLogger(), storage.DesktopPromptResponsesStore.String())
+	require.NoError(t, err)
+	return s
+}
@@ -6,13 +6,16 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"strconv"
 	"sync"
 	"time"
 
 	"github.com/go-kit/kit/log"
 	"github.com/go-kit/kit/log/level"
 	"github.com/kolide/launcher/pkg/agent/flags/keys"
+	"github.com/kolide/launcher/pkg/agent/storage"
 	"github.com/kolide/launcher/pkg/agent/types"
+	"github.com/kolide/launcher/pkg/metrics"
 	"golang.org/x/exp/slices"
 )
 
@@ -159,6 +162,22 @@ func (cs *ControlService) Fetch() error {
 	cs.fetchMutex.Lock()
 	defer cs.fetchMutex.Unlock()
 
+	err := cs.fetch()
+	metrics.AddControlFetch(context.Background(), err)
+
+	if err == nil && cs.store != nil {
+		// Record the check-in, so that it can be shown to the user and included in attestations
+		if err := cs.store.Set(storage.LastControlFetchKey, []byte(strconv.FormatInt(time.Now().Unix(), 10))); err != nil {
+			level.Error(cs.logger).Log("msg", "failed to store last control fetch time", "err", err)
+		}
+	}
+
+	return err
+}
+
+// fetch retrieves the map of subsystems and their hashes, then fetches and updates any
+// subsystems that changed. The caller must hold cs.fetchMutex.
+func (cs *ControlService) fetch() error {
 	// Empty hash means get the map of subsystems & hashes
 	data, err := cs.fetcher.GetConfig()
 	if err != nil {
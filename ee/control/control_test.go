@@ -2,11 +2,13 @@ package control
 
 import (
 	"io"
+	"strconv"
 	"testing"
 	"time"
 
 	"github.com/go-kit/kit/log"
 	"github.com/kolide/launcher/pkg/agent/flags/keys"
+	"github.com/kolide/launcher/pkg/agent/storage"
 	typesMocks "github.com/kolide/launcher/pkg/agent/types/mocks"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
@@ -302,3 +304,26 @@ func TestControlServicePersistLastFetched(t *testing.T) {
 		})
 	}
 }
+
+func TestControlServiceRecordsLastFetch(t *testing.T) {
+	t.Parallel()
+
+	store := &mockStore{keyValues: make(map[string]string)}
+
+	mockKnapsack := typesMocks.NewKnapsack(t)
+	mockKnapsack.On("RegisterChangeObserver", mock.Anything, keys.ControlRequestInterval)
+	mockKnapsack.On("ControlRequestInterval").Return(60 * time.Second)
+
+	// A failed fetch isn't a check-in
+	cs := New(log.NewNopLogger(), mockKnapsack, nopDataProvider{}, WithStore(store))
+	require.Error(t, cs.Fetch())
+	require.NotContains(t, store.keyValues, string(storage.LastControlFetchKey))
+
+	cs.fetcher = &TestClient{map[string]string{}, map[string]any{}}
+	before := time.Now().Unix()
+	require.NoError(t, cs.Fetch())
+
+	lastFetch, err := strconv.ParseInt(store.keyValues[string(storage.LastControlFetchKey)], 10, 64)
+	require.NoError(t, err)
+	require.GreaterOrEqual(t, lastFetch, before)
+}
@@ -0,0 +1,204 @@
+package runner
+
+import (
+	"errors"
+	"fmt"
+	"runtime"
+	"strconv"
+	"time"
+
+	"github.com/go-kit/kit/log/level"
+	"github.com/kolide/launcher/ee/desktop/user/menu"
+	"github.com/kolide/launcher/pkg/agent/storage"
+	"github.com/kolide/launcher/pkg/agent/types"
+)
+
+// deviceStateQueryTimeout bounds each device state query, so that a slow or unavailable osquery
+// instance doesn't hold up refreshing the menu
+const deviceStateQueryTimeout = 10 * time.Second
+
+type querier interface {
+	Query(query string) ([]map[string]string, error)
+}
+
+// deviceStateQuery is an allow-listed osquery query, whose result fills in a menu template key.
+// The query must return a single row, containing column.
+type deviceStateQuery struct {
+	query   string
+	column  string
+	convert func(string) (interface{}, error)
+}
+
+// deviceStateQueries returns the queries used to fill in device state for this platform,
+// keyed by menu template key
+func deviceStateQueries() map[string]deviceStateQuery {
+	queries := map[string]deviceStateQuery{
+		menu.DeviceName: {
+			query:   `SELECT computer_name FROM system_info`,
+			column:  "computer_name",
+			convert: stringValue,
+		},
+	}
+
+	switch runtime.GOOS {
+	case "darwin":
+		queries[menu.DiskEncrypted] = deviceStateQuery{
+			query:   `SELECT de.encrypted FROM mounts m JOIN disk_encryption de ON m.device_alias = de.name WHERE m.path = '/'`,
+			column:  "encrypted",
+			convert: boolValue,
+		}
+		queries[menu.PendingUpdates] = deviceStateQuery{
+			query:   `SELECT COUNT(*) AS count FROM kolide_macos_recommended_updates`,
+			column:  "count",
+			convert: intValue,
+		}
+	case "linux":
+		queries[menu.DiskEncrypted] = deviceStateQuery{
+			query:   `SELECT de.encrypted FROM mounts m JOIN disk_encryption de ON m.device = de.name WHERE m.path = '/'`,
+			column:  "encrypted",
+			convert: boolValue,
+		}
+	case "windows":
+		queries[menu.DiskEncrypted] = deviceStateQuery{
+			query:   `SELECT protection_status FROM bitlocker_info WHERE drive_letter = 'C:'`,
+			column:  "protection_status",
+			convert: boolValue,
+		}
+	}
+
+	return queries
+}
+
+func stringValue(s string) (interface{}, error) {
+	if s == "" {
+		return nil, errors.New("empty value")
+	}
+	return s, nil
+}
+
+func boolValue(s string) (interface{}, error) {
+	return s == "1", nil
+}
+
+func intValue(s string) (interface{}, error) {
+	return strconv.ParseInt(s, 10, 64)
+}
+
+// serverProvidedDataKeys are the server-provided data store keys that may be shown in the menu,
+// keyed by menu template key. Other server-provided data is never exposed to the menu.
+var serverProvidedDataKeys = map[string]string{
+	menu.DeviceId:       "device_id",
+	menu.Munemo:         "munemo",
+	menu.OrganizationId: "organization_id",
+	menu.SerialNumber:   "serial_number",
+}
+
+// WithServerProvidedDataStore sets the store that server-provided device state is read from
+func WithServerProvidedDataStore(store types.Getter) desktopUsersProcessesRunnerOption {
+	return func(r *DesktopUsersProcessesRunner) {
+		r.serverProvidedDataStore = store
+	}
+}
+
+// WithControlStore sets the store that the control service records its last check-in in
+func WithControlStore(store types.Getter) desktopUsersProcessesRunnerOption {
+	return func(r *DesktopUsersProcessesRunner) {
+		r.controlStore = store
+	}
+}
+
+// SetQuerier sets the querier used to fill in device state in the menu template. It is done in a
+// function, so it can happen later in the startup sequencing.
+func (r *DesktopUsersProcessesRunner) SetQuerier(q querier) {
+	r.querierLock.Lock()
+	r.querier = q
+	r.querierLock.Unlock()
+
+	r.refreshMenu()
+}
+
+// addDeviceState fills in whatever device state is available. Data that can't be retrieved is
+// omitted from td, so that it's only ever stale for as long as the menu refresh interval.
+func (r *DesktopUsersProcessesRunner) addDeviceState(td *menu.TemplateData) {
+	r.querierLock.RLock()
+	q := r.querier
+	r.querierLock.RUnlock()
+
+	if q != nil {
+		for templateKey, dsq := range deviceStateQueries() {
+			val, err := queryDeviceState(q, dsq)
+			if err != nil {
+				level.Debug(r.logger).Log(
+					"msg", "could not query device state for menu",
+					"key", templateKey,
+					"err", err,
+				)
+				continue
+			}
+			(*td)[templateKey] = val
+		}
+	}
+
+	if r.serverProvidedDataStore != nil {
+		for templateKey, storeKey := range serverProvidedDataKeys {
+			raw, err := r.serverProvidedDataStore.Get([]byte(storeKey))
+			if err != nil || len(raw) == 0 {
+				continue
+			}
+			(*td)[templateKey] = string(raw)
+		}
+	}
+
+	if r.controlStore != nil {
+		raw, err := r.controlStore.Get(storage.LastControlFetchKey)
+		if err != nil || raw == nil {
+			return
+		}
+
+		lastCheckIn, err := intValue(string(raw))
+		if err != nil {
+			level.Debug(r.logger).Log(
+				"msg", "could not read last check-in for menu",
+				"err", err,
+			)
+			return
+		}
+		(*td)[menu.LastCheckIn] = lastCheckIn
+	}
+}
+
+func queryDeviceState(q querier, dsq deviceStateQuery) (interface{}, error) {
+	type queryResult struct {
+		rows []map[string]string
+		err  error
+	}
+
+	// Buffered, so that the goroutine can exit if we've given up waiting on it
+	resultChan := make(chan queryResult, 1)
+	go func() {
+		rows, err := q.Query(dsq.query)
+		resultChan <- queryResult{rows: rows, err: err}
+	}()
+
+	var result queryResult
+	select {
+	case result = <-resultChan:
+	case <-time.After(deviceStateQueryTimeout):
+		return nil, fmt.Errorf("timed out after %s", deviceStateQueryTimeout)
+	}
+
+	if result.err != nil {
+		return nil, fmt.Errorf("running query: %w", result.err)
+	}
+
+	if len(result.rows) != 1 {
+		return nil, fmt.Errorf("expected 1 row, got %d", len(result.rows))
+	}
+
+	val, ok := result.rows[0][dsq.column]
+	if !ok {
+		return nil, fmt.Errorf("missing column %s", dsq.column)
+	}
+
+	return dsq.convert(val)
+}
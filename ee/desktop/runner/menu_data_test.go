@@ -0,0 +1,125 @@
+package runner
+
+import (
+	"errors"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/go-kit/kit/log"
+	"github.com/kolide/launcher/ee/desktop/user/menu"
+	"github.com/kolide/launcher/pkg/agent/storage"
+	storageci "github.com/kolide/launcher/pkg/agent/storage/ci"
+	"github.com/stretchr/testify/require"
+)
+
+type testQuerier struct{}
+
+func (testQuerier) Query(query string) ([]map[string]string, error) {
+	switch {
+	case strings.Contains(query, "system_info"):
+		return []map[string]string{{"computer_name": "kolide-test-device"}}, nil
+	case strings.Contains(query, "disk_encryption"):
+		return []map[string]string{{"encrypted": "1"}}, nil
+	case strings.Contains(query, "bitlocker_info"):
+		return []map[string]string{{"protection_status": "1"}}, nil
+	case strings.Contains(query, "kolide_macos_recommended_updates"):
+		return []map[string]string{{"count": "3"}}, nil
+	}
+
+	return nil, errors.New("unexpected query")
+}
+
+func TestAddDeviceState(t *testing.T) {
+	t.Parallel()
+
+	store, err := storageci.NewStore(t, log.NewNopLogger(), storage.ControlStore.String())
+	require.NoError(t, err)
+	require.NoError(t, store.Set(storage.LastControlFetchKey, []byte("1700000000")))
+
+	serverProvidedDataStore, err := storageci.NewStore(t, log.NewNopLogger(), storage.ServerProvidedDataStore.String())
+	require.NoError(t, err)
+	require.NoError(t, serverProvidedDataStore.Set([]byte("device_id"), []byte("500")))
+	require.NoError(t, serverProvidedDataStore.Set([]byte("munemo"), []byte("nababe")))
+	require.NoError(t, serverProvidedDataStore.Set([]byte("organization_id"), []byte("101")))
+	require.NoError(t, serverProvidedDataStore.Set([]byte("serial_number"), []byte("C02XL0GYJGH5")))
+	// Not allow-listed, so never shown in the menu
+	require.NoError(t, serverProvidedDataStore.Set([]byte("remote_ip"), []byte("192.0.2.1")))
+
+	r := &DesktopUsersProcessesRunner{
+		logger:                  log.NewNopLogger(),
+		controlStore:            store,
+		serverProvidedDataStore: serverProvidedDataStore,
+	}
+
+	serverProvided := menu.TemplateData{
+		menu.DeviceId:       "500",
+		menu.Munemo:         "nababe",
+		menu.OrganizationId: "101",
+		menu.SerialNumber:   "C02XL0GYJGH5",
+	}
+
+	// Without a querier, only the last check-in and server-provided data are available
+	td := &menu.TemplateData{}
+	r.addDeviceState(td)
+	expectedWithoutQuerier := menu.TemplateData{menu.LastCheckIn: int64(1700000000)}
+	for k, v := range serverProvided {
+		expectedWithoutQuerier[k] = v
+	}
+	require.Equal(t, &expectedWithoutQuerier, td)
+
+	r.querier = testQuerier{}
+	td = &menu.TemplateData{}
+	r.addDeviceState(td)
+
+	expected := &menu.TemplateData{
+		menu.LastCheckIn:   int64(1700000000),
+		menu.DeviceName:    "kolide-test-device",
+		menu.DiskEncrypted: true,
+	}
+	for k, v := range serverProvided {
+		(*expected)[k] = v
+	}
+	if runtime.GOOS == "darwin" {
+		(*expected)[menu.PendingUpdates] = int64(3)
+	}
+	require.Equal(t, expected, td)
+}
+
+func TestAddDeviceState_RendersServerProvidedData(t *testing.T) {
+	t.Parallel()
+
+	serverProvidedDataStore, err := storageci.NewStore(t, log.NewNopLogger(), storage.ServerProvidedDataStore.String())
+	require.NoError(t, err)
+	require.NoError(t, serverProvidedDataStore.Set([]byte("device_id"), []byte("500")))
+	require.NoError(t, serverProvidedDataStore.Set([]byte("munemo"), []byte("nababe")))
+	require.NoError(t, serverProvidedDataStore.Set([]byte("organization_id"), []byte("101")))
+	require.NoError(t, serverProvidedDataStore.Set([]byte("serial_number"), []byte("C02XL0GYJGH5")))
+
+	r := &DesktopUsersProcessesRunner{
+		logger:                  log.NewNopLogger(),
+		serverProvidedDataStore: serverProvidedDataStore,
+	}
+
+	td := &menu.TemplateData{}
+	r.addDeviceState(td)
+
+	tp := menu.NewTemplateParser(td)
+	out, err := tp.Parse("{{.SerialNumber}} ({{.DeviceId}}) in {{.Munemo}}/{{.OrganizationId}}")
+	require.NoError(t, err)
+	require.Equal(t, "C02XL0GYJGH5 (500) in nababe/101", out)
+}
+
+func TestQueryDeviceState(t *testing.T) {
+	t.Parallel()
+
+	_, err := queryDeviceState(testQuerier{}, deviceStateQuery{query: "SELECT * FROM nowhere", column: "a", convert: stringValue})
+	require.Error(t, err, "query errors should be returned")
+
+	_, err = queryDeviceState(testQuerier{}, deviceStateQuery{query: "SELECT * FROM system_info", column: "a", convert: stringValue})
+	require.Error(t, err, "missing columns should be an error")
+
+	val, err := queryDeviceState(testQuerier{}, deviceStateQuery{query: "SELECT * FROM system_info", column: "computer_name", convert: stringValue})
+	require.NoError(t, err)
+	require.Equal(t, "kolide-test-device", val)
+}
@@ -0,0 +1,143 @@
+package runner
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/go-kit/kit/log/level"
+	"github.com/kolide/launcher/ee/desktop/user/client"
+	"github.com/kolide/launcher/ee/desktop/user/prompt"
+	"github.com/kolide/launcher/pkg/agent/types"
+)
+
+// WithPromptResponseStore sets the store that prompt responses are kept in
+func WithPromptResponseStore(store types.KVStore) desktopUsersProcessesRunnerOption {
+	return func(r *DesktopUsersProcessesRunner) {
+		r.promptResponseStore = store
+	}
+}
+
+// promptResponseKey is the key a user's response to a prompt is stored under
+func promptResponseKey(promptID, uid string) []byte {
+	return []byte(fmt.Sprintf("%s:%s", promptID, uid))
+}
+
+// SendPrompt shows p to each console user with a desktop process, who hasn't already been sent it.
+// Their answers arrive later, via the runner server, and are recorded in the prompt response store.
+func (r *DesktopUsersProcessesRunner) SendPrompt(p prompt.Prompt) error {
+	if r.promptResponseStore == nil {
+		return errors.New("cannot send prompt, no prompt response store")
+	}
+
+	procs := r.processes()
+	if len(procs) == 0 {
+		return errors.New("cannot send prompt, no child desktop processes")
+	}
+
+	errs := make([]error, 0)
+	for uid, proc := range procs {
+		existing, err := r.promptResponse(p.ID, uid)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		// Only resend a prompt if the desktop process that was showing it has since been replaced
+		if existing != nil && (existing.Status != prompt.StatusPending || existing.SentAt.After(proc.StartTime)) {
+			continue
+		}
+
+		client := client.New(r.userServerAuthToken, proc.socketPath)
+		if err := client.Prompt(p); err != nil {
+			errs = append(errs, fmt.Errorf("sending prompt to uid %s: %w", uid, err))
+			continue
+		}
+
+		if err := r.setPromptResponse(prompt.Response{
+			PromptID: p.ID,
+			UID:      uid,
+			Title:    p.Title,
+			Status:   prompt.StatusPending,
+			SentAt:   time.Now().UTC(),
+		}); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("errors sending prompts: %+v", errs)
+	}
+
+	return nil
+}
+
+// RecordPromptResponse stores uid's answer to a prompt. Only prompts that were sent to uid, and
+// are still pending, may be answered.
+func (r *DesktopUsersProcessesRunner) RecordPromptResponse(uid string, response prompt.Response) error {
+	if r.promptResponseStore == nil {
+		return errors.New("no prompt response store")
+	}
+
+	existing, err := r.promptResponse(response.PromptID, uid)
+	if err != nil {
+		return err
+	}
+
+	if existing == nil || existing.Status != prompt.StatusPending {
+		return fmt.Errorf("prompt %s is not pending for uid %s", response.PromptID, uid)
+	}
+
+	switch response.Status {
+	case prompt.StatusAnswered, prompt.StatusDismissed, prompt.StatusExpired, prompt.StatusFailed:
+	default:
+		return fmt.Errorf("unknown prompt response status %s", response.Status)
+	}
+
+	existing.Status = response.Status
+	existing.Choice = response.Choice
+	existing.Error = response.Error
+	existing.RespondedAt = time.Now().UTC()
+
+	level.Debug(r.logger).Log(
+		"msg", "received prompt response",
+		"uid", uid,
+		"prompt_id", response.PromptID,
+		"status", response.Status,
+	)
+
+	return r.setPromptResponse(*existing)
+}
+
+// promptResponse returns uid's response to a prompt, or nil if they haven't been sent it
+func (r *DesktopUsersProcessesRunner) promptResponse(promptID, uid string) (*prompt.Response, error) {
+	raw, err := r.promptResponseStore.Get(promptResponseKey(promptID, uid))
+	if err != nil {
+		return nil, fmt.Errorf("reading prompt response: %w", err)
+	}
+
+	if raw == nil {
+		return nil, nil
+	}
+
+	var response prompt.Response
+	if err := json.Unmarshal(raw, &response); err != nil {
+		return nil, fmt.Errorf("unmarshaling prompt response: %w", err)
+	}
+
+	return &response, nil
+}
+
+func (r *DesktopUsersProcessesRunner) setPromptResponse(response prompt.Response) error {
+	raw, err := json.Marshal(response)
+	if err != nil {
+		return fmt.Errorf("marshaling prompt response: %w", err)
+	}
+
+	if err := r.promptResponseStore.Set(promptResponseKey(response.PromptID, response.UID), raw); err != nil {
+		return fmt.Errorf("storing prompt response: %w", err)
+	}
+
+	return nil
+}
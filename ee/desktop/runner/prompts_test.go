@@ -0,0 +1,100 @@
+package runner
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/kolide/kit/ulid"
+	"github.com/kolide/launcher/ee/desktop/user/prompt"
+	userserver "github.com/kolide/launcher/ee/desktop/user/server"
+	"github.com/kolide/launcher/pkg/agent/storage"
+	storageci "github.com/kolide/launcher/pkg/agent/storage/ci"
+	"github.com/stretchr/testify/require"
+)
+
+type testAsker struct {
+	asked []prompt.Prompt
+}
+
+func (a *testAsker) Ask(p prompt.Prompt) error {
+	a.asked = append(a.asked, p)
+	return nil
+}
+
+func TestPrompts(t *testing.T) {
+	t.Parallel()
+
+	store, err := storageci.NewStore(t, log.NewNopLogger(), storage.DesktopPromptResponsesStore.String())
+	require.NoError(t, err)
+
+	r := &DesktopUsersProcessesRunner{
+		logger:              log.NewNopLogger(),
+		uidProcs:            make(map[string]processRecord),
+		userServerAuthToken: "test-auth-token",
+		promptResponseStore: store,
+	}
+
+	p := prompt.Prompt{
+		ID:         "aup",
+		Title:      "Acceptable use policy",
+		Body:       "Do you accept the acceptable use policy?",
+		Choices:    []string{"No", "Yes"},
+		ValidUntil: time.Now().Add(time.Hour).Unix(),
+	}
+
+	require.Error(t, r.SendPrompt(p), "no desktop processes to prompt")
+
+	// Stand in for the user's desktop process
+	socketPath := filepath.Join(os.TempDir(), fmt.Sprintf("prompt_%s", ulid.New()))
+	if runtime.GOOS == "windows" {
+		socketPath = fmt.Sprintf(`\\.\pipe\prompt_%s`, ulid.New())
+	}
+	asker := &testAsker{}
+	server, err := userserver.New(log.NewNopLogger(), r.userServerAuthToken, socketPath, make(chan struct{}), nil, asker)
+	require.NoError(t, err)
+	go server.Serve()
+	t.Cleanup(func() { server.Shutdown(context.Background()) })
+
+	r.uidProcs["501"] = processRecord{
+		Process:    &os.Process{},
+		StartTime:  time.Now().Add(-time.Minute).UTC(),
+		socketPath: socketPath,
+	}
+
+	// Responses are only accepted for prompts that were sent
+	require.Error(t, r.RecordPromptResponse("501", prompt.Response{PromptID: p.ID, Status: prompt.StatusAnswered, Choice: "Yes"}))
+
+	require.NoError(t, r.SendPrompt(p))
+	require.Len(t, asker.asked, 1)
+
+	pending, err := r.promptResponse(p.ID, "501")
+	require.NoError(t, err)
+	require.Equal(t, prompt.StatusPending, pending.Status)
+
+	// A pending prompt isn't sent again to the same desktop process
+	require.NoError(t, r.SendPrompt(p))
+	require.Len(t, asker.asked, 1)
+
+	// Other users can't answer for this one
+	require.Error(t, r.RecordPromptResponse("502", prompt.Response{PromptID: p.ID, Status: prompt.StatusAnswered, Choice: "Yes"}))
+	require.Error(t, r.RecordPromptResponse("501", prompt.Response{PromptID: p.ID, Status: "bogus"}))
+
+	require.NoError(t, r.RecordPromptResponse("501", prompt.Response{PromptID: p.ID, Status: prompt.StatusAnswered, Choice: "Yes"}))
+	answered, err := r.promptResponse(p.ID, "501")
+	require.NoError(t, err)
+	require.Equal(t, prompt.StatusAnswered, answered.Status)
+	require.Equal(t, "Yes", answered.Choice)
+	require.Equal(t, "501", answered.UID)
+	require.False(t, answered.RespondedAt.IsZero())
+
+	// Answered prompts can't be answered again, or resent
+	require.Error(t, r.RecordPromptResponse("501", prompt.Response{PromptID: p.ID, Status: prompt.StatusAnswered, Choice: "No"}))
+	require.NoError(t, r.SendPrompt(p))
+	require.Len(t, asker.asked, 1)
+}
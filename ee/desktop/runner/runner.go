@@ -93,7 +93,7 @@ func InstanceDesktopProcessRecords() map[string]processRecord {
 		return nil
 	}
 
-	return instance.uidProcs
+	return instance.processRecords()
 }
 
 // DesktopUsersProcessesRunner creates a launcher desktop process each time it detects
@@ -107,8 +107,10 @@ type DesktopUsersProcessesRunner struct {
 	// menuRefreshInterval is the interval on which the desktop menu will be refreshed
 	menuRefreshInterval time.Duration
 	interrupt           chan struct{}
-	// uidProcs is a map of uid to desktop process
-	uidProcs map[string]processRecord
+	// uidProcs is a map of uid to desktop process. It's read by the notifier, the control server
+	// consumers and the desktop table, as well as the runner itself, so it's guarded by procsLock.
+	uidProcs  map[string]processRecord
+	procsLock sync.RWMutex
 	// procsWg is a WaitGroup to wait for all desktop processes to finish during an interrupt
 	procsWg *sync.WaitGroup
 	// interruptTimeout how long to wait for desktop proccesses to finish on interrupt
@@ -132,6 +134,21 @@ type DesktopUsersProcessesRunner struct {
 	knapsack types.Knapsack
 	// runnerServer is a local server that desktop processes call to monitor parent
 	runnerServer *runnerserver.RunnerServer
+	// uidSupervision is a map of uid to the supervision state of its desktop processes. It's
+	// updated from the goroutines waiting on the processes, and is guarded by supervisionLock.
+	uidSupervision  map[string]*supervisionState
+	supervisionLock sync.Mutex
+	// restartBackoffMin and restartBackoffMax bound the delay before restarting a desktop process
+	// that failed to start, or crashed
+	restartBackoffMin time.Duration
+	restartBackoffMax time.Duration
+	// promptResponseStore holds users' answers to prompts sent from the control server
+	promptResponseStore types.KVStore
+	// controlStore, serverProvidedDataStore and querier supply the device state shown in the menu
+	controlStore            types.Getter
+	serverProvidedDataStore types.Getter
+	querier                 querier
+	querierLock             sync.RWMutex
 }
 
 // processRecord is used to track spawned desktop processes.
@@ -144,6 +161,7 @@ type processRecord struct {
 	StartTime, LastHealthCheck time.Time
 	path                       string
 	socketPath                 string
+	supervisionState
 }
 
 // New creates and returns a new DesktopUsersProcessesRunner runner and initializes all required fields
@@ -152,6 +170,9 @@ func New(k types.Knapsack, opts ...desktopUsersProcessesRunnerOption) (*DesktopU
 		logger:                 log.NewNopLogger(),
 		interrupt:              make(chan struct{}),
 		uidProcs:               make(map[string]processRecord),
+		uidSupervision:         make(map[string]*supervisionState),
+		restartBackoffMin:      defaultRestartBackoffMin,
+		restartBackoffMax:      defaultRestartBackoffMax,
 		updateInterval:         k.DesktopUpdateInterval(),
 		menuRefreshInterval:    k.DesktopMenuRefreshInterval(),
 		procsWg:                &sync.WaitGroup{},
@@ -179,6 +200,7 @@ func New(k types.Knapsack, opts ...desktopUsersProcessesRunnerOption) (*DesktopU
 	}
 
 	runner.runnerServer = rs
+	runner.runnerServer.SetPromptResponseRecorder(runner)
 	go func() {
 		if err := runner.runnerServer.Serve(); err != nil && !errors.Is(err, http.ErrServerClosed) {
 			level.Error(runner.logger).Log(
@@ -247,10 +269,13 @@ func (r *DesktopUsersProcessesRunner) killDesktopProcesses() {
 		r.procsWg.Wait()
 	}()
 
+	procs := r.processes()
+
 	shutdownRequestCount := 0
-	for uid, proc := range r.uidProcs {
+	for uid, proc := range procs {
 		// unregistering client from runner server so server will not respond to its requests
 		r.runnerServer.DeRegisterClient(uid)
+		r.recordShutdown(uid)
 
 		client := client.New(r.userServerAuthToken, proc.socketPath)
 		if err := client.Shutdown(); err != nil {
@@ -275,11 +300,13 @@ func (r *DesktopUsersProcessesRunner) killDesktopProcesses() {
 			)
 		}
 
+		r.procsLock.Lock()
 		maps.Clear(r.uidProcs)
+		r.procsLock.Unlock()
 		return
 	case <-time.After(r.interruptTimeout):
 		level.Error(r.logger).Log("msg", "timeout waiting for desktop processes to exit, now killing")
-		for uid, processRecord := range r.uidProcs {
+		for uid, processRecord := range procs {
 			if !r.processExists(processRecord) {
 				continue
 			}
@@ -297,12 +324,13 @@ func (r *DesktopUsersProcessesRunner) killDesktopProcesses() {
 }
 
 func (r *DesktopUsersProcessesRunner) SendNotification(n notify.Notification) error {
-	if len(r.uidProcs) == 0 {
+	procs := r.processes()
+	if len(procs) == 0 {
 		return errors.New("cannot send notification, no child desktop processes")
 	}
 
 	errs := make([]error, 0)
-	for _, proc := range r.uidProcs {
+	for _, proc := range procs {
 		client := client.New(r.userServerAuthToken, proc.socketPath)
 		if err := client.Notify(n); err != nil {
 			errs = append(errs, err)
@@ -316,6 +344,41 @@ func (r *DesktopUsersProcessesRunner) SendNotification(n notify.Notification) er
 	return nil
 }
 
+// SendNotificationToUser sends the notification to the desktop process of a single console user
+func (r *DesktopUsersProcessesRunner) SendNotificationToUser(uid string, n notify.Notification) error {
+	proc, ok := r.process(uid)
+	if !ok {
+		return fmt.Errorf("cannot send notification, no desktop process for uid %s", uid)
+	}
+
+	client := client.New(r.userServerAuthToken, proc.socketPath)
+	return client.Notify(n)
+}
+
+// DesktopProcessUids returns the uids of the console users that have desktop processes
+func (r *DesktopUsersProcessesRunner) DesktopProcessUids() []string {
+	r.procsLock.RLock()
+	defer r.procsLock.RUnlock()
+
+	uids := make([]string, 0, len(r.uidProcs))
+	for uid := range r.uidProcs {
+		uids = append(uids, uid)
+	}
+
+	return uids
+}
+
+// notificationAcknowledgementRecorder records that users have clicked on notifications
+type notificationAcknowledgementRecorder interface {
+	RecordNotificationAcknowledgement(uid string, notificationID string) error
+}
+
+// SetNotificationAcknowledgementRecorder sets where the runner server sends notification
+// acknowledgements from desktop processes
+func (r *DesktopUsersProcessesRunner) SetNotificationAcknowledgementRecorder(recorder notificationAcknowledgementRecorder) {
+	r.runnerServer.SetNotificationAcknowledgementRecorder(recorder)
+}
+
 // Update handles control server updates for the desktop-menu subsystem
 func (r *DesktopUsersProcessesRunner) Update(data io.Reader) error {
 	if data == nil {
@@ -380,7 +443,7 @@ func (r *DesktopUsersProcessesRunner) refreshMenu() {
 	}
 
 	// Tell any running desktop user processes that they should refresh the latest menu data
-	for uid, proc := range r.uidProcs {
+	for uid, proc := range r.processes() {
 		client := client.New(r.userServerAuthToken, proc.socketPath)
 		if err := client.Refresh(); err != nil {
 			level.Error(r.logger).Log(
@@ -412,6 +475,7 @@ func (r *DesktopUsersProcessesRunner) generateMenuFile() error {
 		menu.LastMenuUpdateTime: info.ModTime().Unix(),
 		menu.MenuVersion:        menu.CurrentMenuVersion,
 	}
+	r.addDeviceState(td)
 
 	menuTemplateFileBytes, err := os.ReadFile(r.menuTemplatePath())
 	if err != nil {
@@ -476,6 +540,16 @@ func (r *DesktopUsersProcessesRunner) runConsoleUserDesktop() error {
 			continue
 		}
 
+		// the desktop process has been failing for this user, give it a rest before trying again
+		if remaining := r.restartBackoffRemaining(uid); remaining > 0 {
+			level.Debug(r.logger).Log(
+				"msg", "waiting for restart backoff before starting desktop",
+				"uid", uid,
+				"remaining", remaining.String(),
+			)
+			continue
+		}
+
 		// we've decided to spawn a new desktop user process for this user
 		// make sure any existing user desktop processes stop being
 		// recognized by the runner server
@@ -491,7 +565,10 @@ func (r *DesktopUsersProcessesRunner) runConsoleUserDesktop() error {
 			return fmt.Errorf("creating desktop command: %w", err)
 		}
 
+		r.recordStart(uid)
+
 		if err := r.runAsUser(ctx, uid, cmd); err != nil {
+			r.recordStartError(uid, err)
 			return fmt.Errorf("running desktop command as user: %w", err)
 		}
 
@@ -501,6 +578,7 @@ func (r *DesktopUsersProcessesRunner) runConsoleUserDesktop() error {
 		if err := backoff.WaitFor(client.Ping, 10*time.Second, 1*time.Second); err != nil {
 			// unregister proc from desktop server so server will not respond to its requests
 			r.runnerServer.DeRegisterClient(uid)
+			r.recordShutdown(uid)
 
 			if err := cmd.Process.Kill(); err != nil {
 				level.Error(r.logger).Log(
@@ -512,7 +590,9 @@ func (r *DesktopUsersProcessesRunner) runConsoleUserDesktop() error {
 				)
 			}
 
-			return fmt.Errorf("pinging user desktop server after startup: pid %d: %w", cmd.Process.Pid, err)
+			err = fmt.Errorf("pinging user desktop server after startup: pid %d: %w", cmd.Process.Pid, err)
+			r.recordStartError(uid, err)
+			return err
 		}
 
 		level.Debug(r.logger).Log(
@@ -544,6 +624,9 @@ func (r *DesktopUsersProcessesRunner) addProcessTrackingRecordForUser(uid string
 		return fmt.Errorf("getting process path: %w", err)
 	}
 
+	r.procsLock.Lock()
+	defer r.procsLock.Unlock()
+
 	r.uidProcs[uid] = processRecord{
 		Process:    osProcess,
 		StartTime:  time.Now().UTC(),
@@ -554,6 +637,29 @@ func (r *DesktopUsersProcessesRunner) addProcessTrackingRecordForUser(uid string
 	return nil
 }
 
+// processes returns a copy of the process records, so that callers may talk to the desktop
+// processes without holding procsLock.
+func (r *DesktopUsersProcessesRunner) processes() map[string]processRecord {
+	r.procsLock.RLock()
+	defer r.procsLock.RUnlock()
+
+	procs := make(map[string]processRecord, len(r.uidProcs))
+	for uid, proc := range r.uidProcs {
+		procs[uid] = proc
+	}
+
+	return procs
+}
+
+// process returns uid's process record, if there is one
+func (r *DesktopUsersProcessesRunner) process(uid string) (processRecord, bool) {
+	r.procsLock.RLock()
+	defer r.procsLock.RUnlock()
+
+	proc, ok := r.uidProcs[uid]
+	return proc, ok
+}
+
 // waitForProcess adds 1 to DesktopUserProcessRunner.procsWg and runs a goroutine to wait on the process to exit.
 // The go routine will decrement DesktopUserProcessRunner.procsWg when it exits. This is necessary because if
 // the process dies and we do not wait for it, it will live as a zombie and not get cleaned up by the parent.
@@ -573,6 +679,8 @@ func (r *DesktopUsersProcessesRunner) waitOnProcessAsync(uid string, proc *os.Pr
 				"state", state,
 			)
 		}
+
+		r.recordExit(username, state, err)
 	}(uid, proc)
 }
 
@@ -593,7 +701,7 @@ func (r *DesktopUsersProcessesRunner) determineExecutablePath() (string, error)
 
 func (r *DesktopUsersProcessesRunner) userHasDesktopProcess(uid string) bool {
 	// have no record of process
-	proc, ok := r.uidProcs[uid]
+	proc, ok := r.process(uid)
 	if !ok {
 		return false
 	}
@@ -602,16 +710,22 @@ func (r *DesktopUsersProcessesRunner) userHasDesktopProcess(uid string) bool {
 	if !r.processExists(proc) {
 		level.Info(r.logger).Log(
 			"msg", "found existing desktop process dead for console user",
-			"pid", r.uidProcs[uid].Process.Pid,
-			"process_path", r.uidProcs[uid].path,
+			"pid", proc.Process.Pid,
+			"process_path", proc.path,
 			"uid", uid,
 		)
 
 		return false
 	}
 
-	proc.LastHealthCheck = time.Now().UTC()
-	r.uidProcs[uid] = proc
+	r.procsLock.Lock()
+	// the record may have been replaced or cleared while we were checking on the process
+	if current, ok := r.uidProcs[uid]; ok && current.Process == proc.Process {
+		current.LastHealthCheck = time.Now().UTC()
+		r.uidProcs[uid] = current
+	}
+	r.procsLock.Unlock()
+	r.recordHealthy(uid, proc.StartTime)
 
 	// have running process
 	return true
@@ -733,10 +847,23 @@ func (r *DesktopUsersProcessesRunner) desktopCommand(executablePath, uid, socket
 	}
 
 	go func() {
-		combined := io.MultiReader(stdErr, stdOut)
-		scanner := bufio.NewScanner(combined)
+		scanner := bufio.NewScanner(stdOut)
+
+		for scanner.Scan() {
+			level.Info(r.logger).Log(
+				"uid", uid,
+				"subprocess", "desktop",
+				"msg", scanner.Text(),
+			)
+		}
+	}()
+
+	// stderr is also kept in the supervision state, to help diagnose desktop processes that crash
+	go func() {
+		scanner := bufio.NewScanner(stdErr)
 
 		for scanner.Scan() {
+			r.appendStderr(uid, scanner.Text())
 			level.Info(r.logger).Log(
 				"uid", uid,
 				"subprocess", "desktop",
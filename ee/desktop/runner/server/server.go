@@ -2,6 +2,7 @@ package server
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"net"
 	"net/http"
@@ -12,41 +13,73 @@ import (
 	"github.com/go-kit/kit/log"
 	"github.com/go-kit/kit/log/level"
 	"github.com/kolide/kit/ulid"
+	"github.com/kolide/launcher/ee/desktop/user/notify"
+	"github.com/kolide/launcher/ee/desktop/user/prompt"
+	"github.com/kolide/launcher/pkg/agent/types"
+	"github.com/kolide/launcher/pkg/debug/checkups"
 )
 
 // RunnerServer provides IPC for user desktop processes to communicate back to the root desktop runner.
 // It allows the user process to notify and monitor the health of the runner process.
 type RunnerServer struct {
-	server                          *http.Server
-	listener                        net.Listener
-	logger                          log.Logger
-	desktopProcAuthTokens           map[string]string
-	mutex                           sync.Mutex
-	controlRequestIntervalOverrider controlRequestIntervalOverrider
+	server                  *http.Server
+	listener                net.Listener
+	logger                  log.Logger
+	desktopProcAuthTokens   map[string]string
+	mutex                   sync.Mutex
+	knapsack                types.Knapsack
+	promptResponseRecorder  promptResponseRecorder
+	acknowledgementRecorder notificationAcknowledgementRecorder
 }
 
 const (
 	HealthCheckEndpoint                = "/health"
 	MenuOpenedEndpoint                 = "/menuopened"
+	PromptResponseEndpoint             = "/promptresponse"
+	NotificationAcknowledgedEndpoint   = "/notificationacknowledged"
+	AccelerateControlEndpoint          = "/acceleratecontrol"
+	DoctorEndpoint                     = "/doctor"
+	CheckupEndpoint                    = "/checkup"
 	controlRequestAccelerationInterval = 5 * time.Second
 	controlRequestAcclerationDuration  = 1 * time.Minute
 )
 
-type controlRequestIntervalOverrider interface {
-	SetControlRequestIntervalOverride(time.Duration, time.Duration)
+// promptResponseRecorder stores users' answers to prompts. The desktop runner fulfills this interface.
+type promptResponseRecorder interface {
+	RecordPromptResponse(uid string, response prompt.Response) error
 }
 
-func New(logger log.Logger, controlRequestIntervalOverrider controlRequestIntervalOverrider) (*RunnerServer, error) {
+// notificationAcknowledgementRecorder records that users have clicked on notifications. The
+// notification consumer fulfills this interface.
+type notificationAcknowledgementRecorder interface {
+	RecordNotificationAcknowledgement(uid string, notificationID string) error
+}
+
+// CheckupRequest asks the runner server to run a checkup on behalf of the desktop menu
+type CheckupRequest struct {
+	Name string `json:"name"`
+}
+
+// CheckupResponse is the result of a checkup run on behalf of the desktop menu
+type CheckupResponse struct {
+	Name    string          `json:"name"`
+	Status  checkups.Status `json:"status"`
+	Summary string          `json:"summary"`
+}
+
+type clientKeyContextKey struct{}
+
+func New(logger log.Logger, k types.Knapsack) (*RunnerServer, error) {
 	listener, err := net.Listen("tcp", "localhost:0")
 	if err != nil {
 		return nil, fmt.Errorf("creating net listener: %w", err)
 	}
 
 	rs := &RunnerServer{
-		listener:                        listener,
-		logger:                          logger,
-		desktopProcAuthTokens:           make(map[string]string),
-		controlRequestIntervalOverrider: controlRequestIntervalOverrider,
+		listener:              listener,
+		logger:                logger,
+		desktopProcAuthTokens: make(map[string]string),
+		knapsack:              k,
 	}
 
 	if rs.logger != nil {
@@ -68,9 +101,30 @@ func New(logger log.Logger, controlRequestIntervalOverrider controlRequestInterv
 			r.Body.Close()
 		}
 
-		controlRequestIntervalOverrider.SetControlRequestIntervalOverride(controlRequestAccelerationInterval, controlRequestAcclerationDuration)
+		rs.knapsack.SetControlRequestIntervalOverride(controlRequestAccelerationInterval, controlRequestAcclerationDuration)
+	})
+
+	// accelerate control endpoint, the user asked for a control fetch from the menu
+	mux.HandleFunc(AccelerateControlEndpoint, func(w http.ResponseWriter, r *http.Request) {
+		if r.Body != nil {
+			r.Body.Close()
+		}
+
+		rs.knapsack.SetControlRequestIntervalOverride(controlRequestAccelerationInterval, controlRequestAcclerationDuration)
 	})
 
+	// doctor endpoint, returns doctor output for the menu's status window
+	mux.HandleFunc(DoctorEndpoint, rs.doctorHandler)
+
+	// checkup endpoint, runs a checkup the user chose from the menu
+	mux.HandleFunc(CheckupEndpoint, rs.checkupHandler)
+
+	// prompt response endpoint, the desktop process posts the user's answer here
+	mux.HandleFunc(PromptResponseEndpoint, rs.promptResponseHandler)
+
+	// notification acknowledgement endpoint, the desktop process posts here when the user clicks on a notification
+	mux.HandleFunc(NotificationAcknowledgedEndpoint, rs.notificationAcknowledgedHandler)
+
 	rs.server = &http.Server{
 		Handler: rs.authMiddleware(mux),
 	}
@@ -109,6 +163,140 @@ func (ms *RunnerServer) DeRegisterClient(key string) {
 	}
 }
 
+// SetPromptResponseRecorder sets where prompt responses from desktop procs are sent. Until it's
+// set, responses are rejected.
+func (ms *RunnerServer) SetPromptResponseRecorder(recorder promptResponseRecorder) {
+	ms.mutex.Lock()
+	defer ms.mutex.Unlock()
+
+	ms.promptResponseRecorder = recorder
+}
+
+func (ms *RunnerServer) promptResponseHandler(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	ms.mutex.Lock()
+	recorder := ms.promptResponseRecorder
+	ms.mutex.Unlock()
+
+	if recorder == nil {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+
+	var response prompt.Response
+	if err := json.NewDecoder(r.Body).Decode(&response); err != nil {
+		level.Debug(ms.logger).Log("msg", "could not decode prompt response", "err", err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	// The desktop proc can only answer for its own user
+	uid, _ := r.Context().Value(clientKeyContextKey{}).(string)
+
+	if err := recorder.RecordPromptResponse(uid, response); err != nil {
+		level.Debug(ms.logger).Log("msg", "could not record prompt response", "uid", uid, "prompt_id", response.PromptID, "err", err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+}
+
+// SetNotificationAcknowledgementRecorder sets where notification acknowledgements from desktop procs
+// are sent. Until it's set, acknowledgements are rejected.
+func (ms *RunnerServer) SetNotificationAcknowledgementRecorder(recorder notificationAcknowledgementRecorder) {
+	ms.mutex.Lock()
+	defer ms.mutex.Unlock()
+
+	ms.acknowledgementRecorder = recorder
+}
+
+func (ms *RunnerServer) notificationAcknowledgedHandler(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	ms.mutex.Lock()
+	recorder := ms.acknowledgementRecorder
+	ms.mutex.Unlock()
+
+	if recorder == nil {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+
+	var acknowledgement notify.Acknowledgement
+	if err := json.NewDecoder(r.Body).Decode(&acknowledgement); err != nil {
+		level.Debug(ms.logger).Log("msg", "could not decode notification acknowledgement", "err", err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	// The desktop proc can only acknowledge for its own user
+	uid, _ := r.Context().Value(clientKeyContextKey{}).(string)
+
+	if err := recorder.RecordNotificationAcknowledgement(uid, acknowledgement.NotificationID); err != nil {
+		level.Debug(ms.logger).Log("msg", "could not record notification acknowledgement", "uid", uid, "notification_id", acknowledgement.NotificationID, "err", err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+}
+
+func (ms *RunnerServer) doctorHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Body != nil {
+		r.Body.Close()
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 2*time.Minute)
+	defer cancel()
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	checkups.RunDoctor(ctx, ms.knapsack, w)
+}
+
+func (ms *RunnerServer) checkupHandler(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	var checkupRequest CheckupRequest
+	if err := json.NewDecoder(r.Body).Decode(&checkupRequest); err != nil {
+		level.Debug(ms.logger).Log("msg", "could not decode checkup request", "err", err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
+	defer cancel()
+
+	// Only allow-listed checkups may be run, an error means the checkup isn't one of them
+	status, summary, err := checkups.RunMenuCheckup(ctx, ms.knapsack, checkupRequest.Name)
+	if err != nil {
+		level.Debug(ms.logger).Log("msg", "could not run checkup", "name", checkupRequest.Name, "err", err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(CheckupResponse{
+		Name:    checkupRequest.Name,
+		Status:  status,
+		Summary: summary,
+	}); err != nil {
+		level.Debug(ms.logger).Log("msg", "could not encode checkup response", "err", err)
+	}
+}
+
 func (ms *RunnerServer) Url() string {
 	return fmt.Sprintf("http://%s", ms.listener.Addr().String())
 }
@@ -123,25 +311,27 @@ func (ms *RunnerServer) authMiddleware(next http.Handler) http.Handler {
 			return
 		}
 
-		if !ms.isAuthTokenValid(authHeader[1]) {
+		key, ok := ms.clientKeyForAuthToken(authHeader[1])
+		if !ok {
 			level.Debug(ms.logger).Log("msg", "invalid desktop auth token")
 			w.WriteHeader(http.StatusUnauthorized)
 			return
 		}
 
-		next.ServeHTTP(w, r)
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), clientKeyContextKey{}, key)))
 	})
 }
 
-func (ms *RunnerServer) isAuthTokenValid(authToken string) bool {
+// clientKeyForAuthToken returns the key the desktop proc with authToken was registered under
+func (ms *RunnerServer) clientKeyForAuthToken(authToken string) (string, bool) {
 	ms.mutex.Lock()
 	defer ms.mutex.Unlock()
 
-	for _, v := range ms.desktopProcAuthTokens {
+	for k, v := range ms.desktopProcAuthTokens {
 		if v == authToken {
-			return true
+			return k, true
 		}
 	}
 
-	return false
+	return "", false
 }
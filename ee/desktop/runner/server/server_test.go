@@ -1,13 +1,17 @@
 package server
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
 	"fmt"
 	"net/http"
 	"testing"
 	"time"
 
 	"github.com/go-kit/kit/log"
+	"github.com/kolide/launcher/ee/desktop/user/notify"
+	"github.com/kolide/launcher/ee/desktop/user/prompt"
 	"github.com/kolide/launcher/pkg/agent/types/mocks"
 	"github.com/kolide/launcher/pkg/authedclient"
 	"github.com/stretchr/testify/mock"
@@ -43,6 +47,12 @@ func TestRootServer(t *testing.T) {
 	require.NoError(t, err)
 	require.Equal(t, http.StatusOK, response.StatusCode)
 
+	response, err = client.Post(endpointUrl(monitorServer.Url(), AccelerateControlEndpoint), "application/json", http.NoBody)
+	require.NoError(t, response.Body.Close())
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, response.StatusCode)
+	mockSack.AssertNumberOfCalls(t, "SetControlRequestIntervalOverride", 2)
+
 	// deregister and make sure we get unauthorized status codes
 	monitorServer.DeRegisterClient("0")
 
@@ -59,6 +69,148 @@ func TestRootServer(t *testing.T) {
 	require.NoError(t, monitorServer.Shutdown(context.Background()))
 }
 
+type recordedResponse struct {
+	uid      string
+	response prompt.Response
+}
+
+type testRecorder struct {
+	recorded []recordedResponse
+}
+
+func (tr *testRecorder) RecordPromptResponse(uid string, response prompt.Response) error {
+	tr.recorded = append(tr.recorded, recordedResponse{uid, response})
+	return nil
+}
+
+func TestPromptResponse(t *testing.T) {
+	t.Parallel()
+
+	mockSack := mocks.NewKnapsack(t)
+
+	monitorServer, err := New(log.NewNopLogger(), mockSack)
+	require.NoError(t, err)
+
+	go func() {
+		if err := monitorServer.Serve(); err != nil {
+			require.ErrorIs(t, err, http.ErrServerClosed)
+		}
+	}()
+
+	client := authedclient.New(monitorServer.RegisterClient("501"), 1*time.Second)
+	body, err := json.Marshal(prompt.Response{PromptID: "aup", UID: "0", Status: prompt.StatusAnswered, Choice: "Yes"})
+	require.NoError(t, err)
+
+	// Rejected until there's somewhere to record responses
+	response, err := client.Post(endpointUrl(monitorServer.Url(), PromptResponseEndpoint), "application/json", bytes.NewReader(body))
+	require.NoError(t, err)
+	require.NoError(t, response.Body.Close())
+	require.Equal(t, http.StatusServiceUnavailable, response.StatusCode)
+
+	recorder := &testRecorder{}
+	monitorServer.SetPromptResponseRecorder(recorder)
+
+	response, err = client.Post(endpointUrl(monitorServer.Url(), PromptResponseEndpoint), "application/json", bytes.NewReader(body))
+	require.NoError(t, err)
+	require.NoError(t, response.Body.Close())
+	require.Equal(t, http.StatusOK, response.StatusCode)
+
+	// The uid comes from the auth token, not the response
+	require.Len(t, recorder.recorded, 1)
+	require.Equal(t, "501", recorder.recorded[0].uid)
+	require.Equal(t, "Yes", recorder.recorded[0].response.Choice)
+
+	require.NoError(t, monitorServer.Shutdown(context.Background()))
+}
+
+type testAcknowledgementRecorder struct {
+	acknowledged map[string]string
+}
+
+func (tr *testAcknowledgementRecorder) RecordNotificationAcknowledgement(uid string, notificationID string) error {
+	tr.acknowledged[uid] = notificationID
+	return nil
+}
+
+func TestNotificationAcknowledged(t *testing.T) {
+	t.Parallel()
+
+	mockSack := mocks.NewKnapsack(t)
+
+	monitorServer, err := New(log.NewNopLogger(), mockSack)
+	require.NoError(t, err)
+
+	go func() {
+		if err := monitorServer.Serve(); err != nil {
+			require.ErrorIs(t, err, http.ErrServerClosed)
+		}
+	}()
+
+	client := authedclient.New(monitorServer.RegisterClient("501"), 1*time.Second)
+	body, err := json.Marshal(notify.Acknowledgement{NotificationID: "reboot-reminder"})
+	require.NoError(t, err)
+
+	// Rejected until there's somewhere to record acknowledgements
+	response, err := client.Post(endpointUrl(monitorServer.Url(), NotificationAcknowledgedEndpoint), "application/json", bytes.NewReader(body))
+	require.NoError(t, err)
+	require.NoError(t, response.Body.Close())
+	require.Equal(t, http.StatusServiceUnavailable, response.StatusCode)
+
+	recorder := &testAcknowledgementRecorder{acknowledged: make(map[string]string)}
+	monitorServer.SetNotificationAcknowledgementRecorder(recorder)
+
+	response, err = client.Post(endpointUrl(monitorServer.Url(), NotificationAcknowledgedEndpoint), "application/json", bytes.NewReader(body))
+	require.NoError(t, err)
+	require.NoError(t, response.Body.Close())
+	require.Equal(t, http.StatusOK, response.StatusCode)
+
+	// The uid comes from the auth token
+	require.Equal(t, map[string]string{"501": "reboot-reminder"}, recorder.acknowledged)
+
+	require.NoError(t, monitorServer.Shutdown(context.Background()))
+}
+
+func TestCheckup(t *testing.T) {
+	t.Parallel()
+
+	mockSack := mocks.NewKnapsack(t)
+
+	monitorServer, err := New(log.NewNopLogger(), mockSack)
+	require.NoError(t, err)
+
+	go func() {
+		if err := monitorServer.Serve(); err != nil {
+			require.ErrorIs(t, err, http.ErrServerClosed)
+		}
+	}()
+
+	client := authedclient.New(monitorServer.RegisterClient("501"), 10*time.Second)
+
+	checkup := func(name string) *http.Response {
+		body, err := json.Marshal(CheckupRequest{Name: name})
+		require.NoError(t, err)
+		response, err := client.Post(endpointUrl(monitorServer.Url(), CheckupEndpoint), "application/json", bytes.NewReader(body))
+		require.NoError(t, err)
+		return response
+	}
+
+	// Allow-listed checkups run
+	response := checkup("Platform")
+	var result CheckupResponse
+	require.NoError(t, json.NewDecoder(response.Body).Decode(&result))
+	require.NoError(t, response.Body.Close())
+	require.Equal(t, http.StatusOK, response.StatusCode)
+	require.Equal(t, "Platform", result.Name)
+	require.NotEmpty(t, result.Status)
+
+	// Other checkups don't
+	response = checkup("Root directory contents")
+	require.NoError(t, response.Body.Close())
+	require.Equal(t, http.StatusBadRequest, response.StatusCode)
+
+	require.NoError(t, monitorServer.Shutdown(context.Background()))
+}
+
 func endpointUrl(url, endpoint string) string {
 	return fmt.Sprintf("%s%s", url, endpoint)
 }
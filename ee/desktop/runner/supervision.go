@@ -0,0 +1,209 @@
+package runner
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/go-kit/kit/log/level"
+)
+
+const (
+	// defaultRestartBackoffMin is the delay before restarting a desktop process after its first failure.
+	// It doubles with each consecutive failure, up to defaultRestartBackoffMax.
+	defaultRestartBackoffMin = 10 * time.Second
+	defaultRestartBackoffMax = 30 * time.Minute
+	// healthyAfter is how long a desktop process must stay up before its consecutive failures are forgiven
+	healthyAfter = 5 * time.Minute
+	// stderrTailLines is the number of lines of desktop stderr kept for diagnosis
+	stderrTailLines = 20
+)
+
+// WithRestartBackoff sets the minimum and maximum delay before restarting a desktop process that
+// failed to start, or crashed.
+func WithRestartBackoff(min, max time.Duration) desktopUsersProcessesRunnerOption {
+	return func(r *DesktopUsersProcessesRunner) {
+		r.restartBackoffMin = min
+		r.restartBackoffMax = max
+	}
+}
+
+// supervisionState tracks a console user's desktop processes across restarts. It outlives any
+// single process, so that we can back off when the desktop repeatedly fails, and report why.
+type supervisionState struct {
+	// CrashCount is the number of times the desktop process failed to start, or exited unexpectedly
+	CrashCount int
+	// ConsecutiveFailures is the number of failures since the desktop process was last healthy.
+	// It determines the restart backoff.
+	ConsecutiveFailures int
+	// LastExitStatus and LastExitTime describe the most recent desktop process exit
+	LastExitStatus string
+	LastExitTime   time.Time
+	// LastError is the most recent error starting the desktop process
+	LastError string
+	// NextStartTime is the earliest time the desktop process will be restarted
+	NextStartTime time.Time
+	// StderrTail holds the most recent lines the desktop process wrote to stderr
+	StderrTail []string
+
+	// shuttingDown is set when we've asked the process to exit, so that its exit isn't counted as a crash
+	shuttingDown bool
+}
+
+// supervision returns the supervision state for uid, creating it if needed. Callers must hold
+// r.supervisionLock.
+func (r *DesktopUsersProcessesRunner) supervision(uid string) *supervisionState {
+	s, ok := r.uidSupervision[uid]
+	if !ok {
+		s = &supervisionState{}
+		r.uidSupervision[uid] = s
+	}
+	return s
+}
+
+// restartDelay returns how long to wait before the next restart, after the given number of
+// consecutive failures.
+func (r *DesktopUsersProcessesRunner) restartDelay(consecutiveFailures int) time.Duration {
+	if consecutiveFailures <= 0 {
+		return 0
+	}
+
+	delay := r.restartBackoffMin
+	for i := 1; i < consecutiveFailures && delay < r.restartBackoffMax; i++ {
+		delay *= 2
+	}
+
+	if delay > r.restartBackoffMax {
+		return r.restartBackoffMax
+	}
+	return delay
+}
+
+// recordFailure counts a failure of uid's desktop process, and schedules the next restart
+func (r *DesktopUsersProcessesRunner) recordFailure(uid string, s *supervisionState) {
+	s.CrashCount++
+	s.ConsecutiveFailures++
+
+	delay := r.restartDelay(s.ConsecutiveFailures)
+	s.NextStartTime = time.Now().UTC().Add(delay)
+
+	level.Info(r.logger).Log(
+		"msg", "desktop process failed, backing off before restart",
+		"uid", uid,
+		"consecutive_failures", s.ConsecutiveFailures,
+		"crash_count", s.CrashCount,
+		"backoff", delay.String(),
+	)
+}
+
+// recordStartError records that uid's desktop process could not be started
+func (r *DesktopUsersProcessesRunner) recordStartError(uid string, err error) {
+	r.supervisionLock.Lock()
+	defer r.supervisionLock.Unlock()
+
+	s := r.supervision(uid)
+	s.LastError = err.Error()
+	r.recordFailure(uid, s)
+}
+
+// recordStart notes that a new desktop process is starting for uid
+func (r *DesktopUsersProcessesRunner) recordStart(uid string) {
+	r.supervisionLock.Lock()
+	defer r.supervisionLock.Unlock()
+
+	r.supervision(uid).shuttingDown = false
+}
+
+// recordShutdown notes that we've asked uid's desktop process to exit, so its exit is expected
+func (r *DesktopUsersProcessesRunner) recordShutdown(uid string) {
+	r.supervisionLock.Lock()
+	defer r.supervisionLock.Unlock()
+
+	r.supervision(uid).shuttingDown = true
+}
+
+// recordExit records the exit of uid's desktop process. Unless we asked it to exit, it counts as
+// a failure.
+func (r *DesktopUsersProcessesRunner) recordExit(uid string, state *os.ProcessState, waitErr error) {
+	r.supervisionLock.Lock()
+	defer r.supervisionLock.Unlock()
+
+	s := r.supervision(uid)
+	s.LastExitTime = time.Now().UTC()
+
+	switch {
+	case waitErr != nil:
+		s.LastExitStatus = fmt.Sprintf("wait error: %s", waitErr)
+	case state != nil:
+		s.LastExitStatus = state.String()
+	}
+
+	if s.shuttingDown {
+		return
+	}
+
+	if state != nil && state.Success() {
+		// The desktop process exits cleanly when the user logs out. That's not a crash.
+		return
+	}
+
+	r.recordFailure(uid, s)
+}
+
+// recordHealthy notes that uid's desktop process is running. Once it has been up for healthyAfter,
+// previous failures no longer count towards the backoff.
+func (r *DesktopUsersProcessesRunner) recordHealthy(uid string, startTime time.Time) {
+	if time.Since(startTime) < healthyAfter {
+		return
+	}
+
+	r.supervisionLock.Lock()
+	defer r.supervisionLock.Unlock()
+
+	s := r.supervision(uid)
+	s.ConsecutiveFailures = 0
+	s.NextStartTime = time.Time{}
+}
+
+// appendStderr keeps line in uid's stderr tail
+func (r *DesktopUsersProcessesRunner) appendStderr(uid string, line string) {
+	r.supervisionLock.Lock()
+	defer r.supervisionLock.Unlock()
+
+	s := r.supervision(uid)
+	s.StderrTail = append(s.StderrTail, line)
+	if len(s.StderrTail) > stderrTailLines {
+		s.StderrTail = s.StderrTail[len(s.StderrTail)-stderrTailLines:]
+	}
+}
+
+// restartBackoffRemaining returns how long until uid's desktop process may be restarted
+func (r *DesktopUsersProcessesRunner) restartBackoffRemaining(uid string) time.Duration {
+	r.supervisionLock.Lock()
+	defer r.supervisionLock.Unlock()
+
+	s, ok := r.uidSupervision[uid]
+	if !ok {
+		return 0
+	}
+
+	return time.Until(s.NextStartTime)
+}
+
+// processRecords returns a copy of the process records, with their supervision state. Users whose
+// desktop process has never successfully started are included, without a Process.
+func (r *DesktopUsersProcessesRunner) processRecords() map[string]processRecord {
+	records := r.processes()
+
+	r.supervisionLock.Lock()
+	defer r.supervisionLock.Unlock()
+
+	for uid, s := range r.uidSupervision {
+		record := records[uid]
+		record.supervisionState = *s
+		record.StderrTail = append([]string(nil), s.StderrTail...)
+		records[uid] = record
+	}
+
+	return records
+}
@@ -0,0 +1,139 @@
+package runner
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/stretchr/testify/require"
+)
+
+func newSupervisionTestRunner() *DesktopUsersProcessesRunner {
+	return &DesktopUsersProcessesRunner{
+		logger:            log.NewNopLogger(),
+		uidProcs:          make(map[string]processRecord),
+		uidSupervision:    make(map[string]*supervisionState),
+		restartBackoffMin: time.Second,
+		restartBackoffMax: time.Minute,
+	}
+}
+
+// exitState runs the test binary with the given args, and returns how it exited
+func exitState(t *testing.T, args ...string) *os.ProcessState {
+	cmd := exec.Command(os.Args[0], args...)
+	_ = cmd.Run()
+	require.NotNil(t, cmd.ProcessState)
+	return cmd.ProcessState
+}
+
+func TestRestartDelay(t *testing.T) {
+	t.Parallel()
+
+	r := newSupervisionTestRunner()
+
+	var tests = []struct {
+		failures int
+		expected time.Duration
+	}{
+		{failures: 0, expected: 0},
+		{failures: 1, expected: time.Second},
+		{failures: 2, expected: 2 * time.Second},
+		{failures: 4, expected: 8 * time.Second},
+		{failures: 7, expected: time.Minute},
+		{failures: 1000, expected: time.Minute},
+	}
+
+	for _, tt := range tests {
+		require.Equal(t, tt.expected, r.restartDelay(tt.failures), "failures: %d", tt.failures)
+	}
+}
+
+func TestSupervision(t *testing.T) {
+	t.Parallel()
+
+	failed := exitState(t, "-test.run=^$", "-no-such-flag")
+	require.False(t, failed.Success())
+	succeeded := exitState(t, "-test.run=^$")
+	require.True(t, succeeded.Success())
+
+	r := newSupervisionTestRunner()
+
+	// A start error counts as a failure, and delays the next start
+	r.recordStart("501")
+	r.recordStartError("501", errors.New("no display"))
+	require.Greater(t, r.restartBackoffRemaining("501"), time.Duration(0))
+
+	// An unexpected crash counts too, and the backoff grows
+	r.recordStart("501")
+	r.recordExit("501", failed, nil)
+
+	records := r.processRecords()
+	require.Contains(t, records, "501")
+	require.Nil(t, records["501"].Process)
+	require.Equal(t, 2, records["501"].CrashCount)
+	require.Equal(t, 2, records["501"].ConsecutiveFailures)
+	require.Equal(t, "no display", records["501"].LastError)
+	require.Equal(t, failed.String(), records["501"].LastExitStatus)
+
+	// Exits we asked for, and clean exits, are not crashes
+	r.recordStart("501")
+	r.recordShutdown("501")
+	r.recordExit("501", failed, nil)
+	r.recordStart("501")
+	r.recordExit("501", succeeded, nil)
+	require.Equal(t, 2, r.processRecords()["501"].CrashCount)
+
+	// A process that has been up for a while resets the backoff, but not the crash count
+	r.recordHealthy("501", time.Now())
+	require.Equal(t, 2, r.processRecords()["501"].ConsecutiveFailures)
+	r.recordHealthy("501", time.Now().Add(-healthyAfter))
+	require.Equal(t, 0, r.processRecords()["501"].ConsecutiveFailures)
+	require.Equal(t, 2, r.processRecords()["501"].CrashCount)
+	require.LessOrEqual(t, r.restartBackoffRemaining("501"), time.Duration(0))
+}
+
+func TestAppendStderr(t *testing.T) {
+	t.Parallel()
+
+	r := newSupervisionTestRunner()
+	r.uidProcs["501"] = processRecord{Process: &os.Process{Pid: 123}}
+
+	for i := 0; i < stderrTailLines+5; i++ {
+		r.appendStderr("501", "line")
+	}
+	r.appendStderr("501", "last line")
+
+	records := r.processRecords()
+	require.Equal(t, 123, records["501"].Process.Pid)
+	require.Len(t, records["501"].StderrTail, stderrTailLines)
+	require.Equal(t, "last line", records["501"].StderrTail[stderrTailLines-1])
+}
+
+func TestProcessRecords_ConcurrentUpdates(t *testing.T) {
+	t.Parallel()
+
+	r := newSupervisionTestRunner()
+
+	// Run with -race: the records are read while the process tracking state is written
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			require.NoError(t, r.addProcessTrackingRecordForUser("501", "socket", &os.Process{Pid: os.Getpid()}))
+			r.userHasDesktopProcess("501")
+		}()
+		go func() {
+			defer wg.Done()
+			_ = r.processRecords()
+			_ = r.DesktopProcessUids()
+		}()
+	}
+	wg.Wait()
+
+	require.Contains(t, r.processRecords(), "501")
+}
@@ -8,6 +8,7 @@ import (
 	"time"
 
 	"github.com/kolide/launcher/ee/desktop/user/notify"
+	"github.com/kolide/launcher/ee/desktop/user/prompt"
 )
 
 type transport struct {
@@ -55,10 +56,12 @@ func (c *client) Refresh() error {
 }
 
 func (c *client) Notify(n notify.Notification) error {
+	// The ID is included so that the desktop process can report when the user clicks on the notification
 	notificationToSend := notify.Notification{
 		Title:     n.Title,
 		Body:      n.Body,
 		ActionUri: n.ActionUri,
+		ID:        n.ID,
 	}
 	bodyBytes, err := json.Marshal(notificationToSend)
 	if err != nil {
@@ -79,6 +82,28 @@ func (c *client) Notify(n notify.Notification) error {
 	return nil
 }
 
+// Prompt asks the user to answer p. It returns once the prompt is shown; the answer is sent
+// to the runner server.
+func (c *client) Prompt(p prompt.Prompt) error {
+	bodyBytes, err := json.Marshal(p)
+	if err != nil {
+		return fmt.Errorf("could not marshal prompt: %w", err)
+	}
+
+	resp, err := c.base.Post("http://unix/prompt", "application/json", bytes.NewBuffer(bodyBytes))
+	if err != nil {
+		return fmt.Errorf("could not send prompt: %w", err)
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusAccepted {
+		return fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+
+	return nil
+}
+
 func (c *client) get(path string) error {
 	resp, err := c.base.Get(fmt.Sprintf("http://unix/%s", path))
 	if err != nil {
@@ -8,9 +8,11 @@ import (
 	"runtime"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/go-kit/kit/log"
 
+	"github.com/kolide/launcher/ee/desktop/user/prompt"
 	"github.com/kolide/launcher/ee/desktop/user/server"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -43,7 +45,7 @@ func TestClient_GetAndShutdown(t *testing.T) {
 
 			socketPath := testSocketPath(t)
 			shutdownChan := make(chan struct{})
-			server, err := server.New(log.NewNopLogger(), validAuthToken, socketPath, shutdownChan, nil)
+			server, err := server.New(log.NewNopLogger(), validAuthToken, socketPath, shutdownChan, nil, nil)
 			require.NoError(t, err)
 
 			go func() {
@@ -70,6 +72,49 @@ func TestClient_GetAndShutdown(t *testing.T) {
 	}
 }
 
+type testAsker struct {
+	asked []prompt.Prompt
+}
+
+func (a *testAsker) Ask(p prompt.Prompt) error {
+	a.asked = append(a.asked, p)
+	return nil
+}
+
+func TestClient_Prompt(t *testing.T) {
+	t.Parallel()
+
+	const validAuthToken = "test-auth-header"
+
+	socketPath := testSocketPath(t)
+	asker := &testAsker{}
+	server, err := server.New(log.NewNopLogger(), validAuthToken, socketPath, make(chan struct{}), nil, asker)
+	require.NoError(t, err)
+
+	go func() {
+		server.Serve()
+	}()
+
+	client := New(validAuthToken, socketPath)
+
+	p := prompt.Prompt{
+		ID:         "defer-reboot",
+		Title:      "Reboot required",
+		Body:       "Your device needs to reboot to finish updating.",
+		Choices:    []string{"Later", "Now"},
+		ValidUntil: time.Now().Add(time.Hour).Unix(),
+	}
+	require.NoError(t, client.Prompt(p))
+	require.Equal(t, []prompt.Prompt{p}, asker.asked)
+
+	// Invalid prompts are rejected before they reach the user
+	p.ValidUntil = time.Now().Add(-time.Hour).Unix()
+	require.Error(t, client.Prompt(p))
+	require.Len(t, asker.asked, 1)
+
+	assert.NoError(t, server.Shutdown(context.Background()))
+}
+
 func testSocketPath(t *testing.T) string {
 	socketFileName := strings.Replace(t.Name(), "/", "_", -1)
 
@@ -9,9 +9,13 @@ import (
 type actionType string
 
 const (
-	DoNothing actionType = "" // Omitted action implies do nothing
-	OpenURL              = "open-url"
-	Flare                = "flare"
+	DoNothing         actionType = "" // Omitted action implies do nothing
+	OpenURL                      = "open-url"
+	Flare                        = "flare"
+	CopyText                     = "copy-text"
+	ShowStatus                   = "show-status"
+	AccelerateControl            = "accelerate-control"
+	RunCheck                     = "run-check"
 )
 
 // Action encapsulates what action should be performed when a menu item is invoked
@@ -51,6 +55,22 @@ func (a *Action) UnmarshalJSON(data []byte) error {
 		a.Performer = openURL
 	case Flare:
 		a.Performer = actionFlare{}
+	case CopyText:
+		copyText := actionCopyText{}
+		if err := json.Unmarshal(a.Action, &copyText); err != nil {
+			return fmt.Errorf("failed to unmarshal ActionCopyText: %w", err)
+		}
+		a.Performer = copyText
+	case ShowStatus:
+		a.Performer = actionShowStatus{}
+	case AccelerateControl:
+		a.Performer = actionAccelerateControl{}
+	case RunCheck:
+		runCheck := actionRunCheck{}
+		if err := json.Unmarshal(a.Action, &runCheck); err != nil {
+			return fmt.Errorf("failed to unmarshal ActionRunCheck: %w", err)
+		}
+		a.Performer = runCheck
 	default:
 		// Silently ignore unrecognized actions because:
 		// 1. We don't have a logger reference here
@@ -0,0 +1,23 @@
+package menu
+
+import (
+	"net/http"
+
+	"github.com/go-kit/kit/log/level"
+	runnerserver "github.com/kolide/launcher/ee/desktop/runner/server"
+)
+
+// Performs the AccelerateControl action, asking launcher to fetch updates from the control server
+// more frequently for a short while
+type actionAccelerateControl struct{}
+
+func (a actionAccelerateControl) Perform(m *menu) {
+	resp, err := m.runnerServerRequest(http.MethodPost, runnerserver.AccelerateControlEndpoint, http.NoBody)
+	if err != nil {
+		level.Error(m.logger).Log(
+			"msg", "failed to accelerate control requests",
+			"err", err)
+		return
+	}
+	resp.Body.Close()
+}
@@ -0,0 +1,18 @@
+package menu
+
+import (
+	"github.com/go-kit/kit/log/level"
+)
+
+// Performs the CopyText action, e.g. to copy device details for a help desk ticket
+type actionCopyText struct {
+	Text string `json:"text"`
+}
+
+func (a actionCopyText) Perform(m *menu) {
+	if err := copyToClipboard(a.Text); err != nil {
+		level.Error(m.logger).Log(
+			"msg", "failed to copy text to clipboard",
+			"err", err)
+	}
+}
@@ -0,0 +1,16 @@
+//go:build darwin
+// +build darwin
+
+package menu
+
+import (
+	"os/exec"
+	"strings"
+)
+
+// copyToClipboard replaces the contents of the user's clipboard with text
+func copyToClipboard(text string) error {
+	cmd := exec.Command("/usr/bin/pbcopy")
+	cmd.Stdin = strings.NewReader(text)
+	return cmd.Run()
+}
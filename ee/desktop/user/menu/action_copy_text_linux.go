@@ -0,0 +1,32 @@
+//go:build linux
+// +build linux
+
+package menu
+
+import (
+	"errors"
+	"os/exec"
+	"strings"
+)
+
+// clipboardCommands are tried in order; wl-copy covers Wayland sessions, the others X11
+var clipboardCommands = [][]string{
+	{"wl-copy"},
+	{"xclip", "-selection", "clipboard"},
+	{"xsel", "--clipboard", "--input"},
+}
+
+// copyToClipboard replaces the contents of the user's clipboard with text
+func copyToClipboard(text string) error {
+	for _, clipboardCommand := range clipboardCommands {
+		if _, err := exec.LookPath(clipboardCommand[0]); err != nil {
+			continue
+		}
+
+		cmd := exec.Command(clipboardCommand[0], clipboardCommand[1:]...)
+		cmd.Stdin = strings.NewReader(text)
+		return cmd.Run()
+	}
+
+	return errors.New("no clipboard tool available")
+}
@@ -0,0 +1,20 @@
+//go:build windows
+// +build windows
+
+package menu
+
+import (
+	"os/exec"
+	"strings"
+	"syscall"
+)
+
+// copyToClipboard replaces the contents of the user's clipboard with text. Set-Clipboard is used
+// rather than clip.exe, because clip.exe mangles non-ASCII text read from stdin.
+func copyToClipboard(text string) error {
+	cmd := exec.Command("powershell.exe", "-NoProfile", "-NonInteractive", "-Command", "$input | Set-Clipboard")
+	cmd.Stdin = strings.NewReader(text)
+	// Otherwise the powershell window will appear briefly
+	cmd.SysProcAttr = &syscall.SysProcAttr{HideWindow: true}
+	return cmd.Run()
+}
@@ -0,0 +1,51 @@
+package menu
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/go-kit/kit/log/level"
+	runnerserver "github.com/kolide/launcher/ee/desktop/runner/server"
+)
+
+// Performs the RunCheck action. The named checkup is run by the root launcher process, which only
+// runs checkups that are allow-listed for the menu, and the result is shown in a notification.
+type actionRunCheck struct {
+	Name string `json:"name"`
+}
+
+func (a actionRunCheck) Perform(m *menu) {
+	result, err := a.runCheck(m)
+	if err != nil {
+		level.Error(m.logger).Log(
+			"msg", "failed to run check",
+			"name", a.Name,
+			"err", err)
+		m.notify(a.Name, "The check could not be run.")
+		return
+	}
+
+	m.notify(result.Name, fmt.Sprintf("%s: %s", result.Status, result.Summary))
+}
+
+func (a actionRunCheck) runCheck(m *menu) (*runnerserver.CheckupResponse, error) {
+	body, err := json.Marshal(runnerserver.CheckupRequest{Name: a.Name})
+	if err != nil {
+		return nil, fmt.Errorf("marshaling checkup request: %w", err)
+	}
+
+	resp, err := m.runnerServerRequest(http.MethodPost, runnerserver.CheckupEndpoint, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var result runnerserver.CheckupResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("decoding checkup response: %w", err)
+	}
+
+	return &result, nil
+}
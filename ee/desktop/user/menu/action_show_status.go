@@ -0,0 +1,65 @@
+package menu
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	"github.com/go-kit/kit/log/level"
+	runnerserver "github.com/kolide/launcher/ee/desktop/runner/server"
+)
+
+// Performs the ShowStatus action, showing launcher's doctor output in a window
+type actionShowStatus struct{}
+
+func (a actionShowStatus) Perform(m *menu) {
+	statusFile, err := a.writeStatusFile(m)
+	if err != nil {
+		level.Error(m.logger).Log(
+			"msg", "failed to get launcher status",
+			"err", err)
+		return
+	}
+
+	if err := showTextWindow("Kolide Status", statusFile); err != nil {
+		level.Error(m.logger).Log(
+			"msg", "failed to show launcher status",
+			"path", statusFile,
+			"err", err)
+	}
+}
+
+// writeStatusFile fetches the doctor output from the runner server, and writes it to the menu's
+// status file for display. The previous status is replaced, rather than left behind.
+func (a actionShowStatus) writeStatusFile(m *menu) (string, error) {
+	resp, err := m.runnerServerRequest(http.MethodGet, runnerserver.DoctorEndpoint, http.NoBody)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	// Write to a new file and rename it into place, so that we never write through an existing
+	// file or link at the status file's path
+	tmpFile, err := os.CreateTemp(filepath.Dir(m.statusFilePath), "kolide-status-*.tmp")
+	if err != nil {
+		return "", fmt.Errorf("creating status file: %w", err)
+	}
+	defer os.Remove(tmpFile.Name())
+
+	if _, err := io.Copy(tmpFile, resp.Body); err != nil {
+		tmpFile.Close()
+		return "", fmt.Errorf("writing status file: %w", err)
+	}
+
+	if err := tmpFile.Close(); err != nil {
+		return "", fmt.Errorf("closing status file: %w", err)
+	}
+
+	if err := os.Rename(tmpFile.Name(), m.statusFilePath); err != nil {
+		return "", fmt.Errorf("replacing status file: %w", err)
+	}
+
+	return m.statusFilePath, nil
+}
@@ -0,0 +1,13 @@
+//go:build darwin
+// +build darwin
+
+package menu
+
+import (
+	"os/exec"
+)
+
+// showTextWindow opens the text file at path in TextEdit
+func showTextWindow(_ string, path string) error {
+	return exec.Command("/usr/bin/open", "-e", path).Start()
+}
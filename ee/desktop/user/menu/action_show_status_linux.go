@@ -0,0 +1,18 @@
+//go:build linux
+// +build linux
+
+package menu
+
+import (
+	"os/exec"
+)
+
+// showTextWindow shows the text file at path in a zenity window, falling back to the user's
+// default application for text files
+func showTextWindow(title string, path string) error {
+	if zenity, err := exec.LookPath("zenity"); err == nil {
+		return exec.Command(zenity, "--text-info", "--title", title, "--filename", path, "--width", "720", "--height", "480").Start()
+	}
+
+	return open(path)
+}
@@ -0,0 +1,50 @@
+package menu
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-kit/kit/log"
+	runnerserver "github.com/kolide/launcher/ee/desktop/runner/server"
+	"github.com/stretchr/testify/require"
+)
+
+func TestActionShowStatus_writeStatusFile(t *testing.T) {
+	t.Parallel()
+
+	statuses := make(chan string, 2)
+	statuses <- "first status"
+	statuses <- "second status"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, runnerserver.DoctorEndpoint, r.URL.Path)
+		w.Write([]byte(<-statuses))
+	}))
+	t.Cleanup(server.Close)
+
+	statusDir := t.TempDir()
+	statusFilePath := filepath.Join(statusDir, "kolide-status.txt")
+	m := New(log.NewNopLogger(), "", "", WithRunnerServer(server.URL, "token"), WithStatusFilePath(statusFilePath))
+
+	path, err := actionShowStatus{}.writeStatusFile(m)
+	require.NoError(t, err)
+	require.Equal(t, statusFilePath, path)
+
+	contents, err := os.ReadFile(statusFilePath)
+	require.NoError(t, err)
+	require.Equal(t, "first status", string(contents))
+
+	// Showing the status again replaces the file, rather than adding another
+	_, err = actionShowStatus{}.writeStatusFile(m)
+	require.NoError(t, err)
+
+	contents, err = os.ReadFile(statusFilePath)
+	require.NoError(t, err)
+	require.Equal(t, "second status", string(contents))
+
+	entries, err := os.ReadDir(statusDir)
+	require.NoError(t, err)
+	require.Len(t, entries, 1, "expected only the status file to remain")
+}
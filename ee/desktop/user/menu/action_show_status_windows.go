@@ -0,0 +1,13 @@
+//go:build windows
+// +build windows
+
+package menu
+
+import (
+	"os/exec"
+)
+
+// showTextWindow opens the text file at path in Notepad
+func showTextWindow(_ string, path string) error {
+	return exec.Command("notepad.exe", path).Start()
+}
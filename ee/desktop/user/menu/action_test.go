@@ -40,6 +40,31 @@ func Test_Unmarshal(t *testing.T) {
 			data:   `{"type":"open-url","action":{"url":"https://localhost:3443"}}`,
 			action: Action{Type: OpenURL, Action: json.RawMessage(`{"url":"https://localhost:3443"}`), Performer: actionOpenURL{URL: "https://localhost:3443"}},
 		},
+		{
+			name:   "copy text",
+			data:   `{"type":"copy-text","action":{"text":"Serial: C02XL0GYJGH5"}}`,
+			action: Action{Type: CopyText, Action: json.RawMessage(`{"text":"Serial: C02XL0GYJGH5"}`), Performer: actionCopyText{Text: "Serial: C02XL0GYJGH5"}},
+		},
+		{
+			name:   "show status",
+			data:   `{"type":"show-status"}`,
+			action: Action{Type: ShowStatus, Action: json.RawMessage(nil), Performer: actionShowStatus{}},
+		},
+		{
+			name:   "accelerate control",
+			data:   `{"type":"accelerate-control"}`,
+			action: Action{Type: AccelerateControl, Action: json.RawMessage(nil), Performer: actionAccelerateControl{}},
+		},
+		{
+			name:   "run check",
+			data:   `{"type":"run-check","action":{"name":"Check communication with Kolide"}}`,
+			action: Action{Type: RunCheck, Action: json.RawMessage(`{"name":"Check communication with Kolide"}`), Performer: actionRunCheck{Name: "Check communication with Kolide"}},
+		},
+		{
+			name:        "run check with malformed action",
+			data:        `{"type":"run-check","action":{"name":7}}`,
+			expectedErr: true,
+		},
 	}
 	for _, tt := range tests {
 		tt := tt
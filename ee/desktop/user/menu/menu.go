@@ -3,12 +3,19 @@ package menu
 import (
 	_ "embed"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"net/http"
 	"os"
+	"path/filepath"
+	"time"
 
 	"github.com/go-kit/kit/log"
 	"github.com/go-kit/kit/log/level"
 	"github.com/kolide/kit/version"
+	"github.com/kolide/launcher/ee/desktop/user/notify"
+	"github.com/kolide/launcher/pkg/authedclient"
 )
 
 //go:embed initial_menu.json
@@ -54,23 +61,98 @@ type menuBuilder interface {
 	addSeparator()
 }
 
+// notificationSender sends notifications to the user, e.g. to show the result of an action
+type notificationSender interface {
+	SendNotification(notify.Notification) error
+}
+
 // menu handles common functionality like retrieving menu data, and allows menu builders to provide their implementations
 type menu struct {
-	logger   log.Logger
-	hostname string
-	filePath string
+	logger                log.Logger
+	hostname              string
+	filePath              string
+	runnerServerUrl       string
+	runnerServerAuthToken string
+	notifier              notificationSender
+	statusFilePath        string
+}
+
+type menuOption func(*menu)
+
+// WithRunnerServer sets the runner server that actions needing the root launcher process call
+func WithRunnerServer(url, authToken string) menuOption {
+	return func(m *menu) {
+		m.runnerServerUrl = url
+		m.runnerServerAuthToken = authToken
+	}
+}
+
+// WithNotifier sets how actions show their results to the user
+func WithNotifier(notifier notificationSender) menuOption {
+	return func(m *menu) {
+		m.notifier = notifier
+	}
+}
+
+// WithStatusFilePath sets where the show-status action writes launcher's status. The file is
+// overwritten each time the action is performed.
+func WithStatusFilePath(path string) menuOption {
+	return func(m *menu) {
+		m.statusFilePath = path
+	}
 }
 
-func New(logger log.Logger, hostname, filePath string) *menu {
+func New(logger log.Logger, hostname, filePath string, opts ...menuOption) *menu {
 	m := &menu{
-		logger:   logger,
-		hostname: hostname,
-		filePath: filePath,
+		logger:         logger,
+		hostname:       hostname,
+		filePath:       filePath,
+		statusFilePath: filepath.Join(os.TempDir(), "kolide-status.txt"),
+	}
+
+	for _, opt := range opts {
+		opt(m)
 	}
 
 	return m
 }
 
+// runnerServerRequest makes a request to the runner server, for actions that need the root launcher process
+func (m *menu) runnerServerRequest(method, endpoint string, body io.Reader) (*http.Response, error) {
+	if m.runnerServerUrl == "" {
+		return nil, errors.New("no runner server configured")
+	}
+
+	req, err := http.NewRequest(method, m.runnerServerUrl+endpoint, body)
+	if err != nil {
+		return nil, fmt.Errorf("creating request: %w", err)
+	}
+
+	client := authedclient.New(m.runnerServerAuthToken, 2*time.Minute)
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+
+	return resp, nil
+}
+
+// notify shows the user a notification, e.g. with the result of an action
+func (m *menu) notify(title, body string) {
+	if m.notifier == nil {
+		return
+	}
+
+	if err := m.notifier.SendNotification(notify.Notification{Title: title, Body: body}); err != nil {
+		level.Error(m.logger).Log("msg", "failed to send notification", "title", title, "err", err)
+	}
+}
+
 // getMenuData ingests the shared menu.json file created by the desktop runner
 // It unmarshals the data into a MenuData struct representing the menu, which is suitable for parsing and building the menu
 func (m *menu) getMenuData() *MenuData {
@@ -11,10 +11,15 @@ const (
 	CurrentMenuVersion string = "0.1.0" // Bump menu version when major changes occur to the TemplateData format
 
 	// Capabilities queriable via hasCapability
-	funcHasCapability     = "hasCapability"
-	funcRelativeTime      = "relativeTime"
-	errorlessTemplateVars = "errorlessTemplateVars" // capability to evaluate undefined template vars without failing
-	errorlessActions      = "errorlessActions"      // capability to evaluate undefined menu item actions without failing
+	funcHasCapability       = "hasCapability"
+	funcRelativeTime        = "relativeTime"
+	errorlessTemplateVars   = "errorlessTemplateVars"   // capability to evaluate undefined template vars without failing
+	errorlessActions        = "errorlessActions"        // capability to evaluate undefined menu item actions without failing
+	copyTextAction          = "copyTextAction"          // capability to perform the copy-text action
+	showStatusAction        = "showStatusAction"        // capability to perform the show-status action
+	accelerateControlAction = "accelerateControlAction" // capability to perform the accelerate-control action
+	runCheckAction          = "runCheckAction"          // capability to perform the run-check action
+	deviceStateTemplateVars = "deviceStateTemplateVars" // capability to use the device state TemplateData keys
 
 	// TemplateData keys
 	LauncherVersion    string = "LauncherVersion"
@@ -23,6 +28,17 @@ const (
 	ServerHostname     string = "ServerHostname"
 	LastMenuUpdateTime string = "LastMenuUpdateTime"
 	MenuVersion        string = "MenuVersion"
+
+	// Device state TemplateData keys. These are filled in on a best-effort basis, and are omitted
+	// when the data is not available.
+	DeviceName     string = "DeviceName"     // the device's computer name
+	DiskEncrypted  string = "DiskEncrypted"  // bool, whether the boot volume is encrypted
+	PendingUpdates string = "PendingUpdates" // int, the number of pending OS updates
+	LastCheckIn    string = "LastCheckIn"    // Unix timestamp of launcher's last successful control server fetch
+	DeviceId       string = "DeviceId"       // the device's ID, as provided by the server
+	Munemo         string = "Munemo"         // the device's organization munemo, as provided by the server
+	OrganizationId string = "OrganizationId" // the device's organization ID, as provided by the server
+	SerialNumber   string = "SerialNumber"   // the device's serial number, as provided by the server
 )
 
 type TemplateData map[string]interface{}
@@ -56,6 +72,10 @@ func (tp *templateParser) Parse(text string) (string, error) {
 				return true
 			case errorlessActions:
 				return true
+			case copyTextAction, showStatusAction, accelerateControlAction, runCheckAction:
+				return true
+			case deviceStateTemplateVars:
+				return true
 			}
 			return false
 		},
@@ -43,6 +43,18 @@ func Test_Parse(t *testing.T) {
 			text:   "This capability is {{if hasCapability `bad capability`}}supported{{else}}unsupported{{end}}.",
 			output: "This capability is unsupported.",
 		},
+		{
+			name:   "action capability",
+			td:     &TemplateData{},
+			text:   "Copying text is {{if hasCapability `copyTextAction`}}supported{{else}}unsupported{{end}}.",
+			output: "Copying text is supported.",
+		},
+		{
+			name:   "device state",
+			td:     &TemplateData{DeviceName: "kolide-mbp", DiskEncrypted: true, PendingUpdates: 2},
+			text:   "{{if hasCapability `deviceStateTemplateVars`}}{{.DeviceName}}: encrypted {{.DiskEncrypted}}, {{.PendingUpdates}} updates{{end}}",
+			output: "kolide-mbp: encrypted true, 2 updates",
+		},
 		{
 			name:   "relativeTime 2 hours ago",
 			td:     &TemplateData{LastMenuUpdateTime: time.Now().Add(-2 * time.Hour).Unix()},
@@ -1,14 +1,141 @@
 package notify
 
-import "time"
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+	"github.com/kolide/launcher/pkg/authedclient"
+)
 
 // Represents notification received from control server; SentAt is set by this consumer after sending.
-// For the time being, notifications are per-end user device and not per-user.
+// Notifications are sent to every console user, unless TargetUids or TargetUsernames are set.
 type Notification struct {
-	Title      string    `json:"title"`
-	Body       string    `json:"body"`
-	ActionUri  string    `json:"action_uri,omitempty"`
-	ID         string    `json:"id"`
-	ValidUntil int64     `json:"valid_until"` // timestamp
-	SentAt     time.Time `json:"sent_at,omitempty"`
+	Title           string      `json:"title"`
+	Body            string      `json:"body"`
+	ActionUri       string      `json:"action_uri,omitempty"`
+	ID              string      `json:"id"`
+	ValidUntil      int64       `json:"valid_until"`                // timestamp
+	NotBefore       int64       `json:"not_before,omitempty"`       // timestamp
+	SnoozeInterval  int64       `json:"snooze_interval,omitempty"`  // seconds; if set, the notification repeats until acknowledged
+	QuietHours      *QuietHours `json:"quiet_hours,omitempty"`      // no notifications are sent during quiet hours
+	TargetUids      []string    `json:"target_uids,omitempty"`      // only send to these console users
+	TargetUsernames []string    `json:"target_usernames,omitempty"` // only send to these console users
+	SentAt          time.Time   `json:"sent_at,omitempty"`
+}
+
+// QuietHours is a daily window, in the device's local time, during which notifications are held
+// back. Start and End are formatted as "15:04"; the window may wrap past midnight.
+type QuietHours struct {
+	Start string `json:"start"`
+	End   string `json:"end"`
+}
+
+const quietHoursFormat = "15:04"
+
+// Validate checks that Start and End are well-formed
+func (q QuietHours) Validate() error {
+	start, err := time.Parse(quietHoursFormat, q.Start)
+	if err != nil {
+		return fmt.Errorf("parsing quiet hours start: %w", err)
+	}
+
+	end, err := time.Parse(quietHoursFormat, q.End)
+	if err != nil {
+		return fmt.Errorf("parsing quiet hours end: %w", err)
+	}
+
+	if start.Equal(end) {
+		return errors.New("quiet hours start and end must differ")
+	}
+
+	return nil
+}
+
+// Contains reports whether t falls within quiet hours. Invalid quiet hours contain nothing.
+func (q QuietHours) Contains(t time.Time) bool {
+	start, err := time.Parse(quietHoursFormat, q.Start)
+	if err != nil {
+		return false
+	}
+
+	end, err := time.Parse(quietHoursFormat, q.End)
+	if err != nil {
+		return false
+	}
+
+	minuteOfDay := func(t time.Time) int { return t.Hour()*60 + t.Minute() }
+	now, startMinute, endMinute := minuteOfDay(t), minuteOfDay(start), minuteOfDay(end)
+
+	if startMinute < endMinute {
+		return now >= startMinute && now < endMinute
+	}
+
+	// The window wraps past midnight, e.g. 22:00 to 07:00
+	return now >= startMinute || now < endMinute
+}
+
+// Acknowledgement is sent to the runner server when the user clicks on a notification
+type Acknowledgement struct {
+	NotificationID string `json:"notification_id"`
+}
+
+// Acknowledger reports notification clicks to the runner server, so that repeating notifications
+// are not sent again.
+type Acknowledger struct {
+	logger             log.Logger
+	acknowledgementUrl string
+	client             *http.Client
+}
+
+// NewAcknowledger returns an Acknowledger that reports clicks to acknowledgementUrl, on the runner server
+func NewAcknowledger(logger log.Logger, acknowledgementUrl string, runnerServerAuthToken string) *Acknowledger {
+	client := authedclient.New(runnerServerAuthToken, 30*time.Second)
+
+	return &Acknowledger{
+		logger:             log.With(logger, "component", "desktop_notification_acknowledger"),
+		acknowledgementUrl: acknowledgementUrl,
+		client:             &client.Client,
+	}
+}
+
+// Acknowledge reports, in the background, that the user clicked on the notification with the given ID
+func (a *Acknowledger) Acknowledge(notificationID string) {
+	if a == nil || notificationID == "" {
+		return
+	}
+
+	go func() {
+		if err := a.acknowledge(notificationID); err != nil {
+			level.Error(a.logger).Log(
+				"msg", "could not send notification acknowledgement to runner server",
+				"notification_id", notificationID,
+				"err", err,
+			)
+		}
+	}()
+}
+
+func (a *Acknowledger) acknowledge(notificationID string) error {
+	body, err := json.Marshal(Acknowledgement{NotificationID: notificationID})
+	if err != nil {
+		return fmt.Errorf("marshaling acknowledgement: %w", err)
+	}
+
+	resp, err := a.client.Post(a.acknowledgementUrl, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+
+	return nil
 }
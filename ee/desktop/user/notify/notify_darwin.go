@@ -10,7 +10,7 @@ package notify
 #include <stdbool.h>
 #include <stdlib.h>
 
-bool sendNotification(char *cTitle, char *cBody, char *cActionUri);
+bool sendNotification(char *cTitle, char *cBody, char *cActionUri, char *cNotificationId);
 void runNotificationListenerApp(void);
 */
 import "C"
@@ -29,7 +29,12 @@ type macNotifier struct {
 	interrupt chan struct{}
 }
 
-func NewDesktopNotifier(logger log.Logger, _ string) *macNotifier {
+// acknowledger is package-level so that the notification delegate can reach it via notificationClicked
+var acknowledger *Acknowledger
+
+func NewDesktopNotifier(logger log.Logger, _ string, notificationAcknowledger *Acknowledger) *macNotifier {
+	acknowledger = notificationAcknowledger
+
 	return &macNotifier{
 		logger:    log.With(logger, "component", "desktop_notifier"),
 		interrupt: make(chan struct{}),
@@ -62,8 +67,10 @@ func (m *macNotifier) SendNotification(n Notification) error {
 	defer C.free(unsafe.Pointer(bodyCStr))
 	actionUriCStr := C.CString(n.ActionUri)
 	defer C.free(unsafe.Pointer(actionUriCStr))
+	notificationIdCStr := C.CString(n.ID)
+	defer C.free(unsafe.Pointer(notificationIdCStr))
 
-	success := C.sendNotification(titleCStr, bodyCStr, actionUriCStr)
+	success := C.sendNotification(titleCStr, bodyCStr, actionUriCStr, notificationIdCStr)
 	if !success {
 		return fmt.Errorf("could not send notification: %s", n.Title)
 	}
@@ -80,3 +87,10 @@ func isBundle() bool {
 
 	return strings.Contains(currentExecutable, ".app")
 }
+
+// notificationClicked is called by the notification delegate when the user clicks on a notification
+//
+//export notificationClicked
+func notificationClicked(cNotificationId *C.char) {
+	acknowledger.Acknowledge(C.GoString(cNotificationId))
+}
@@ -7,12 +7,19 @@
 #import <UserNotifications/UserNotifications.h>
 #import <AppKit/AppKit.h>
 
+#include "_cgo_export.h"
+
 @interface NotificationDelegate: NSObject <UNUserNotificationCenterDelegate>
 @end
 @implementation NotificationDelegate
 - (void)userNotificationCenter:(UNUserNotificationCenter *)center didReceiveNotificationResponse:(UNNotificationResponse *)response withCompletionHandler:(void (^)(void))completionHandler {
     NSDictionary *userInfo = response.notification.request.content.userInfo;
 
+    NSString *notificationId = userInfo[@"notification_id"];
+    if ([notificationId length] != 0) {
+        notificationClicked((char *)[notificationId UTF8String]);
+    }
+
     NSString *actionUri = userInfo[@"action_uri"];
     if ([actionUri length] != 0) {
         [[NSWorkspace sharedWorkspace] openURL:[NSURL URLWithString:actionUri]];
@@ -45,17 +52,19 @@ void runNotificationListenerApp(void) {
     }
 }
 
-BOOL doSendNotification(UNUserNotificationCenter *center, NSString *title, NSString *body, NSString *actionUri) {
+BOOL doSendNotification(UNUserNotificationCenter *center, NSString *title, NSString *body, NSString *actionUri, NSString *notificationId) {
     UNMutableNotificationContent *content = [UNMutableNotificationContent new];
     [content autorelease];
     content.title = title;
     content.body = body;
 
+    NSMutableDictionary *userInfo = [NSMutableDictionary dictionaryWithObject:notificationId forKey:@"notification_id"];
     if (actionUri != (id)[NSNull null] && actionUri.length > 0) {
         // Only create "Learn more" button if we have an action URI to go with it
         content.categoryIdentifier = @"KolideNotificationWithButtonCategory";
-        content.userInfo = @{@"action_uri": actionUri};
+        userInfo[@"action_uri"] = actionUri;
     }
+    content.userInfo = userInfo;
 
     NSString *uuid = [[NSUUID UUID] UUIDString];
     NSString *identifier = [NSString stringWithFormat:@"kolide-notify-%@", uuid];
@@ -87,12 +96,13 @@ BOOL doSendNotification(UNUserNotificationCenter *center, NSString *title, NSStr
     return success;
 }
 
-BOOL sendNotification(char *cTitle, char *cBody, char *cActionUri) {
+BOOL sendNotification(char *cTitle, char *cBody, char *cActionUri, char *cNotificationId) {
     UNUserNotificationCenter *center = [UNUserNotificationCenter currentNotificationCenter];
 
     NSString *title = [NSString stringWithUTF8String:cTitle];
     NSString *body = [NSString stringWithUTF8String:cBody];
     NSString *actionUri = [NSString stringWithUTF8String:cActionUri];
+    NSString *notificationId = [NSString stringWithUTF8String:cNotificationId];
 
     __block BOOL canSendNotification = NO;
     UNAuthorizationOptions options = (UNAuthorizationOptionAlert | UNAuthorizationStatusProvisional);
@@ -119,7 +129,7 @@ BOOL sendNotification(char *cTitle, char *cBody, char *cActionUri) {
     dispatch_semaphore_wait(semaphore, timeout);
 
     if (canSendNotification) {
-        return doSendNotification(center, title, body, actionUri);
+        return doSendNotification(center, title, body, actionUri, notificationId);
     }
 
     return NO;
@@ -16,39 +16,43 @@ import (
 )
 
 type dbusNotifier struct {
-	iconFilepath        string
-	logger              log.Logger
-	conn                *dbus.Conn
-	signal              chan *dbus.Signal
-	interrupt           chan struct{}
-	sentNotificationIds map[uint32]bool
-	lock                sync.RWMutex
+	iconFilepath      string
+	logger            log.Logger
+	conn              *dbus.Conn
+	signal            chan *dbus.Signal
+	interrupt         chan struct{}
+	sentNotifications map[uint32]Notification // maps dbus notification IDs to the notifications we sent
+	acknowledger      *Acknowledger
+	lock              sync.RWMutex
 }
 
 const (
 	notificationServiceObj       = "/org/freedesktop/Notifications"
 	notificationServiceInterface = "org.freedesktop.Notifications"
 	signalActionInvoked          = "org.freedesktop.Notifications.ActionInvoked"
+	// defaultActionKey is invoked when the user clicks on the body of the notification
+	defaultActionKey = "default"
 )
 
 // We default to xdg-open first because, if available, it appears to be better at picking
 // the correct default browser.
 var browserLaunchers = []string{"xdg-open", "x-www-browser"}
 
-func NewDesktopNotifier(logger log.Logger, iconFilepath string) *dbusNotifier {
+func NewDesktopNotifier(logger log.Logger, iconFilepath string, acknowledger *Acknowledger) *dbusNotifier {
 	conn, err := dbus.ConnectSessionBus()
 	if err != nil {
 		level.Warn(logger).Log("msg", "couldn't connect to dbus to start notifier listener, proceeding without it", "err", err)
 	}
 
 	return &dbusNotifier{
-		iconFilepath:        iconFilepath,
-		logger:              log.With(logger, "component", "desktop_notifier"),
-		conn:                conn,
-		signal:              make(chan *dbus.Signal),
-		interrupt:           make(chan struct{}),
-		sentNotificationIds: make(map[uint32]bool),
-		lock:                sync.RWMutex{},
+		iconFilepath:      iconFilepath,
+		logger:            log.With(logger, "component", "desktop_notifier"),
+		conn:              conn,
+		signal:            make(chan *dbus.Signal),
+		interrupt:         make(chan struct{}),
+		sentNotifications: make(map[uint32]Notification),
+		acknowledger:      acknowledger,
+		lock:              sync.RWMutex{},
 	}
 }
 
@@ -76,15 +80,25 @@ func (d *dbusNotifier) Listen() error {
 			// Confirm that this is a Kolide-originated notification by checking for known notification IDs
 			notificationId := signal.Body[0].(uint32)
 			d.lock.RLock()
-			if _, found := d.sentNotificationIds[notificationId]; !found {
+			sentNotification, found := d.sentNotifications[notificationId]
+			if !found {
 				// This notification didn't come from us -- ignore it
 				d.lock.RUnlock()
 				continue
 			}
 			d.lock.RUnlock()
 
+			// Any click on the notification counts as the user having seen it
+			d.acknowledger.Acknowledge(sentNotification.ID)
+
 			// Attempt to open a browser to the given URL
 			actionUri := signal.Body[1].(string)
+			if actionUri == defaultActionKey {
+				if sentNotification.ActionUri == "" {
+					continue
+				}
+				actionUri = sentNotification.ActionUri
+			}
 
 			for _, browserLauncher := range browserLaunchers {
 				ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
@@ -129,7 +143,8 @@ func (d *dbusNotifier) sendNotificationViaDbus(n Notification) error {
 		return fmt.Errorf("could not connect to dbus: %w", err)
 	}
 
-	actions := []string{}
+	// The default action has no label, it makes the body of the notification clickable
+	actions := []string{defaultActionKey, ""}
 	if n.ActionUri != "" {
 		actions = append(actions, n.ActionUri, "Learn More")
 	}
@@ -159,7 +174,7 @@ func (d *dbusNotifier) sendNotificationViaDbus(n Notification) error {
 	} else {
 		d.lock.Lock()
 		defer d.lock.Unlock()
-		d.sentNotificationIds[notificationId] = true
+		d.sentNotifications[notificationId] = n
 	}
 
 	return nil
@@ -0,0 +1,84 @@
+package notify
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/stretchr/testify/require"
+)
+
+func TestQuietHours(t *testing.T) {
+	t.Parallel()
+
+	at := func(clock string) time.Time {
+		parsed, err := time.Parse(quietHoursFormat, clock)
+		require.NoError(t, err)
+		return time.Date(2023, 6, 1, parsed.Hour(), parsed.Minute(), 0, 0, time.Local)
+	}
+
+	var tests = []struct {
+		name       string
+		quietHours QuietHours
+		quiet      []string
+		notQuiet   []string
+	}{
+		{
+			name:       "same day",
+			quietHours: QuietHours{Start: "12:00", End: "13:30"},
+			quiet:      []string{"12:00", "13:29"},
+			notQuiet:   []string{"11:59", "13:30", "00:00"},
+		},
+		{
+			name:       "past midnight",
+			quietHours: QuietHours{Start: "22:00", End: "07:00"},
+			quiet:      []string{"22:00", "23:59", "00:00", "06:59"},
+			notQuiet:   []string{"07:00", "12:00", "21:59"},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			require.NoError(t, tt.quietHours.Validate())
+			for _, clock := range tt.quiet {
+				require.True(t, tt.quietHours.Contains(at(clock)), clock)
+			}
+			for _, clock := range tt.notQuiet {
+				require.False(t, tt.quietHours.Contains(at(clock)), clock)
+			}
+		})
+	}
+
+	require.Error(t, QuietHours{Start: "10pm", End: "07:00"}.Validate())
+	require.Error(t, QuietHours{Start: "07:00", End: "07:00"}.Validate())
+}
+
+func TestAcknowledger(t *testing.T) {
+	t.Parallel()
+
+	acknowledgements := make(chan Acknowledgement, 1)
+	runnerServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "Bearer test-token", r.Header.Get("Authorization"))
+
+		var acknowledgement Acknowledgement
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&acknowledgement))
+		acknowledgements <- acknowledgement
+	}))
+	defer runnerServer.Close()
+
+	acknowledger := NewAcknowledger(log.NewNopLogger(), runnerServer.URL, "test-token")
+	acknowledger.Acknowledge("reboot-reminder")
+
+	select {
+	case acknowledgement := <-acknowledgements:
+		require.Equal(t, "reboot-reminder", acknowledgement.NotificationID)
+	case <-time.After(10 * time.Second):
+		t.Fatal("timed out waiting for acknowledgement")
+	}
+}
@@ -14,7 +14,9 @@ type windowsNotifier struct {
 	interrupt    chan struct{}
 }
 
-func NewDesktopNotifier(logger log.Logger, iconFilepath string) *windowsNotifier {
+// NewDesktopNotifier returns a notifier for Windows. Toast activations are handled by the OS, so
+// clicks are not reported to the acknowledger -- repeating notifications repeat until they expire.
+func NewDesktopNotifier(logger log.Logger, iconFilepath string, _ *Acknowledger) *windowsNotifier {
 	return &windowsNotifier{
 		iconFilepath: iconFilepath,
 		logger:       log.With(logger, "component", "desktop_notifier"),
@@ -0,0 +1,196 @@
+// prompt asks the console user a question, on behalf of the control server, and reports their
+// answer back to the desktop runner.
+package prompt
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+	"github.com/kolide/launcher/pkg/authedclient"
+)
+
+// Represents a prompt received from control server. Like notifications, prompts are per-end user
+// device, but each console user answers separately.
+type Prompt struct {
+	ID         string   `json:"id"`
+	Title      string   `json:"title"`
+	Body       string   `json:"body"`
+	Choices    []string `json:"choices,omitempty"` // Button labels, defaults to a single "OK"
+	ValidUntil int64    `json:"valid_until"`       // timestamp
+}
+
+// MaxChoices is the most buttons a prompt may have. It's limited by macOS dialogs.
+const MaxChoices = 3
+
+// Prompt response statuses
+const (
+	StatusPending   = "pending"   // sent to the user's desktop process, not yet answered
+	StatusAnswered  = "answered"  // the user chose one of the choices
+	StatusDismissed = "dismissed" // the user closed the prompt without choosing
+	StatusExpired   = "expired"   // the prompt was not answered before it was no longer valid
+	StatusFailed    = "failed"    // the prompt could not be shown
+)
+
+// Response records a console user's answer to a prompt
+type Response struct {
+	PromptID    string    `json:"prompt_id"`
+	UID         string    `json:"uid"`
+	Title       string    `json:"title"`
+	Status      string    `json:"status"`
+	Choice      string    `json:"choice,omitempty"`
+	Error       string    `json:"error,omitempty"`
+	SentAt      time.Time `json:"sent_at"`
+	RespondedAt time.Time `json:"responded_at,omitempty"`
+}
+
+// errDismissed is returned by the platform dialogs when the user closes them without choosing
+var errDismissed = errors.New("prompt dismissed")
+
+// Validate checks that a prompt can be shown
+func (p Prompt) Validate() error {
+	if p.ID == "" {
+		return errors.New("prompt has no id")
+	}
+
+	if p.Title == "" || p.Body == "" {
+		return errors.New("prompt must have a title and body")
+	}
+
+	if time.Unix(p.ValidUntil, 0).Before(time.Now()) {
+		return errors.New("prompt has expired")
+	}
+
+	if len(p.Choices) > MaxChoices {
+		return fmt.Errorf("prompt has %d choices, at most %d are supported", len(p.Choices), MaxChoices)
+	}
+
+	for _, choice := range p.Choices {
+		if choice == "" {
+			return errors.New("prompt choices must not be blank")
+		}
+	}
+
+	return nil
+}
+
+func (p Prompt) choices() []string {
+	if len(p.Choices) == 0 {
+		return []string{"OK"}
+	}
+	return p.Choices
+}
+
+// Asker shows prompts to the console user, and posts their answers to the runner server.
+type Asker struct {
+	logger      log.Logger
+	responseUrl string
+	client      *http.Client
+	ask         func(context.Context, Prompt) (string, error)
+	inFlight    map[string]bool
+	lock        sync.Mutex
+}
+
+// NewAsker returns an Asker that reports answers to responseUrl, on the runner server
+func NewAsker(logger log.Logger, responseUrl string, runnerServerAuthToken string) *Asker {
+	client := authedclient.New(runnerServerAuthToken, 30*time.Second)
+
+	return &Asker{
+		logger:      log.With(logger, "component", "desktop_prompter"),
+		responseUrl: responseUrl,
+		client:      &client.Client,
+		ask:         ask,
+		inFlight:    make(map[string]bool),
+	}
+}
+
+// Ask shows the prompt to the user. It returns once the prompt is shown, and the answer is posted
+// to the runner server when the user responds, or the prompt expires.
+func (a *Asker) Ask(p Prompt) error {
+	if err := p.Validate(); err != nil {
+		return err
+	}
+
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	// The user is already looking at this one
+	if a.inFlight[p.ID] {
+		return nil
+	}
+	a.inFlight[p.ID] = true
+
+	go func() {
+		defer func() {
+			a.lock.Lock()
+			delete(a.inFlight, p.ID)
+			a.lock.Unlock()
+		}()
+
+		response := a.askUntilExpired(p)
+		if err := a.respond(response); err != nil {
+			level.Error(a.logger).Log(
+				"msg", "could not send prompt response to runner server",
+				"prompt_id", p.ID,
+				"err", err,
+			)
+		}
+	}()
+
+	return nil
+}
+
+func (a *Asker) askUntilExpired(p Prompt) Response {
+	ctx, cancel := context.WithDeadline(context.Background(), time.Unix(p.ValidUntil, 0))
+	defer cancel()
+
+	response := Response{
+		PromptID: p.ID,
+		Title:    p.Title,
+	}
+
+	choice, err := a.ask(ctx, p)
+	response.RespondedAt = time.Now().UTC()
+
+	switch {
+	case err == nil:
+		response.Status = StatusAnswered
+		response.Choice = choice
+	case ctx.Err() != nil:
+		response.Status = StatusExpired
+	case errors.Is(err, errDismissed):
+		response.Status = StatusDismissed
+	default:
+		level.Info(a.logger).Log("msg", "could not show prompt", "prompt_id", p.ID, "err", err)
+		response.Status = StatusFailed
+		response.Error = err.Error()
+	}
+
+	return response
+}
+
+func (a *Asker) respond(response Response) error {
+	body, err := json.Marshal(response)
+	if err != nil {
+		return fmt.Errorf("marshaling response: %w", err)
+	}
+
+	resp, err := a.client.Post(a.responseUrl, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+
+	return nil
+}
@@ -0,0 +1,41 @@
+//go:build darwin
+// +build darwin
+
+package prompt
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// askScript displays a dialog with the title, body and buttons given as arguments. Passing them
+// as arguments, rather than in the script, avoids having to escape them. The last button is
+// the default.
+var askScript = []string{
+	"-e", "on run argv",
+	"-e", "set buttonList to items 3 thru -1 of argv",
+	"-e", "display dialog (item 2 of argv) with title (item 1 of argv) buttons buttonList default button (count of buttonList)",
+	"-e", "return button returned of result",
+	"-e", "end run",
+}
+
+func ask(ctx context.Context, p Prompt) (string, error) {
+	args := append([]string{}, askScript...)
+	args = append(args, p.Title, p.Body)
+	args = append(args, p.choices()...)
+
+	out, err := exec.CommandContext(ctx, "/usr/bin/osascript", args...).Output()
+	if err != nil {
+		var exitErr *exec.ExitError
+		// -128 is "User canceled"
+		if errors.As(err, &exitErr) && strings.Contains(string(exitErr.Stderr), "(-128)") {
+			return "", errDismissed
+		}
+		return "", fmt.Errorf("running osascript: %w", err)
+	}
+
+	return strings.TrimSpace(string(out)), nil
+}
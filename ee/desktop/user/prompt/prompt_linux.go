@@ -0,0 +1,43 @@
+//go:build linux
+// +build linux
+
+package prompt
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// ask shows the prompt with zenity. With --switch, the dialog only has the extra buttons, and
+// zenity prints the label of the one clicked.
+func ask(ctx context.Context, p Prompt) (string, error) {
+	zenity, err := exec.LookPath("zenity")
+	if err != nil {
+		return "", fmt.Errorf("no dialog tool available: %w", err)
+	}
+
+	args := []string{"--question", "--switch", "--no-wrap", "--title", p.Title, "--text", p.Body}
+	for _, choice := range p.choices() {
+		args = append(args, "--extra-button", choice)
+	}
+
+	// zenity exits 1 when an extra button is clicked, so the output is what matters
+	out, err := exec.CommandContext(ctx, zenity, args...).Output()
+	var exitErr *exec.ExitError
+	if err != nil && !errors.As(err, &exitErr) {
+		return "", fmt.Errorf("running zenity: %w", err)
+	}
+	if ctx.Err() != nil {
+		return "", ctx.Err()
+	}
+
+	choice := strings.TrimSpace(string(out))
+	if choice == "" {
+		return "", errDismissed
+	}
+
+	return choice, nil
+}
@@ -0,0 +1,115 @@
+package prompt
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidate(t *testing.T) {
+	t.Parallel()
+
+	validUntil := time.Now().Add(time.Hour).Unix()
+
+	var tests = []struct {
+		name    string
+		prompt  Prompt
+		wantErr bool
+	}{
+		{name: "valid", prompt: Prompt{ID: "1", Title: "t", Body: "b", ValidUntil: validUntil}},
+		{name: "valid with choices", prompt: Prompt{ID: "1", Title: "t", Body: "b", Choices: []string{"No", "Yes"}, ValidUntil: validUntil}},
+		{name: "no id", prompt: Prompt{Title: "t", Body: "b", ValidUntil: validUntil}, wantErr: true},
+		{name: "no body", prompt: Prompt{ID: "1", Title: "t", ValidUntil: validUntil}, wantErr: true},
+		{name: "expired", prompt: Prompt{ID: "1", Title: "t", Body: "b", ValidUntil: time.Now().Add(-time.Hour).Unix()}, wantErr: true},
+		{name: "too many choices", prompt: Prompt{ID: "1", Title: "t", Body: "b", Choices: []string{"1", "2", "3", "4"}, ValidUntil: validUntil}, wantErr: true},
+		{name: "blank choice", prompt: Prompt{ID: "1", Title: "t", Body: "b", Choices: []string{"Yes", ""}, ValidUntil: validUntil}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			if tt.wantErr {
+				require.Error(t, tt.prompt.Validate())
+			} else {
+				require.NoError(t, tt.prompt.Validate())
+			}
+		})
+	}
+}
+
+func TestAsker(t *testing.T) {
+	t.Parallel()
+
+	var tests = []struct {
+		name           string
+		ask            func(context.Context, Prompt) (string, error)
+		expectedStatus string
+		expectedChoice string
+	}{
+		{
+			name:           "answered",
+			ask:            func(context.Context, Prompt) (string, error) { return "Yes", nil },
+			expectedStatus: StatusAnswered,
+			expectedChoice: "Yes",
+		},
+		{
+			name:           "dismissed",
+			ask:            func(context.Context, Prompt) (string, error) { return "", errDismissed },
+			expectedStatus: StatusDismissed,
+		},
+		{
+			name: "expired",
+			ask: func(ctx context.Context, _ Prompt) (string, error) {
+				<-ctx.Done()
+				return "", ctx.Err()
+			},
+			expectedStatus: StatusExpired,
+		},
+		{
+			name:           "failed",
+			ask:            func(context.Context, Prompt) (string, error) { return "", errors.New("no display") },
+			expectedStatus: StatusFailed,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			responses := make(chan Response, 1)
+			runnerServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				require.Equal(t, "Bearer test-token", r.Header.Get("Authorization"))
+
+				var response Response
+				require.NoError(t, json.NewDecoder(r.Body).Decode(&response))
+				responses <- response
+			}))
+			defer runnerServer.Close()
+
+			asker := NewAsker(log.NewNopLogger(), runnerServer.URL, "test-token")
+			asker.ask = tt.ask
+
+			p := Prompt{ID: "reboot", Title: "Reboot", Body: "Reboot now?", Choices: []string{"Later", "Yes"}, ValidUntil: time.Now().Add(2 * time.Second).Unix()}
+			require.NoError(t, asker.Ask(p))
+
+			select {
+			case response := <-responses:
+				require.Equal(t, "reboot", response.PromptID)
+				require.Equal(t, tt.expectedStatus, response.Status)
+				require.Equal(t, tt.expectedChoice, response.Choice)
+			case <-time.After(10 * time.Second):
+				t.Fatal("timed out waiting for prompt response")
+			}
+		})
+	}
+}
@@ -0,0 +1,91 @@
+//go:build windows
+// +build windows
+
+package prompt
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+	"text/template"
+	"unicode/utf16"
+)
+
+// askTemplate is a powershell script showing a form with a button per choice. It prints the
+// label of the button clicked, or exits 1 if the form is closed.
+var askTemplate = template.Must(template.New("ask").Funcs(template.FuncMap{"quote": psQuote}).Parse(`
+Add-Type -AssemblyName System.Windows.Forms
+Add-Type -AssemblyName System.Drawing
+$form = New-Object System.Windows.Forms.Form
+$form.Text = {{quote .Title}}
+$form.TopMost = $true
+$form.AutoSize = $true
+$form.AutoSizeMode = 'GrowAndShrink'
+$form.StartPosition = 'CenterScreen'
+$form.FormBorderStyle = 'FixedDialog'
+$form.MaximizeBox = $false
+$form.MinimizeBox = $false
+$layout = New-Object System.Windows.Forms.FlowLayoutPanel
+$layout.FlowDirection = 'TopDown'
+$layout.AutoSize = $true
+$layout.Padding = New-Object System.Windows.Forms.Padding(12)
+$label = New-Object System.Windows.Forms.Label
+$label.Text = {{quote .Body}}
+$label.AutoSize = $true
+$label.MaximumSize = New-Object System.Drawing.Size(420, 0)
+$layout.Controls.Add($label)
+$buttons = New-Object System.Windows.Forms.FlowLayoutPanel
+$buttons.AutoSize = $true
+{{- range .Choices }}
+$button = New-Object System.Windows.Forms.Button
+$button.Text = {{quote .}}
+$button.AutoSize = $true
+$button.Add_Click({ $form.Tag = $this.Text; $form.Close() })
+$buttons.Controls.Add($button)
+{{- end }}
+$layout.Controls.Add($buttons)
+$form.Controls.Add($layout)
+[void]$form.ShowDialog()
+if ($form.Tag) { Write-Output $form.Tag } else { exit 1 }
+`))
+
+func ask(ctx context.Context, p Prompt) (string, error) {
+	var script bytes.Buffer
+	if err := askTemplate.Execute(&script, struct {
+		Title, Body string
+		Choices     []string
+	}{p.Title, p.Body, p.choices()}); err != nil {
+		return "", fmt.Errorf("rendering prompt script: %w", err)
+	}
+
+	cmd := exec.CommandContext(ctx, "powershell.exe", "-NoProfile", "-NonInteractive", "-EncodedCommand", encodeCommand(script.String()))
+	out, err := cmd.Output()
+	if err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && exitErr.ExitCode() == 1 && ctx.Err() == nil {
+			return "", errDismissed
+		}
+		return "", fmt.Errorf("running powershell: %w", err)
+	}
+
+	return strings.TrimSpace(string(out)), nil
+}
+
+// psQuote returns s as a single quoted powershell string
+func psQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
+
+// encodeCommand encodes script for powershell's -EncodedCommand, which is base64 of UTF-16LE
+func encodeCommand(script string) string {
+	var buf bytes.Buffer
+	for _, r := range utf16.Encode([]rune(script)) {
+		binary.Write(&buf, binary.LittleEndian, r)
+	}
+	return base64.StdEncoding.EncodeToString(buf.Bytes())
+}
@@ -17,6 +17,7 @@ import (
 	"github.com/go-kit/kit/log"
 	"github.com/go-kit/kit/log/level"
 	"github.com/kolide/launcher/ee/desktop/user/notify"
+	"github.com/kolide/launcher/ee/desktop/user/prompt"
 	"github.com/kolide/launcher/pkg/backoff"
 )
 
@@ -24,6 +25,11 @@ type notificationSender interface {
 	SendNotification(notify.Notification) error
 }
 
+// promptAsker shows prompts to the user. Answers are sent to the runner server asynchronously.
+type promptAsker interface {
+	Ask(prompt.Prompt) error
+}
+
 // UserServer provides IPC for the root desktop runner to communicate with the user desktop processes.
 // It allows the runner process to send notficaitons and commands to the desktop processes.
 type UserServer struct {
@@ -34,16 +40,18 @@ type UserServer struct {
 	authToken        string
 	socketPath       string
 	notifier         notificationSender
+	asker            promptAsker
 	refreshListeners []func()
 }
 
-func New(logger log.Logger, authToken string, socketPath string, shutdownChan chan<- struct{}, notifier notificationSender) (*UserServer, error) {
+func New(logger log.Logger, authToken string, socketPath string, shutdownChan chan<- struct{}, notifier notificationSender, asker promptAsker) (*UserServer, error) {
 	userServer := &UserServer{
 		shutdownChan: shutdownChan,
 		authToken:    authToken,
 		logger:       log.With(logger, "component", "desktop_server"),
 		socketPath:   socketPath,
 		notifier:     notifier,
+		asker:        asker,
 	}
 
 	authedMux := http.NewServeMux()
@@ -51,6 +59,7 @@ func New(logger log.Logger, authToken string, socketPath string, shutdownChan ch
 	authedMux.HandleFunc("/ping", userServer.pingHandler)
 	authedMux.HandleFunc("/notification", userServer.notificationHandler)
 	authedMux.HandleFunc("/refresh", userServer.refreshHandler)
+	authedMux.HandleFunc("/prompt", userServer.promptHandler)
 
 	userServer.server = &http.Server{
 		Handler: userServer.authMiddleware(authedMux),
@@ -137,6 +146,34 @@ func (s *UserServer) notificationHandler(w http.ResponseWriter, req *http.Reques
 	w.WriteHeader(http.StatusOK)
 }
 
+// promptHandler shows a prompt to the user. It responds as soon as the prompt is shown; the answer
+// is sent to the runner server once the user responds.
+func (s *UserServer) promptHandler(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	defer req.Body.Close()
+
+	var promptToShow prompt.Prompt
+	if err := json.NewDecoder(req.Body).Decode(&promptToShow); err != nil {
+		level.Error(s.logger).Log("msg", "could not decode prompt request", "err", err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	if err := promptToShow.Validate(); err != nil {
+		level.Error(s.logger).Log("msg", "received invalid prompt", "prompt_id", promptToShow.ID, "err", err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	if err := s.asker.Ask(promptToShow); err != nil {
+		level.Error(s.logger).Log("msg", "could not show prompt", "prompt_id", promptToShow.ID, "err", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
 func (s *UserServer) refreshHandler(w http.ResponseWriter, req *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
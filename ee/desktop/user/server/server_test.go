@@ -116,7 +116,7 @@ func testHandler() http.Handler {
 func testServer(t *testing.T, authHeader, socketPath string, logBytes *bytes.Buffer) (*UserServer, chan struct{}) {
 	shutdownChan := make(chan struct{})
 
-	server, err := New(log.NewLogfmtLogger(logBytes), authHeader, socketPath, shutdownChan, nil)
+	server, err := New(log.NewLogfmtLogger(logBytes), authHeader, socketPath, shutdownChan, nil, nil)
 	require.NoError(t, err)
 	return server, shutdownChan
 }
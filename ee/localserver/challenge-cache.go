@@ -0,0 +1,119 @@
+package localserver
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+	"github.com/kolide/launcher/pkg/agent/types"
+)
+
+// challengeCacheMaxSize bounds the number of challenges remembered at once. Challenges are only
+// remembered until their timestamp is out of range, so this is only reached under a flood.
+const challengeCacheMaxSize = 4096
+
+// challengeCache remembers the challenges the local server has accepted, so that a captured
+// challenge can't be replayed while its timestamp is still valid. If a store is provided, seen
+// challenges are persisted there, so they also can't be replayed across a restart.
+type challengeCache struct {
+	logger log.Logger
+	store  types.KVStore
+
+	lock sync.Mutex
+	seen map[string]int64 // challenge hash to the timestamp after which it's no longer valid anyway
+}
+
+func newChallengeCache(logger log.Logger, store types.KVStore) *challengeCache {
+	c := &challengeCache{
+		logger: logger,
+		store:  store,
+		seen:   make(map[string]int64),
+	}
+
+	if store == nil {
+		return c
+	}
+
+	// Load unexpired challenges, and clear out the rest
+	now := time.Now().Unix()
+	expiredKeys := make([][]byte, 0)
+	if err := store.ForEach(func(k, v []byte) error {
+		expiry, err := strconv.ParseInt(string(v), 10, 64)
+		if err != nil || expiry < now {
+			expiredKeys = append(expiredKeys, k)
+			return nil
+		}
+
+		c.seen[string(k)] = expiry
+		return nil
+	}); err != nil {
+		level.Error(logger).Log("msg", "could not load seen challenges", "err", err)
+	}
+
+	if len(expiredKeys) > 0 {
+		if err := store.Delete(expiredKeys...); err != nil {
+			level.Error(logger).Log("msg", "could not delete expired challenges", "err", err)
+		}
+	}
+
+	return c
+}
+
+// checkAndAdd records the challenge with the given signed message and timestamp, returning an
+// error if it has already been seen.
+func (c *challengeCache) checkAndAdd(msg []byte, timestamp int64) error {
+	hash := sha256.Sum256(msg)
+	key := hex.EncodeToString(hash[:])
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if _, ok := c.seen[key]; ok {
+		return errors.New("challenge has already been used")
+	}
+
+	if len(c.seen) >= challengeCacheMaxSize {
+		c.pruneExpired()
+	}
+
+	// If we can't remember this challenge, we can't safely accept it
+	if len(c.seen) >= challengeCacheMaxSize {
+		return errors.New("too many outstanding challenges")
+	}
+
+	expiry := timestamp + timestampValidityRange
+	c.seen[key] = expiry
+
+	if c.store != nil {
+		if err := c.store.Set([]byte(key), []byte(strconv.FormatInt(expiry, 10))); err != nil {
+			level.Error(c.logger).Log("msg", "could not persist seen challenge", "err", err)
+		}
+	}
+
+	return nil
+}
+
+// pruneExpired forgets challenges whose timestamps are now out of range. c.lock must be held.
+func (c *challengeCache) pruneExpired() {
+	now := time.Now().Unix()
+	expiredKeys := make([][]byte, 0)
+	for key, expiry := range c.seen {
+		if expiry < now {
+			delete(c.seen, key)
+			expiredKeys = append(expiredKeys, []byte(key))
+		}
+	}
+
+	if c.store == nil || len(expiredKeys) == 0 {
+		return
+	}
+
+	if err := c.store.Delete(expiredKeys...); err != nil {
+		level.Error(c.logger).Log("msg", "could not delete expired challenges", "err", err)
+	}
+}
@@ -0,0 +1,64 @@
+package localserver
+
+import (
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/kolide/launcher/pkg/agent/storage"
+	storageci "github.com/kolide/launcher/pkg/agent/storage/ci"
+	"github.com/stretchr/testify/require"
+)
+
+func TestChallengeCache(t *testing.T) {
+	t.Parallel()
+
+	c := newChallengeCache(log.NewNopLogger(), nil)
+	now := time.Now().Unix()
+
+	require.NoError(t, c.checkAndAdd([]byte("one"), now))
+	require.NoError(t, c.checkAndAdd([]byte("two"), now))
+	require.Error(t, c.checkAndAdd([]byte("one"), now), "replayed challenge should be rejected")
+}
+
+func TestChallengeCache_Full(t *testing.T) {
+	t.Parallel()
+
+	c := newChallengeCache(log.NewNopLogger(), nil)
+
+	// Fill the cache with expired challenges; these should be pruned to make room
+	expired := time.Now().Unix() - 2*timestampValidityRange
+	for i := 0; i < challengeCacheMaxSize; i++ {
+		c.seen[string(rune(i))] = expired
+	}
+	require.NoError(t, c.checkAndAdd([]byte("new"), time.Now().Unix()))
+	require.Len(t, c.seen, 1)
+
+	// Fill the cache with valid challenges; new challenges can't be accepted
+	valid := time.Now().Unix() + timestampValidityRange
+	for i := 0; i < challengeCacheMaxSize; i++ {
+		c.seen[string(rune(i))] = valid
+	}
+	require.Error(t, c.checkAndAdd([]byte("another"), time.Now().Unix()))
+}
+
+func TestChallengeCache_Persistence(t *testing.T) {
+	t.Parallel()
+
+	store, err := storageci.NewStore(t, log.NewNopLogger(), storage.LocalServerChallengesStore.String())
+	require.NoError(t, err)
+
+	// An expired record, left over from a previous run
+	require.NoError(t, store.Set([]byte("expired"), []byte("1")))
+
+	c := newChallengeCache(log.NewNopLogger(), store)
+	require.NoError(t, c.checkAndAdd([]byte("one"), time.Now().Unix()))
+
+	// A new cache, as after a restart, should still reject the challenge
+	c = newChallengeCache(log.NewNopLogger(), store)
+	require.Error(t, c.checkAndAdd([]byte("one"), time.Now().Unix()))
+
+	expiredVal, err := store.Get([]byte("expired"))
+	require.NoError(t, err)
+	require.Nil(t, expiredVal, "expired records should be cleaned up")
+}
@@ -36,14 +36,18 @@ type v2CmdRequestType struct {
 type kryptoEcMiddleware struct {
 	localDbSigner, hardwareSigner crypto.Signer
 	counterParty                  ecdsa.PublicKey
+	seenChallenges                *challengeCache
+	limiter                       *endpointRateLimiter
 	logger                        log.Logger
 }
 
-func newKryptoEcMiddleware(logger log.Logger, localDbSigner, hardwareSigner crypto.Signer, counterParty ecdsa.PublicKey) *kryptoEcMiddleware {
+func newKryptoEcMiddleware(logger log.Logger, localDbSigner, hardwareSigner crypto.Signer, counterParty ecdsa.PublicKey, seenChallenges *challengeCache, limiter *endpointRateLimiter) *kryptoEcMiddleware {
 	return &kryptoEcMiddleware{
 		localDbSigner:  localDbSigner,
 		hardwareSigner: hardwareSigner,
 		counterParty:   counterParty,
+		seenChallenges: seenChallenges,
+		limiter:        limiter,
 		logger:         log.With(logger, "keytype", "ec"),
 	}
 }
@@ -92,6 +96,23 @@ func (e *kryptoEcMiddleware) Wrap(next http.Handler) http.Handler {
 			return
 		}
 
+		if !e.limiter.Allow(cmdReq.Path) {
+			span.SetStatus(codes.Error, "over rate limit")
+			level.Error(e.logger).Log("msg", "Over rate limit", "path", cmdReq.Path)
+			http.Error(w, http.StatusText(http.StatusTooManyRequests), http.StatusTooManyRequests)
+			return
+		}
+
+		// The timestamp check alone still allows a challenge to be replayed until it's out of range,
+		// so we also reject any challenge we've already seen. This happens after the rate limit
+		// check, so that a request turned away for being over the limit can be retried.
+		if err := e.seenChallenges.checkAndAdd(challengeBox.Msg, challengeBox.Timestamp()); err != nil {
+			traces.SetError(span, err)
+			level.Debug(e.logger).Log("msg", "rejecting challenge", "err", err)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
 		newReq := &http.Request{
 			Method: http.MethodPost,
 			URL: &url.URL{
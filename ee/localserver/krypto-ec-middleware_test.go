@@ -23,6 +23,7 @@ import (
 	"github.com/kolide/launcher/pkg/agent/keys"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"golang.org/x/time/rate"
 )
 
 func TestKryptoEcMiddleware(t *testing.T) {
@@ -157,7 +158,7 @@ func TestKryptoEcMiddleware(t *testing.T) {
 					var logBytes bytes.Buffer
 
 					// set up middlewares
-					kryptoEcMiddleware := newKryptoEcMiddleware(log.NewLogfmtLogger(&logBytes), tt.localDbKey, tt.hardwareKey, counterpartyKey.PublicKey)
+					kryptoEcMiddleware := newKryptoEcMiddleware(log.NewLogfmtLogger(&logBytes), tt.localDbKey, tt.hardwareKey, counterpartyKey.PublicKey, newChallengeCache(log.NewNopLogger(), nil), newEndpointRateLimiter())
 					require.NoError(t, err)
 
 					// give our middleware with the test handler to the determiner
@@ -253,3 +254,64 @@ func mustMarshal(t *testing.T, v interface{}) []byte {
 	require.NoError(t, err)
 	return b
 }
+
+func TestKryptoEcMiddleware_Replay(t *testing.T) {
+	t.Parallel()
+
+	counterpartyKey, err := echelper.GenerateEcdsaKey()
+	require.NoError(t, err)
+
+	cmdReq := mustMarshal(t, v2CmdRequestType{Path: "/id"})
+	challengeBytes, _, err := challenge.Generate(counterpartyKey, []byte(ulid.New()), []byte(ulid.New()), cmdReq)
+	require.NoError(t, err)
+	encodedChallenge := base64.StdEncoding.EncodeToString(challengeBytes)
+
+	var logBytes bytes.Buffer
+	kryptoEcMiddleware := newKryptoEcMiddleware(log.NewLogfmtLogger(&logBytes), ecdsaKey(t), nil, counterpartyKey.PublicKey, newChallengeCache(log.NewNopLogger(), nil), newEndpointRateLimiter())
+	h := kryptoEcMiddleware.Wrap(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(ulid.New()))
+	}))
+
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, makeGetRequest(t, encodedChallenge))
+	require.Equal(t, http.StatusOK, rr.Code)
+
+	// The same challenge must not be accepted again, whichever way it's sent
+	for _, req := range []*http.Request{makeGetRequest(t, encodedChallenge), makePostRequest(t, encodedChallenge)} {
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, req)
+		require.Equal(t, http.StatusUnauthorized, rr.Code)
+	}
+	require.Contains(t, logBytes.String(), "challenge has already been used")
+}
+
+func TestKryptoEcMiddleware_RateLimitedChallengeCanBeRetried(t *testing.T) {
+	t.Parallel()
+
+	counterpartyKey, err := echelper.GenerateEcdsaKey()
+	require.NoError(t, err)
+
+	cmdReq := mustMarshal(t, v2CmdRequestType{Path: "/id"})
+	challengeBytes, _, err := challenge.Generate(counterpartyKey, []byte(ulid.New()), []byte(ulid.New()), cmdReq)
+	require.NoError(t, err)
+	encodedChallenge := base64.StdEncoding.EncodeToString(challengeBytes)
+
+	exhaustedLimiter := &endpointRateLimiter{
+		limiters:       make(map[string]*rate.Limiter),
+		defaultLimiter: rate.NewLimiter(0, 0),
+	}
+	kryptoEcMiddleware := newKryptoEcMiddleware(log.NewNopLogger(), ecdsaKey(t), nil, counterpartyKey.PublicKey, newChallengeCache(log.NewNopLogger(), nil), exhaustedLimiter)
+	h := kryptoEcMiddleware.Wrap(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(ulid.New()))
+	}))
+
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, makeGetRequest(t, encodedChallenge))
+	require.Equal(t, http.StatusTooManyRequests, rr.Code)
+
+	// Once the limit allows it, the same challenge should be accepted
+	kryptoEcMiddleware.limiter = newEndpointRateLimiter()
+	rr = httptest.NewRecorder()
+	h.ServeHTTP(rr, makeGetRequest(t, encodedChallenge))
+	require.Equal(t, http.StatusOK, rr.Code)
+}
@@ -0,0 +1,225 @@
+package localserver
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os/user"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-kit/kit/log/level"
+	"github.com/kolide/kit/version"
+	"github.com/kolide/launcher/pkg/agent/flags/keys"
+	"github.com/kolide/launcher/pkg/traces"
+	"golang.org/x/exp/slices"
+)
+
+// The local API is a second listener, for other software on the same device. It listens on a
+// permission-restricted Unix socket (or, on Windows, a named pipe restricted to SYSTEM and
+// Administrators), and authorizes callers by their peer credentials rather than by krypto boxes.
+// It only serves the endpoints in localApiEndpoints.
+
+type peerCredentialsContextKey struct{}
+
+// peerCredentials identifies the process on the other end of a local API connection
+type peerCredentials struct {
+	uid  uint32
+	gids []uint32
+}
+
+// WithLocalApiPath sets the path of the local API socket or named pipe. If unset, the local API is disabled.
+func WithLocalApiPath(path string) LocalServerOption {
+	return func(s *localServer) {
+		s.localApiPath = path
+	}
+}
+
+func (ls *localServer) localApiEndpoints() map[string]http.Handler {
+	return map[string]http.Handler{
+		"/status": ls.localApiStatusHandler(),
+		"/query":  ls.requestQueryHandler(),
+	}
+}
+
+func (ls *localServer) newLocalApiServer() *http.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", http.NotFound)
+	for path, handler := range ls.localApiEndpoints() {
+		mux.Handle(path, handler)
+	}
+
+	// The local API has its own rate limits, so that its callers can't use up the local server's
+	ls.localApiLimiter = newEndpointRateLimiter()
+
+	return &http.Server{
+		Handler: ls.requestLoggingHandler(ls.localApiAuthHandler(ls.rateLimitHandler(ls.localApiLimiter, mux))),
+		ConnContext: func(ctx context.Context, c net.Conn) context.Context {
+			creds, err := peerCredentialsFromConn(c)
+			if err != nil {
+				level.Debug(ls.logger).Log("msg", "could not get local api peer credentials", "err", err)
+				return ctx
+			}
+			return context.WithValue(ctx, peerCredentialsContextKey{}, creds)
+		},
+		ReadTimeout:       500 * time.Millisecond,
+		ReadHeaderTimeout: 50 * time.Millisecond,
+		WriteTimeout:      30 * time.Second,
+		MaxHeaderBytes:    1024,
+	}
+}
+
+// serveLocalApi runs the local API until it's shut down
+func (ls *localServer) serveLocalApi() error {
+	l, err := localApiListener(ls.localApiPath, ls.localApiAllowedGids())
+	if err != nil {
+		return fmt.Errorf("listening on %s: %w", ls.localApiPath, err)
+	}
+
+	level.Info(ls.logger).Log("msg", "serving local api", "path", ls.localApiPath)
+	return ls.localApiSrv.Serve(l)
+}
+
+// FlagsChanged updates the local API socket's permissions when the allowed groups change, so that
+// members of newly allowed groups can connect without a restart
+func (ls *localServer) FlagsChanged(flagKeys ...keys.FlagKey) {
+	if !slices.Contains(flagKeys, keys.LocalApiAllowedGroups) || ls.localApiSrv == nil {
+		return
+	}
+
+	if err := setLocalApiSocketPermissions(ls.localApiPath, ls.localApiAllowedGids()); err != nil {
+		level.Error(ls.logger).Log("msg", "could not update local api permissions", "path", ls.localApiPath, "err", err)
+	}
+}
+
+// localApiAuthHandler only lets through callers that are root, or a member of an allowed group
+func (ls *localServer) localApiAuthHandler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !localApiRequiresPeerCredentials {
+			// Access is restricted by the named pipe's security descriptor instead
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		creds, ok := r.Context().Value(peerCredentialsContextKey{}).(peerCredentials)
+		if !ok {
+			http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+			return
+		}
+
+		if err := authorizePeer(creds, ls.localApiAllowedGids()); err != nil {
+			level.Info(ls.logger).Log("msg", "rejecting local api request", "uid", creds.uid, "err", err)
+			http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// authorizePeer allows root, and members of any of the allowed groups
+func authorizePeer(creds peerCredentials, allowedGids []uint32) error {
+	if creds.uid == 0 {
+		return nil
+	}
+
+	for _, gid := range creds.gids {
+		for _, allowedGid := range allowedGids {
+			if gid == allowedGid {
+				return nil
+			}
+		}
+	}
+
+	return errors.New("peer is not root, or a member of an allowed group")
+}
+
+// localApiAllowedGids resolves the allowed groups flag, which may contain group names or gids
+func (ls *localServer) localApiAllowedGids() []uint32 {
+	gids := make([]uint32, 0)
+	for _, group := range strings.Split(ls.knapsack.LocalApiAllowedGroups(), ",") {
+		group = strings.TrimSpace(group)
+		if group == "" {
+			continue
+		}
+
+		if gid, err := strconv.ParseUint(group, 10, 32); err == nil {
+			gids = append(gids, uint32(gid))
+			continue
+		}
+
+		g, err := user.LookupGroup(group)
+		if err != nil {
+			level.Debug(ls.logger).Log("msg", "could not look up local api allowed group", "group", group, "err", err)
+			continue
+		}
+
+		gid, err := strconv.ParseUint(g.Gid, 10, 32)
+		if err != nil {
+			continue
+		}
+		gids = append(gids, uint32(gid))
+	}
+
+	return gids
+}
+
+// groupIdsForUid returns the uid's primary and supplementary groups
+func groupIdsForUid(uid uint32) []uint32 {
+	u, err := user.LookupId(strconv.FormatUint(uint64(uid), 10))
+	if err != nil {
+		return nil
+	}
+
+	groupIds, err := u.GroupIds()
+	if err != nil {
+		groupIds = []string{u.Gid}
+	}
+
+	gids := make([]uint32, 0, len(groupIds))
+	for _, groupId := range groupIds {
+		if gid, err := strconv.ParseUint(groupId, 10, 32); err == nil {
+			gids = append(gids, uint32(gid))
+		}
+	}
+
+	return gids
+}
+
+type localApiStatusResponse struct {
+	LauncherVersion  string `json:"launcher_version"`
+	LauncherRevision string `json:"launcher_revision"`
+	GoVersion        string `json:"go_version"`
+	KolideServer     string `json:"kolide_server"`
+	identifiers
+	Timestamp time.Time `json:"timestamp"`
+}
+
+func (ls *localServer) localApiStatusHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, span := traces.StartSpan(r.Context(), "path", r.URL.Path)
+		defer span.End()
+
+		v := version.Version()
+		response := localApiStatusResponse{
+			LauncherVersion:  v.Version,
+			LauncherRevision: v.Revision,
+			GoVersion:        v.GoVersion,
+			KolideServer:     ls.kolideServer,
+			identifiers:      ls.identifiers,
+			Timestamp:        time.Now(),
+		}
+
+		jsonBytes, err := json.Marshal(response)
+		if err != nil {
+			sendClientError(w, span, fmt.Errorf("error marshalling status to json: %w", err))
+			return
+		}
+
+		w.Write(jsonBytes)
+	})
+}
@@ -0,0 +1,35 @@
+//go:build darwin
+// +build darwin
+
+package localserver
+
+import (
+	"fmt"
+	"net"
+
+	"golang.org/x/sys/unix"
+)
+
+func peerCredentialsFromConn(c net.Conn) (peerCredentials, error) {
+	var xucred *unix.Xucred
+	var credErr error
+	if err := controlUnixConn(c, func(fd int) {
+		xucred, credErr = unix.GetsockoptXucred(fd, unix.SOL_LOCAL, unix.LOCAL_PEERCRED)
+	}); err != nil {
+		return peerCredentials{}, err
+	}
+
+	if credErr != nil {
+		return peerCredentials{}, fmt.Errorf("getting peer credentials: %w", credErr)
+	}
+
+	creds := peerCredentials{
+		uid:  xucred.Uid,
+		gids: make([]uint32, 0, xucred.Ngroups),
+	}
+	for i := 0; i < int(xucred.Ngroups) && i < len(xucred.Groups); i++ {
+		creds.gids = append(creds.gids, xucred.Groups[i])
+	}
+
+	return creds, nil
+}
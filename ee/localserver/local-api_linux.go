@@ -0,0 +1,30 @@
+//go:build linux
+// +build linux
+
+package localserver
+
+import (
+	"fmt"
+	"net"
+
+	"golang.org/x/sys/unix"
+)
+
+func peerCredentialsFromConn(c net.Conn) (peerCredentials, error) {
+	var ucred *unix.Ucred
+	var credErr error
+	if err := controlUnixConn(c, func(fd int) {
+		ucred, credErr = unix.GetsockoptUcred(fd, unix.SOL_SOCKET, unix.SO_PEERCRED)
+	}); err != nil {
+		return peerCredentials{}, err
+	}
+
+	if credErr != nil {
+		return peerCredentials{}, fmt.Errorf("getting peer credentials: %w", credErr)
+	}
+
+	return peerCredentials{
+		uid:  ucred.Uid,
+		gids: append(groupIdsForUid(ucred.Uid), ucred.Gid),
+	}, nil
+}
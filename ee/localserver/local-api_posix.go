@@ -0,0 +1,74 @@
+//go:build darwin || linux
+// +build darwin linux
+
+package localserver
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+)
+
+const localApiRequiresPeerCredentials = true
+
+// DefaultLocalApiPath returns the path of the local API socket, in the root directory
+func DefaultLocalApiPath(rootDirectory string) string {
+	return filepath.Join(rootDirectory, "launcher-local-api.sock")
+}
+
+// localApiListener listens on a Unix socket that only root can connect to, or anyone once groups are
+// allowed. Callers are then authorized by their peer credentials.
+func localApiListener(socketPath string, allowedGids []uint32) (net.Listener, error) {
+	// Clean up a socket left behind by a previous run
+	if err := os.Remove(socketPath); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("removing existing socket: %w", err)
+	}
+
+	l, err := net.Listen("unix", socketPath)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := setLocalApiSocketPermissions(socketPath, allowedGids); err != nil {
+		l.Close()
+		return nil, err
+	}
+
+	return l, nil
+}
+
+// setLocalApiSocketPermissions lets only root connect to the socket when no groups are allowed. A
+// socket can only be owned by one group, so once any are allowed it's opened up to everyone, and
+// membership in any of the allowed groups is enforced by authorizePeer instead. It's reapplied
+// whenever the allowed groups change.
+func setLocalApiSocketPermissions(socketPath string, allowedGids []uint32) error {
+	var mode os.FileMode = 0600
+	if len(allowedGids) > 0 {
+		mode = 0666
+	}
+
+	if err := os.Chmod(socketPath, mode); err != nil {
+		return fmt.Errorf("setting socket permissions: %w", err)
+	}
+
+	return nil
+}
+
+// controlUnixConn runs fn on the file descriptor underlying a Unix socket connection
+func controlUnixConn(c net.Conn, fn func(fd int)) error {
+	unixConn, ok := c.(*net.UnixConn)
+	if !ok {
+		return fmt.Errorf("expected unix connection, got %T", c)
+	}
+
+	rawConn, err := unixConn.SyscallConn()
+	if err != nil {
+		return fmt.Errorf("getting raw connection: %w", err)
+	}
+
+	return rawConn.Control(func(fd uintptr) {
+		fn(int(fd))
+	})
+}
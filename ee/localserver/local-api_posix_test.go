@@ -0,0 +1,150 @@
+//go:build darwin || linux
+// +build darwin linux
+
+package localserver
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"os"
+	"os/user"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/kolide/launcher/pkg/agent/flags/keys"
+	"github.com/kolide/launcher/pkg/agent/storage"
+	storageci "github.com/kolide/launcher/pkg/agent/storage/ci"
+	typesMocks "github.com/kolide/launcher/pkg/agent/types/mocks"
+	"github.com/kolide/launcher/pkg/osquery"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLocalApi(t *testing.T) {
+	t.Parallel()
+
+	currentUser, err := user.Current()
+	require.NoError(t, err)
+
+	// Keep the socket path short, since there's a length limit on Unix socket paths
+	socketDir, err := os.MkdirTemp("/tmp", "lapi")
+	require.NoError(t, err)
+	t.Cleanup(func() { os.RemoveAll(socketDir) })
+	socketPath := filepath.Join(socketDir, "test.sock")
+
+	mockKnapsack := typesMocks.NewKnapsack(t)
+	mockKnapsack.On("ConfigStore").Return(storageci.NewStore(t, log.NewNopLogger(), storage.ConfigStore.String()))
+	mockKnapsack.On("KolideServerURL").Return("localhost")
+	mockKnapsack.On("LocalApiAllowedGroups").Return("12345," + currentUser.Gid)
+	mockKnapsack.On("RegisterChangeObserver", mock.Anything, keys.LocalApiAllowedGroups)
+	require.NoError(t, osquery.SetupLauncherKeys(mockKnapsack.ConfigStore()))
+
+	var logBytes bytes.Buffer
+	server, err := New(mockKnapsack, WithLogger(log.NewLogfmtLogger(&logBytes)), WithLocalApiPath(socketPath))
+	require.NoError(t, err)
+
+	go func() {
+		if err := server.serveLocalApi(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			t.Errorf("serving local api: %v", err)
+		}
+	}()
+	t.Cleanup(func() { server.localApiSrv.Close() })
+
+	client := http.Client{
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				var d net.Dialer
+				return d.DialContext(ctx, "unix", socketPath)
+			},
+		},
+		Timeout: 5 * time.Second,
+	}
+
+	// Wait for the socket to be ready, and opened up for the allowed groups
+	require.Eventually(t, func() bool {
+		info, err := os.Stat(socketPath)
+		return err == nil && info.Mode().Perm() == os.FileMode(0666)
+	}, 5*time.Second, 50*time.Millisecond)
+
+	resp, err := client.Get("http://local-api/status")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var status localApiStatusResponse
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&status))
+	require.Equal(t, "localhost", status.KolideServer)
+
+	// Endpoints that aren't on the local API's allow-list aren't served
+	resp, err = client.Get("http://local-api/id")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusNotFound, resp.StatusCode)
+
+	// The local API has its own rate limits, separate from the local server's
+	require.NotSame(t, server.limiter, server.localApiLimiter)
+}
+
+func TestLocalApi_peerCredentials(t *testing.T) {
+	t.Parallel()
+
+	socketDir, err := os.MkdirTemp("/tmp", "lapi")
+	require.NoError(t, err)
+	t.Cleanup(func() { os.RemoveAll(socketDir) })
+	socketPath := filepath.Join(socketDir, "test.sock")
+
+	l, err := localApiListener(socketPath, nil)
+	require.NoError(t, err)
+	defer l.Close()
+
+	info, err := os.Stat(socketPath)
+	require.NoError(t, err)
+	require.Equal(t, os.FileMode(0600), info.Mode().Perm(), "only root may connect with no allowed groups")
+
+	// Allowing groups opens the socket up, without listening again, and leaves authorization to the
+	// peer credentials -- so members of every allowed group can connect, not just the first
+	require.NoError(t, setLocalApiSocketPermissions(socketPath, []uint32{12345, uint32(os.Getgid())}))
+	info, err = os.Stat(socketPath)
+	require.NoError(t, err)
+	require.Equal(t, os.FileMode(0666), info.Mode().Perm())
+
+	// Removing the allowed groups locks it down again
+	require.NoError(t, setLocalApiSocketPermissions(socketPath, nil))
+	info, err = os.Stat(socketPath)
+	require.NoError(t, err)
+	require.Equal(t, os.FileMode(0600), info.Mode().Perm())
+	require.NoError(t, setLocalApiSocketPermissions(socketPath, []uint32{12345, uint32(os.Getgid())}))
+
+	credsChan := make(chan peerCredentials, 1)
+	go func() {
+		c, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer c.Close()
+
+		creds, err := peerCredentialsFromConn(c)
+		if err != nil {
+			t.Errorf("getting peer credentials: %v", err)
+		}
+		credsChan <- creds
+	}()
+
+	c, err := net.Dial("unix", socketPath)
+	require.NoError(t, err)
+	defer c.Close()
+
+	select {
+	case creds := <-credsChan:
+		require.Equal(t, uint32(os.Getuid()), creds.uid)
+		require.Contains(t, creds.gids, uint32(os.Getgid()))
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for peer credentials")
+	}
+}
@@ -0,0 +1,26 @@
+package localserver
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_authorizePeer(t *testing.T) {
+	t.Parallel()
+
+	require.NoError(t, authorizePeer(peerCredentials{uid: 0}, nil), "root is always allowed")
+	require.NoError(t, authorizePeer(peerCredentials{uid: 501, gids: []uint32{20, 80}}, []uint32{80}))
+	require.Error(t, authorizePeer(peerCredentials{uid: 501, gids: []uint32{20}}, []uint32{80}))
+	require.NoError(t, authorizePeer(peerCredentials{uid: 501, gids: []uint32{20, 90}}, []uint32{80, 90}), "any allowed group is enough")
+	require.Error(t, authorizePeer(peerCredentials{uid: 501, gids: []uint32{20}}, nil))
+}
+
+func TestDefaultLocalApiPath(t *testing.T) {
+	t.Parallel()
+
+	// Launchers with different root directories must not share a local API
+	require.NotEqual(t, DefaultLocalApiPath(filepath.Join("some", "launcher-one")), DefaultLocalApiPath(filepath.Join("some", "launcher-two")))
+	require.Equal(t, DefaultLocalApiPath(filepath.Join("some", "launcher-one")), DefaultLocalApiPath(filepath.Join("some", "launcher-one")))
+}
@@ -0,0 +1,46 @@
+//go:build windows
+// +build windows
+
+package localserver
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"net"
+	"path/filepath"
+	"strings"
+
+	"github.com/Microsoft/go-winio"
+)
+
+// Named pipes don't give us peer credentials to check, so access is restricted by the pipe's
+// security descriptor instead: only SYSTEM and Administrators may connect.
+const (
+	localApiRequiresPeerCredentials = false
+	localApiSecurityDescriptor      = "D:P(A;;GA;;;SY)(A;;GA;;;BA)"
+)
+
+// DefaultLocalApiPath returns the name of the local API named pipe. Named pipes all share one
+// namespace, so the name includes a hash of the root directory, to keep launchers with different
+// identifiers apart.
+func DefaultLocalApiPath(rootDirectory string) string {
+	rootHash := sha256.Sum256([]byte(strings.ToLower(filepath.Clean(rootDirectory))))
+	return fmt.Sprintf(`\\.\pipe\kolide-launcher-local-api-%x`, rootHash[:6])
+}
+
+func localApiListener(pipeName string, _ []uint32) (net.Listener, error) {
+	return winio.ListenPipe(pipeName, &winio.PipeConfig{
+		SecurityDescriptor: localApiSecurityDescriptor,
+	})
+}
+
+// setLocalApiSocketPermissions is a no-op, since the named pipe's security descriptor doesn't depend
+// on the allowed groups
+func setLocalApiSocketPermissions(_ string, _ []uint32) error {
+	return nil
+}
+
+func peerCredentialsFromConn(_ net.Conn) (peerCredentials, error) {
+	return peerCredentials{}, errors.New("peer credentials are not available for named pipes")
+}
@@ -0,0 +1,53 @@
+package localserver
+
+import (
+	"net/url"
+	"strings"
+)
+
+// parseAllowedOrigins parses a comma-separated list of web origins
+func parseAllowedOrigins(raw string) []string {
+	origins := make([]string, 0)
+	for _, origin := range strings.Split(raw, ",") {
+		origin = strings.TrimSpace(origin)
+		if origin == "" {
+			continue
+		}
+		origins = append(origins, strings.ToLower(strings.TrimSuffix(origin, "/")))
+	}
+
+	return origins
+}
+
+// originAllowed reports whether origin matches any of the allowed origins. An allowed origin may
+// use a wildcard for its subdomain, e.g. https://*.example.com, which doesn't match the bare domain.
+func originAllowed(origin string, allowedOrigins []string) bool {
+	parsedOrigin, err := url.Parse(strings.ToLower(origin))
+	if err != nil || parsedOrigin.Scheme == "" || parsedOrigin.Host == "" {
+		return false
+	}
+
+	for _, allowed := range allowedOrigins {
+		parsedAllowed, err := url.Parse(allowed)
+		if err != nil {
+			continue
+		}
+
+		if parsedOrigin.Scheme != parsedAllowed.Scheme {
+			continue
+		}
+
+		if wildcardDomain, ok := strings.CutPrefix(parsedAllowed.Host, "*."); ok {
+			if strings.HasSuffix(parsedOrigin.Host, "."+wildcardDomain) {
+				return true
+			}
+			continue
+		}
+
+		if parsedOrigin.Host == parsedAllowed.Host {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,36 @@
+package localserver
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_originAllowed(t *testing.T) {
+	t.Parallel()
+
+	allowed := parseAllowedOrigins(" https://*.kolide.com, http://localhost:3000/ ,")
+
+	var tests = []struct {
+		origin  string
+		allowed bool
+	}{
+		{origin: "https://app.kolide.com", allowed: true},
+		{origin: "https://App.Kolide.com", allowed: true},
+		{origin: "https://a.b.kolide.com", allowed: true},
+		{origin: "http://localhost:3000", allowed: true},
+		{origin: "https://kolide.com", allowed: false},
+		{origin: "http://app.kolide.com", allowed: false},
+		{origin: "https://app.kolide.com.example.com", allowed: false},
+		{origin: "https://evilkolide.com", allowed: false},
+		{origin: "http://localhost:3001", allowed: false},
+		{origin: "null", allowed: false},
+		{origin: "", allowed: false},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.allowed, originAllowed(tt.origin, allowed), tt.origin)
+	}
+
+	assert.False(t, originAllowed("https://app.kolide.com", parseAllowedOrigins("")), "nothing is allowed by an empty list")
+}
@@ -0,0 +1,214 @@
+package localserver
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kolide/launcher/pkg/agent/types"
+)
+
+// catalogQuery is a named query in the catalog pushed by the control server. Its SQL may contain
+// :name placeholders for each of its parameters, which are bound to typed, validated values from the
+// request before the query is run. Results are cached for TTL seconds, if set.
+//
+// For example:
+//
+//	{"query": "select * from users where username = :username", "parameters": {"username": "string"}, "ttl": 60}
+type catalogQuery struct {
+	Query      string                   `json:"query"`
+	Parameters map[string]parameterType `json:"parameters,omitempty"`
+	TTL        int64                    `json:"ttl,omitempty"`
+}
+
+type parameterType string
+
+const (
+	parameterTypeString  parameterType = "string"
+	parameterTypeInteger parameterType = "integer"
+	parameterTypeBoolean parameterType = "boolean"
+)
+
+var parameterPlaceholder = regexp.MustCompile(`:([A-Za-z_][A-Za-z0-9_]*)`)
+
+// scheduledQueryLookup finds osquery scheduled queries by name, for the scheduledquery endpoint
+var scheduledQueryLookup = catalogQuery{
+	Query:      "select name, query from osquery_schedule where name like :name",
+	Parameters: map[string]parameterType{"name": parameterTypeString},
+}
+
+// lookupCatalogQuery finds the named query in the catalog
+func lookupCatalogQuery(store types.Getter, name string) (catalogQuery, error) {
+	var q catalogQuery
+
+	if store == nil {
+		return q, errors.New("no query catalog")
+	}
+
+	raw, err := store.Get([]byte(name))
+	if err != nil {
+		return q, fmt.Errorf("getting query from catalog: %w", err)
+	}
+
+	if raw == nil {
+		return q, fmt.Errorf("no query named %s in catalog", name)
+	}
+
+	if err := json.Unmarshal(raw, &q); err != nil {
+		return q, fmt.Errorf("unmarshalling catalog query %s: %w", name, err)
+	}
+
+	if q.Query == "" {
+		return q, fmt.Errorf("catalog query %s has no sql", name)
+	}
+
+	for paramName, paramType := range q.Parameters {
+		switch paramType {
+		case parameterTypeString, parameterTypeInteger, parameterTypeBoolean:
+		default:
+			return q, fmt.Errorf("catalog query %s parameter %s has unknown type %s", name, paramName, paramType)
+		}
+	}
+
+	return q, nil
+}
+
+// bind returns the query's SQL with its placeholders replaced by the given parameters. Every declared
+// parameter must be provided, and no others.
+func (q catalogQuery) bind(params map[string]any) (string, error) {
+	literals := make(map[string]string, len(q.Parameters))
+	for paramName, paramType := range q.Parameters {
+		val, ok := params[paramName]
+		if !ok || val == nil {
+			return "", fmt.Errorf("missing parameter %s", paramName)
+		}
+
+		literal, err := paramType.literal(val)
+		if err != nil {
+			return "", fmt.Errorf("parameter %s: %w", paramName, err)
+		}
+		literals[paramName] = literal
+	}
+
+	for paramName := range params {
+		if _, ok := q.Parameters[paramName]; !ok {
+			return "", fmt.Errorf("unexpected parameter %s", paramName)
+		}
+	}
+
+	return parameterPlaceholder.ReplaceAllStringFunc(q.Query, func(placeholder string) string {
+		if literal, ok := literals[placeholder[1:]]; ok {
+			return literal
+		}
+		return placeholder
+	}), nil
+}
+
+// literal validates val and formats it as a SQL literal of this type
+func (t parameterType) literal(val any) (string, error) {
+	var s string
+	switch v := val.(type) {
+	case string:
+		s = v
+	case json.Number:
+		s = v.String()
+	case float64:
+		// Numbers decoded without UseNumber arrive as float64; format them without an exponent so
+		// large integers still parse, and leave any fractional part in place to be rejected below.
+		s = strconv.FormatFloat(v, 'f', -1, 64)
+	default:
+		s = fmt.Sprint(val)
+	}
+
+	switch t {
+	case parameterTypeString:
+		if strings.ContainsRune(s, 0) {
+			return "", errors.New("string contains a NUL byte")
+		}
+		return "'" + strings.ReplaceAll(s, "'", "''") + "'", nil
+	case parameterTypeInteger:
+		i, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			return "", fmt.Errorf("not an integer: %w", err)
+		}
+		return strconv.FormatInt(i, 10), nil
+	case parameterTypeBoolean:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return "", fmt.Errorf("not a boolean: %w", err)
+		}
+		if b {
+			return "1", nil
+		}
+		return "0", nil
+	}
+
+	return "", fmt.Errorf("unknown parameter type %s", t)
+}
+
+// queryResultCacheMaxSize bounds the number of cached results, since each set of parameters
+// is cached separately
+const queryResultCacheMaxSize = 256
+
+type cachedQueryResult struct {
+	results []map[string]string
+	expires time.Time
+}
+
+// queryResultCache holds catalog query results until their TTL passes
+type queryResultCache struct {
+	lock    sync.Mutex
+	results map[string]cachedQueryResult
+}
+
+func newQueryResultCache() *queryResultCache {
+	return &queryResultCache{
+		results: make(map[string]cachedQueryResult),
+	}
+}
+
+func (c *queryResultCache) get(key string) ([]map[string]string, bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	cached, ok := c.results[key]
+	if !ok {
+		return nil, false
+	}
+
+	if time.Now().After(cached.expires) {
+		delete(c.results, key)
+		return nil, false
+	}
+
+	return cached.results, true
+}
+
+func (c *queryResultCache) set(key string, results []map[string]string, ttl time.Duration) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if len(c.results) >= queryResultCacheMaxSize {
+		now := time.Now()
+		for k, cached := range c.results {
+			if now.After(cached.expires) {
+				delete(c.results, k)
+			}
+		}
+	}
+
+	// Still full, so skip caching rather than grow without bound
+	if len(c.results) >= queryResultCacheMaxSize {
+		return
+	}
+
+	c.results[key] = cachedQueryResult{
+		results: results,
+		expires: time.Now().Add(ttl),
+	}
+}
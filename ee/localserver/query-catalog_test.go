@@ -0,0 +1,100 @@
+package localserver
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/kolide/launcher/pkg/agent/storage"
+	storageci "github.com/kolide/launcher/pkg/agent/storage/ci"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_catalogQuery_bind(t *testing.T) {
+	t.Parallel()
+
+	q := catalogQuery{
+		Query: "select * from t where s = :s and i = :i and b = :b and time = '12:30' and other = :other",
+		Parameters: map[string]parameterType{
+			"s": parameterTypeString,
+			"i": parameterTypeInteger,
+			"b": parameterTypeBoolean,
+		},
+	}
+
+	bound, err := q.bind(map[string]any{"s": "x' or '1'='1", "i": float64(42), "b": true})
+	require.NoError(t, err)
+	require.Equal(t, "select * from t where s = 'x'' or ''1''=''1' and i = 42 and b = 1 and time = '12:30' and other = :other", bound)
+
+	_, err = q.bind(map[string]any{"s": "x", "i": "1"})
+	require.ErrorContains(t, err, "missing parameter b")
+
+	_, err = q.bind(map[string]any{"s": "x", "i": "1", "b": "false", "extra": "1"})
+	require.ErrorContains(t, err, "unexpected parameter extra")
+
+	_, err = q.bind(map[string]any{"s": "x", "i": "1 or 1=1", "b": "false"})
+	require.ErrorContains(t, err, "not an integer")
+
+	bound, err = q.bind(map[string]any{"s": "x", "i": float64(1000000), "b": "false"})
+	require.NoError(t, err)
+	require.Contains(t, bound, "i = 1000000 ")
+
+	bound, err = q.bind(map[string]any{"s": "x", "i": json.Number("9007199254740993"), "b": "false"})
+	require.NoError(t, err)
+	require.Contains(t, bound, "i = 9007199254740993 ")
+
+	_, err = q.bind(map[string]any{"s": "x", "i": float64(1.5), "b": "false"})
+	require.ErrorContains(t, err, "not an integer")
+
+	_, err = q.bind(map[string]any{"s": "x", "i": json.Number("1.5"), "b": "false"})
+	require.ErrorContains(t, err, "not an integer")
+
+	_, err = q.bind(map[string]any{"s": "x", "i": "1", "b": "maybe"})
+	require.ErrorContains(t, err, "not a boolean")
+}
+
+func Test_lookupCatalogQuery(t *testing.T) {
+	t.Parallel()
+
+	store, err := storageci.NewStore(t, log.NewNopLogger(), storage.LocalServerQueriesStore.String())
+	require.NoError(t, err)
+
+	_, err = store.Update(map[string]string{
+		"good":     `{"query": "select 1 where 1 = :one", "parameters": {"one": "integer"}, "ttl": 30}`,
+		"bad_type": `{"query": "select 1 where 1 = :one", "parameters": {"one": "sql"}}`,
+		"no_sql":   `{"parameters": {}}`,
+		"bad_json": `select 1`,
+	})
+	require.NoError(t, err)
+
+	q, err := lookupCatalogQuery(store, "good")
+	require.NoError(t, err)
+	require.Equal(t, int64(30), q.TTL)
+	require.Equal(t, parameterTypeInteger, q.Parameters["one"])
+
+	for _, name := range []string{"bad_type", "no_sql", "bad_json", "missing"} {
+		_, err := lookupCatalogQuery(store, name)
+		require.Error(t, err, name)
+	}
+}
+
+func Test_queryResultCache(t *testing.T) {
+	t.Parallel()
+
+	c := newQueryResultCache()
+	results := []map[string]string{{"a": "b"}}
+
+	c.set("fresh", results, time.Minute)
+	c.set("stale", results, -time.Second)
+
+	cached, ok := c.get("fresh")
+	require.True(t, ok)
+	require.Equal(t, results, cached)
+
+	_, ok = c.get("stale")
+	require.False(t, ok)
+
+	_, ok = c.get("missing")
+	require.False(t, ok)
+}
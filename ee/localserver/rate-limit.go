@@ -0,0 +1,59 @@
+package localserver
+
+import (
+	"strings"
+
+	"golang.org/x/time/rate"
+)
+
+const (
+	defaultRateLimit = 5
+	defaultRateBurst = 10
+)
+
+type rateLimit struct {
+	limit rate.Limit
+	burst int
+}
+
+// endpointRateLimits are the rate limits for each endpoint. Endpoints that aren't listed share
+// a single limiter, with the default limits.
+var endpointRateLimits = map[string]rateLimit{
+	"/v0/cmd":            {limit: defaultRateLimit, burst: defaultRateBurst},
+	"/v1/cmd":            {limit: defaultRateLimit, burst: defaultRateBurst},
+	"/acceleratecontrol": {limit: 1, burst: 2},
+	"/attestation":       {limit: 1, burst: 5},
+	"/id":                {limit: defaultRateLimit, burst: defaultRateBurst},
+	"/query":             {limit: 2, burst: 5},
+	"/scheduledquery":    {limit: 2, burst: 5},
+}
+
+// endpointRateLimiter rate limits each endpoint separately, so that a burst of requests
+// to one endpoint doesn't starve the others.
+type endpointRateLimiter struct {
+	limiters       map[string]*rate.Limiter
+	defaultLimiter *rate.Limiter
+}
+
+func newEndpointRateLimiter() *endpointRateLimiter {
+	e := &endpointRateLimiter{
+		limiters:       make(map[string]*rate.Limiter),
+		defaultLimiter: rate.NewLimiter(defaultRateLimit, defaultRateBurst),
+	}
+
+	for path, l := range endpointRateLimits {
+		e.limiters[path] = rate.NewLimiter(l.limit, l.burst)
+	}
+
+	return e
+}
+
+// Allow reports whether a request to the given path may proceed now
+func (e *endpointRateLimiter) Allow(path string) bool {
+	// The png endpoints are the same endpoints, with a different response encoding
+	if limiter, ok := e.limiters[strings.TrimSuffix(path, ".png")]; ok {
+		return limiter.Allow()
+	}
+
+	return e.defaultLimiter.Allow()
+}
@@ -0,0 +1,24 @@
+package localserver
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestEndpointRateLimiter(t *testing.T) {
+	t.Parallel()
+
+	e := newEndpointRateLimiter()
+
+	// Exhaust the query endpoint's burst; the png variant shares its limiter
+	for i := 0; i < endpointRateLimits["/query"].burst; i++ {
+		require.True(t, e.Allow("/query"))
+	}
+	require.False(t, e.Allow("/query"))
+	require.False(t, e.Allow("/query.png"))
+
+	// Other endpoints are unaffected
+	require.True(t, e.Allow("/id"))
+	require.True(t, e.Allow("/unknown"))
+}
@@ -0,0 +1,236 @@
+package localserver
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kolide/kit/version"
+	"github.com/kolide/krypto/pkg/echelper"
+	"github.com/kolide/launcher/pkg/agent/storage"
+	"github.com/kolide/launcher/pkg/traces"
+)
+
+const (
+	// postureQueryPrefix marks query catalog entries that add to, or override, the default posture queries
+	postureQueryPrefix = "posture:"
+	maxNonceLength     = 256
+)
+
+// defaultPostureQueries returns the queries whose results make up the posture document on this platform,
+// keyed by posture item
+func defaultPostureQueries() map[string]string {
+	queries := map[string]string{
+		"os_version": "select name, version, build, platform from os_version",
+	}
+
+	switch runtime.GOOS {
+	case "darwin":
+		queries["disk_encryption"] = "select de.encrypted from mounts m join disk_encryption de on m.device_alias = de.name where m.path = '/'"
+		queries["firewall"] = "select global_state from alf"
+		queries["screen_lock"] = "select enabled, grace_period from screenlock"
+	case "linux":
+		queries["disk_encryption"] = "select de.encrypted from mounts m join disk_encryption de on m.device = de.name where m.path = '/'"
+		queries["firewall"] = "select count(*) as rule_count from iptables"
+		queries["screen_lock"] = "select value as enabled from kolide_gsettings where schema = 'org.gnome.desktop.screensaver' and key = 'lock-enabled'"
+	case "windows":
+		queries["disk_encryption"] = "select protection_status as encrypted from bitlocker_info where drive_letter = 'C:'"
+		queries["firewall"] = `select name, data as enabled from registry where key = 'HKEY_LOCAL_MACHINE\SYSTEM\CurrentControlSet\Services\SharedAccess\Parameters\FirewallPolicy\StandardProfile' and name = 'EnableFirewall'`
+	}
+
+	return queries
+}
+
+// postureDocument is a statement of the device's posture at a point in time. It carries the
+// nonce supplied by the verifier, so that it can't be replayed to another verifier, or later.
+type postureDocument struct {
+	Nonce     string `json:"nonce"`
+	Timestamp int64  `json:"timestamp"`
+	identifiers
+	AgentVersion            string                         `json:"agent_version"`
+	SecondsSinceLastCheckIn *int64                         `json:"seconds_since_last_check_in,omitempty"`
+	Posture                 map[string][]map[string]string `json:"posture"`
+	Errors                  map[string]string              `json:"errors,omitempty"`
+}
+
+// attestationResponse holds the posture document exactly as it was signed, with signatures by the
+// local db key and, if available, the hardware key.
+type attestationResponse struct {
+	Document          []byte `json:"document"`
+	Signature         []byte `json:"signature"`
+	PublicKey         string `json:"public_key"`
+	HardwareSignature []byte `json:"hardware_signature,omitempty"`
+	HardwarePublicKey string `json:"hardware_public_key,omitempty"`
+}
+
+func (ls *localServer) requestAttestationHandler() http.Handler {
+	return http.HandlerFunc(ls.requestAttestationHandlerFunc)
+}
+
+// requestAttestationHandlerFunc builds and signs a posture document, for SSO/IdP integrations to
+// check device trust. The request body supplies the nonce, e.g. `{"nonce": "..."}`.
+func (ls *localServer) requestAttestationHandlerFunc(w http.ResponseWriter, r *http.Request) {
+	_, span := traces.StartSpan(r.Context(), "path", r.URL.Path)
+	defer span.End()
+
+	if r.Body == nil {
+		sendClientError(w, span, errors.New("request body is nil"))
+		return
+	}
+
+	var body map[string]string
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		sendClientError(w, span, fmt.Errorf("error unmarshaling request body: %s", err))
+		return
+	}
+
+	nonce, ok := body["nonce"]
+	if !ok || nonce == "" {
+		sendClientError(w, span, errors.New("no nonce key found in request body json"))
+		return
+	}
+
+	if len(nonce) > maxNonceLength {
+		sendClientError(w, span, fmt.Errorf("nonce is longer than %d characters", maxNonceLength))
+		return
+	}
+
+	documentBytes, err := json.Marshal(ls.postureDocument(nonce))
+	if err != nil {
+		sendClientError(w, span, fmt.Errorf("error marshalling posture document: %s", err))
+		return
+	}
+
+	response, err := ls.signPostureDocument(documentBytes)
+	if err != nil {
+		traces.SetError(span, err)
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	jsonBytes, err := json.Marshal(response)
+	if err != nil {
+		sendClientError(w, span, fmt.Errorf("error marshalling attestation to json: %s", err))
+		return
+	}
+
+	w.Write(jsonBytes)
+}
+
+func (ls *localServer) postureDocument(nonce string) postureDocument {
+	doc := postureDocument{
+		Nonce:        nonce,
+		Timestamp:    time.Now().Unix(),
+		identifiers:  ls.identifiers,
+		AgentVersion: version.Version().Version,
+		Posture:      make(map[string][]map[string]string),
+		Errors:       make(map[string]string),
+	}
+
+	// The last check-in is the last successful control server fetch
+	if lastCheckIn, err := ls.knapsack.ControlStore().Get(storage.LastControlFetchKey); err == nil && lastCheckIn != nil {
+		if ts, err := strconv.ParseInt(string(lastCheckIn), 10, 64); err == nil {
+			since := doc.Timestamp - ts
+			doc.SecondsSinceLastCheckIn = &since
+		}
+	}
+
+	for item, query := range ls.postureQueries() {
+		if ls.querier == nil {
+			doc.Errors[item] = "no querier set"
+			continue
+		}
+
+		results, err := queryWithRetries(ls.querier, query)
+		if err != nil {
+			doc.Errors[item] = err.Error()
+			continue
+		}
+		doc.Posture[item] = results
+	}
+
+	return doc
+}
+
+// postureQueries returns the default posture queries, with any overrides and additions from the
+// query catalog. Catalog entries for posture must not take parameters.
+func (ls *localServer) postureQueries() map[string]string {
+	queries := defaultPostureQueries()
+
+	store := ls.knapsack.LocalServerQueriesStore()
+	if store == nil {
+		return queries
+	}
+
+	names := make([]string, 0)
+	store.ForEach(func(k, _ []byte) error {
+		if strings.HasPrefix(string(k), postureQueryPrefix) {
+			names = append(names, string(k))
+		}
+		return nil
+	})
+
+	for _, name := range names {
+		q, err := lookupCatalogQuery(store, name)
+		if err != nil || len(q.Parameters) > 0 {
+			continue
+		}
+		queries[strings.TrimPrefix(name, postureQueryPrefix)] = q.Query
+	}
+
+	return queries
+}
+
+func (ls *localServer) signPostureDocument(document []byte) (attestationResponse, error) {
+	response := attestationResponse{Document: document}
+
+	if ls.myLocalDbSigner == nil || ls.myLocalDbSigner.Public() == nil {
+		return response, errors.New("no local db key available to sign with")
+	}
+
+	sig, pub, err := signWith(ls.myLocalDbSigner, document)
+	if err != nil {
+		return response, fmt.Errorf("signing with local db key: %w", err)
+	}
+	response.Signature, response.PublicKey = sig, pub
+
+	// The hardware key may be a noop key, if there's no hardware key on this device
+	if ls.myLocalHardwareSigner == nil || ls.myLocalHardwareSigner.Public() == nil {
+		return response, nil
+	}
+
+	sig, pub, err = signWith(ls.myLocalHardwareSigner, document)
+	if err != nil {
+		return response, fmt.Errorf("signing with hardware key: %w", err)
+	}
+	response.HardwareSignature, response.HardwarePublicKey = sig, pub
+
+	return response, nil
+}
+
+func signWith(signer crypto.Signer, data []byte) ([]byte, string, error) {
+	pub, ok := signer.Public().(*ecdsa.PublicKey)
+	if !ok {
+		return nil, "", errors.New("public key is not an ecdsa key")
+	}
+
+	pubDer, err := echelper.PublicEcdsaToB64Der(pub)
+	if err != nil {
+		return nil, "", fmt.Errorf("marshalling public key: %w", err)
+	}
+
+	sig, err := echelper.SignWithTimeout(signer, data, 1*time.Second, 250*time.Millisecond)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return sig, string(pubDer), nil
+}
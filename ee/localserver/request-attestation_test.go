@@ -0,0 +1,100 @@
+package localserver
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/kolide/krypto/pkg/echelper"
+	"github.com/kolide/launcher/ee/localserver/mocks"
+	"github.com/kolide/launcher/pkg/agent/storage"
+	storageci "github.com/kolide/launcher/pkg/agent/storage/ci"
+	typesMocks "github.com/kolide/launcher/pkg/agent/types/mocks"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_localServer_requestAttestationHandler(t *testing.T) {
+	t.Parallel()
+
+	catalogStore, err := storageci.NewStore(t, log.NewNopLogger(), storage.LocalServerQueriesStore.String())
+	require.NoError(t, err)
+	require.NoError(t, catalogStore.Set([]byte("posture:custom"), []byte(`{"query": "select 1 as custom"}`)))
+	require.NoError(t, catalogStore.Set([]byte("not_posture"), []byte(`{"query": "select 1 as other"}`)))
+
+	controlStore, err := storageci.NewStore(t, log.NewNopLogger(), storage.ControlStore.String())
+	require.NoError(t, err)
+	require.NoError(t, controlStore.Set(storage.LastControlFetchKey, []byte(strconv.FormatInt(time.Now().Add(-time.Hour).Unix(), 10))))
+
+	mockKnapsack := typesMocks.NewKnapsack(t)
+	mockKnapsack.On("ConfigStore").Return(storageci.NewStore(t, log.NewNopLogger(), storage.ConfigStore.String()))
+	mockKnapsack.On("KolideServerURL").Return("localhost")
+	mockKnapsack.On("LocalServerQueriesStore").Return(catalogStore)
+	mockKnapsack.On("ControlStore").Return(controlStore)
+
+	mockQuerier := mocks.NewQuerier(t)
+	mockQuerier.On("Query", "select 1 as custom").Return([]map[string]string{{"custom": "1"}}, nil).Once()
+	mockQuerier.On("Query", mock.Anything).Return([]map[string]string{{"some": "posture"}}, nil)
+
+	var logBytes bytes.Buffer
+	server := testServer(t, mockKnapsack, &logBytes)
+	server.querier = mockQuerier
+
+	localDbKey, hardwareKey := ecdsaKey(t), ecdsaKey(t)
+	server.myLocalDbSigner = localDbKey
+	server.myLocalHardwareSigner = hardwareKey
+
+	req, err := http.NewRequest("", "", bytes.NewBuffer(mustMarshal(t, map[string]string{"nonce": "verifier-nonce"})))
+	require.NoError(t, err)
+
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(server.requestAttestationHandlerFunc).ServeHTTP(rr, req)
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+
+	var response attestationResponse
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &response))
+
+	// Both signatures should verify against the exact document bytes
+	require.NoError(t, echelper.VerifySignature(localDbKey.PublicKey, response.Document, response.Signature))
+	require.NoError(t, echelper.VerifySignature(hardwareKey.PublicKey, response.Document, response.HardwareSignature))
+
+	publicKey, err := echelper.PublicB64DerToEcdsaKey([]byte(response.PublicKey))
+	require.NoError(t, err)
+	require.True(t, localDbKey.PublicKey.Equal(publicKey))
+
+	var doc postureDocument
+	require.NoError(t, json.Unmarshal(response.Document, &doc))
+	require.Equal(t, "verifier-nonce", doc.Nonce)
+	require.WithinDuration(t, time.Now(), time.Unix(doc.Timestamp, 0), 5*time.Second)
+	require.NotNil(t, doc.SecondsSinceLastCheckIn)
+	require.InDelta(t, 3600, *doc.SecondsSinceLastCheckIn, 5)
+	require.Equal(t, []map[string]string{{"custom": "1"}}, doc.Posture["custom"])
+	require.Contains(t, doc.Posture, "os_version")
+	require.NotContains(t, doc.Posture, "not_posture")
+	require.Empty(t, doc.Errors)
+}
+
+func Test_localServer_requestAttestationHandler_Errors(t *testing.T) {
+	t.Parallel()
+
+	mockKnapsack := typesMocks.NewKnapsack(t)
+	mockKnapsack.On("ConfigStore").Return(storageci.NewStore(t, log.NewNopLogger(), storage.ConfigStore.String()))
+	mockKnapsack.On("KolideServerURL").Return("localhost")
+
+	var logBytes bytes.Buffer
+	server := testServer(t, mockKnapsack, &logBytes)
+
+	for _, body := range []map[string]string{{}, {"nonce": ""}, {"nonce": string(make([]byte, maxNonceLength+1))}} {
+		req, err := http.NewRequest("", "", bytes.NewBuffer(mustMarshal(t, body)))
+		require.NoError(t, err)
+
+		rr := httptest.NewRecorder()
+		http.HandlerFunc(server.requestAttestationHandlerFunc).ServeHTTP(rr, req)
+		require.Equal(t, http.StatusBadRequest, rr.Code)
+	}
+}
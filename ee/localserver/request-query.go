@@ -27,24 +27,50 @@ func (ls *localServer) requestQueryHanlderFunc(w http.ResponseWriter, r *http.Re
 		return
 	}
 
-	var body map[string]string
-	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+	// Only named queries from the catalog pushed by the control server may be run, with their
+	// parameters bound from the request body, e.g. `{"name": "some_query", "parameters": {"username": "kolide"}}`
+	var body struct {
+		Name       string         `json:"name"`
+		Parameters map[string]any `json:"parameters"`
+	}
+	decoder := json.NewDecoder(r.Body)
+	decoder.UseNumber()
+	if err := decoder.Decode(&body); err != nil {
 		sendClientError(w, span, fmt.Errorf("error unmarshaling request body: %s", err))
 		return
 	}
 
-	query, ok := body["query"]
-	if !ok || query == "" {
-		sendClientError(w, span, errors.New("no query key found in request body json"))
+	if body.Name == "" {
+		sendClientError(w, span, errors.New("no name key found in request body json"))
 		return
 	}
 
-	results, err := queryWithRetries(ls.querier, query)
+	catalogQuery, err := lookupCatalogQuery(ls.knapsack.LocalServerQueriesStore(), body.Name)
 	if err != nil {
-		sendClientError(w, span, fmt.Errorf("error executing query: %s", err))
+		sendClientError(w, span, err)
 		return
 	}
 
+	query, err := catalogQuery.bind(body.Parameters)
+	if err != nil {
+		sendClientError(w, span, fmt.Errorf("error binding parameters for query %s: %s", body.Name, err))
+		return
+	}
+
+	cacheKey := body.Name + "\x00" + query
+	results, ok := ls.queryCache.get(cacheKey)
+	if !ok {
+		results, err = queryWithRetries(ls.querier, query)
+		if err != nil {
+			sendClientError(w, span, fmt.Errorf("error executing query: %s", err))
+			return
+		}
+
+		if catalogQuery.TTL > 0 {
+			ls.queryCache.set(cacheKey, results, time.Duration(catalogQuery.TTL)*time.Second)
+		}
+	}
+
 	jsonBytes, err := json.Marshal(results)
 	if err != nil {
 		sendClientError(w, span, fmt.Errorf("error marshalling results to json: %s", err))
@@ -90,7 +116,11 @@ func (ls *localServer) requestScheduledQueryHandlerFunc(w http.ResponseWriter, r
 		return
 	}
 
-	scheduledQueryQuery := fmt.Sprintf("select name, query from osquery_schedule where name like '%s'", name)
+	scheduledQueryQuery, err := scheduledQueryLookup.bind(map[string]any{"name": name})
+	if err != nil {
+		sendClientError(w, span, fmt.Errorf("error binding scheduled query name: %s", err))
+		return
+	}
 
 	scheduledQueriesQueryResults, err := queryWithRetries(ls.querier, scheduledQueryQuery)
 	if err != nil {
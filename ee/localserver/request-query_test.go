@@ -22,16 +22,20 @@ func Test_localServer_requestQueryHandler(t *testing.T) {
 	t.Parallel()
 
 	tests := []struct {
-		name  string
-		query string
+		name       string
+		queryName  string
+		parameters map[string]any
 
+		expectedQuery   string
 		mockQueryResult []map[string]string
 
 		errStr string
 	}{
 		{
-			name:  "happy path",
-			query: "select blah from blah_blah",
+			name:          "happy path",
+			queryName:     "blah",
+			parameters:    map[string]any{"blah": "it's blah", "count": 3},
+			expectedQuery: "select blah from blah_blah where blah = 'it''s blah' limit 3",
 			mockQueryResult: []map[string]string{
 				{
 					"blah": "blah",
@@ -39,13 +43,43 @@ func Test_localServer_requestQueryHandler(t *testing.T) {
 			},
 		},
 		{
-			name:   "no query",
-			errStr: "no query key found in request body json",
+			name:          "large integer parameter",
+			queryName:     "blah",
+			parameters:    map[string]any{"blah": "blah", "count": 1000000},
+			expectedQuery: "select blah from blah_blah where blah = 'blah' limit 1000000",
+			mockQueryResult: []map[string]string{
+				{
+					"blah": "blah",
+				},
+			},
+		},
+		{
+			name:       "fractional integer parameter",
+			queryName:  "blah",
+			parameters: map[string]any{"blah": "blah", "count": 1.5},
+			errStr:     "not an integer",
+		},
+		{
+			name:   "no name",
+			errStr: "no name key found in request body json",
 		},
 		{
-			name:   "empty query",
-			query:  "",
-			errStr: "no query key found in request body json",
+			name:       "not in catalog",
+			queryName:  "select blah from blah_blah",
+			parameters: map[string]any{},
+			errStr:     "no query named select blah from blah_blah in catalog",
+		},
+		{
+			name:       "missing parameter",
+			queryName:  "blah",
+			parameters: map[string]any{"blah": "blah"},
+			errStr:     "missing parameter count",
+		},
+		{
+			name:       "bad parameter",
+			queryName:  "blah",
+			parameters: map[string]any{"blah": "blah", "count": "3; select 1"},
+			errStr:     "not an integer",
 		},
 	}
 
@@ -54,42 +88,56 @@ func Test_localServer_requestQueryHandler(t *testing.T) {
 		t.Run(tt.name, func(t *testing.T) {
 			t.Parallel()
 
+			catalogStore, err := storageci.NewStore(t, log.NewNopLogger(), storage.LocalServerQueriesStore.String())
+			require.NoError(t, err)
+			require.NoError(t, catalogStore.Set([]byte("blah"), mustMarshal(t, catalogQuery{
+				Query:      "select blah from blah_blah where blah = :blah limit :count",
+				Parameters: map[string]parameterType{"blah": parameterTypeString, "count": parameterTypeInteger},
+				TTL:        60,
+			})))
+
 			mockKnapsack := typesMocks.NewKnapsack(t)
 			mockKnapsack.On("ConfigStore").Return(storageci.NewStore(t, log.NewNopLogger(), storage.ConfigStore.String()))
 			mockKnapsack.On("KolideServerURL").Return("localhost")
+			if tt.queryName != "" {
+				mockKnapsack.On("LocalServerQueriesStore").Return(catalogStore)
+			}
 
 			//go:generate mockery --name Querier
 			// https://github.com/vektra/mockery <-- cli tool to generate mocks for usage with testify
 			mockQuerier := mocks.NewQuerier(t)
 
 			if tt.mockQueryResult != nil {
-				mockQuerier.On("Query", tt.query).Return(tt.mockQueryResult, nil).Once()
+				// The result should be cached, so the query is only run once
+				mockQuerier.On("Query", tt.expectedQuery).Return(tt.mockQueryResult, nil).Once()
 			}
 
 			var logBytes bytes.Buffer
 			server := testServer(t, mockKnapsack, &logBytes)
 			server.querier = mockQuerier
 
-			jsonBytes, err := json.Marshal(map[string]string{
-				"query": tt.query,
+			jsonBytes, err := json.Marshal(map[string]any{
+				"name":       tt.queryName,
+				"parameters": tt.parameters,
 			})
-			req, err := http.NewRequest("", "", bytes.NewBuffer(jsonBytes))
 			require.NoError(t, err)
 
-			queryParams := req.URL.Query()
-			queryParams.Add("query", tt.query)
-			req.URL.RawQuery = queryParams.Encode()
+			for i := 0; i < 2; i++ {
+				req, err := http.NewRequest("", "", bytes.NewBuffer(jsonBytes))
+				require.NoError(t, err)
 
-			handler := http.HandlerFunc(server.requestQueryHanlderFunc)
-			rr := httptest.NewRecorder()
-			handler.ServeHTTP(rr, req)
+				handler := http.HandlerFunc(server.requestQueryHanlderFunc)
+				rr := httptest.NewRecorder()
+				handler.ServeHTTP(rr, req)
 
-			if tt.mockQueryResult != nil {
-				require.Equal(t, mustMarshal(t, tt.mockQueryResult), rr.Body.Bytes())
-				return
-			}
+				if tt.mockQueryResult != nil {
+					require.Equal(t, mustMarshal(t, tt.mockQueryResult), rr.Body.Bytes())
+					continue
+				}
 
-			require.Contains(t, rr.Body.String(), tt.errStr)
+				require.Equal(t, http.StatusBadRequest, rr.Code)
+				require.Contains(t, rr.Body.String(), tt.errStr)
+			}
 		})
 	}
 }
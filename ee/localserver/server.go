@@ -19,11 +19,11 @@ import (
 	"github.com/kolide/krypto"
 	"github.com/kolide/krypto/pkg/echelper"
 	"github.com/kolide/launcher/pkg/agent"
+	"github.com/kolide/launcher/pkg/agent/flags/keys"
 	"github.com/kolide/launcher/pkg/agent/types"
 	"github.com/kolide/launcher/pkg/backoff"
 	"github.com/kolide/launcher/pkg/osquery"
 	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
-	"golang.org/x/time/rate"
 )
 
 // Special Kolide Ports
@@ -45,9 +45,10 @@ type localServer struct {
 	knapsack     types.Knapsack
 	srv          *http.Server
 	identifiers  identifiers
-	limiter      *rate.Limiter
+	limiter      *endpointRateLimiter
 	tlsCerts     []tls.Certificate
 	querier      Querier
+	queryCache   *queryResultCache
 	kolideServer string
 
 	myKey                 *rsa.PrivateKey
@@ -56,12 +57,16 @@ type localServer struct {
 
 	serverKey   *rsa.PublicKey
 	serverEcKey *ecdsa.PublicKey
-}
 
-const (
-	defaultRateLimit = 5
-	defaultRateBurst = 10
-)
+	// challengeStore, if set, persists seen challenges so they can't be replayed across restarts
+	challengeStore types.KVStore
+
+	// localApiPath, localApiSrv and localApiLimiter are the socket, server and rate limits for the
+	// local API, if it's enabled
+	localApiPath    string
+	localApiSrv     *http.Server
+	localApiLimiter *endpointRateLimiter
+}
 
 type LocalServerOption func(*localServer)
 
@@ -71,11 +76,19 @@ func WithLogger(logger log.Logger) LocalServerOption {
 	}
 }
 
+// WithChallengeStore sets the store that seen challenges are persisted to
+func WithChallengeStore(store types.KVStore) LocalServerOption {
+	return func(s *localServer) {
+		s.challengeStore = store
+	}
+}
+
 func New(k types.Knapsack, opts ...LocalServerOption) (*localServer, error) {
 	ls := &localServer{
 		logger:                log.NewNopLogger(),
 		knapsack:              k,
-		limiter:               rate.NewLimiter(defaultRateLimit, defaultRateBurst),
+		limiter:               newEndpointRateLimiter(),
+		queryCache:            newQueryResultCache(),
 		kolideServer:          k.KolideServerURL(),
 		myLocalDbSigner:       agent.LocalDbKeys(),
 		myLocalHardwareSigner: agent.HardwareKeys(),
@@ -98,11 +111,14 @@ func New(k types.Knapsack, opts ...LocalServerOption) (*localServer, error) {
 	}
 	ls.myKey = privateKey
 
-	ecKryptoMiddleware := newKryptoEcMiddleware(ls.logger, ls.myLocalDbSigner, ls.myLocalHardwareSigner, *ls.serverEcKey)
+	seenChallenges := newChallengeCache(ls.logger, ls.challengeStore)
+	ecKryptoMiddleware := newKryptoEcMiddleware(ls.logger, ls.myLocalDbSigner, ls.myLocalHardwareSigner, *ls.serverEcKey, seenChallenges, ls.limiter)
 	ecAuthedMux := http.NewServeMux()
 	ecAuthedMux.HandleFunc("/", http.NotFound)
 	ecAuthedMux.Handle("/acceleratecontrol", ls.requestAccelerateControlHandler())
 	ecAuthedMux.Handle("/acceleratecontrol.png", ls.requestAccelerateControlHandler())
+	ecAuthedMux.Handle("/attestation", ls.requestAttestationHandler())
+	ecAuthedMux.Handle("/attestation.png", ls.requestAttestationHandler())
 	ecAuthedMux.Handle("/id", ls.requestIdHandler())
 	ecAuthedMux.Handle("/id.png", ls.requestIdHandler())
 	ecAuthedMux.Handle("/query", ls.requestQueryHandler())
@@ -122,7 +138,7 @@ func New(k types.Knapsack, opts ...LocalServerOption) (*localServer, error) {
 
 	// uncomment to test without going through middleware
 	// for example:
-	// curl localhost:40978/query --data '{"query":"select * from kolide_launcher_info"}'
+	// curl localhost:40978/query --data '{"name":"launcher_info","parameters":{}}'
 	// mux.Handle("/query", ls.requestQueryHandler())
 	// curl localhost:40978/scheduledquery --data '{"name":"pack:kolide_device_updaters:agentprocesses-all:snapshot"}'
 	// mux.Handle("/scheduledquery", ls.requestScheduledQueryHandler())
@@ -130,7 +146,7 @@ func New(k types.Knapsack, opts ...LocalServerOption) (*localServer, error) {
 	// mux.Handle("/acceleratecontrol", ls.requestAccelerateControlHandler())
 
 	srv := &http.Server{
-		Handler: otelhttp.NewHandler(ls.requestLoggingHandler(ls.preflightCorsHandler(ls.rateLimitHandler(mux))), "localserver", otelhttp.WithSpanNameFormatter(func(operation string, r *http.Request) string {
+		Handler: otelhttp.NewHandler(ls.requestLoggingHandler(ls.preflightCorsHandler(ls.rateLimitHandler(ls.limiter, mux))), "localserver", otelhttp.WithSpanNameFormatter(func(operation string, r *http.Request) string {
 			return r.URL.Path
 		})),
 		ReadTimeout:       500 * time.Millisecond,
@@ -142,6 +158,11 @@ func New(k types.Knapsack, opts ...LocalServerOption) (*localServer, error) {
 
 	ls.srv = srv
 
+	if ls.localApiPath != "" {
+		ls.localApiSrv = ls.newLocalApiServer()
+		k.RegisterChangeObserver(ls, keys.LocalApiAllowedGroups)
+	}
+
 	return ls, nil
 }
 
@@ -248,6 +269,15 @@ func (ls *localServer) Start() error {
 		}
 	}()
 
+	// The local API is secondary, so failing to serve it shouldn't stop the local server
+	if ls.localApiSrv != nil {
+		go func() {
+			if err := ls.serveLocalApi(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				level.Error(ls.logger).Log("msg", "serving local api", "err", err)
+			}
+		}()
+	}
+
 	l, err := ls.startListener()
 	if err != nil {
 		return fmt.Errorf("starting listener: %w", err)
@@ -275,6 +305,12 @@ func (ls *localServer) Stop() error {
 		level.Info(ls.logger).Log("message", "got error shutting down", "error", err)
 	}
 
+	if ls.localApiSrv != nil {
+		if err := ls.localApiSrv.Shutdown(ctx); err != nil {
+			level.Info(ls.logger).Log("message", "got error shutting down local api", "error", err)
+		}
+	}
+
 	// Consider calling srv.Stop as a more forceful shutdown?
 
 	return nil
@@ -310,10 +346,17 @@ func (ls *localServer) startListener() (net.Listener, error) {
 
 func (ls *localServer) preflightCorsHandler(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		// Think harder, maybe?
+		// Browsers send an Origin header on cross-origin requests. Only web origins on the allow-list,
+		// which is set by flag or by the control server, may make requests.
 		// https://stackoverflow.com/questions/12830095/setting-http-headers
 		if origin := r.Header.Get("Origin"); origin != "" {
+			if !originAllowed(origin, parseAllowedOrigins(ls.knapsack.LocalServerAllowedOrigins())) {
+				level.Info(ls.logger).Log("msg", "rejecting request from disallowed origin", "origin", origin)
+				http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+				return
+			}
 			w.Header().Set("Access-Control-Allow-Origin", origin)
+			w.Header().Add("Vary", "Origin")
 		}
 		w.Header().Set("Access-Control-Allow-Methods", "POST, GET, OPTIONS, PUT, DELETE")
 		w.Header().Set("Access-Control-Allow-Headers",
@@ -328,11 +371,11 @@ func (ls *localServer) preflightCorsHandler(next http.Handler) http.Handler {
 	})
 }
 
-func (ls *localServer) rateLimitHandler(next http.Handler) http.Handler {
+func (ls *localServer) rateLimitHandler(limiter *endpointRateLimiter, next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		if ls.limiter.Allow() == false {
+		if limiter.Allow(r.URL.Path) == false {
 			http.Error(w, http.StatusText(429), http.StatusTooManyRequests)
-			level.Error(ls.logger).Log("msg", "Over rate limit")
+			level.Error(ls.logger).Log("msg", "Over rate limit", "path", r.URL.Path)
 			return
 		}
 
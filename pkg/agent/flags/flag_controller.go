@@ -454,6 +454,28 @@ func (fc *FlagController) ExportTraces() bool {
 	).get(fc.getControlServerValue(keys.ExportTraces))
 }
 
+func (fc *FlagController) SetExportLogsOtlp(enabled bool) error {
+	return fc.setControlServerValue(keys.ExportLogsOtlp, boolToBytes(enabled))
+}
+func (fc *FlagController) ExportLogsOtlp() bool {
+	return NewBoolFlagValue(
+		WithDefaultBool(fc.cmdLineOpts.ExportLogsOtlp),
+	).get(fc.getControlServerValue(keys.ExportLogsOtlp))
+}
+
+func (fc *FlagController) SetExportMetrics(enabled bool) error {
+	return fc.setControlServerValue(keys.ExportMetrics, boolToBytes(enabled))
+}
+func (fc *FlagController) ExportMetrics() bool {
+	return NewBoolFlagValue(
+		WithDefaultBool(fc.cmdLineOpts.ExportMetrics),
+	).get(fc.getControlServerValue(keys.ExportMetrics))
+}
+
+func (fc *FlagController) MetricsPrometheusPort() int {
+	return fc.cmdLineOpts.MetricsPrometheusPort
+}
+
 func (fc *FlagController) SetTraceSamplingRate(rate float64) error {
 	return fc.setControlServerValue(keys.TraceSamplingRate, float64ToBytes(rate))
 }
@@ -483,6 +505,28 @@ func (fc *FlagController) TraceIngestServerURL() string {
 	).get(fc.getControlServerValue(keys.TraceIngestServerURL))
 }
 
+func (fc *FlagController) SetLocalServerAllowedOrigins(origins string) error {
+	return fc.setControlServerValue(keys.LocalServerAllowedOrigins, []byte(origins))
+}
+func (fc *FlagController) LocalServerAllowedOrigins() string {
+	return NewStringFlagValue(
+		WithDefaultString(fc.cmdLineOpts.LocalServerAllowedOrigins),
+	).get(fc.getControlServerValue(keys.LocalServerAllowedOrigins))
+}
+
+func (fc *FlagController) SetLocalApiAllowedGroups(groups string) error {
+	return fc.setControlServerValue(keys.LocalApiAllowedGroups, []byte(groups))
+}
+func (fc *FlagController) LocalApiAllowedGroups() string {
+	return NewStringFlagValue(
+		WithDefaultString(fc.cmdLineOpts.LocalApiAllowedGroups),
+	).get(fc.getControlServerValue(keys.LocalApiAllowedGroups))
+}
+
+func (fc *FlagController) LocalApiEnabled() bool {
+	return fc.cmdLineOpts.LocalApiEnabled
+}
+
 func (fc *FlagController) SetDisableTraceIngestTLS(enabled bool) error {
 	return fc.setControlServerValue(keys.DisableTraceIngestTLS, boolToBytes(enabled))
 }
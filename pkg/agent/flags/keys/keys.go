@@ -43,10 +43,14 @@ const (
 	AutoupdateInitialDelay     FlagKey = "autoupdater_initial_delay"
 	UpdateDirectory            FlagKey = "update_directory"
 	ExportTraces               FlagKey = "export_traces"
+	ExportLogsOtlp             FlagKey = "export_logs_otlp"
+	ExportMetrics              FlagKey = "export_metrics"
 	TraceSamplingRate          FlagKey = "trace_sampling_rate"
 	LogIngestServerURL         FlagKey = "log_ingest_url"
 	TraceIngestServerURL       FlagKey = "trace_ingest_url"
 	DisableTraceIngestTLS      FlagKey = "disable_trace_ingest_tls"
+	LocalServerAllowedOrigins  FlagKey = "localserver_allowed_origins"
+	LocalApiAllowedGroups      FlagKey = "local_api_allowed_groups"
 )
 
 func (key FlagKey) String() string {
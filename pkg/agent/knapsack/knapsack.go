@@ -53,6 +53,10 @@ func (k *knapsack) AutoupdateErrorsStore() types.KVStore {
 	return k.getKVStore(storage.AutoupdateErrorsStore)
 }
 
+func (k *knapsack) AutoupdateEventsStore() types.KVStore {
+	return k.getKVStore(storage.AutoupdateEventsStore)
+}
+
 func (k *knapsack) ConfigStore() types.KVStore {
 	return k.getKVStore(storage.ConfigStore)
 }
@@ -61,10 +65,26 @@ func (k *knapsack) ControlStore() types.KVStore {
 	return k.getKVStore(storage.ControlStore)
 }
 
+func (k *knapsack) DesktopPromptResponsesStore() types.KVStore {
+	return k.getKVStore(storage.DesktopPromptResponsesStore)
+}
+
 func (k *knapsack) InitialResultsStore() types.KVStore {
 	return k.getKVStore(storage.InitialResultsStore)
 }
 
+func (k *knapsack) LocalServerChallengesStore() types.KVStore {
+	return k.getKVStore(storage.LocalServerChallengesStore)
+}
+
+func (k *knapsack) LocalServerQueriesStore() types.KVStore {
+	return k.getKVStore(storage.LocalServerQueriesStore)
+}
+
+func (k *knapsack) LogShipperBufferStore() types.KVStore {
+	return k.getKVStore(storage.LogShipperBufferStore)
+}
+
 func (k *knapsack) ResultLogsStore() types.KVStore {
 	return k.getKVStore(storage.ResultLogsStore)
 }
@@ -89,6 +109,10 @@ func (k *knapsack) TokenStore() types.KVStore {
 	return k.getKVStore(storage.TokenStore)
 }
 
+func (k *knapsack) TraceSamplingRulesStore() types.KVStore {
+	return k.getKVStore(storage.TraceSamplingRulesStore)
+}
+
 func (k *knapsack) getKVStore(storeType storage.Store) types.KVStore {
 	if k == nil {
 		return nil
@@ -355,6 +379,24 @@ func (k *knapsack) ExportTraces() bool {
 	return k.flags.ExportTraces()
 }
 
+func (k *knapsack) SetExportLogsOtlp(enabled bool) error {
+	return k.flags.SetExportLogsOtlp(enabled)
+}
+func (k *knapsack) ExportLogsOtlp() bool {
+	return k.flags.ExportLogsOtlp()
+}
+
+func (k *knapsack) SetExportMetrics(enabled bool) error {
+	return k.flags.SetExportMetrics(enabled)
+}
+func (k *knapsack) ExportMetrics() bool {
+	return k.flags.ExportMetrics()
+}
+
+func (k *knapsack) MetricsPrometheusPort() int {
+	return k.flags.MetricsPrometheusPort()
+}
+
 func (k *knapsack) SetTraceSamplingRate(rate float64) error {
 	return k.flags.SetTraceSamplingRate(rate)
 }
@@ -369,6 +411,24 @@ func (k *knapsack) TraceIngestServerURL() string {
 	return k.flags.TraceIngestServerURL()
 }
 
+func (k *knapsack) SetLocalServerAllowedOrigins(origins string) error {
+	return k.flags.SetLocalServerAllowedOrigins(origins)
+}
+func (k *knapsack) LocalServerAllowedOrigins() string {
+	return k.flags.LocalServerAllowedOrigins()
+}
+
+func (k *knapsack) SetLocalApiAllowedGroups(groups string) error {
+	return k.flags.SetLocalApiAllowedGroups(groups)
+}
+func (k *knapsack) LocalApiAllowedGroups() string {
+	return k.flags.LocalApiAllowedGroups()
+}
+
+func (k *knapsack) LocalApiEnabled() bool {
+	return k.flags.LocalApiEnabled()
+}
+
 func (k *knapsack) SetDisableTraceIngestTLS(enabled bool) error {
 	return k.flags.SetDisableTraceIngestTLS(enabled)
 }
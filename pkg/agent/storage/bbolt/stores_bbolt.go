@@ -17,15 +17,21 @@ func MakeStores(logger log.Logger, db *bbolt.DB) (map[storage.Store]types.KVStor
 	var storeNames = []storage.Store{
 		storage.AgentFlagsStore,
 		storage.AutoupdateErrorsStore,
+		storage.AutoupdateEventsStore,
 		storage.ConfigStore,
 		storage.ControlStore,
+		storage.DesktopPromptResponsesStore,
 		storage.InitialResultsStore,
+		storage.LocalServerChallengesStore,
+		storage.LocalServerQueriesStore,
+		storage.LogShipperBufferStore,
 		storage.ResultLogsStore,
 		storage.OsqueryHistoryInstanceStore,
 		storage.SentNotificationsStore,
 		storage.StatusLogsStore,
 		storage.ServerProvidedDataStore,
 		storage.TokenStore,
+		storage.TraceSamplingRulesStore,
 	}
 
 	for _, storeName := range storeNames {
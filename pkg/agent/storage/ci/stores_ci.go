@@ -19,15 +19,21 @@ func MakeStores(t *testing.T, logger log.Logger, db *bbolt.DB) (map[storage.Stor
 	var storeNames = []storage.Store{
 		storage.AgentFlagsStore,
 		storage.AutoupdateErrorsStore,
+		storage.AutoupdateEventsStore,
 		storage.ConfigStore,
 		storage.ControlStore,
+		storage.DesktopPromptResponsesStore,
 		storage.InitialResultsStore,
+		storage.LocalServerChallengesStore,
+		storage.LocalServerQueriesStore,
+		storage.LogShipperBufferStore,
 		storage.ResultLogsStore,
 		storage.OsqueryHistoryInstanceStore,
 		storage.SentNotificationsStore,
 		storage.StatusLogsStore,
 		storage.ServerProvidedDataStore,
 		storage.TokenStore,
+		storage.TraceSamplingRulesStore,
 	}
 
 	if os.Getenv("CI") == "true" {
@@ -2,4 +2,7 @@ package storage
 
 var (
 	ObservabilityIngestAuthTokenKey = []byte("observability_ingest_auth_token")
+	// LastControlFetchKey holds the Unix timestamp of the last successful control server
+	// fetch, in the control store. It's the device's last check-in.
+	LastControlFetchKey = []byte("last_control_fetch")
 )
@@ -6,15 +6,21 @@ type Store string
 const (
 	AgentFlagsStore             Store = "agent_flags"              // The store used for agent control flags.
 	AutoupdateErrorsStore       Store = "tuf_autoupdate_errors"    // The store used for tracking new autoupdater errors.
+	AutoupdateEventsStore       Store = "tuf_autoupdate_events"    // The store used for the history of new autoupdater events.
 	ConfigStore                 Store = "config"                   // The store used for launcher configuration.
 	ControlStore                Store = "control_service_data"     // The store used for control service caching data.
+	DesktopPromptResponsesStore Store = "desktop_prompt_responses" // The store used for users' answers to desktop prompts.
 	InitialResultsStore         Store = "initial_results"          // The store used for initial runner queries.
+	LocalServerChallengesStore  Store = "localserver_challenges"   // The store used for challenges the local server has seen, to prevent replays.
+	LocalServerQueriesStore     Store = "localserver_queries"      // The store used for the catalog of named queries the local server may run.
+	LogShipperBufferStore       Store = "log_shipper_buffer"       // The store used for logs waiting to be shipped.
 	ResultLogsStore             Store = "result_logs"              // The store used for buffered result logs.
 	OsqueryHistoryInstanceStore Store = "osquery_instance_history" // The store used for the history of osquery instances.
 	SentNotificationsStore      Store = "sent_notifications"       // The store used for sent notifications.
 	StatusLogsStore             Store = "status_logs"              // The store used for buffered status logs.
 	ServerProvidedDataStore     Store = "server_provided_data"     // The store used for pushing values from server-backed tables.
 	TokenStore                  Store = "token_store"              // The store used for holding bearer auth tokens, e.g. the ones used to authenticate with the observability ingest server.
+	TraceSamplingRulesStore     Store = "trace_sampling_rules"     // The store used for the rules deciding which traces are exported.
 )
 
 func (storeType Store) String() string {
@@ -173,6 +173,17 @@ type Flags interface {
 	SetExportTraces(enabled bool) error
 	ExportTraces() bool
 
+	// ExportLogsOtlp ships logs as OTLP log records to the trace ingest server, instead of as JSON to the log ingest server
+	SetExportLogsOtlp(enabled bool) error
+	ExportLogsOtlp() bool
+
+	// ExportMetrics enables exporting our metrics to the trace ingest server
+	SetExportMetrics(enabled bool) error
+	ExportMetrics() bool
+
+	// MetricsPrometheusPort is the localhost port to serve metrics on, in the Prometheus format. 0 disables it.
+	MetricsPrometheusPort() int
+
 	// TraceSamplingRate is a number between 0.0 and 1.0 that indicates what fraction of traces should be sampled.
 	SetTraceSamplingRate(rate float64) error
 	TraceSamplingRate() float64
@@ -188,4 +199,17 @@ type Flags interface {
 	// DisableTraceIngestTLS disables TLS for observability ingest server communication
 	SetDisableTraceIngestTLS(enabled bool) error
 	DisableTraceIngestTLS() bool
+
+	// LocalServerAllowedOrigins is a comma-separated list of web origins allowed to make requests to the
+	// local server. An origin may use a wildcard for its subdomain, e.g. https://*.example.com.
+	SetLocalServerAllowedOrigins(origins string) error
+	LocalServerAllowedOrigins() string
+
+	// LocalApiAllowedGroups is a comma-separated list of groups whose members, in addition to root, may use
+	// the local API socket
+	SetLocalApiAllowedGroups(groups string) error
+	LocalApiAllowedGroups() string
+
+	// LocalApiEnabled enables the local API socket or named pipe
+	LocalApiEnabled() bool
 }
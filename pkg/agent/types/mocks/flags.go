@@ -272,6 +272,34 @@ func (_m *Flags) EnrollSecretPath() string {
 	return r0
 }
 
+// ExportLogsOtlp provides a mock function with given fields:
+func (_m *Flags) ExportLogsOtlp() bool {
+	ret := _m.Called()
+
+	var r0 bool
+	if rf, ok := ret.Get(0).(func() bool); ok {
+		r0 = rf()
+	} else {
+		r0 = ret.Get(0).(bool)
+	}
+
+	return r0
+}
+
+// ExportMetrics provides a mock function with given fields:
+func (_m *Flags) ExportMetrics() bool {
+	ret := _m.Called()
+
+	var r0 bool
+	if rf, ok := ret.Get(0).(func() bool); ok {
+		r0 = rf()
+	} else {
+		r0 = ret.Get(0).(bool)
+	}
+
+	return r0
+}
+
 // ExportTraces provides a mock function with given fields:
 func (_m *Flags) ExportTraces() bool {
 	ret := _m.Called()
@@ -384,6 +412,48 @@ func (_m *Flags) KolideServerURL() string {
 	return r0
 }
 
+// LocalApiAllowedGroups provides a mock function with given fields:
+func (_m *Flags) LocalApiAllowedGroups() string {
+	ret := _m.Called()
+
+	var r0 string
+	if rf, ok := ret.Get(0).(func() string); ok {
+		r0 = rf()
+	} else {
+		r0 = ret.Get(0).(string)
+	}
+
+	return r0
+}
+
+// LocalApiEnabled provides a mock function with given fields:
+func (_m *Flags) LocalApiEnabled() bool {
+	ret := _m.Called()
+
+	var r0 bool
+	if rf, ok := ret.Get(0).(func() bool); ok {
+		r0 = rf()
+	} else {
+		r0 = ret.Get(0).(bool)
+	}
+
+	return r0
+}
+
+// LocalServerAllowedOrigins provides a mock function with given fields:
+func (_m *Flags) LocalServerAllowedOrigins() string {
+	ret := _m.Called()
+
+	var r0 string
+	if rf, ok := ret.Get(0).(func() string); ok {
+		r0 = rf()
+	} else {
+		r0 = ret.Get(0).(string)
+	}
+
+	return r0
+}
+
 // LogMaxBytesPerBatch provides a mock function with given fields:
 func (_m *Flags) LogMaxBytesPerBatch() int {
 	ret := _m.Called()
@@ -412,6 +482,20 @@ func (_m *Flags) LoggingInterval() time.Duration {
 	return r0
 }
 
+// MetricsPrometheusPort provides a mock function with given fields:
+func (_m *Flags) MetricsPrometheusPort() int {
+	ret := _m.Called()
+
+	var r0 int
+	if rf, ok := ret.Get(0).(func() int); ok {
+		r0 = rf()
+	} else {
+		r0 = ret.Get(0).(int)
+	}
+
+	return r0
+}
+
 // MirrorServerURL provides a mock function with given fields:
 func (_m *Flags) MirrorServerURL() string {
 	ret := _m.Called()
@@ -795,6 +879,34 @@ func (_m *Flags) SetDisableTraceIngestTLS(enabled bool) error {
 	return r0
 }
 
+// SetExportLogsOtlp provides a mock function with given fields: enabled
+func (_m *Flags) SetExportLogsOtlp(enabled bool) error {
+	ret := _m.Called(enabled)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(bool) error); ok {
+		r0 = rf(enabled)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// SetExportMetrics provides a mock function with given fields: enabled
+func (_m *Flags) SetExportMetrics(enabled bool) error {
+	ret := _m.Called(enabled)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(bool) error); ok {
+		r0 = rf(enabled)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
 // SetExportTraces provides a mock function with given fields: enabled
 func (_m *Flags) SetExportTraces(enabled bool) error {
 	ret := _m.Called(enabled)
@@ -879,6 +991,34 @@ func (_m *Flags) SetKolideServerURL(url string) error {
 	return r0
 }
 
+// SetLocalApiAllowedGroups provides a mock function with given fields: groups
+func (_m *Flags) SetLocalApiAllowedGroups(groups string) error {
+	ret := _m.Called(groups)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(string) error); ok {
+		r0 = rf(groups)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// SetLocalServerAllowedOrigins provides a mock function with given fields: origins
+func (_m *Flags) SetLocalServerAllowedOrigins(origins string) error {
+	ret := _m.Called(origins)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(string) error); ok {
+		r0 = rf(origins)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
 // SetLoggingInterval provides a mock function with given fields: interval
 func (_m *Flags) SetLoggingInterval(interval time.Duration) error {
 	ret := _m.Called(interval)
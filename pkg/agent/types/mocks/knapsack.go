@@ -107,6 +107,22 @@ func (_m *Knapsack) AutoupdateInterval() time.Duration {
 	return r0
 }
 
+// AutoupdateEventsStore provides a mock function with given fields:
+func (_m *Knapsack) AutoupdateEventsStore() types.GetterSetterDeleterIteratorUpdater {
+	ret := _m.Called()
+
+	var r0 types.GetterSetterDeleterIteratorUpdater
+	if rf, ok := ret.Get(0).(func() types.GetterSetterDeleterIteratorUpdater); ok {
+		r0 = rf()
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(types.GetterSetterDeleterIteratorUpdater)
+		}
+	}
+
+	return r0
+}
+
 // BboltDB provides a mock function with given fields:
 func (_m *Knapsack) BboltDB() *bbolt.DB {
 	ret := _m.Called()
@@ -269,6 +285,22 @@ func (_m *Knapsack) DesktopMenuRefreshInterval() time.Duration {
 	return r0
 }
 
+// DesktopPromptResponsesStore provides a mock function with given fields:
+func (_m *Knapsack) DesktopPromptResponsesStore() types.GetterSetterDeleterIteratorUpdater {
+	ret := _m.Called()
+
+	var r0 types.GetterSetterDeleterIteratorUpdater
+	if rf, ok := ret.Get(0).(func() types.GetterSetterDeleterIteratorUpdater); ok {
+		r0 = rf()
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(types.GetterSetterDeleterIteratorUpdater)
+		}
+	}
+
+	return r0
+}
+
 // DesktopUpdateInterval provides a mock function with given fields:
 func (_m *Knapsack) DesktopUpdateInterval() time.Duration {
 	ret := _m.Called()
@@ -353,6 +385,34 @@ func (_m *Knapsack) EnrollSecretPath() string {
 	return r0
 }
 
+// ExportLogsOtlp provides a mock function with given fields:
+func (_m *Knapsack) ExportLogsOtlp() bool {
+	ret := _m.Called()
+
+	var r0 bool
+	if rf, ok := ret.Get(0).(func() bool); ok {
+		r0 = rf()
+	} else {
+		r0 = ret.Get(0).(bool)
+	}
+
+	return r0
+}
+
+// ExportMetrics provides a mock function with given fields:
+func (_m *Knapsack) ExportMetrics() bool {
+	ret := _m.Called()
+
+	var r0 bool
+	if rf, ok := ret.Get(0).(func() bool); ok {
+		r0 = rf()
+	} else {
+		r0 = ret.Get(0).(bool)
+	}
+
+	return r0
+}
+
 // ExportTraces provides a mock function with given fields:
 func (_m *Knapsack) ExportTraces() bool {
 	ret := _m.Called()
@@ -481,6 +541,80 @@ func (_m *Knapsack) KolideServerURL() string {
 	return r0
 }
 
+// LocalApiAllowedGroups provides a mock function with given fields:
+func (_m *Knapsack) LocalApiAllowedGroups() string {
+	ret := _m.Called()
+
+	var r0 string
+	if rf, ok := ret.Get(0).(func() string); ok {
+		r0 = rf()
+	} else {
+		r0 = ret.Get(0).(string)
+	}
+
+	return r0
+}
+
+// LocalApiEnabled provides a mock function with given fields:
+func (_m *Knapsack) LocalApiEnabled() bool {
+	ret := _m.Called()
+
+	var r0 bool
+	if rf, ok := ret.Get(0).(func() bool); ok {
+		r0 = rf()
+	} else {
+		r0 = ret.Get(0).(bool)
+	}
+
+	return r0
+}
+
+// LocalServerAllowedOrigins provides a mock function with given fields:
+func (_m *Knapsack) LocalServerAllowedOrigins() string {
+	ret := _m.Called()
+
+	var r0 string
+	if rf, ok := ret.Get(0).(func() string); ok {
+		r0 = rf()
+	} else {
+		r0 = ret.Get(0).(string)
+	}
+
+	return r0
+}
+
+// LocalServerChallengesStore provides a mock function with given fields:
+func (_m *Knapsack) LocalServerChallengesStore() types.GetterSetterDeleterIteratorUpdater {
+	ret := _m.Called()
+
+	var r0 types.GetterSetterDeleterIteratorUpdater
+	if rf, ok := ret.Get(0).(func() types.GetterSetterDeleterIteratorUpdater); ok {
+		r0 = rf()
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(types.GetterSetterDeleterIteratorUpdater)
+		}
+	}
+
+	return r0
+}
+
+// LocalServerQueriesStore provides a mock function with given fields:
+func (_m *Knapsack) LocalServerQueriesStore() types.GetterSetterDeleterIteratorUpdater {
+	ret := _m.Called()
+
+	var r0 types.GetterSetterDeleterIteratorUpdater
+	if rf, ok := ret.Get(0).(func() types.GetterSetterDeleterIteratorUpdater); ok {
+		r0 = rf()
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(types.GetterSetterDeleterIteratorUpdater)
+		}
+	}
+
+	return r0
+}
+
 // LogMaxBytesPerBatch provides a mock function with given fields:
 func (_m *Knapsack) LogMaxBytesPerBatch() int {
 	ret := _m.Called()
@@ -495,6 +629,22 @@ func (_m *Knapsack) LogMaxBytesPerBatch() int {
 	return r0
 }
 
+// LogShipperBufferStore provides a mock function with given fields:
+func (_m *Knapsack) LogShipperBufferStore() types.GetterSetterDeleterIteratorUpdater {
+	ret := _m.Called()
+
+	var r0 types.GetterSetterDeleterIteratorUpdater
+	if rf, ok := ret.Get(0).(func() types.GetterSetterDeleterIteratorUpdater); ok {
+		r0 = rf()
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(types.GetterSetterDeleterIteratorUpdater)
+		}
+	}
+
+	return r0
+}
+
 // LoggingInterval provides a mock function with given fields:
 func (_m *Knapsack) LoggingInterval() time.Duration {
 	ret := _m.Called()
@@ -509,6 +659,20 @@ func (_m *Knapsack) LoggingInterval() time.Duration {
 	return r0
 }
 
+// MetricsPrometheusPort provides a mock function with given fields:
+func (_m *Knapsack) MetricsPrometheusPort() int {
+	ret := _m.Called()
+
+	var r0 int
+	if rf, ok := ret.Get(0).(func() int); ok {
+		r0 = rf()
+	} else {
+		r0 = ret.Get(0).(int)
+	}
+
+	return r0
+}
+
 // MirrorServerURL provides a mock function with given fields:
 func (_m *Knapsack) MirrorServerURL() string {
 	ret := _m.Called()
@@ -956,6 +1120,34 @@ func (_m *Knapsack) SetDisableTraceIngestTLS(enabled bool) error {
 	return r0
 }
 
+// SetExportLogsOtlp provides a mock function with given fields: enabled
+func (_m *Knapsack) SetExportLogsOtlp(enabled bool) error {
+	ret := _m.Called(enabled)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(bool) error); ok {
+		r0 = rf(enabled)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// SetExportMetrics provides a mock function with given fields: enabled
+func (_m *Knapsack) SetExportMetrics(enabled bool) error {
+	ret := _m.Called(enabled)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(bool) error); ok {
+		r0 = rf(enabled)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
 // SetExportTraces provides a mock function with given fields: enabled
 func (_m *Knapsack) SetExportTraces(enabled bool) error {
 	ret := _m.Called(enabled)
@@ -1054,6 +1246,34 @@ func (_m *Knapsack) Set(enabled bool) error {
 	return r0
 }
 
+// SetLocalApiAllowedGroups provides a mock function with given fields: groups
+func (_m *Knapsack) SetLocalApiAllowedGroups(groups string) error {
+	ret := _m.Called(groups)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(string) error); ok {
+		r0 = rf(groups)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// SetLocalServerAllowedOrigins provides a mock function with given fields: origins
+func (_m *Knapsack) SetLocalServerAllowedOrigins(origins string) error {
+	ret := _m.Called(origins)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(string) error); ok {
+		r0 = rf(origins)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
 // SetLoggingInterval provides a mock function with given fields: interval
 func (_m *Knapsack) SetLoggingInterval(interval time.Duration) error {
 	ret := _m.Called(interval)
@@ -1268,6 +1488,22 @@ func (_m *Knapsack) TraceSamplingRate() float64 {
 	return r0
 }
 
+// TraceSamplingRulesStore provides a mock function with given fields:
+func (_m *Knapsack) TraceSamplingRulesStore() types.GetterSetterDeleterIteratorUpdater {
+	ret := _m.Called()
+
+	var r0 types.GetterSetterDeleterIteratorUpdater
+	if rf, ok := ret.Get(0).(func() types.GetterSetterDeleterIteratorUpdater); ok {
+		r0 = rf()
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(types.GetterSetterDeleterIteratorUpdater)
+		}
+	}
+
+	return r0
+}
+
 // Transport provides a mock function with given fields:
 func (_m *Knapsack) Transport() string {
 	ret := _m.Called()
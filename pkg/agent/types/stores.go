@@ -3,13 +3,19 @@ package types
 type Stores interface {
 	AgentFlagsStore() KVStore
 	AutoupdateErrorsStore() KVStore
+	AutoupdateEventsStore() KVStore
 	ConfigStore() KVStore
 	ControlStore() KVStore
+	DesktopPromptResponsesStore() KVStore
 	InitialResultsStore() KVStore
+	LocalServerChallengesStore() KVStore
+	LocalServerQueriesStore() KVStore
+	LogShipperBufferStore() KVStore
 	ResultLogsStore() KVStore
 	OsqueryHistoryInstanceStore() KVStore
 	SentNotificationsStore() KVStore
 	StatusLogsStore() KVStore
 	ServerProvidedDataStore() KVStore
 	TokenStore() KVStore
+	TraceSamplingRulesStore() KVStore
 }
@@ -351,6 +351,13 @@ func FindBaseDir(path string) string {
 	return filepath.Dir(components[0])
 }
 
+// CheckExecutablePermissions tests whether something looks like an
+// executable, without trying to exec it. It's for binaries that can't
+// be safely run with arguments like `--version`.
+func CheckExecutablePermissions(potentialBinary string) error {
+	return checkExecutablePermissions(potentialBinary)
+}
+
 // CheckExecutable tests whether something is an executable. It
 // examines permissions, mode, and tries to exec it directly.
 func CheckExecutable(ctx context.Context, potentialBinary string, args ...string) error {
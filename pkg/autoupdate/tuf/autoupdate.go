@@ -14,6 +14,7 @@ import (
 	"path/filepath"
 	"runtime"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/go-kit/kit/log"
@@ -42,6 +43,8 @@ const (
 	binaryOsqueryd autoupdatableBinary = "osqueryd"
 )
 
+// binaries are always autoupdated. Autoloaded extensions are additionally autoupdated
+// when TUF publishes releases for them; see extensionBinaries.
 var binaries = []autoupdatableBinary{binaryLauncher, binaryOsqueryd}
 
 type ReleaseFileCustomMetadata struct {
@@ -64,9 +67,12 @@ type TufAutoupdater struct {
 	libraryManager         librarian
 	osquerier              querier // used to query for current running osquery version
 	osquerierRetryInterval time.Duration
+	extensions             []autoupdatableBinary // autoloaded extensions that may also be autoupdated
+	extensionLookup        *ExtensionLookup      // knows which extension versions osquery was given
 	channel                string
 	checkInterval          time.Duration
 	store                  types.KVStore // stores autoupdater errors for kolide_tuf_autoupdater_errors table
+	history                *eventHistory // stores autoupdater events for kolide_tuf_autoupdater_events table
 	interrupt              chan struct{}
 	logger                 log.Logger
 }
@@ -79,10 +85,19 @@ func WithLogger(logger log.Logger) TufAutoupdaterOption {
 	}
 }
 
+// WithExtensionLookup sets the lookup that checked out osquery's autoloaded extensions, so that
+// the autoupdater can tell which versions of them are running.
+func WithExtensionLookup(extensionLookup *ExtensionLookup) TufAutoupdaterOption {
+	return func(ta *TufAutoupdater) {
+		ta.extensionLookup = extensionLookup
+	}
+}
+
 func NewTufAutoupdater(k types.Knapsack, metadataHttpClient *http.Client, mirrorHttpClient *http.Client,
 	osquerier querier, opts ...TufAutoupdaterOption) (*TufAutoupdater, error) {
 	ta := &TufAutoupdater{
 		channel:                k.UpdateChannel(),
+		extensions:             extensionBinaries(k.AutoloadedExtensions()),
 		interrupt:              make(chan struct{}),
 		checkInterval:          k.AutoupdateInterval(),
 		store:                  k.AutoupdateErrorsStore(),
@@ -95,6 +110,8 @@ func NewTufAutoupdater(k types.Knapsack, metadataHttpClient *http.Client, mirror
 		opt(ta)
 	}
 
+	ta.history = newEventHistory(k.AutoupdateEventsStore(), ta.logger)
+
 	var err error
 	ta.metadataClient, err = initMetadataClient(k.RootDirectory(), k.TufServerURL(), metadataHttpClient)
 	if err != nil {
@@ -106,10 +123,12 @@ func NewTufAutoupdater(k types.Knapsack, metadataHttpClient *http.Client, mirror
 	if updateDirectory == "" {
 		updateDirectory = defaultLibraryDirectory(k.RootDirectory())
 	}
-	ta.libraryManager, err = newUpdateLibraryManager(k.MirrorServerURL(), mirrorHttpClient, updateDirectory, ta.logger)
+	libraryManager, err := newUpdateLibraryManager(k.MirrorServerURL(), mirrorHttpClient, updateDirectory, ta.logger)
 	if err != nil {
 		return nil, fmt.Errorf("could not init update library manager: %w", err)
 	}
+	libraryManager.history = ta.history
+	ta.libraryManager = libraryManager
 
 	return ta, nil
 }
@@ -154,9 +173,47 @@ func defaultLibraryDirectory(rootDirectory string) string {
 	return filepath.Join(rootDirectory, "updates")
 }
 
+// extensionBinaries returns the autoupdatable binaries corresponding to the given autoloaded
+// extensions. The binary name is the extension's filename, without any `.exe` suffix.
+func extensionBinaries(autoloadedExtensions []string) []autoupdatableBinary {
+	extensions := make([]autoupdatableBinary, 0)
+	for _, extension := range autoloadedExtensions {
+		extensions = append(extensions, extensionBinary(extension))
+	}
+
+	return extensions
+}
+
+func extensionBinary(autoloadedExtension string) autoupdatableBinary {
+	return autoupdatableBinary(strings.TrimSuffix(filepath.Base(autoloadedExtension), ".exe"))
+}
+
+// managedBinaries returns our core binaries along with all autoloaded extensions.
+func (ta *TufAutoupdater) managedBinaries() []autoupdatableBinary {
+	managedBinaries := make([]autoupdatableBinary, 0, len(binaries)+len(ta.extensions))
+	managedBinaries = append(managedBinaries, binaries...)
+	return append(managedBinaries, ta.extensions...)
+}
+
+// updatableBinaries returns the binaries that we should check for updates: our core binaries, plus
+// any autoloaded extensions that have a release file in TUF for our platform and channel.
+func (ta *TufAutoupdater) updatableBinaries(targets data.TargetFiles) []autoupdatableBinary {
+	updatableBinaries := make([]autoupdatableBinary, len(binaries))
+	copy(updatableBinaries, binaries)
+
+	for _, extension := range ta.extensions {
+		if _, ok := targets[releaseFilePath(extension, ta.channel, runtime.GOOS, PlatformArch())]; !ok {
+			continue
+		}
+		updatableBinaries = append(updatableBinaries, extension)
+	}
+
+	return updatableBinaries
+}
+
 // Execute is the TufAutoupdater run loop. It periodically checks to see if a new release
-// has been published; less frequently, it removes old/outdated TUF errors from the bucket
-// we store them in.
+// has been published; less frequently, it removes old/outdated TUF errors and events from the
+// buckets we store them in.
 func (ta *TufAutoupdater) Execute() (err error) {
 	// For now, tidy the library on startup. In the future, we will tidy the library
 	// earlier, after version selection.
@@ -170,12 +227,16 @@ func (ta *TufAutoupdater) Execute() (err error) {
 	for {
 		select {
 		case <-checkTicker.C:
-			if err := ta.checkForUpdate(); err != nil {
+			checkStart := time.Now()
+			err := ta.checkForUpdate()
+			ta.history.record(EventTypeCheck, "", "", "", checkStart, err)
+			if err != nil {
 				ta.storeError(err)
 				level.Debug(ta.logger).Log("msg", "error checking for update", "err", err)
 			}
 		case <-cleanupTicker.C:
 			ta.cleanUpOldErrors()
+			ta.history.cleanUp()
 		case <-ta.interrupt:
 			level.Debug(ta.logger).Log("msg", "received interrupt, stopping")
 			return nil
@@ -191,9 +252,10 @@ func (ta *TufAutoupdater) Interrupt(_ error) {
 }
 
 // tidyLibrary gets the current running version for each binary (so that the current version is not removed)
-// and then asks the update library manager to tidy the update library.
+// and then asks the update library manager to tidy the update library. Since it runs on startup, it also
+// records any restart onto a new version in the event history.
 func (ta *TufAutoupdater) tidyLibrary() {
-	for _, binary := range binaries {
+	for _, binary := range ta.managedBinaries() {
 		// Get the current running version to preserve it when tidying the available updates
 		currentVersion, err := ta.currentRunningVersion(binary)
 		if err != nil {
@@ -201,12 +263,14 @@ func (ta *TufAutoupdater) tidyLibrary() {
 			continue
 		}
 
+		ta.history.recordStartup(binary, currentVersion)
 		ta.libraryManager.TidyLibrary(binary, currentVersion)
 	}
 }
 
 // currentRunningVersion returns the current running version of the given binary.
-// It will perform retries for osqueryd.
+// It will perform retries for osqueryd. For autoloaded extensions, it returns the
+// version that was checked out from the library when osquery's options were set up.
 func (ta *TufAutoupdater) currentRunningVersion(binary autoupdatableBinary) (string, error) {
 	switch binary {
 	case binaryLauncher:
@@ -234,8 +298,28 @@ func (ta *TufAutoupdater) currentRunningVersion(binary autoupdatableBinary) (str
 		}
 		return "", err
 	default:
-		return "", fmt.Errorf("cannot determine current running version for unexpected binary %s", binary)
+		if !ta.isExtension(binary) {
+			return "", fmt.Errorf("cannot determine current running version for unexpected binary %s", binary)
+		}
+		if ta.extensionLookup == nil {
+			return "", fmt.Errorf("extension %s is not running a version from the update library", binary)
+		}
+		checkedOutVersion, ok := ta.extensionLookup.checkedOutVersion(binary)
+		if !ok {
+			return "", fmt.Errorf("extension %s is not running a version from the update library", binary)
+		}
+		return checkedOutVersion, nil
+	}
+}
+
+func (ta *TufAutoupdater) isExtension(binary autoupdatableBinary) bool {
+	for _, extension := range ta.extensions {
+		if binary == extension {
+			return true
+		}
 	}
+
+	return false
 }
 
 // checkForUpdate fetches latest metadata from the TUF server, then checks to see if there's
@@ -265,9 +349,10 @@ func (ta *TufAutoupdater) checkForUpdate() error {
 	}
 
 	// Check for and download any new releases that are available
-	updatesDownloaded := make([]bool, len(binaries))
+	updatableBinaries := ta.updatableBinaries(targets)
+	updatesDownloaded := make([]bool, len(updatableBinaries))
 	updateErrors := make([]error, 0)
-	for i, binary := range binaries {
+	for i, binary := range updatableBinaries {
 		downloadedUpdateVersion, err := ta.downloadUpdate(binary, targets)
 		if err != nil {
 			updateErrors = append(updateErrors, fmt.Errorf("could not download update for %s: %w", binary, err))
@@ -330,9 +415,15 @@ func (ta *TufAutoupdater) downloadUpdate(binary autoupdatableBinary, targets dat
 // has been published for the given channel. If it has, it returns the target for that release
 // and its associated metadata.
 func findRelease(binary autoupdatableBinary, targets data.TargetFiles, channel string) (string, data.TargetFileMeta, error) {
+	return findReleaseForPlatform(binary, targets, channel, runtime.GOOS, PlatformArch())
+}
+
+// findReleaseForPlatform behaves like findRelease, but looks up the release for the given OS and
+// arch instead of the current platform.
+func findReleaseForPlatform(binary autoupdatableBinary, targets data.TargetFiles, channel string, goos string, arch string) (string, data.TargetFileMeta, error) {
 	// First, find the target that the channel release file is pointing to
 	var releaseTarget string
-	targetReleaseFile := path.Join(string(binary), runtime.GOOS, PlatformArch(), channel, "release.json")
+	targetReleaseFile := releaseFilePath(binary, channel, goos, arch)
 	for targetName, target := range targets {
 		if targetName != targetReleaseFile {
 			continue
@@ -365,6 +456,12 @@ func findRelease(binary autoupdatableBinary, targets data.TargetFiles, channel s
 	return "", data.TargetFileMeta{}, fmt.Errorf("could not find metadata for release target %s for binary %s", releaseTarget, binary)
 }
 
+// releaseFilePath returns the name of the TUF target that points to the current release
+// for the given binary, channel, and platform.
+func releaseFilePath(binary autoupdatableBinary, channel string, goos string, arch string) string {
+	return path.Join(string(binary), goos, arch, channel, "release.json")
+}
+
 // PlatformArch returns the correct arch for the runtime OS. For now, since osquery doesn't publish an arm64 release,
 // we use the universal binaries for darwin.
 func PlatformArch() string {
@@ -23,6 +23,7 @@ import (
 	"github.com/kolide/launcher/pkg/threadsafebuffer"
 	mock "github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
+	"github.com/theupdateframework/go-tuf/data"
 )
 
 func TestNewTufAutoupdater(t *testing.T) {
@@ -33,8 +34,10 @@ func TestNewTufAutoupdater(t *testing.T) {
 	mockKnapsack := typesmocks.NewKnapsack(t)
 	mockKnapsack.On("RootDirectory").Return(testRootDir)
 	mockKnapsack.On("UpdateChannel").Return("nightly")
+	mockKnapsack.On("AutoloadedExtensions").Return([]string{})
 	mockKnapsack.On("AutoupdateInterval").Return(60 * time.Second)
 	mockKnapsack.On("AutoupdateErrorsStore").Return(s)
+	mockKnapsack.On("AutoupdateEventsStore").Return(setupEventsStorage(t))
 	mockKnapsack.On("TufServerURL").Return("https://example.com")
 	mockKnapsack.On("UpdateDirectory").Return("")
 	mockKnapsack.On("MirrorServerURL").Return("https://example.com")
@@ -70,8 +73,10 @@ func TestExecute(t *testing.T) {
 	mockKnapsack := typesmocks.NewKnapsack(t)
 	mockKnapsack.On("RootDirectory").Return(testRootDir)
 	mockKnapsack.On("UpdateChannel").Return("nightly")
+	mockKnapsack.On("AutoloadedExtensions").Return([]string{})
 	mockKnapsack.On("AutoupdateInterval").Return(60 * time.Second)
 	mockKnapsack.On("AutoupdateErrorsStore").Return(s)
+	mockKnapsack.On("AutoupdateEventsStore").Return(setupEventsStorage(t))
 	mockKnapsack.On("TufServerURL").Return(tufServerUrl)
 	mockKnapsack.On("UpdateDirectory").Return("")
 	mockKnapsack.On("MirrorServerURL").Return("https://example.com")
@@ -194,6 +199,34 @@ func Test_currentRunningVersion_osqueryd_handlesQueryError(t *testing.T) {
 	require.Equal(t, "", osqueryVersion)
 }
 
+func Test_currentRunningVersion_extension(t *testing.T) {
+	t.Parallel()
+
+	extensionLookup := NewExtensionLookup()
+	extensionLookup.checkedOutVersions["tables.ext"] = "1.2.3"
+
+	autoupdater := &TufAutoupdater{
+		logger:          log.NewNopLogger(),
+		extensions:      []autoupdatableBinary{"tables.ext", "other.ext"},
+		extensionLookup: extensionLookup,
+	}
+
+	extensionVersion, err := autoupdater.currentRunningVersion("tables.ext")
+	require.NoError(t, err)
+	require.Equal(t, "1.2.3", extensionVersion)
+
+	_, err = autoupdater.currentRunningVersion("other.ext")
+	require.Error(t, err, "expected an error for an extension that is not running from the update library")
+
+	// Lookups are per instance: another autoupdater doesn't see this one's checked-out versions
+	otherAutoupdater := &TufAutoupdater{
+		logger:     log.NewNopLogger(),
+		extensions: []autoupdatableBinary{"tables.ext"},
+	}
+	_, err = otherAutoupdater.currentRunningVersion("tables.ext")
+	require.Error(t, err, "expected an error when the autoupdater has no extension lookup")
+}
+
 func Test_storeError(t *testing.T) {
 	t.Parallel()
 
@@ -206,8 +239,10 @@ func Test_storeError(t *testing.T) {
 	mockKnapsack := typesmocks.NewKnapsack(t)
 	mockKnapsack.On("RootDirectory").Return(testRootDir)
 	mockKnapsack.On("UpdateChannel").Return("nightly")
+	mockKnapsack.On("AutoloadedExtensions").Return([]string{})
 	mockKnapsack.On("AutoupdateInterval").Return(60 * time.Second)
 	mockKnapsack.On("AutoupdateErrorsStore").Return(setupStorage(t))
+	mockKnapsack.On("AutoupdateEventsStore").Return(setupEventsStorage(t))
 	mockKnapsack.On("TufServerURL").Return(testTufServer.URL)
 	mockKnapsack.On("UpdateDirectory").Return("")
 	mockKnapsack.On("MirrorServerURL").Return("https://example.com")
@@ -301,8 +336,46 @@ func Test_cleanUpOldErrors(t *testing.T) {
 	require.Equal(t, 1, keyCount, "cleanup routine did not clean up correct number of old errors")
 }
 
+func Test_extensionBinaries(t *testing.T) {
+	t.Parallel()
+
+	extensions := extensionBinaries([]string{
+		"tables.ext",
+		filepath.Join("some", "path", "to", "other.ext"),
+		filepath.Join("some", "path", "to", "windows.ext.exe"),
+	})
+
+	require.Equal(t, []autoupdatableBinary{"tables.ext", "other.ext", "windows.ext"}, extensions)
+}
+
+func Test_updatableBinaries(t *testing.T) {
+	t.Parallel()
+
+	autoupdater := &TufAutoupdater{
+		channel:    "stable",
+		extensions: []autoupdatableBinary{"published.ext", "unpublished.ext"},
+	}
+
+	// Only the extension with a release file for our platform and channel should be updatable
+	targets := data.TargetFiles{
+		releaseFilePath("published.ext", "stable", runtime.GOOS, PlatformArch()): data.TargetFileMeta{},
+		releaseFilePath("unpublished.ext", "beta", runtime.GOOS, PlatformArch()): data.TargetFileMeta{},
+	}
+
+	require.Equal(t, []autoupdatableBinary{binaryLauncher, binaryOsqueryd, "published.ext"}, autoupdater.updatableBinaries(targets))
+
+	// Our core binaries should be unaffected
+	require.Equal(t, []autoupdatableBinary{binaryLauncher, binaryOsqueryd}, binaries)
+}
+
 func setupStorage(t *testing.T) types.KVStore {
 	s, err := storageci.NewStore(t, log.NewNopLogger(), storage.AutoupdateErrorsStore.String())
 	require.NoError(t, err)
 	return s
 }
+
+func setupEventsStorage(t *testing.T) types.KVStore {
+	s, err := storageci.NewStore(t, log.NewNopLogger(), storage.AutoupdateEventsStore.String())
+	require.NoError(t, err)
+	return s
+}
@@ -0,0 +1,347 @@
+package tuf
+
+// Update bundles allow devices that cannot reach our TUF server or mirror to receive updates.
+// A bundle is a gzipped tarball laid out the same way as our update infrastructure:
+//
+//	repository/                             TUF metadata, as served by the TUF server
+//	kolide/<binary>/<os>/<arch>/<target>    release archives, as served by the mirror
+//
+// The metadata in the bundle is verified against our embedded root metadata before any
+// of the targets are installed into the update library.
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+
+	"github.com/go-kit/kit/log"
+	"github.com/kolide/kit/fsutil"
+	"github.com/kolide/launcher/pkg/agent"
+	client "github.com/theupdateframework/go-tuf/client"
+	filejsonstore "github.com/theupdateframework/go-tuf/client/filejsonstore"
+	"github.com/theupdateframework/go-tuf/data"
+	tufutil "github.com/theupdateframework/go-tuf/util"
+)
+
+const (
+	bundleMetadataDirectory = "repository"
+
+	// bundleMirrorUrl is used in place of the mirror URL when installing targets from an
+	// extracted bundle; see newBundleMirrorClient.
+	bundleMirrorUrl = "file://"
+
+	// maxBundleMetadataSize caps how much of a single metadata file we will record during export.
+	maxBundleMetadataSize = 10 * 1024 * 1024
+)
+
+// ImportBundle verifies the TUF metadata in the update bundle at `bundlePath` against our
+// embedded root metadata, persists it to the local TUF repository, and then adds the release
+// for the given channel for each binary to the update library. It returns the binaries that are
+// now available in the library.
+//
+// Note that the metadata in the bundle must not have expired by the time it is imported.
+func ImportBundle(bundlePath string, rootDirectory string, updateDirectory string, channel string, logger log.Logger) ([]*BinaryUpdateInfo, error) {
+	return importBundle(bundlePath, rootDirectory, updateDirectory, channel, rootJson, logger)
+}
+
+func importBundle(bundlePath string, rootDirectory string, updateDirectory string, channel string, trustedRootJson []byte, logger log.Logger) ([]*BinaryUpdateInfo, error) {
+	extractDir, err := agent.MkdirTemp("update-bundle")
+	if err != nil {
+		return nil, fmt.Errorf("could not make directory to extract bundle: %w", err)
+	}
+	defer os.RemoveAll(extractDir)
+
+	// Note that `UntarBundle` calls `filepath.Dir(destination)`, so the bundle is extracted into extractDir.
+	if err := fsutil.UntarBundle(filepath.Join(extractDir, "bundle"), bundlePath); err != nil {
+		return nil, fmt.Errorf("could not extract bundle %s: %w", bundlePath, err)
+	}
+
+	// Set up a TUF client that verifies the bundle's metadata and persists it to our local TUF directory
+	localTufDirectory := LocalTufDirectory(rootDirectory)
+	if err := os.MkdirAll(localTufDirectory, 0750); err != nil {
+		return nil, fmt.Errorf("could not make local TUF directory %s: %w", localTufDirectory, err)
+	}
+	localStore, err := filejsonstore.NewFileJSONStore(localTufDirectory)
+	if err != nil {
+		return nil, fmt.Errorf("could not initialize local TUF store: %w", err)
+	}
+	metadataClient := client.NewClient(localStore, newBundleRemoteStore(filepath.Join(extractDir, bundleMetadataDirectory)))
+	if err := metadataClient.Init(trustedRootJson); err != nil {
+		return nil, fmt.Errorf("failed to initialize TUF client with root JSON: %w", err)
+	}
+	if _, err := metadataClient.Update(); err != nil {
+		return nil, fmt.Errorf("could not verify bundle metadata: %w", err)
+	}
+	targets, err := metadataClient.Targets()
+	if err != nil {
+		return nil, fmt.Errorf("could not get complete list of targets from bundle: %w", err)
+	}
+
+	// Install the releases, using the extracted bundle in place of the mirror
+	if updateDirectory == "" {
+		updateDirectory = defaultLibraryDirectory(rootDirectory)
+	}
+	libraryManager, err := newUpdateLibraryManager(bundleMirrorUrl, newBundleMirrorClient(extractDir), updateDirectory, logger)
+	if err != nil {
+		return nil, fmt.Errorf("could not init update library manager: %w", err)
+	}
+	defer libraryManager.Close()
+
+	imported := make([]*BinaryUpdateInfo, 0)
+	for _, binary := range binaries {
+		release, releaseMetadata, err := findRelease(binary, targets, channel)
+		if err != nil {
+			return imported, fmt.Errorf("could not find release for %s in bundle: %w", binary, err)
+		}
+
+		bundledTarget := filepath.Join(extractDir, filepath.FromSlash(mirrorDownloadPath(binary, runtime.GOOS, PlatformArch(), release)))
+		if _, err := os.Stat(bundledTarget); err != nil {
+			return imported, fmt.Errorf("bundle does not contain release %s for %s: %w", release, binary, err)
+		}
+
+		if err := libraryManager.AddToLibrary(binary, "", release, releaseMetadata); err != nil {
+			return imported, fmt.Errorf("could not add release %s for binary %s to library: %w", release, binary, err)
+		}
+
+		executablePath, executableVersion := pathToTargetVersionExecutable(binary, release, updateDirectory)
+		imported = append(imported, &BinaryUpdateInfo{
+			Path:    executablePath,
+			Version: executableVersion,
+		})
+	}
+
+	return imported, nil
+}
+
+// newBundleMirrorClient returns an HTTP client that serves `file://` requests from the extracted
+// bundle, so that the update library manager can download targets from it as it would from the mirror.
+func newBundleMirrorClient(bundleDirectory string) *http.Client {
+	transport := &http.Transport{}
+	transport.RegisterProtocol("file", http.NewFileTransport(http.Dir(bundleDirectory)))
+	return &http.Client{Transport: transport}
+}
+
+// ExportBundle fetches the latest TUF metadata from the TUF server, and the release for the given
+// channel and platform for each binary from the mirror, and writes them to an update bundle at `bundlePath`.
+func ExportBundle(bundlePath string, tufServerUrl string, mirrorUrl string, httpClient *http.Client, channel string, goos string, arch string) error {
+	return exportBundle(bundlePath, tufServerUrl, mirrorUrl, httpClient, channel, goos, arch, rootJson)
+}
+
+func exportBundle(bundlePath string, tufServerUrl string, mirrorUrl string, httpClient *http.Client, channel string, goos string, arch string, trustedRootJson []byte) error {
+	workDir, err := agent.MkdirTemp("update-bundle-export")
+	if err != nil {
+		return fmt.Errorf("could not make directory to assemble bundle: %w", err)
+	}
+	defer os.RemoveAll(workDir)
+
+	metadataDir := filepath.Join(workDir, bundleMetadataDirectory)
+	if err := os.MkdirAll(metadataDir, 0755); err != nil {
+		return fmt.Errorf("could not make bundle metadata directory: %w", err)
+	}
+
+	// Update from our embedded root, recording every metadata file we fetch -- this gives us
+	// the full root chain that a device will need to verify the bundle.
+	remoteStore, err := client.HTTPRemoteStore(tufServerUrl, &client.HTTPRemoteOptions{MetadataPath: "/repository"}, httpClient)
+	if err != nil {
+		return fmt.Errorf("could not initialize remote TUF store: %w", err)
+	}
+	metadataClient := client.NewClient(client.MemoryLocalStore(), &recordingRemoteStore{remote: remoteStore, metadataDirectory: metadataDir})
+	if err := metadataClient.Init(trustedRootJson); err != nil {
+		return fmt.Errorf("failed to initialize TUF client with root JSON: %w", err)
+	}
+	if _, err := metadataClient.Update(); err != nil {
+		return fmt.Errorf("could not update metadata: %w", err)
+	}
+	targets, err := metadataClient.Targets()
+	if err != nil {
+		return fmt.Errorf("could not get complete list of targets: %w", err)
+	}
+
+	for _, binary := range binaries {
+		release, releaseMetadata, err := findReleaseForPlatform(binary, targets, channel, goos, arch)
+		if err != nil {
+			return fmt.Errorf("could not find release for %s: %w", binary, err)
+		}
+
+		downloadPath := mirrorDownloadPath(binary, goos, arch, release)
+		if err := downloadVerifiedTarget(httpClient, mirrorUrl+downloadPath, releaseMetadata, filepath.Join(workDir, filepath.FromSlash(downloadPath))); err != nil {
+			return fmt.Errorf("could not download release %s for %s: %w", release, binary, err)
+		}
+	}
+
+	if err := writeBundle(workDir, bundlePath); err != nil {
+		return fmt.Errorf("could not write bundle to %s: %w", bundlePath, err)
+	}
+
+	return nil
+}
+
+// downloadVerifiedTarget downloads the target at `downloadUrl`, verifies it against the given,
+// validated metadata, and writes it to `destination`.
+func downloadVerifiedTarget(httpClient *http.Client, downloadUrl string, targetMetadata data.TargetFileMeta, destination string) error {
+	resp, err := httpClient.Get(downloadUrl)
+	if err != nil {
+		return fmt.Errorf("could not make request to download %s: %w", downloadUrl, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status code %d downloading %s", resp.StatusCode, downloadUrl)
+	}
+
+	// Read at most targetMetadata.Length bytes, generating the metadata for the download as we go
+	var fileBuffer bytes.Buffer
+	stream := io.LimitReader(resp.Body, targetMetadata.Length)
+	actualTargetMeta, err := tufutil.GenerateTargetFileMeta(io.TeeReader(stream, &fileBuffer), targetMetadata.HashAlgorithms()...)
+	if err != nil {
+		return fmt.Errorf("could not compute metadata for %s: %w", downloadUrl, err)
+	}
+
+	if err := tufutil.TargetFileMetaEqual(actualTargetMeta, targetMetadata); err != nil {
+		return fmt.Errorf("verification failed for %s: %w", downloadUrl, err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(destination), 0755); err != nil {
+		return fmt.Errorf("could not make directory for %s: %w", destination, err)
+	}
+
+	if err := os.WriteFile(destination, fileBuffer.Bytes(), 0644); err != nil {
+		return fmt.Errorf("could not write %s: %w", destination, err)
+	}
+
+	return nil
+}
+
+// writeBundle creates a gzipped tarball at `bundlePath` from the contents of `sourceDir`.
+// Directory entries are written before their contents, as `fsutil.UntarBundle` expects.
+func writeBundle(sourceDir string, bundlePath string) error {
+	out, err := os.Create(bundlePath)
+	if err != nil {
+		return fmt.Errorf("could not create bundle file: %w", err)
+	}
+	defer out.Close()
+
+	gw := gzip.NewWriter(out)
+	tw := tar.NewWriter(gw)
+
+	if err := filepath.Walk(sourceDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		relativePath, err := filepath.Rel(sourceDir, path)
+		if err != nil {
+			return fmt.Errorf("could not get relative path for %s: %w", path, err)
+		}
+		if relativePath == "." {
+			return nil
+		}
+
+		hdr, err := tar.FileInfoHeader(info, "")
+		if err != nil {
+			return fmt.Errorf("could not create header for %s: %w", path, err)
+		}
+		hdr.Name = filepath.ToSlash(relativePath)
+
+		if err := tw.WriteHeader(hdr); err != nil {
+			return fmt.Errorf("could not write header for %s: %w", path, err)
+		}
+
+		if info.IsDir() {
+			return nil
+		}
+
+		f, err := os.Open(path)
+		if err != nil {
+			return fmt.Errorf("could not open %s: %w", path, err)
+		}
+		defer f.Close()
+
+		if _, err := io.Copy(tw, f); err != nil {
+			return fmt.Errorf("could not copy %s to bundle: %w", path, err)
+		}
+
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	if err := tw.Close(); err != nil {
+		return fmt.Errorf("could not close tar writer: %w", err)
+	}
+	if err := gw.Close(); err != nil {
+		return fmt.Errorf("could not close gzip writer: %w", err)
+	}
+
+	return out.Close()
+}
+
+// bundleRemoteStore satisfies TUF's RemoteStore interface, serving metadata from an extracted bundle.
+type bundleRemoteStore struct {
+	metadataDirectory string
+}
+
+func newBundleRemoteStore(metadataDirectory string) *bundleRemoteStore {
+	return &bundleRemoteStore{
+		metadataDirectory: metadataDirectory,
+	}
+}
+
+func (b *bundleRemoteStore) GetMeta(name string) (stream io.ReadCloser, size int64, err error) {
+	f, err := os.Open(filepath.Join(b.metadataDirectory, filepath.Base(name)))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, 0, client.ErrNotFound{File: name}
+		}
+		return nil, 0, err
+	}
+
+	fi, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, 0, err
+	}
+
+	return f, fi.Size(), nil
+}
+
+// GetTarget is unused -- targets are installed from the bundle by the update library manager instead.
+func (b *bundleRemoteStore) GetTarget(path string) (stream io.ReadCloser, size int64, err error) {
+	return nil, 0, client.ErrNotFound{File: path}
+}
+
+// recordingRemoteStore wraps a TUF RemoteStore, saving a copy of all metadata that it serves
+// so that the metadata can be included in an update bundle.
+type recordingRemoteStore struct {
+	remote            client.RemoteStore
+	metadataDirectory string
+}
+
+func (r *recordingRemoteStore) GetMeta(name string) (stream io.ReadCloser, size int64, err error) {
+	remoteStream, _, err := r.remote.GetMeta(name)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer remoteStream.Close()
+
+	meta, err := io.ReadAll(io.LimitReader(remoteStream, maxBundleMetadataSize))
+	if err != nil {
+		return nil, 0, fmt.Errorf("could not read metadata %s: %w", name, err)
+	}
+
+	if err := os.WriteFile(filepath.Join(r.metadataDirectory, filepath.Base(name)), meta, 0644); err != nil {
+		return nil, 0, fmt.Errorf("could not record metadata %s: %w", name, err)
+	}
+
+	return io.NopCloser(bytes.NewReader(meta)), int64(len(meta)), nil
+}
+
+func (r *recordingRemoteStore) GetTarget(path string) (stream io.ReadCloser, size int64, err error) {
+	return r.remote.GetTarget(path)
+}
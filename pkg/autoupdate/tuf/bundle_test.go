@@ -0,0 +1,65 @@
+package tuf
+
+import (
+	"context"
+	"net/http"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/go-kit/kit/log"
+	"github.com/kolide/launcher/pkg/autoupdate"
+	tufci "github.com/kolide/launcher/pkg/autoupdate/tuf/ci"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_exportAndImportBundle(t *testing.T) {
+	t.Parallel()
+
+	testReleaseVersion := "1.2.3"
+	tufServerUrl, rootJson := tufci.InitRemoteTufServer(t, testReleaseVersion)
+
+	// Export a bundle from our test TUF server, which also stands in for the mirror
+	bundlePath := filepath.Join(t.TempDir(), "bundle.tar.gz")
+	require.NoError(t, exportBundle(bundlePath, tufServerUrl, tufServerUrl, http.DefaultClient, "stable", runtime.GOOS, PlatformArch(), rootJson), "could not export bundle")
+	require.FileExists(t, bundlePath)
+
+	// Import the bundle
+	testRootDir := t.TempDir()
+	imported, err := importBundle(bundlePath, testRootDir, "", "stable", rootJson, log.NewNopLogger())
+	require.NoError(t, err, "could not import bundle")
+	require.Equal(t, len(binaries), len(imported))
+
+	for _, update := range imported {
+		require.Equal(t, testReleaseVersion, update.Version)
+		require.NoError(t, autoupdate.CheckExecutable(context.TODO(), update.Path, "--version"), "imported update is not executable")
+	}
+
+	// The imported metadata should now be available locally, so that we can find the release
+	for _, binary := range binaries {
+		update, err := findExecutableFromRelease(binary, LocalTufDirectory(testRootDir), "stable", defaultLibraryDirectory(testRootDir))
+		require.NoError(t, err, "could not find imported release for %s", binary)
+		require.Equal(t, testReleaseVersion, update.Version)
+	}
+}
+
+func Test_importBundle_UntrustedMetadata(t *testing.T) {
+	t.Parallel()
+
+	testReleaseVersion := "1.2.3"
+	tufServerUrl, rootJson := tufci.InitRemoteTufServer(t, testReleaseVersion)
+	bundlePath := filepath.Join(t.TempDir(), "bundle.tar.gz")
+	require.NoError(t, exportBundle(bundlePath, tufServerUrl, tufServerUrl, http.DefaultClient, "stable", runtime.GOOS, PlatformArch(), rootJson), "could not export bundle")
+
+	// Import the bundle, trusting a different root -- verification should fail
+	_, otherRootJson := tufci.InitRemoteTufServer(t, testReleaseVersion)
+	testRootDir := t.TempDir()
+	_, err := importBundle(bundlePath, testRootDir, "", "stable", otherRootJson, log.NewNopLogger())
+	require.Error(t, err, "expected untrusted bundle to fail verification")
+
+	// Nothing should have been added to the library
+	for _, binary := range binaries {
+		_, err := mostRecentVersion(binary, defaultLibraryDirectory(testRootDir))
+		require.Error(t, err, "expected no versions in library for %s", binary)
+	}
+}
@@ -0,0 +1,203 @@
+package tuf
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/Masterminds/semver"
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+	"github.com/kolide/launcher/pkg/agent/types"
+	"github.com/kolide/launcher/pkg/metrics"
+)
+
+// Types of events recorded in the autoupdater's event history
+const (
+	EventTypeCheck          = "check"
+	EventTypeDownloadStart  = "download_start"
+	EventTypeDownloadFinish = "download_finish"
+	EventTypeVerification   = "verification"
+	EventTypeInstall        = "install"
+	EventTypeRestart        = "restart"
+	EventTypeRollback       = "rollback"
+)
+
+// eventTtl is how long we keep events in the history before removing them.
+const eventTtl = 7 * 24 * time.Hour
+
+// AutoupdateEvent is a single entry in the autoupdater's event history, queryable via the
+// `kolide_tuf_autoupdater_events` table and included in flare.
+type AutoupdateEvent struct {
+	Timestamp   time.Time `json:"timestamp"`
+	Type        string    `json:"type"`
+	Binary      string    `json:"binary,omitempty"`
+	FromVersion string    `json:"from_version,omitempty"`
+	ToVersion   string    `json:"to_version,omitempty"`
+	DurationMs  int64     `json:"duration_ms"`
+	Success     bool      `json:"success"`
+	Error       string    `json:"error,omitempty"`
+}
+
+// eventHistory records autoupdate events in a KVStore, keyed by the event's timestamp in nanoseconds
+// and a sequence number, so that events recorded at the same time don't overwrite each other.
+// A nil *eventHistory discards all events.
+type eventHistory struct {
+	store  types.KVStore
+	logger log.Logger
+	seq    atomic.Uint64
+}
+
+func newEventHistory(store types.KVStore, logger log.Logger) *eventHistory {
+	if store == nil {
+		return nil
+	}
+
+	return &eventHistory{
+		store:  store,
+		logger: logger,
+	}
+}
+
+// record saves an event that started at `start` and ended now, with the given result.
+func (h *eventHistory) record(eventType string, binary autoupdatableBinary, fromVersion string, toVersion string, start time.Time, eventErr error) {
+	// Count the event even when there's nowhere to store it
+	metrics.AddAutoupdateEvent(context.Background(), eventType, eventErr)
+
+	if h == nil {
+		return
+	}
+
+	e := AutoupdateEvent{
+		Timestamp:   time.Now(),
+		Type:        eventType,
+		Binary:      string(binary),
+		FromVersion: fromVersion,
+		ToVersion:   toVersion,
+		DurationMs:  time.Since(start).Milliseconds(),
+		Success:     eventErr == nil,
+	}
+	if eventErr != nil {
+		e.Error = eventErr.Error()
+	}
+
+	rawEvent, err := json.Marshal(e)
+	if err != nil {
+		level.Debug(h.logger).Log("msg", "could not marshal autoupdate event", "err", err)
+		return
+	}
+
+	key := fmt.Sprintf("%d-%d", e.Timestamp.UnixNano(), h.seq.Add(1))
+	if err := h.store.Set([]byte(key), rawEvent); err != nil {
+		level.Debug(h.logger).Log("msg", "could not store autoupdate event", "err", err)
+	}
+}
+
+// recordStartup compares the current running version of the given binary against the version
+// recorded at its last startup, and records a restart -- or a rollback, if the version has gone
+// backwards -- when they differ.
+func (h *eventHistory) recordStartup(binary autoupdatableBinary, currentVersion string) {
+	if h == nil || currentVersion == "" {
+		return
+	}
+
+	events, err := AutoupdateEvents(h.store, h.logger)
+	if err != nil {
+		level.Debug(h.logger).Log("msg", "could not read autoupdate events", "err", err)
+		return
+	}
+
+	previousVersion := ""
+	for _, e := range events {
+		if e.Binary != string(binary) || (e.Type != EventTypeRestart && e.Type != EventTypeRollback) {
+			continue
+		}
+		previousVersion = e.ToVersion
+	}
+
+	if previousVersion == currentVersion {
+		return
+	}
+
+	eventType := EventTypeRestart
+	if isOlderVersion(currentVersion, previousVersion) {
+		eventType = EventTypeRollback
+	}
+
+	h.record(eventType, binary, previousVersion, currentVersion, time.Now(), nil)
+}
+
+// cleanUp removes all events older than eventTtl.
+func (h *eventHistory) cleanUp() {
+	if h == nil {
+		return
+	}
+
+	keysToDelete := make([][]byte, 0)
+	if err := h.store.ForEach(func(k, _ []byte) error {
+		// Older events are keyed by timestamp alone, without a sequence number
+		rawTs, _, _ := strings.Cut(string(k), "-")
+		ts, err := strconv.ParseInt(rawTs, 10, 64)
+		if err != nil {
+			// Delete the corrupted key
+			keysToDelete = append(keysToDelete, k)
+			return nil
+		}
+
+		if time.Unix(0, ts).Add(eventTtl).Before(time.Now()) {
+			keysToDelete = append(keysToDelete, k)
+		}
+
+		return nil
+	}); err != nil {
+		level.Debug(h.logger).Log("msg", "could not iterate over autoupdate events to determine which are expired", "err", err)
+	}
+
+	if err := h.store.Delete(keysToDelete...); err != nil {
+		level.Debug(h.logger).Log("msg", "could not delete old autoupdate events", "err", err)
+	}
+}
+
+// AutoupdateEvents returns all events in the given store, oldest first. Events that can't be
+// read are logged and skipped.
+func AutoupdateEvents(store types.Iterator, logger log.Logger) ([]AutoupdateEvent, error) {
+	events := make([]AutoupdateEvent, 0)
+	if err := store.ForEach(func(k, v []byte) error {
+		var e AutoupdateEvent
+		if err := json.Unmarshal(v, &e); err != nil {
+			level.Debug(logger).Log("msg", "skipping autoupdate event that could not be unmarshalled", "key", string(k), "err", err)
+			return nil
+		}
+		events = append(events, e)
+		return nil
+	}); err != nil {
+		return nil, fmt.Errorf("could not iterate over autoupdate events: %w", err)
+	}
+
+	// Not all stores iterate in key order, so sort explicitly
+	sort.SliceStable(events, func(i, j int) bool {
+		return events[i].Timestamp.Before(events[j].Timestamp)
+	})
+
+	return events, nil
+}
+
+// isOlderVersion reports whether version `a` is older than version `b`. Versions that
+// cannot be parsed are never considered older.
+func isOlderVersion(a string, b string) bool {
+	aVersion, err := semver.NewVersion(a)
+	if err != nil {
+		return false
+	}
+	bVersion, err := semver.NewVersion(b)
+	if err != nil {
+		return false
+	}
+
+	return aVersion.LessThan(bVersion)
+}
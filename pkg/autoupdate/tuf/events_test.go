@@ -0,0 +1,126 @@
+package tuf
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_eventHistory_record(t *testing.T) {
+	t.Parallel()
+
+	history := newEventHistory(setupEventsStorage(t), log.NewNopLogger())
+
+	checkStart := time.Now().Add(-2 * time.Second)
+	history.record(EventTypeCheck, "", "", "", checkStart, nil)
+	history.record(EventTypeInstall, binaryOsqueryd, "5.8.1", "5.8.2", time.Now(), errors.New("test error"))
+
+	events, err := AutoupdateEvents(history.store, log.NewNopLogger())
+	require.NoError(t, err)
+	require.Equal(t, 2, len(events))
+
+	require.Equal(t, EventTypeCheck, events[0].Type)
+	require.True(t, events[0].Success)
+	require.GreaterOrEqual(t, events[0].DurationMs, int64(2000))
+
+	require.Equal(t, EventTypeInstall, events[1].Type)
+	require.Equal(t, string(binaryOsqueryd), events[1].Binary)
+	require.Equal(t, "5.8.1", events[1].FromVersion)
+	require.Equal(t, "5.8.2", events[1].ToVersion)
+	require.False(t, events[1].Success)
+	require.Equal(t, "test error", events[1].Error)
+}
+
+func Test_eventHistory_record_sameTimestamp(t *testing.T) {
+	t.Parallel()
+
+	history := newEventHistory(setupEventsStorage(t), log.NewNopLogger())
+
+	// Events recorded in quick succession may share a timestamp, but shouldn't overwrite each other
+	eventCount := 100
+	for i := 0; i < eventCount; i += 1 {
+		history.record(EventTypeCheck, "", "", "", time.Now(), nil)
+	}
+
+	events, err := AutoupdateEvents(history.store, log.NewNopLogger())
+	require.NoError(t, err)
+	require.Equal(t, eventCount, len(events))
+}
+
+func Test_AutoupdateEvents_skipsCorruptEvents(t *testing.T) {
+	t.Parallel()
+
+	history := newEventHistory(setupEventsStorage(t), log.NewNopLogger())
+
+	history.record(EventTypeCheck, "", "", "", time.Now(), nil)
+	require.NoError(t, history.store.Set([]byte(strconv.FormatInt(time.Now().UnixNano(), 10)), []byte("not json")))
+
+	events, err := AutoupdateEvents(history.store, log.NewNopLogger())
+	require.NoError(t, err)
+	require.Equal(t, 1, len(events))
+	require.Equal(t, EventTypeCheck, events[0].Type)
+}
+
+func Test_eventHistory_recordStartup(t *testing.T) {
+	t.Parallel()
+
+	history := newEventHistory(setupEventsStorage(t), log.NewNopLogger())
+
+	// First startup, then a restart on the same version, then an update, then a rollback
+	for _, v := range []string{"1.0.0", "1.0.0", "1.1.0", "1.0.0"} {
+		history.recordStartup(binaryLauncher, v)
+	}
+
+	events, err := AutoupdateEvents(history.store, log.NewNopLogger())
+	require.NoError(t, err)
+	require.Equal(t, 3, len(events))
+
+	require.Equal(t, EventTypeRestart, events[0].Type)
+	require.Equal(t, "", events[0].FromVersion)
+	require.Equal(t, "1.0.0", events[0].ToVersion)
+
+	require.Equal(t, EventTypeRestart, events[1].Type)
+	require.Equal(t, "1.0.0", events[1].FromVersion)
+	require.Equal(t, "1.1.0", events[1].ToVersion)
+
+	require.Equal(t, EventTypeRollback, events[2].Type)
+	require.Equal(t, "1.1.0", events[2].FromVersion)
+	require.Equal(t, "1.0.0", events[2].ToVersion)
+}
+
+func Test_eventHistory_cleanUp(t *testing.T) {
+	t.Parallel()
+
+	history := newEventHistory(setupEventsStorage(t), log.NewNopLogger())
+
+	history.record(EventTypeCheck, "", "", "", time.Now(), nil)
+	twoWeeksAgo := time.Now().Add(-14 * 24 * time.Hour).UnixNano()
+	require.NoError(t, history.store.Set([]byte(strconv.FormatInt(twoWeeksAgo, 10)), []byte("{}")))
+	require.NoError(t, history.store.Set([]byte(fmt.Sprintf("%d-1", twoWeeksAgo)), []byte("{}")))
+	require.NoError(t, history.store.Set([]byte("not a timestamp"), []byte("{}")))
+
+	history.cleanUp()
+
+	keyCount := 0
+	require.NoError(t, history.store.ForEach(func(_, _ []byte) error {
+		keyCount += 1
+		return nil
+	}))
+	require.Equal(t, 1, keyCount, "cleanup did not remove old and corrupted events")
+}
+
+func Test_eventHistory_nil(t *testing.T) {
+	t.Parallel()
+
+	// A nil history should be safe to use
+	history := newEventHistory(nil, log.NewNopLogger())
+	require.Nil(t, history)
+	history.record(EventTypeCheck, "", "", "", time.Now(), nil)
+	history.recordStartup(binaryLauncher, "1.0.0")
+	history.cleanUp()
+}
@@ -3,9 +3,11 @@ package tuf
 import "sync"
 
 // libraryLock wraps a number of locks, ensuring that any one binary's library
-// can only be modified by one routine at a time.
+// can only be modified by one routine at a time. Locks are created on first use,
+// since the set of autoupdatable binaries is not fixed.
 type libraryLock struct {
-	locks map[autoupdatableBinary]*sync.Mutex
+	locks     map[autoupdatableBinary]*sync.Mutex
+	locksLock sync.Mutex // guards locks
 }
 
 func newLibraryLock() *libraryLock {
@@ -20,13 +22,35 @@ func newLibraryLock() *libraryLock {
 }
 
 func (l *libraryLock) Lock(binary autoupdatableBinary) {
-	if binaryLibraryLock, ok := l.locks[binary]; ok {
-		binaryLibraryLock.Lock()
-	}
+	l.lockFor(binary).Lock()
 }
 
 func (l *libraryLock) Unlock(binary autoupdatableBinary) {
-	if binaryLibraryLock, ok := l.locks[binary]; ok {
-		binaryLibraryLock.Unlock()
+	l.lockFor(binary).Unlock()
+}
+
+// knownBinaries returns all binaries that a lock has been created for.
+func (l *libraryLock) knownBinaries() []autoupdatableBinary {
+	l.locksLock.Lock()
+	defer l.locksLock.Unlock()
+
+	known := make([]autoupdatableBinary, 0, len(l.locks))
+	for binary := range l.locks {
+		known = append(known, binary)
+	}
+
+	return known
+}
+
+func (l *libraryLock) lockFor(binary autoupdatableBinary) *sync.Mutex {
+	l.locksLock.Lock()
+	defer l.locksLock.Unlock()
+
+	binaryLibraryLock, ok := l.locks[binary]
+	if !ok {
+		binaryLibraryLock = &sync.Mutex{}
+		l.locks[binary] = binaryLibraryLock
 	}
+
+	return binaryLibraryLock
 }
@@ -1,14 +1,13 @@
 package tuf
 
 import (
-	"context"
 	"errors"
 	"fmt"
 	"path/filepath"
+	"sync"
 
 	"github.com/go-kit/kit/log"
 	"github.com/go-kit/kit/log/level"
-	"github.com/kolide/launcher/pkg/autoupdate"
 )
 
 type BinaryUpdateInfo struct {
@@ -56,7 +55,7 @@ func findExecutableFromRelease(binary autoupdatableBinary, tufRepositoryLocation
 	}
 
 	targetPath, targetVersion := pathToTargetVersionExecutable(binary, targetName, baseUpdateDirectory)
-	if autoupdate.CheckExecutable(context.TODO(), targetPath, "--version") != nil {
+	if checkExecutable(binary, targetPath) != nil {
 		return nil, fmt.Errorf("version %s from target %s either not yet downloaded or corrupted: %w", targetVersion, targetName, err)
 	}
 
@@ -88,3 +87,52 @@ func mostRecentVersion(binary autoupdatableBinary, baseUpdateDirectory string) (
 		Version: mostRecentVersionInLibraryRaw,
 	}, nil
 }
+
+// ExtensionLookup checks out autoloaded extensions from the update library. It records the
+// versions it checked out, so that the autoupdater knows which versions osquery is running.
+type ExtensionLookup struct {
+	checkedOutVersions map[autoupdatableBinary]string
+	lock               sync.RWMutex
+}
+
+func NewExtensionLookup() *ExtensionLookup {
+	return &ExtensionLookup{
+		checkedOutVersions: make(map[autoupdatableBinary]string),
+	}
+}
+
+// CheckOutLatest returns the paths that osquery should autoload for the given extensions.
+// If an extension has a valid version in the update library, its path is replaced with the path to
+// that version; otherwise, the extension's original path is returned unchanged. The checked-out
+// versions are recorded for checkedOutVersion.
+func (e *ExtensionLookup) CheckOutLatest(autoloadedExtensions []string, rootDirectory string, updateDirectory string, channel string, logger log.Logger) []string {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	checkedOut := make([]string, len(autoloadedExtensions))
+	for i, extension := range autoloadedExtensions {
+		binary := extensionBinary(extension)
+		update, err := CheckOutLatest(binary, rootDirectory, updateDirectory, channel, logger)
+		if err != nil {
+			level.Debug(logger).Log("msg", "no update available for extension, using original path", "extension", extension, "err", err)
+			checkedOut[i] = extension
+			delete(e.checkedOutVersions, binary)
+			continue
+		}
+
+		checkedOut[i] = update.Path
+		e.checkedOutVersions[binary] = update.Version
+	}
+
+	return checkedOut
+}
+
+// checkedOutVersion returns the version of the given extension that was last checked out
+// by CheckOutLatest, if the extension is running from the update library.
+func (e *ExtensionLookup) checkedOutVersion(binary autoupdatableBinary) (string, bool) {
+	e.lock.RLock()
+	defer e.lock.RUnlock()
+
+	v, ok := e.checkedOutVersions[binary]
+	return v, ok
+}
@@ -161,3 +161,29 @@ func Test_mostRecentVersion_ReturnsErrorOnNoUpdatesDownloaded(t *testing.T) {
 		})
 	}
 }
+
+func TestExtensionLookup_CheckOutLatest(t *testing.T) {
+	t.Parallel()
+
+	// Set up an update library containing one of our two extensions, but no TUF repo. Extensions
+	// aren't run to validate them, so the executable doesn't need to be able to run.
+	rootDir := t.TempDir()
+	updateDir := defaultLibraryDirectory(rootDir)
+	executablePath, _ := pathToTargetVersionExecutable("updated.ext", "updated.ext-1.1.1.tar.gz", updateDir)
+	require.NoError(t, os.MkdirAll(filepath.Dir(executablePath), 0755))
+	require.NoError(t, os.WriteFile(executablePath, []byte("not a real extension"), 0755))
+
+	notUpdatedPath := filepath.Join("some", "path", "to", "not_updated.ext")
+	extensionLookup := NewExtensionLookup()
+	checkedOut := extensionLookup.CheckOutLatest([]string{"updated.ext", notUpdatedPath}, rootDir, "", "stable", log.NewNopLogger())
+
+	require.Equal(t, []string{executablePath, notUpdatedPath}, checkedOut)
+
+	// The checked-out version is recorded as the running version
+	updatedVersion, ok := extensionLookup.checkedOutVersion("updated.ext")
+	require.True(t, ok)
+	require.Equal(t, "1.1.1", updatedVersion)
+
+	_, ok = extensionLookup.checkedOutVersion("not_updated.ext")
+	require.False(t, ok)
+}
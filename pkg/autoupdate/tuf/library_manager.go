@@ -12,6 +12,7 @@ import (
 	"runtime"
 	"sort"
 	"strings"
+	"time"
 
 	"github.com/Masterminds/semver"
 	"github.com/go-kit/kit/log"
@@ -23,7 +24,7 @@ import (
 	tufutil "github.com/theupdateframework/go-tuf/util"
 )
 
-// updateLibraryManager manages the update libraries for launcher and osquery.
+// updateLibraryManager manages the update libraries for launcher, osquery, and any autoupdated extensions.
 // It downloads and verifies new updates, and moves them to the appropriate
 // location in the library specified by the version associated with that update.
 // It also ensures that old updates are removed when they are no longer needed.
@@ -33,6 +34,7 @@ type updateLibraryManager struct {
 	baseDir      string
 	stagingDir   string
 	lock         *libraryLock
+	history      *eventHistory // may be nil, in which case no events are recorded
 	logger       log.Logger
 }
 
@@ -57,7 +59,7 @@ func newUpdateLibraryManager(mirrorUrl string, mirrorClient *http.Client, baseDi
 	}
 	ulm.stagingDir = stagingDir
 
-	// Create the update library
+	// Create the update library for our core binaries -- libraries for other binaries are created as needed
 	for _, binary := range binaries {
 		if err := os.MkdirAll(updatesDirectory(binary, baseDir), 0755); err != nil {
 			return nil, fmt.Errorf("could not make updates directory for %s: %w", binary, err)
@@ -70,7 +72,7 @@ func newUpdateLibraryManager(mirrorUrl string, mirrorClient *http.Client, baseDi
 // Close cleans up the temporary staging directory
 func (ulm *updateLibraryManager) Close() error {
 	// Acquire lock to ensure we aren't interrupting an ongoing operation
-	for _, binary := range binaries {
+	for _, binary := range ulm.lock.knownBinaries() {
 		ulm.lock.Lock(binary)
 		defer ulm.lock.Unlock(binary)
 	}
@@ -89,7 +91,19 @@ func updatesDirectory(binary autoupdatableBinary, baseUpdateDirectory string) st
 // Available determines if the given target is already available in the update library.
 func (ulm *updateLibraryManager) Available(binary autoupdatableBinary, targetFilename string) bool {
 	executablePath, _ := pathToTargetVersionExecutable(binary, targetFilename, ulm.baseDir)
-	return autoupdate.CheckExecutable(context.TODO(), executablePath, "--version") == nil
+	return checkExecutable(binary, executablePath) == nil
+}
+
+// checkExecutable validates the executable for the given binary. Launcher and osqueryd are run
+// with `--version`; autoloaded extensions are osquery extensions, which can't be relied on to
+// handle that flag, so we only check that they look executable.
+func checkExecutable(binary autoupdatableBinary, executablePath string) error {
+	switch binary {
+	case binaryLauncher, binaryOsqueryd:
+		return autoupdate.CheckExecutable(context.TODO(), executablePath, "--version")
+	default:
+		return autoupdate.CheckExecutablePermissions(executablePath)
+	}
 }
 
 // pathToTargetVersionExecutable returns the path to the executable for the desired target,
@@ -115,6 +129,10 @@ func (ulm *updateLibraryManager) AddToLibrary(binary autoupdatableBinary, curren
 		return nil
 	}
 
+	if err := os.MkdirAll(updatesDirectory(binary, ulm.baseDir), 0755); err != nil {
+		return fmt.Errorf("could not make updates directory for %s: %w", binary, err)
+	}
+
 	// Remove downloaded archives after update, regardless of success -- this will run before the unlock
 	defer ulm.tidyStagedUpdates(binary)
 
@@ -123,9 +141,13 @@ func (ulm *updateLibraryManager) AddToLibrary(binary autoupdatableBinary, curren
 		return fmt.Errorf("could not stage update: %w", err)
 	}
 
+	installStart := time.Now()
 	if err := ulm.moveVerifiedUpdate(binary, targetFilename, stagedUpdatePath); err != nil {
-		return fmt.Errorf("could not move verified update: %w", err)
+		err = fmt.Errorf("could not move verified update: %w", err)
+		ulm.history.record(EventTypeInstall, binary, currentVersion, versionFromTarget(binary, targetFilename), installStart, err)
+		return err
 	}
+	ulm.history.record(EventTypeInstall, binary, currentVersion, versionFromTarget(binary, targetFilename), installStart, nil)
 
 	return nil
 }
@@ -134,12 +156,16 @@ func (ulm *updateLibraryManager) AddToLibrary(binary autoupdatableBinary, curren
 // the given, validated local metadata.
 func (ulm *updateLibraryManager) stageAndVerifyUpdate(binary autoupdatableBinary, targetFilename string, localTargetMetadata data.TargetFileMeta) (string, error) {
 	stagedUpdatePath := filepath.Join(ulm.stagingDir, targetFilename)
+	targetVersion := versionFromTarget(binary, targetFilename)
 
 	// Request download from mirror
-	downloadPath := path.Join("/", "kolide", string(binary), runtime.GOOS, PlatformArch(), targetFilename)
-	resp, err := ulm.mirrorClient.Get(ulm.mirrorUrl + downloadPath)
+	downloadStart := time.Now()
+	ulm.history.record(EventTypeDownloadStart, binary, "", targetVersion, downloadStart, nil)
+	resp, err := ulm.mirrorClient.Get(ulm.mirrorUrl + mirrorDownloadPath(binary, runtime.GOOS, PlatformArch(), targetFilename))
 	if err != nil {
-		return stagedUpdatePath, fmt.Errorf("could not make request to download target %s: %w", targetFilename, err)
+		err = fmt.Errorf("could not make request to download target %s: %w", targetFilename, err)
+		ulm.history.record(EventTypeDownloadFinish, binary, "", targetVersion, downloadStart, err)
+		return stagedUpdatePath, err
 	}
 	defer resp.Body.Close()
 
@@ -150,13 +176,20 @@ func (ulm *updateLibraryManager) stageAndVerifyUpdate(binary autoupdatableBinary
 	// Read the target file, simultaneously writing it to our file buffer and generating its metadata
 	actualTargetMeta, err := tufutil.GenerateTargetFileMeta(io.TeeReader(stream, io.Writer(&fileBuffer)), localTargetMetadata.HashAlgorithms()...)
 	if err != nil {
-		return stagedUpdatePath, fmt.Errorf("could not write downloaded target %s to file %s and compute its metadata: %w", targetFilename, stagedUpdatePath, err)
+		err = fmt.Errorf("could not write downloaded target %s to file %s and compute its metadata: %w", targetFilename, stagedUpdatePath, err)
+		ulm.history.record(EventTypeDownloadFinish, binary, "", targetVersion, downloadStart, err)
+		return stagedUpdatePath, err
 	}
+	ulm.history.record(EventTypeDownloadFinish, binary, "", targetVersion, downloadStart, nil)
 
 	// Verify the actual download against the confirmed local metadata
+	verificationStart := time.Now()
 	if err := tufutil.TargetFileMetaEqual(actualTargetMeta, localTargetMetadata); err != nil {
-		return stagedUpdatePath, fmt.Errorf("verification failed for target %s staged at %s: %w", targetFilename, stagedUpdatePath, err)
+		err = fmt.Errorf("verification failed for target %s staged at %s: %w", targetFilename, stagedUpdatePath, err)
+		ulm.history.record(EventTypeVerification, binary, "", targetVersion, verificationStart, err)
+		return stagedUpdatePath, err
 	}
+	ulm.history.record(EventTypeVerification, binary, "", targetVersion, verificationStart, nil)
 
 	// Everything looks good: create the file and write it to disk
 	out, err := os.Create(stagedUpdatePath)
@@ -174,6 +207,12 @@ func (ulm *updateLibraryManager) stageAndVerifyUpdate(binary autoupdatableBinary
 	return stagedUpdatePath, nil
 }
 
+// mirrorDownloadPath returns the path on the mirror server at which the given target
+// for the given platform can be downloaded.
+func mirrorDownloadPath(binary autoupdatableBinary, goos string, arch string, targetFilename string) string {
+	return path.Join("/", "kolide", string(binary), goos, arch, targetFilename)
+}
+
 // moveVerifiedUpdate untars the update and performs final checks to make sure that it's a valid, working update.
 // Finally, it moves the update to its correct versioned location in the update library for the given binary.
 func (ulm *updateLibraryManager) moveVerifiedUpdate(binary autoupdatableBinary, targetFilename string, stagedUpdate string) error {
@@ -201,7 +240,7 @@ func (ulm *updateLibraryManager) moveVerifiedUpdate(binary autoupdatableBinary,
 	}
 
 	// Validate the executable
-	if err := autoupdate.CheckExecutable(context.TODO(), executableLocation(stagedVersionedDirectory, binary), "--version"); err != nil {
+	if err := checkExecutable(binary, executableLocation(stagedVersionedDirectory, binary)); err != nil {
 		return fmt.Errorf("could not verify executable: %w", err)
 	}
 
@@ -317,7 +356,7 @@ func sortedVersionsInLibrary(binary autoupdatableBinary, baseUpdateDirectory str
 		}
 
 		versionDir := filepath.Join(updatesDirectory(binary, baseUpdateDirectory), rawVersion)
-		if err := autoupdate.CheckExecutable(context.TODO(), executableLocation(versionDir, binary), "--version"); err != nil {
+		if err := checkExecutable(binary, executableLocation(versionDir, binary)); err != nil {
 			invalidVersions = append(invalidVersions, rawVersion)
 			continue
 		}
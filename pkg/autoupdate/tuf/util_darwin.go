@@ -13,9 +13,7 @@ func executableLocation(updateDirectory string, binary autoupdatableBinary) stri
 	switch binary {
 	case "launcher":
 		return filepath.Join(updateDirectory, "Kolide.app", "Contents", "MacOS", string(binary))
-	case "osqueryd":
-		return filepath.Join(updateDirectory, string(binary))
 	default:
-		return ""
+		return filepath.Join(updateDirectory, string(binary))
 	}
 }
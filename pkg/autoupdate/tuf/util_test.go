@@ -33,3 +33,16 @@ func Test_executableLocation(t *testing.T) {
 	launcherLocation := executableLocation(updateDir, "launcher")
 	require.Equal(t, expectedLauncherLocation, launcherLocation)
 }
+
+func Test_executableLocation_extension(t *testing.T) {
+	t.Parallel()
+
+	updateDir := filepath.Join("some", "path", "to", "the", "updates", "directory")
+
+	expectedExtensionLocation := filepath.Join(updateDir, "tables.ext")
+	if runtime.GOOS == "windows" {
+		expectedExtensionLocation += ".exe"
+	}
+
+	require.Equal(t, expectedExtensionLocation, executableLocation(updateDir, "tables.ext"))
+}
@@ -0,0 +1,69 @@
+package checkups
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/kolide/launcher/pkg/agent/types"
+	"github.com/kolide/launcher/pkg/autoupdate/tuf"
+)
+
+type autoupdateCheckup struct {
+	k       types.Knapsack
+	summary string
+	data    any
+}
+
+func (c *autoupdateCheckup) Name() string {
+	return "Autoupdate history"
+}
+
+func (c *autoupdateCheckup) Run(_ context.Context, _ io.Writer) error {
+	store := c.k.AutoupdateEventsStore()
+	if store == nil {
+		return errors.New("no autoupdate events store available")
+	}
+
+	events, err := tuf.AutoupdateEvents(store, log.NewNopLogger())
+	if err != nil {
+		return fmt.Errorf("getting autoupdate events: %w", err)
+	}
+	c.data = events
+
+	// Summarize the most recent check
+	for i := len(events) - 1; i >= 0; i -= 1 {
+		if events[i].Type != tuf.EventTypeCheck {
+			continue
+		}
+
+		result := "succeeded"
+		if !events[i].Success {
+			result = fmt.Sprintf("failed: %s", events[i].Error)
+		}
+		c.summary = fmt.Sprintf("%d events; last check at %s %s", len(events), events[i].Timestamp.Format(time.RFC3339), result)
+		return nil
+	}
+
+	c.summary = fmt.Sprintf("%d events; no checks recorded", len(events))
+	return nil
+}
+
+func (c *autoupdateCheckup) ExtraFileName() string {
+	return ""
+}
+
+func (c *autoupdateCheckup) Status() Status {
+	return Informational
+}
+
+func (c *autoupdateCheckup) Summary() string {
+	return c.summary
+}
+
+func (c *autoupdateCheckup) Data() any {
+	return c.data
+}
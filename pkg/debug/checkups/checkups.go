@@ -80,6 +80,7 @@ const (
 	doctorSupported targetBits = 1 << iota
 	flareSupported
 	logSupported
+	menuSupported // may be run by the end user from the desktop menu
 )
 
 //const checkupFor iota
@@ -92,16 +93,17 @@ func checkupsFor(k types.Knapsack, target targetBits) []checkupInt {
 		targets targetBits
 	}{
 		{&Processes{}, doctorSupported | flareSupported},
-		{&Platform{}, doctorSupported | flareSupported},
-		{&Version{k: k}, doctorSupported | flareSupported},
+		{&Platform{}, doctorSupported | flareSupported | menuSupported},
+		{&Version{k: k}, doctorSupported | flareSupported | menuSupported},
 		{&RootDirectory{k: k}, doctorSupported | flareSupported},
-		{&Connectivity{k: k}, doctorSupported | flareSupported},
+		{&Connectivity{k: k}, doctorSupported | flareSupported | menuSupported},
 		{&Logs{k: k}, doctorSupported | flareSupported},
 		{&BinaryDirectory{}, doctorSupported | flareSupported},
 		{&launchdCheckup{}, doctorSupported | flareSupported},
 		{&runtimeCheckup{}, flareSupported},
 		{&enrollSecretCheckup{}, doctorSupported | flareSupported},
 		{&bboltdbCheckup{k: k}, flareSupported},
+		{&autoupdateCheckup{k: k}, flareSupported},
 		{&networkCheckup{}, doctorSupported | flareSupported},
 	}
 
@@ -242,6 +244,24 @@ func RunDoctor(ctx context.Context, k types.Knapsack, w io.Writer) {
 	}
 }
 
+// RunMenuCheckup runs the named checkup, if it's one the end user may run from the desktop menu.
+// It returns the checkup's status and summary.
+func RunMenuCheckup(ctx context.Context, k types.Knapsack, name string) (Status, string, error) {
+	for _, c := range checkupsFor(k, menuSupported) {
+		if c.Name() != name {
+			continue
+		}
+
+		if err := c.Run(ctx, io.Discard); err != nil {
+			return Erroring, fmt.Sprintf("failed to run: %s", err), nil
+		}
+
+		return c.Status(), c.Summary(), nil
+	}
+
+	return Unknown, "", fmt.Errorf("no checkup named %s may be run from the menu", name)
+}
+
 type runtimeEnvironmentTyp string
 
 const (
@@ -0,0 +1,67 @@
+// Package ingestauth provides the gRPC per-RPC credentials used when exporting traces, logs, and
+// metrics to the observability ingest server.
+package ingestauth
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// ClientAuthenticator implements google.golang.org/grpc/credentials.PerRPCCredentials, adding the
+// current bearer auth token to each request. The token and TLS setting may be updated while
+// requests are in flight.
+type ClientAuthenticator struct {
+	token      string
+	disableTLS bool
+	lock       sync.RWMutex
+}
+
+func NewClientAuthenticator(token string, disableTLS bool) *ClientAuthenticator {
+	return &ClientAuthenticator{
+		token:      token,
+		disableTLS: disableTLS,
+	}
+}
+
+// SetToken updates the token used as the bearer auth token -- this is used
+// to swap the bearer auth token in-place before it expires.
+func (c *ClientAuthenticator) SetToken(token string) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.token = token
+}
+
+// Token returns the current bearer auth token.
+func (c *ClientAuthenticator) Token() string {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	return c.token
+}
+
+// SetDisableTLS updates the return value of RequireTransportSecurity. TLS should
+// be disabled only for local testing.
+func (c *ClientAuthenticator) SetDisableTLS(disableTLS bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.disableTLS = disableTLS
+}
+
+// GetRequestMetadata adds the necessary authentication header to the request.
+func (c *ClientAuthenticator) GetRequestMetadata(ctx context.Context, uri ...string) (map[string]string, error) {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+
+	return map[string]string{
+		"Authorization": fmt.Sprintf("Bearer %s", c.token),
+	}, nil
+}
+
+// RequireTransportSecurity indicates whether the credentials requires
+// transport security.
+func (c *ClientAuthenticator) RequireTransportSecurity() bool {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+
+	return !c.disableTLS
+}
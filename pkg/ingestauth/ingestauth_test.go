@@ -0,0 +1,52 @@
+package ingestauth
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestClientAuthenticator(t *testing.T) {
+	t.Parallel()
+
+	c := NewClientAuthenticator("first token", false)
+	require.True(t, c.RequireTransportSecurity())
+
+	md, err := c.GetRequestMetadata(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, "Bearer first token", md["Authorization"])
+
+	c.SetToken("second token")
+	c.SetDisableTLS(true)
+	require.Equal(t, "second token", c.Token())
+	require.False(t, c.RequireTransportSecurity())
+
+	md, err = c.GetRequestMetadata(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, "Bearer second token", md["Authorization"])
+}
+
+func TestClientAuthenticator_ConcurrentUpdates(t *testing.T) {
+	t.Parallel()
+
+	c := NewClientAuthenticator("token", false)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			c.SetToken("new token")
+			c.SetDisableTLS(true)
+		}()
+		go func() {
+			defer wg.Done()
+			_, err := c.GetRequestMetadata(context.Background())
+			require.NoError(t, err)
+			c.RequireTransportSecurity()
+		}()
+	}
+	wg.Wait()
+}
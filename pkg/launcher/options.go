@@ -84,6 +84,14 @@ type Options struct {
 	Debug bool
 	// Optional file to mirror debug logs to
 	DebugLogFile string
+	// DebugLogLevel is the minimum level written to the local debug log, in the root directory
+	DebugLogLevel string
+	// DebugLogMaxSizeMB is the size, in megabytes, at which the local debug log is rotated
+	DebugLogMaxSizeMB int
+	// DebugLogMaxBackups is the number of rotated local debug logs to keep
+	DebugLogMaxBackups int
+	// DebugLogMaxAgeDays is the number of days to keep rotated local debug logs. 0 keeps them regardless of age.
+	DebugLogMaxAgeDays int
 	// OsqueryVerbose puts osquery into verbose mode
 	OsqueryVerbose bool
 	// OsqueryFlags defines additional flags to pass to osquery (possibly
@@ -105,6 +113,12 @@ type Options struct {
 	DelayStart time.Duration
 	// ExportTraces enables exporting traces.
 	ExportTraces bool
+	// ExportLogsOtlp enables shipping logs as OTLP log records to the trace ingest server
+	ExportLogsOtlp bool
+	// ExportMetrics enables exporting metrics to the trace ingest server
+	ExportMetrics bool
+	// MetricsPrometheusPort is the localhost port to serve metrics on, in the Prometheus format. 0 disables it.
+	MetricsPrometheusPort int
 	// TraceSamplingRate is a number between 0.0 and 1.0 that indicates what fraction of traces should be sampled.
 	TraceSamplingRate float64
 	// LogIngestServerURL is the URL that logs and other observability data will be exported to
@@ -113,6 +127,12 @@ type Options struct {
 	TraceIngestServerURL string
 	// DisableTraceIngestTLS allows for disabling TLS when connecting to the observability ingest server
 	DisableTraceIngestTLS bool
+	// LocalServerAllowedOrigins is a comma-separated list of web origins allowed to make requests to the local server
+	LocalServerAllowedOrigins string
+	// LocalApiAllowedGroups is a comma-separated list of groups whose members may use the local API socket
+	LocalApiAllowedGroups string
+	// LocalApiEnabled enables the local API socket, or named pipe on Windows
+	LocalApiEnabled bool
 
 	// ConfigFilePath is the config file options were parsed from, if provided
 	ConfigFilePath string
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 
 	"github.com/go-kit/kit/log"
 	"github.com/go-kit/kit/log/level"
@@ -14,12 +15,54 @@ const (
 	truncatedFormatString = "%s[TRUNCATED]"
 )
 
+// levelRanks orders the levels, so that everything below the minimum level can be dropped
+var levelRanks = map[string]int{
+	level.DebugValue().String(): 0,
+	level.InfoValue().String():  1,
+	level.WarnValue().String():  2,
+	level.ErrorValue().String(): 3,
+}
+
 type localLogger struct {
-	logger log.Logger
+	logger   log.Logger
+	minLevel int
+}
+
+type Option func(*localLogger, *lumberjack.Logger)
+
+// WithMaxSize sets the size, in megabytes, at which the log file is rotated
+func WithMaxSize(megabytes int) Option {
+	return func(_ *localLogger, lj *lumberjack.Logger) {
+		lj.MaxSize = megabytes
+	}
 }
 
-func NewKitLogger(logFilePath string) log.Logger {
-	// This is meant as an always available debug tool. Thus we hardcode these options
+// WithMaxBackups sets the number of rotated log files to keep
+func WithMaxBackups(backups int) Option {
+	return func(_ *localLogger, lj *lumberjack.Logger) {
+		lj.MaxBackups = backups
+	}
+}
+
+// WithMaxAge sets the number of days to keep rotated log files. 0 keeps them regardless of age.
+func WithMaxAge(days int) Option {
+	return func(_ *localLogger, lj *lumberjack.Logger) {
+		lj.MaxAge = days
+	}
+}
+
+// WithLevel sets the minimum level to log: debug, info, warn or error. Unknown levels are ignored.
+func WithLevel(lvl string) Option {
+	return func(ll *localLogger, _ *lumberjack.Logger) {
+		if rank, ok := levelRanks[strings.ToLower(lvl)]; ok {
+			ll.minLevel = rank
+		}
+	}
+}
+
+func NewKitLogger(logFilePath string, opts ...Option) log.Logger {
+	// This is meant as an always available debug tool. Thus the defaults log everything,
+	// and keep a modest amount of history.
 	lj := &lumberjack.Logger{
 		Filename:   logFilePath,
 		MaxSize:    3, // megabytes
@@ -27,22 +70,52 @@ func NewKitLogger(logFilePath string) log.Logger {
 		MaxBackups: 5,
 	}
 
-	ll := localLogger{
-		logger: log.With(
-			log.NewJSONLogger(log.NewSyncWriter(lj)),
-			"ts", log.DefaultTimestampUTC,
-			"caller", log.DefaultCaller, ///log.Caller(6),
-		),
+	ll := &localLogger{}
+
+	for _, opt := range opts {
+		opt(ll, lj)
 	}
 
+	ll.logger = log.With(
+		log.NewJSONLogger(log.NewSyncWriter(lj)),
+		"ts", log.DefaultTimestampUTC,
+		"caller", log.DefaultCaller, ///log.Caller(6),
+	)
+
 	return ll
 }
 
-func (ll localLogger) Log(keyvals ...interface{}) error {
+func (ll *localLogger) Log(keyvals ...interface{}) error {
+	if !ll.levelAllowed(keyvals...) {
+		return nil
+	}
+
 	filterResults(keyvals...)
 	return ll.logger.Log(keyvals...)
 }
 
+// levelAllowed reports whether the log line is at or above the minimum level. Lines
+// without a level are always allowed.
+func (ll *localLogger) levelAllowed(keyvals ...interface{}) bool {
+	for i := 0; i < len(keyvals)-1; i += 2 {
+		if keyvals[i] != level.Key() {
+			continue
+		}
+
+		lvl, ok := keyvals[i+1].(level.Value)
+		if !ok {
+			return true
+		}
+
+		if rank, ok := levelRanks[lvl.String()]; ok {
+			return rank >= ll.minLevel
+		}
+		return true
+	}
+
+	return true
+}
+
 func CleanUpRenamedDebugLogs(cleanupPath string, logger log.Logger) {
 	// We renamed the debug log file from debug.log to debug.json for compatibility with support tools.
 	// Check to see if we have any of the old debug.log files still hanging around, and clean them up
@@ -5,9 +5,11 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
 	"github.com/kolide/kit/stringutil"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -75,6 +77,31 @@ func TestKitLogging(t *testing.T) {
 
 }
 
+func TestKitLoggingLevel(t *testing.T) {
+	t.Parallel()
+
+	logFilePath := filepath.Join(t.TempDir(), "debug.json")
+	logger := NewKitLogger(logFilePath, WithLevel("warn"))
+
+	level.Debug(logger).Log("msg", "dropped debug")
+	level.Info(logger).Log("msg", "dropped info")
+	level.Warn(logger).Log("msg", "kept warn")
+	level.Error(logger).Log("msg", "kept error")
+	logger.Log("msg", "kept without level")
+
+	contentsRaw, err := os.ReadFile(logFilePath)
+	require.NoError(t, err, "read log file")
+
+	var msgs []string
+	for _, line := range strings.Split(strings.TrimSpace(string(contentsRaw)), "\n") {
+		var contents map[string]string
+		require.NoError(t, json.Unmarshal([]byte(line), &contents), "unmarshal json")
+		msgs = append(msgs, contents["msg"])
+	}
+
+	assert.Equal(t, []string{"kept warn", "kept error", "kept without level"}, msgs)
+}
+
 func TestCleanUpRenamedDebugLogs(t *testing.T) {
 	t.Parallel()
 
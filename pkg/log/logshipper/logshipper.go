@@ -1,9 +1,12 @@
 package logshipper
 
 import (
+	"bytes"
 	"context"
 	"fmt"
+	"io"
 	"net/url"
+	"sync/atomic"
 	"time"
 
 	"github.com/go-kit/kit/log"
@@ -11,7 +14,9 @@ import (
 	"github.com/kolide/kit/ulid"
 	"github.com/kolide/launcher/pkg/agent/storage"
 	"github.com/kolide/launcher/pkg/agent/types"
+	"github.com/kolide/launcher/pkg/metrics"
 	"github.com/kolide/launcher/pkg/sendbuffer"
+	"go.opentelemetry.io/otel/trace"
 )
 
 const (
@@ -22,7 +27,10 @@ const (
 
 type LogShipper struct {
 	sender     *authedHttpSender
-	sendBuffer *sendbuffer.SendBuffer
+	otlpSender *otlpSender
+	// protocolSender picks between sender and otlpSender
+	protocolSender *protocolSender
+	sendBuffer     *sendbuffer.SendBuffer
 	//shippingLogger is the logs that will be shipped
 	shippingLogger log.Logger
 	//baseLogger is for logShipper interal logging
@@ -34,19 +42,37 @@ type LogShipper struct {
 
 func New(k types.Knapsack, baseLogger log.Logger) *LogShipper {
 	sender := newAuthHttpSender()
+	otlpSender := newOtlpSender()
+	protocolSender := &protocolSender{json: sender, otlp: otlpSender}
 
 	sendInterval := defaultSendInterval
 	if k.Debug() {
 		sendInterval = debugSendInterval
 	}
 
-	sendBuffer := sendbuffer.New(sender, sendbuffer.WithSendInterval(sendInterval))
+	sendBuffer := sendbuffer.New(
+		protocolSender,
+		sendbuffer.WithSendInterval(sendInterval),
+		sendbuffer.WithLogger(baseLogger),
+		sendbuffer.WithStore(k.LogShipperBufferStore()),
+	)
+
+	metrics.SetLogShipperDroppedSource(func() map[string]int64 {
+		dropped := sendBuffer.DroppedBytes()
+		return map[string]int64{
+			"oversize":     dropped.Oversize,
+			"overflow":     dropped.Overflow,
+			"send_failure": dropped.SendFailure,
+		}
+	})
 
 	// setting a ulid as session_ulid allows us to follow a single run of launcher
-	shippingLogger := log.With(log.NewJSONLogger(sendBuffer), "caller", log.Caller(6), "session_ulid", ulid.New())
+	shippingLogger := log.With(log.NewJSONLogger(sendBuffer), "ts", log.DefaultTimestampUTC, "caller", log.Caller(6), "session_ulid", ulid.New())
 
 	ls := &LogShipper{
 		sender:         sender,
+		otlpSender:     otlpSender,
+		protocolSender: protocolSender,
 		sendBuffer:     sendBuffer,
 		shippingLogger: shippingLogger,
 		baseLogger:     baseLogger,
@@ -62,24 +88,51 @@ func (ls *LogShipper) Ping() {
 	// set up new auth token
 	token, _ := ls.knapsack.TokenStore().Get(storage.ObservabilityIngestAuthTokenKey)
 	ls.sender.authtoken = string(token)
+	ls.otlpSender.setToken(string(token))
 
-	shouldEnable := ls.knapsack.LogIngestServerURL() != ""
+	ls.addDeviceIdentifyingAttributesToLogger()
+
+	// The JSON sender is set up even when logs are shipped with OTLP, so that it can take over
+	// if an OTLP export fails
+	jsonEnabled := ls.knapsack.LogIngestServerURL() != ""
 	parsedUrl, err := url.Parse(ls.knapsack.LogIngestServerURL())
 	if err != nil || parsedUrl.String() == "" {
 		// If we have a bad endpoint, just disable for now.
 		// It will get renabled when control server sends a
 		// valid endpoint.
-		shouldEnable = false
+		jsonEnabled = false
 		level.Debug(ls.baseLogger).Log(
-			"msg", "error parsing log ingest server url, shipping disabled",
+			"msg", "error parsing log ingest server url, json shipping disabled",
 			"err", err,
 			"log_ingest_url", ls.knapsack.LogIngestServerURL(),
 		)
 	}
 
-	ls.sender.endpoint = parsedUrl.String()
-	ls.isShippingEnabled = shouldEnable
-	ls.addDeviceIdentifyingAttributesToLogger()
+	ls.sender.endpoint = ""
+	if jsonEnabled {
+		ls.sender.endpoint = parsedUrl.String()
+	}
+
+	// Prefer OTLP, through the same ingest server as traces, when it's enabled. Otherwise,
+	// fall back to JSON to the log ingest server.
+	if ls.knapsack.ExportLogsOtlp() && ls.knapsack.TraceIngestServerURL() != "" {
+		err := ls.otlpSender.configure(ls.knapsack.TraceIngestServerURL(), ls.knapsack.DisableTraceIngestTLS())
+		if err == nil {
+			ls.protocolSender.useOtlp.Store(true)
+			ls.isShippingEnabled = true
+			return
+		}
+
+		level.Debug(ls.baseLogger).Log(
+			"msg", "error configuring otlp log export, falling back to json",
+			"err", err,
+			"trace_ingest_url", ls.knapsack.TraceIngestServerURL(),
+		)
+	}
+	ls.protocolSender.useOtlp.Store(false)
+	ls.otlpSender.close()
+
+	ls.isShippingEnabled = jsonEnabled
 
 	if !ls.isShippingEnabled {
 		ls.sendBuffer.DeleteAllData()
@@ -102,7 +155,62 @@ func (ls *LogShipper) Log(keyvals ...interface{}) error {
 	}
 
 	filterResults(keyvals...)
-	return ls.shippingLogger.Log(keyvals...)
+	return ls.shippingLogger.Log(addTraceIds(keyvals)...)
+}
+
+// addTraceIds replaces a context in keyvals (e.g. logged as "ctx", ctx) with the trace and span
+// ids of the span it carries, so that shipped logs can be correlated with traces. keyvals is
+// shared with the other loggers, so it's copied rather than modified.
+func addTraceIds(keyvals []interface{}) []interface{} {
+	for i := 1; i < len(keyvals); i += 2 {
+		ctx, ok := keyvals[i].(context.Context)
+		if !ok {
+			continue
+		}
+
+		withIds := make([]interface{}, 0, len(keyvals)+2)
+		withIds = append(withIds, keyvals[:i-1]...)
+		withIds = append(withIds, keyvals[i+1:]...)
+
+		if spanCtx := trace.SpanContextFromContext(ctx); spanCtx.IsValid() {
+			withIds = append(withIds, "trace_id", spanCtx.TraceID().String(), "span_id", spanCtx.SpanID().String())
+		}
+
+		return withIds
+	}
+
+	return keyvals
+}
+
+// protocolSender sends with OTLP when it's enabled, and with the JSON sender otherwise. If an OTLP
+// export fails, the same data is sent with the JSON sender instead, when it has an endpoint.
+type protocolSender struct {
+	json    *authedHttpSender
+	otlp    *otlpSender
+	useOtlp atomic.Bool
+}
+
+func (p *protocolSender) Send(r io.Reader) error {
+	if !p.useOtlp.Load() {
+		return p.json.Send(r)
+	}
+
+	// Keep a copy of the data, since the OTLP sender consumes the reader
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return fmt.Errorf("reading logs to send: %w", err)
+	}
+
+	otlpErr := p.otlp.Send(bytes.NewReader(data))
+	if otlpErr == nil || p.json.endpoint == "" {
+		return otlpErr
+	}
+
+	if err := p.json.Send(bytes.NewReader(data)); err != nil {
+		return fmt.Errorf("sending with otlp: %v, then falling back to json: %w", otlpErr, err)
+	}
+
+	return nil
 }
 
 // filterResults filteres out the osquery results,
@@ -1,15 +1,31 @@
 package logshipper
 
 import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
 	"github.com/kolide/kit/ulid"
 	"github.com/kolide/launcher/pkg/agent/storage"
 	storageci "github.com/kolide/launcher/pkg/agent/storage/ci"
 	"github.com/kolide/launcher/pkg/agent/types"
 	"github.com/kolide/launcher/pkg/agent/types/mocks"
 	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/trace"
+	collogspb "go.opentelemetry.io/proto/otlp/collector/logs/v1"
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	logspb "go.opentelemetry.io/proto/otlp/logs/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
 )
 
 func TestLogShipper(t *testing.T) {
@@ -39,6 +55,10 @@ func TestLogShipper(t *testing.T) {
 			knapsack.On("LogIngestServerURL").Return(endpoint).Times(2)
 			knapsack.On("ServerProvidedDataStore").Return(tokenStore)
 			knapsack.On("Debug").Return(true)
+			knapsack.On("ExportLogsOtlp").Return(false)
+			bufferStore, err := storageci.NewStore(t, log.NewNopLogger(), storage.LogShipperBufferStore.String())
+			require.NoError(t, err)
+			knapsack.On("LogShipperBufferStore").Return(bufferStore)
 
 			ls := New(knapsack, log.NewNopLogger())
 
@@ -68,6 +88,187 @@ func TestLogShipper(t *testing.T) {
 	}
 }
 
+func TestLogShipper_Otlp(t *testing.T) {
+	t.Parallel()
+
+	// Set up an OTLP log ingest server
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	logsServer := &testLogsServer{}
+	grpcServer := grpc.NewServer()
+	collogspb.RegisterLogsServiceServer(grpcServer, logsServer)
+	go grpcServer.Serve(listener)
+	t.Cleanup(grpcServer.Stop)
+
+	knapsack := mocks.NewKnapsack(t)
+	tokenStore := testTokenStore(t)
+	authToken := ulid.New()
+	tokenStore.Set(storage.ObservabilityIngestAuthTokenKey, []byte(authToken))
+	bufferStore, err := storageci.NewStore(t, log.NewNopLogger(), storage.LogShipperBufferStore.String())
+	require.NoError(t, err)
+
+	knapsack.On("TokenStore").Return(tokenStore)
+	knapsack.On("ServerProvidedDataStore").Return(tokenStore)
+	knapsack.On("Debug").Return(true)
+	knapsack.On("LogShipperBufferStore").Return(bufferStore)
+	knapsack.On("ExportLogsOtlp").Return(true)
+	knapsack.On("TraceIngestServerURL").Return(listener.Addr().String())
+	knapsack.On("DisableTraceIngestTLS").Return(true)
+	knapsack.On("LogIngestServerURL").Return("")
+
+	ls := New(knapsack, log.NewNopLogger())
+	require.True(t, ls.isShippingEnabled, "shipping should be enabled")
+	require.True(t, ls.protocolSender.useOtlp.Load(), "logs should be shipped with otlp")
+
+	traceId, err := trace.TraceIDFromHex("0102030405060708090a0b0c0d0e0f10")
+	require.NoError(t, err)
+	spanId, err := trace.SpanIDFromHex("0102030405060708")
+	require.NoError(t, err)
+	ctx := trace.ContextWithSpanContext(context.Background(), trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    traceId,
+		SpanID:     spanId,
+		TraceFlags: trace.FlagsSampled,
+	}))
+
+	level.Info(ls).Log("msg", "hello from launcher", "ctx", ctx, "count", 3)
+
+	go ls.Run()
+	t.Cleanup(func() { ls.Stop(nil) })
+
+	require.Eventually(t, func() bool {
+		return logsServer.receivedCount() > 0
+	}, 5*time.Second, 50*time.Millisecond)
+
+	logsServer.lock.Lock()
+	defer logsServer.lock.Unlock()
+
+	require.Equal(t, "Bearer "+authToken, logsServer.authorization)
+	record := logsServer.requests[0].ResourceLogs[0].ScopeLogs[0].LogRecords[0]
+	require.Equal(t, "hello from launcher", record.Body.GetStringValue())
+	require.Equal(t, logspb.SeverityNumber_SEVERITY_NUMBER_INFO, record.SeverityNumber)
+	require.Equal(t, traceId[:], record.TraceId)
+	require.Equal(t, spanId[:], record.SpanId)
+	require.NotZero(t, record.TimeUnixNano)
+
+	attributes := make(map[string]*commonpb.AnyValue)
+	for _, kv := range record.Attributes {
+		attributes[kv.Key] = kv.Value
+	}
+	require.Equal(t, int64(3), attributes["count"].GetIntValue())
+	require.NotContains(t, attributes, "ctx")
+}
+
+func TestLogShipper_OtlpFailureFallsBackToJson(t *testing.T) {
+	t.Parallel()
+
+	// Set up an OTLP log ingest server that rejects every export
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	logsServer := &testLogsServer{err: status.Error(codes.Unavailable, "ingest unavailable")}
+	grpcServer := grpc.NewServer()
+	collogspb.RegisterLogsServiceServer(grpcServer, logsServer)
+	go grpcServer.Serve(listener)
+	t.Cleanup(grpcServer.Stop)
+
+	// ...and a JSON log ingest server to fall back to
+	jsonReceived := make(chan []byte, 10)
+	jsonServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+		jsonReceived <- body
+	}))
+	t.Cleanup(jsonServer.Close)
+
+	knapsack := mocks.NewKnapsack(t)
+	tokenStore := testTokenStore(t)
+	tokenStore.Set(storage.ObservabilityIngestAuthTokenKey, []byte(ulid.New()))
+	bufferStore, err := storageci.NewStore(t, log.NewNopLogger(), storage.LogShipperBufferStore.String())
+	require.NoError(t, err)
+
+	knapsack.On("TokenStore").Return(tokenStore)
+	knapsack.On("ServerProvidedDataStore").Return(tokenStore)
+	knapsack.On("Debug").Return(true)
+	knapsack.On("LogShipperBufferStore").Return(bufferStore)
+	knapsack.On("ExportLogsOtlp").Return(true)
+	knapsack.On("TraceIngestServerURL").Return(listener.Addr().String())
+	knapsack.On("DisableTraceIngestTLS").Return(true)
+	knapsack.On("LogIngestServerURL").Return(jsonServer.URL)
+
+	ls := New(knapsack, log.NewNopLogger())
+	require.True(t, ls.protocolSender.useOtlp.Load(), "logs should be shipped with otlp")
+
+	level.Info(ls).Log("msg", "hello from launcher")
+
+	go ls.Run()
+	t.Cleanup(func() { ls.Stop(nil) })
+
+	select {
+	case body := <-jsonReceived:
+		require.Contains(t, string(body), "hello from launcher")
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for logs to be sent with json")
+	}
+
+	require.Positive(t, logsServer.receivedCount(), "otlp export should have been tried first")
+	require.Zero(t, ls.sendBuffer.DroppedBytes().SendFailure)
+}
+
+type testLogsServer struct {
+	collogspb.UnimplementedLogsServiceServer
+	requests      []*collogspb.ExportLogsServiceRequest
+	authorization string
+	err           error
+	lock          sync.Mutex
+}
+
+func (s *testLogsServer) Export(ctx context.Context, req *collogspb.ExportLogsServiceRequest) (*collogspb.ExportLogsServiceResponse, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if md, ok := metadata.FromIncomingContext(ctx); ok && len(md.Get("authorization")) > 0 {
+		s.authorization = md.Get("authorization")[0]
+	}
+	s.requests = append(s.requests, req)
+
+	if s.err != nil {
+		return nil, s.err
+	}
+
+	return &collogspb.ExportLogsServiceResponse{}, nil
+}
+
+func (s *testLogsServer) receivedCount() int {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return len(s.requests)
+}
+
+func Test_addTraceIds(t *testing.T) {
+	t.Parallel()
+
+	traceId, err := trace.TraceIDFromHex("0102030405060708090a0b0c0d0e0f10")
+	require.NoError(t, err)
+	spanId, err := trace.SpanIDFromHex("0102030405060708")
+	require.NoError(t, err)
+	ctx := trace.ContextWithSpanContext(context.Background(), trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID: traceId,
+		SpanID:  spanId,
+	}))
+
+	keyvals := []interface{}{"msg", "hello", "ctx", ctx}
+	require.Equal(t,
+		[]interface{}{"msg", "hello", "trace_id", traceId.String(), "span_id", spanId.String()},
+		addTraceIds(keyvals),
+	)
+	require.Equal(t, ctx, keyvals[3], "keyvals should not be modified")
+
+	// A context without a span is dropped
+	require.Equal(t, []interface{}{"msg", "hello"}, addTraceIds([]interface{}{"msg", "hello", "ctx", context.Background()}))
+
+	// No context, no change
+	require.Equal(t, []interface{}{"msg", "hello"}, addTraceIds([]interface{}{"msg", "hello"}))
+}
+
 func testTokenStore(t *testing.T) types.KVStore {
 	s, err := storageci.NewStore(t, log.NewNopLogger(), storage.TokenStore.String())
 	require.NoError(t, err)
@@ -0,0 +1,239 @@
+package logshipper
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kolide/kit/version"
+	"github.com/kolide/launcher/pkg/ingestauth"
+	collogspb "go.opentelemetry.io/proto/otlp/collector/logs/v1"
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	logspb "go.opentelemetry.io/proto/otlp/logs/v1"
+	resourcepb "go.opentelemetry.io/proto/otlp/resource/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+const (
+	applicationName = "launcher"
+
+	// maxLogLineSize bounds a single buffered log line; the send buffer already caps them well below this
+	maxLogLineSize = 1024 * 1024
+)
+
+var otlpSeverities = map[string]logspb.SeverityNumber{
+	"debug": logspb.SeverityNumber_SEVERITY_NUMBER_DEBUG,
+	"info":  logspb.SeverityNumber_SEVERITY_NUMBER_INFO,
+	"warn":  logspb.SeverityNumber_SEVERITY_NUMBER_WARN,
+	"error": logspb.SeverityNumber_SEVERITY_NUMBER_ERROR,
+}
+
+// otlpSender ships the JSON log lines from the send buffer as OTLP log records, over gRPC,
+// to the same ingest server and with the same auth as our traces.
+type otlpSender struct {
+	endpoint    string
+	disableTLS  bool
+	credentials *ingestauth.ClientAuthenticator
+	conn        *grpc.ClientConn
+	client      collogspb.LogsServiceClient
+	lock        sync.RWMutex
+}
+
+func newOtlpSender() *otlpSender {
+	return &otlpSender{
+		credentials: ingestauth.NewClientAuthenticator("", false),
+	}
+}
+
+// setToken updates the bearer auth token in place
+func (o *otlpSender) setToken(token string) {
+	o.credentials.SetToken(token)
+}
+
+// configure connects to the given ingest server, replacing any previous connection
+// if the server or its TLS setting changed.
+func (o *otlpSender) configure(endpoint string, disableTLS bool) error {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+
+	if o.conn != nil && o.endpoint == endpoint && o.disableTLS == disableTLS {
+		return nil
+	}
+
+	transportCredentials := credentials.NewTLS(&tls.Config{MinVersion: tls.VersionTLS12})
+	if disableTLS {
+		transportCredentials = insecure.NewCredentials()
+	}
+	o.credentials.SetDisableTLS(disableTLS)
+
+	// Dial doesn't block, so this doesn't wait on the ingest server
+	conn, err := grpc.Dial(
+		endpoint,
+		grpc.WithTransportCredentials(transportCredentials),
+		grpc.WithPerRPCCredentials(o.credentials),
+	)
+	if err != nil {
+		return fmt.Errorf("dialing %s: %w", endpoint, err)
+	}
+
+	if o.conn != nil {
+		o.conn.Close()
+	}
+
+	o.endpoint = endpoint
+	o.disableTLS = disableTLS
+	o.conn = conn
+	o.client = collogspb.NewLogsServiceClient(conn)
+
+	return nil
+}
+
+// close closes the connection to the ingest server, if any
+func (o *otlpSender) close() {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+
+	if o.conn != nil {
+		o.conn.Close()
+	}
+	o.conn = nil
+	o.client = nil
+}
+
+func (o *otlpSender) Send(r io.Reader) error {
+	o.lock.RLock()
+	client := o.client
+	o.lock.RUnlock()
+
+	if client == nil {
+		return errors.New("otlp sender is not configured")
+	}
+
+	records, err := logRecords(r, time.Now())
+	if err != nil {
+		return fmt.Errorf("converting logs to otlp: %w", err)
+	}
+
+	if len(records) == 0 {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	resp, err := client.Export(ctx, &collogspb.ExportLogsServiceRequest{
+		ResourceLogs: []*logspb.ResourceLogs{
+			{
+				Resource: &resourcepb.Resource{
+					Attributes: []*commonpb.KeyValue{
+						stringKeyValue("service.name", applicationName),
+						stringKeyValue("service.version", version.Version().Version),
+					},
+				},
+				ScopeLogs: []*logspb.ScopeLogs{
+					{
+						Scope:      &commonpb.InstrumentationScope{Name: applicationName},
+						LogRecords: records,
+					},
+				},
+			},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("exporting logs: %w", err)
+	}
+
+	if partialSuccess := resp.GetPartialSuccess(); partialSuccess != nil && partialSuccess.GetRejectedLogRecords() > 0 {
+		return fmt.Errorf("ingest server rejected %d log records: %s", partialSuccess.GetRejectedLogRecords(), partialSuccess.GetErrorMessage())
+	}
+
+	return nil
+}
+
+// logRecords converts newline-delimited JSON log lines into OTLP log records. The msg becomes
+// the body, level the severity, trace_id and span_id the trace context, and everything else
+// an attribute. Lines that aren't JSON objects are skipped.
+func logRecords(r io.Reader, observedAt time.Time) ([]*logspb.LogRecord, error) {
+	records := make([]*logspb.LogRecord, 0)
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxLogLineSize)
+	for scanner.Scan() {
+		var entry map[string]any
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			continue
+		}
+
+		record := &logspb.LogRecord{
+			ObservedTimeUnixNano: uint64(observedAt.UnixNano()),
+			Attributes:           make([]*commonpb.KeyValue, 0, len(entry)),
+		}
+
+		for k, v := range entry {
+			switch k {
+			case "msg":
+				record.Body = anyValue(v)
+			case "level":
+				lvl := fmt.Sprint(v)
+				record.SeverityText = strings.ToUpper(lvl)
+				record.SeverityNumber = otlpSeverities[lvl]
+			case "ts":
+				if ts, err := time.Parse(time.RFC3339Nano, fmt.Sprint(v)); err == nil {
+					record.TimeUnixNano = uint64(ts.UnixNano())
+				}
+			case "trace_id":
+				if traceId, err := hex.DecodeString(fmt.Sprint(v)); err == nil && len(traceId) == 16 {
+					record.TraceId = traceId
+				}
+			case "span_id":
+				if spanId, err := hex.DecodeString(fmt.Sprint(v)); err == nil && len(spanId) == 8 {
+					record.SpanId = spanId
+				}
+			default:
+				record.Attributes = append(record.Attributes, &commonpb.KeyValue{Key: k, Value: anyValue(v)})
+			}
+		}
+
+		records = append(records, record)
+	}
+
+	return records, scanner.Err()
+}
+
+// anyValue converts a value decoded from JSON into an OTLP value
+func anyValue(v any) *commonpb.AnyValue {
+	switch val := v.(type) {
+	case string:
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_StringValue{StringValue: val}}
+	case bool:
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_BoolValue{BoolValue: val}}
+	case float64:
+		if val == float64(int64(val)) {
+			return &commonpb.AnyValue{Value: &commonpb.AnyValue_IntValue{IntValue: int64(val)}}
+		}
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_DoubleValue{DoubleValue: val}}
+	case nil:
+		return &commonpb.AnyValue{}
+	default:
+		// Nested objects and arrays are kept as their JSON
+		raw, err := json.Marshal(val)
+		if err != nil {
+			return &commonpb.AnyValue{Value: &commonpb.AnyValue_StringValue{StringValue: fmt.Sprint(val)}}
+		}
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_StringValue{StringValue: string(raw)}}
+	}
+}
+
+func stringKeyValue(k, v string) *commonpb.KeyValue {
+	return &commonpb.KeyValue{Key: k, Value: &commonpb.AnyValue{Value: &commonpb.AnyValue_StringValue{StringValue: v}}}
+}
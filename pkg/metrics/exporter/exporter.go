@@ -0,0 +1,241 @@
+package exporter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"runtime"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+	"github.com/kolide/kit/version"
+	"github.com/kolide/launcher/pkg/agent/flags/keys"
+	"github.com/kolide/launcher/pkg/agent/storage"
+	"github.com/kolide/launcher/pkg/agent/types"
+	"github.com/kolide/launcher/pkg/ingestauth"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/aggregation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	semconv "go.opentelemetry.io/otel/semconv/v1.17.0"
+	"golang.org/x/exp/slices"
+)
+
+const applicationName = "launcher"
+
+var (
+	exportInterval = 1 * time.Minute
+
+	// durationBoundaries are the histogram buckets for our durations, which are recorded in seconds --
+	// the SDK's default buckets are better suited to milliseconds.
+	durationBoundaries = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60}
+)
+
+type MetricsExporter struct {
+	provider         *sdkmetric.MeterProvider
+	otlpExporter     *otlpExporter
+	knapsack         types.Knapsack
+	logger           log.Logger
+	authenticator    *ingestauth.ClientAuthenticator
+	prometheusPort   int
+	prometheusServer *http.Server
+	ingestUrl        string
+	disableIngestTLS bool
+	enabled          bool
+	interrupt        chan struct{}
+}
+
+// NewMetricsExporter sets up the global meter provider for launcher's metrics, which are exported
+// via OTLP to the trace ingest server when enabled, and served for Prometheus on localhost when
+// a port is configured. On interrupt, the provider will be shut down.
+func NewMetricsExporter(ctx context.Context, k types.Knapsack, logger log.Logger) (*MetricsExporter, error) {
+	currentToken, _ := k.TokenStore().Get(storage.ObservabilityIngestAuthTokenKey)
+
+	m := &MetricsExporter{
+		knapsack:         k,
+		logger:           log.With(logger, "component", "metrics_exporter"),
+		authenticator:    ingestauth.NewClientAuthenticator(string(currentToken), k.DisableTraceIngestTLS()),
+		prometheusPort:   k.MetricsPrometheusPort(),
+		ingestUrl:        k.TraceIngestServerURL(),
+		disableIngestTLS: k.DisableTraceIngestTLS(),
+		enabled:          k.ExportMetrics(),
+		interrupt:        make(chan struct{}),
+	}
+
+	m.otlpExporter = newOtlpExporter(m.authenticator)
+	m.addDeviceIdentifyingAttributes()
+
+	opts := []sdkmetric.Option{
+		sdkmetric.WithResource(m.resource()),
+		sdkmetric.WithReader(sdkmetric.NewPeriodicReader(m.otlpExporter, sdkmetric.WithInterval(exportInterval))),
+		sdkmetric.WithView(sdkmetric.NewView(
+			sdkmetric.Instrument{Name: "*.duration", Kind: sdkmetric.InstrumentKindHistogram},
+			sdkmetric.Stream{Aggregation: aggregation.ExplicitBucketHistogram{Boundaries: durationBoundaries}},
+		)),
+	}
+
+	if m.prometheusPort > 0 {
+		prometheusReader, prometheusHandler, err := newPrometheusHandler()
+		if err != nil {
+			return nil, fmt.Errorf("setting up prometheus metrics: %w", err)
+		}
+		opts = append(opts, sdkmetric.WithReader(prometheusReader))
+
+		mux := http.NewServeMux()
+		mux.Handle("/metrics", prometheusHandler)
+		m.prometheusServer = &http.Server{
+			Handler:           mux,
+			ReadHeaderTimeout: 5 * time.Second,
+		}
+	}
+
+	// The global meter provider can only be set once -- instruments created before then are
+	// delegated to the first provider set, and no other. So we always set it, and toggle
+	// exporting within the otlp exporter instead.
+	m.provider = sdkmetric.NewMeterProvider(opts...)
+	otel.SetMeterProvider(m.provider)
+
+	if m.enabled {
+		if err := m.otlpExporter.configure(true, m.ingestUrl, m.disableIngestTLS); err != nil {
+			level.Debug(m.logger).Log("msg", "could not configure metrics export", "err", err)
+		}
+	}
+
+	// Observe ExportMetrics and IngestServerURL changes to know when to start/stop exporting, and where
+	// to export to
+	m.knapsack.RegisterChangeObserver(m, keys.ExportMetrics, keys.TraceIngestServerURL, keys.DisableTraceIngestTLS)
+
+	return m, nil
+}
+
+// resource returns the resource attributes for our metrics, identifying launcher. Attributes
+// identifying the device are added on export, since they may not be available yet at startup.
+func (m *MetricsExporter) resource() *resource.Resource {
+	attrs := []attribute.KeyValue{
+		semconv.ServiceName(applicationName),
+		semconv.ServiceVersion(version.Version().Version),
+		semconv.HostArchKey.String(runtime.GOARCH),
+	}
+
+	r, err := resource.Merge(
+		resource.Default(),
+		resource.NewWithAttributes(semconv.SchemaURL, attrs...),
+	)
+	if err != nil {
+		return resource.Default()
+	}
+
+	return r
+}
+
+// addDeviceIdentifyingAttributes gets device identifiers from the server-provided
+// data and hands them to the otlp exporter.
+func (m *MetricsExporter) addDeviceIdentifyingAttributes() {
+	attrs := make([]attribute.KeyValue, 0)
+
+	for attrName, storeKey := range map[string]string{
+		"k2.device_id":       "device_id",
+		"k2.munemo":          "munemo",
+		"k2.organization_id": "organization_id",
+	} {
+		val, err := m.knapsack.ServerProvidedDataStore().Get([]byte(storeKey))
+		if err != nil || len(val) == 0 {
+			level.Debug(m.logger).Log("msg", "could not get device identifier", "key", storeKey, "err", err)
+			continue
+		}
+		attrs = append(attrs, attribute.String(attrName, string(val)))
+	}
+
+	m.otlpExporter.setDeviceAttributes(attrs)
+}
+
+// Execute serves metrics for Prometheus, if configured; the OTLP export runs in the background.
+// The MetricsExporter otherwise only responds to control server events.
+func (m *MetricsExporter) Execute() error {
+	if m.prometheusServer != nil {
+		listener, err := net.Listen("tcp", fmt.Sprintf("127.0.0.1:%d", m.prometheusPort))
+		if err != nil {
+			// Not fatal -- the port may be in use, and we can still export metrics via OTLP
+			level.Info(m.logger).Log("msg", "could not listen for prometheus metrics", "port", m.prometheusPort, "err", err)
+		} else {
+			go func() {
+				if err := m.prometheusServer.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+					level.Info(m.logger).Log("msg", "prometheus metrics server stopped", "err", err)
+				}
+			}()
+		}
+	}
+
+	<-m.interrupt
+	return nil
+}
+
+func (m *MetricsExporter) Interrupt(_ error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if m.prometheusServer != nil {
+		m.prometheusServer.Shutdown(ctx)
+	}
+
+	if m.provider != nil {
+		m.provider.Shutdown(ctx)
+	}
+
+	m.interrupt <- struct{}{}
+}
+
+// Ping satisfies control.subscriber interface -- looks at changes to the `observability_ingest` subsystem,
+// which amounts to a new bearer auth token being provided.
+func (m *MetricsExporter) Ping() {
+	newToken, err := m.knapsack.TokenStore().Get(storage.ObservabilityIngestAuthTokenKey)
+	if err != nil {
+		level.Debug(m.logger).Log("msg", "could not get new token from token store", "err", err)
+		return
+	}
+
+	m.authenticator.SetToken(string(newToken))
+
+	// A new token is a good moment to pick up device identifiers we didn't have before enrollment
+	m.addDeviceIdentifyingAttributes()
+}
+
+// FlagsChanged satisfies the types.FlagsChangeObserver interface -- handles updates to flags
+// that we care about, which are ingest_url, disable_trace_ingest_tls and export_metrics.
+func (m *MetricsExporter) FlagsChanged(flagKeys ...keys.FlagKey) {
+	needsReconfigure := false
+
+	if slices.Contains(flagKeys, keys.ExportMetrics) && m.enabled != m.knapsack.ExportMetrics() {
+		m.enabled = m.knapsack.ExportMetrics()
+		needsReconfigure = true
+		level.Debug(m.logger).Log("msg", "toggling metrics export", "enabled", m.enabled)
+	}
+
+	if slices.Contains(flagKeys, keys.TraceIngestServerURL) && m.ingestUrl != m.knapsack.TraceIngestServerURL() {
+		m.ingestUrl = m.knapsack.TraceIngestServerURL()
+		needsReconfigure = true
+		level.Debug(m.logger).Log("msg", "updating ingest server url", "new_ingest_url", m.ingestUrl)
+	}
+
+	if slices.Contains(flagKeys, keys.DisableTraceIngestTLS) && m.disableIngestTLS != m.knapsack.DisableTraceIngestTLS() {
+		m.disableIngestTLS = m.knapsack.DisableTraceIngestTLS()
+		needsReconfigure = true
+		level.Debug(m.logger).Log("msg", "updating ingest server config", "new_disable_trace_ingest_tls", m.disableIngestTLS)
+	}
+
+	if !needsReconfigure {
+		return
+	}
+
+	if m.enabled {
+		m.addDeviceIdentifyingAttributes()
+	}
+
+	if err := m.otlpExporter.configure(m.enabled, m.ingestUrl, m.disableIngestTLS); err != nil {
+		level.Debug(m.logger).Log("msg", "could not configure metrics export", "err", err)
+	}
+}
@@ -0,0 +1,66 @@
+package exporter
+
+import (
+	"context"
+	"testing"
+
+	"github.com/go-kit/kit/log"
+	"github.com/kolide/launcher/pkg/agent/flags/keys"
+	"github.com/kolide/launcher/pkg/agent/storage"
+	storageci "github.com/kolide/launcher/pkg/agent/storage/ci"
+	"github.com/kolide/launcher/pkg/agent/types"
+	typesmocks "github.com/kolide/launcher/pkg/agent/types/mocks"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// NB - NewMetricsExporter sets the global meter provider, so tests calling it should not be
+// run in parallel.
+
+func TestMetricsExporter_FlagsChanged(t *testing.T) { //nolint:paralleltest
+	tokenStore := testStore(t, storage.TokenStore.String())
+	tokenStore.Set(storage.ObservabilityIngestAuthTokenKey, []byte("test token"))
+	serverProvidedDataStore := testStore(t, storage.ServerProvidedDataStore.String())
+	serverProvidedDataStore.Set([]byte("device_id"), []byte("500"))
+
+	mockKnapsack := typesmocks.NewKnapsack(t)
+	mockKnapsack.On("TokenStore").Return(tokenStore)
+	mockKnapsack.On("ServerProvidedDataStore").Return(serverProvidedDataStore)
+	mockKnapsack.On("MetricsPrometheusPort").Return(0)
+	mockKnapsack.On("TraceIngestServerURL").Return("localhost:3417")
+	mockKnapsack.On("DisableTraceIngestTLS").Return(true)
+	mockKnapsack.On("ExportMetrics").Return(false).Once()
+	mockKnapsack.On("RegisterChangeObserver", mock.Anything, keys.ExportMetrics, keys.TraceIngestServerURL, keys.DisableTraceIngestTLS).Return(nil)
+
+	m, err := NewMetricsExporter(context.Background(), mockKnapsack, log.NewNopLogger())
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		go m.Execute()
+		m.Interrupt(nil)
+	})
+
+	require.NotNil(t, m.provider, "expected provider to be created even while export is disabled")
+	require.False(t, m.otlpExporter.enabled)
+	require.Equal(t, "500", m.otlpExporter.deviceAttrs[0].Value.AsString())
+
+	// Enable export
+	mockKnapsack.On("ExportMetrics").Return(true)
+	m.FlagsChanged(keys.ExportMetrics)
+
+	m.otlpExporter.lock.RLock()
+	require.True(t, m.otlpExporter.enabled)
+	require.NotNil(t, m.otlpExporter.exporter, "expected exporter to be created for the ingest server")
+	require.Equal(t, "localhost:3417", m.otlpExporter.endpoint)
+	m.otlpExporter.lock.RUnlock()
+
+	// Unrelated flags don't reconfigure the exporter
+	exp := m.otlpExporter.exporter
+	m.FlagsChanged(keys.ControlRequestInterval)
+	require.Equal(t, exp, m.otlpExporter.exporter)
+}
+
+func testStore(t *testing.T, name string) types.KVStore {
+	s, err := storageci.NewStore(t, log.NewNopLogger(), name)
+	require.NoError(t, err)
+	return s
+}
@@ -0,0 +1,149 @@
+package exporter
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/kolide/launcher/pkg/ingestauth"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetricgrpc"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/aggregation"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	"go.opentelemetry.io/otel/sdk/resource"
+	"google.golang.org/grpc"
+)
+
+// otlpExporter implements sdkmetric.Exporter, sending metrics via otlpmetricgrpc to the same ingest
+// server and with the same auth as our traces. The meter provider can only be set globally
+// once, so rather than replacing the provider when export is toggled or the ingest server
+// changes, the underlying exporter is replaced; while disabled, metrics are dropped.
+type otlpExporter struct {
+	authenticator *ingestauth.ClientAuthenticator
+	deviceAttrs   []attribute.KeyValue
+	enabled       bool
+	endpoint      string
+	disableTLS    bool
+	exporter      sdkmetric.Exporter
+	lock          sync.RWMutex
+}
+
+func newOtlpExporter(authenticator *ingestauth.ClientAuthenticator) *otlpExporter {
+	return &otlpExporter{
+		authenticator: authenticator,
+	}
+}
+
+// configure enables or disables export, creating a new exporter for the given ingest server if it
+// or its TLS setting changed.
+func (o *otlpExporter) configure(enabled bool, endpoint string, disableTLS bool) error {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+
+	o.enabled = enabled
+	if !enabled {
+		o.shutdownExporter()
+		return nil
+	}
+
+	if o.exporter != nil && o.endpoint == endpoint && o.disableTLS == disableTLS {
+		return nil
+	}
+
+	o.authenticator.SetDisableTLS(disableTLS)
+
+	opts := []otlpmetricgrpc.Option{
+		otlpmetricgrpc.WithEndpoint(endpoint),
+		otlpmetricgrpc.WithDialOption(grpc.WithPerRPCCredentials(o.authenticator)),
+	}
+	if disableTLS {
+		opts = append(opts, otlpmetricgrpc.WithInsecure())
+	}
+
+	// This doesn't wait on the ingest server to be reachable
+	exp, err := otlpmetricgrpc.New(context.Background(), opts...)
+	if err != nil {
+		return fmt.Errorf("creating metrics exporter for %s: %w", endpoint, err)
+	}
+
+	o.shutdownExporter()
+
+	o.endpoint = endpoint
+	o.disableTLS = disableTLS
+	o.exporter = exp
+
+	return nil
+}
+
+// setDeviceAttributes sets the attributes identifying this device, which are added to the resource on export
+func (o *otlpExporter) setDeviceAttributes(attrs []attribute.KeyValue) {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+	o.deviceAttrs = attrs
+}
+
+// shutdownExporter shuts down the underlying exporter, if any. The caller must hold o.lock.
+func (o *otlpExporter) shutdownExporter() {
+	if o.exporter != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		o.exporter.Shutdown(ctx)
+	}
+	o.exporter = nil
+}
+
+func (o *otlpExporter) Temporality(kind sdkmetric.InstrumentKind) metricdata.Temporality {
+	return sdkmetric.DefaultTemporalitySelector(kind)
+}
+
+func (o *otlpExporter) Aggregation(kind sdkmetric.InstrumentKind) aggregation.Aggregation {
+	return sdkmetric.DefaultAggregationSelector(kind)
+}
+
+func (o *otlpExporter) Export(ctx context.Context, rm *metricdata.ResourceMetrics) error {
+	o.lock.RLock()
+	enabled := o.enabled
+	exp := o.exporter
+	deviceAttrs := o.deviceAttrs
+	o.lock.RUnlock()
+
+	if !enabled || exp == nil {
+		return nil
+	}
+
+	if len(deviceAttrs) > 0 {
+		r, err := resource.Merge(rm.Resource, resource.NewSchemaless(deviceAttrs...))
+		if err != nil {
+			return fmt.Errorf("adding device attributes to resource: %w", err)
+		}
+
+		withDeviceAttrs := *rm
+		withDeviceAttrs.Resource = r
+		rm = &withDeviceAttrs
+	}
+
+	return exp.Export(ctx, rm)
+}
+
+func (o *otlpExporter) ForceFlush(ctx context.Context) error {
+	o.lock.RLock()
+	defer o.lock.RUnlock()
+
+	if o.exporter == nil {
+		return nil
+	}
+
+	return o.exporter.ForceFlush(ctx)
+}
+
+func (o *otlpExporter) Shutdown(_ context.Context) error {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+
+	o.enabled = false
+	o.shutdownExporter()
+
+	return nil
+}
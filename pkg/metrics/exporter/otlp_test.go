@@ -0,0 +1,124 @@
+package exporter
+
+import (
+	"context"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/kolide/launcher/pkg/ingestauth"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	colmetricspb "go.opentelemetry.io/proto/otlp/collector/metrics/v1"
+	metricspb "go.opentelemetry.io/proto/otlp/metrics/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+func TestOtlpExporter_Export(t *testing.T) {
+	t.Parallel()
+
+	// Set up an OTLP metrics ingest server
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	metricsServer := &testMetricsServer{}
+	grpcServer := grpc.NewServer()
+	colmetricspb.RegisterMetricsServiceServer(grpcServer, metricsServer)
+	go grpcServer.Serve(listener)
+	t.Cleanup(grpcServer.Stop)
+
+	rm := collectTestMetrics(t)
+
+	o := newOtlpExporter(ingestauth.NewClientAuthenticator("test token", true))
+	o.setDeviceAttributes([]attribute.KeyValue{attribute.String("k2.device_id", "500")})
+
+	// Nothing is sent while disabled
+	require.NoError(t, o.Export(context.Background(), rm))
+
+	require.NoError(t, o.configure(true, listener.Addr().String(), true))
+	t.Cleanup(func() { o.Shutdown(context.Background()) })
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	require.NoError(t, o.Export(ctx, rm))
+
+	metricsServer.lock.Lock()
+	defer metricsServer.lock.Unlock()
+
+	require.Equal(t, 1, len(metricsServer.requests))
+	require.Equal(t, "Bearer test token", metricsServer.authorization)
+
+	resourceMetrics := metricsServer.requests[0].ResourceMetrics[0]
+	resourceAttrs := make(map[string]string)
+	for _, kv := range resourceMetrics.Resource.Attributes {
+		resourceAttrs[kv.Key] = kv.Value.GetStringValue()
+	}
+	require.Equal(t, "launcher", resourceAttrs["service.name"])
+	require.Equal(t, "500", resourceAttrs["k2.device_id"])
+
+	received := make(map[string]*metricspb.Metric)
+	for _, m := range resourceMetrics.ScopeMetrics[0].Metrics {
+		received[m.Name] = m
+	}
+
+	require.Contains(t, received, "launcher.reenrollments")
+	sum := received["launcher.reenrollments"].GetSum()
+	require.True(t, sum.IsMonotonic)
+	require.Equal(t, metricspb.AggregationTemporality_AGGREGATION_TEMPORALITY_CUMULATIVE, sum.AggregationTemporality)
+	require.Equal(t, int64(3), sum.DataPoints[0].GetAsInt())
+
+	require.Contains(t, received, "launcher.log_publish.duration")
+	histogram := received["launcher.log_publish.duration"].GetHistogram()
+	require.Equal(t, "s", received["launcher.log_publish.duration"].Unit)
+	require.Equal(t, uint64(2), histogram.DataPoints[0].Count)
+	require.InDelta(t, 1.5, histogram.DataPoints[0].GetSum(), 0.0001)
+	require.Equal(t, "string", histogram.DataPoints[0].Attributes[0].Value.GetStringValue())
+}
+
+// collectTestMetrics records some metrics with a standalone provider, and collects them
+func collectTestMetrics(t *testing.T) *metricdata.ResourceMetrics {
+	reader := sdkmetric.NewManualReader()
+	provider := sdkmetric.NewMeterProvider(
+		sdkmetric.WithReader(reader),
+		sdkmetric.WithResource((&MetricsExporter{}).resource()),
+	)
+	t.Cleanup(func() { provider.Shutdown(context.Background()) })
+	meter := provider.Meter("test")
+
+	counter, err := meter.Int64Counter("launcher.reenrollments")
+	require.NoError(t, err)
+	counter.Add(context.Background(), 3)
+
+	histogram, err := meter.Float64Histogram("launcher.log_publish.duration", metric.WithUnit("s"))
+	require.NoError(t, err)
+	histogram.Record(context.Background(), 0.5, metric.WithAttributes(attribute.String("log_type", "string")))
+	histogram.Record(context.Background(), 1, metric.WithAttributes(attribute.String("log_type", "string")))
+
+	var rm metricdata.ResourceMetrics
+	require.NoError(t, reader.Collect(context.Background(), &rm))
+
+	return &rm
+}
+
+type testMetricsServer struct {
+	colmetricspb.UnimplementedMetricsServiceServer
+	requests      []*colmetricspb.ExportMetricsServiceRequest
+	authorization string
+	lock          sync.Mutex
+}
+
+func (s *testMetricsServer) Export(ctx context.Context, req *colmetricspb.ExportMetricsServiceRequest) (*colmetricspb.ExportMetricsServiceResponse, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if md, ok := metadata.FromIncomingContext(ctx); ok && len(md.Get("authorization")) > 0 {
+		s.authorization = md.Get("authorization")[0]
+	}
+	s.requests = append(s.requests, req)
+
+	return &colmetricspb.ExportMetricsServiceResponse{}, nil
+}
@@ -0,0 +1,29 @@
+package exporter
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	otelprometheus "go.opentelemetry.io/otel/exporters/prometheus"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+)
+
+// newPrometheusHandler returns a reader for the meter provider, and a handler serving what it
+// collects in the Prometheus text format. It uses its own registry, rather than the global one,
+// so that only launcher's metrics are served.
+func newPrometheusHandler() (sdkmetric.Reader, http.Handler, error) {
+	registry := prometheus.NewRegistry()
+
+	// All of our instruments come from the same meter, so the scope labels would only add noise
+	exp, err := otelprometheus.New(
+		otelprometheus.WithRegisterer(registry),
+		otelprometheus.WithoutScopeInfo(),
+	)
+	if err != nil {
+		return nil, nil, fmt.Errorf("creating prometheus exporter: %w", err)
+	}
+
+	return exp, promhttp.HandlerFor(registry, promhttp.HandlerOpts{}), nil
+}
@@ -0,0 +1,68 @@
+package exporter
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/aggregation"
+)
+
+func TestPrometheusHandler(t *testing.T) {
+	t.Parallel()
+
+	reader, handler, err := newPrometheusHandler()
+	require.NoError(t, err)
+	provider := sdkmetric.NewMeterProvider(
+		sdkmetric.WithReader(reader),
+		sdkmetric.WithView(sdkmetric.NewView(
+			sdkmetric.Instrument{Name: "*.duration", Kind: sdkmetric.InstrumentKindHistogram},
+			sdkmetric.Stream{Aggregation: aggregation.ExplicitBucketHistogram{Boundaries: []float64{0.1, 1}}},
+		)),
+	)
+	t.Cleanup(func() { provider.Shutdown(context.Background()) })
+	meter := provider.Meter("test")
+
+	counter, err := meter.Int64Counter("launcher.control.fetches", metric.WithDescription("Number of fetches"))
+	require.NoError(t, err)
+	counter.Add(context.Background(), 2, metric.WithAttributes(attribute.Bool("success", true)))
+	counter.Add(context.Background(), 1, metric.WithAttributes(attribute.Bool("success", false)))
+
+	histogram, err := meter.Float64Histogram("launcher.table.generate.duration", metric.WithUnit("s"))
+	require.NoError(t, err)
+	histogram.Record(context.Background(), 0.05, metric.WithAttributes(attribute.String("table", "kolide_launcher_info")))
+	histogram.Record(context.Background(), 0.5, metric.WithAttributes(attribute.String("table", "kolide_launcher_info")))
+	histogram.Record(context.Background(), 5, metric.WithAttributes(attribute.String("table", "kolide_launcher_info")))
+
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	resp, err := http.Get(server.URL)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+
+	for _, expectedLine := range []string{
+		"# HELP launcher_control_fetches_total Number of fetches",
+		"# TYPE launcher_control_fetches_total counter",
+		`launcher_control_fetches_total{success="true"} 2`,
+		`launcher_control_fetches_total{success="false"} 1`,
+		"# TYPE launcher_table_generate_duration histogram",
+		`launcher_table_generate_duration_bucket{table="kolide_launcher_info",le="0.1"} 1`,
+		`launcher_table_generate_duration_bucket{table="kolide_launcher_info",le="1"} 2`,
+		`launcher_table_generate_duration_bucket{table="kolide_launcher_info",le="+Inf"} 3`,
+		`launcher_table_generate_duration_sum{table="kolide_launcher_info"} 5.55`,
+		`launcher_table_generate_duration_count{table="kolide_launcher_info"} 3`,
+	} {
+		require.Contains(t, string(body), expectedLine+"\n")
+	}
+}
@@ -0,0 +1,168 @@
+package metrics
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+const instrumentationPkg = "github.com/kolide/launcher/pkg/metrics"
+
+// Launcher's instruments are created from the global meter provider, which forwards them to
+// the provider set up by the metrics exporter, once there is one. Until then, recording to
+// them is a no-op.
+var (
+	logBufferDepth         metric.Int64ObservableGauge
+	logPublishDuration     metric.Float64Histogram
+	logPublishFailures     metric.Int64Counter
+	reenrollments          metric.Int64Counter
+	osqueryRestarts        metric.Int64Counter
+	controlFetches         metric.Int64Counter
+	autoupdateEvents       metric.Int64Counter
+	tableGenerateDurations metric.Float64Histogram
+	logShipperDropped      metric.Int64ObservableCounter
+
+	// logBufferDepths holds the latest depth of each log buffer, for the observable gauge
+	logBufferDepths     = make(map[string]int64)
+	logBufferDepthsLock sync.RWMutex
+
+	// logShipperDroppedSource reports the bytes the log shipper has dropped so far, by reason
+	logShipperDroppedSource     func() map[string]int64
+	logShipperDroppedSourceLock sync.RWMutex
+)
+
+func init() {
+	meter := otel.Meter(instrumentationPkg)
+
+	// Creating instruments only fails for invalid names or options, in which case the
+	// returned instrument is a no-op -- so the errors are safe to ignore.
+	logBufferDepth, _ = meter.Int64ObservableGauge(
+		"launcher.log_buffer.depth",
+		metric.WithDescription("Number of osquery logs buffered, waiting to be published"),
+		metric.WithUnit("{log}"),
+		metric.WithInt64Callback(observeLogBufferDepths),
+	)
+	logPublishDuration, _ = meter.Float64Histogram(
+		"launcher.log_publish.duration",
+		metric.WithDescription("Time taken to publish a batch of osquery logs"),
+		metric.WithUnit("s"),
+	)
+	logPublishFailures, _ = meter.Int64Counter(
+		"launcher.log_publish.failures",
+		metric.WithDescription("Number of batches of osquery logs that failed to publish"),
+		metric.WithUnit("{batch}"),
+	)
+	reenrollments, _ = meter.Int64Counter(
+		"launcher.reenrollments",
+		metric.WithDescription("Number of times launcher was required to re-enroll"),
+		metric.WithUnit("{enrollment}"),
+	)
+	osqueryRestarts, _ = meter.Int64Counter(
+		"launcher.osquery.restarts",
+		metric.WithDescription("Number of times the osquery instance was restarted"),
+		metric.WithUnit("{restart}"),
+	)
+	controlFetches, _ = meter.Int64Counter(
+		"launcher.control.fetches",
+		metric.WithDescription("Number of fetches from the control server"),
+		metric.WithUnit("{fetch}"),
+	)
+	autoupdateEvents, _ = meter.Int64Counter(
+		"launcher.autoupdate.events",
+		metric.WithDescription("Number of autoupdate checks, downloads and other events, by outcome"),
+		metric.WithUnit("{event}"),
+	)
+	tableGenerateDurations, _ = meter.Float64Histogram(
+		"launcher.table.generate.duration",
+		metric.WithDescription("Time taken to generate rows for a launcher table"),
+		metric.WithUnit("s"),
+	)
+	logShipperDropped, _ = meter.Int64ObservableCounter(
+		"launcher.log_shipper.dropped",
+		metric.WithDescription("Bytes of launcher logs the log shipper dropped without sending, by reason"),
+		metric.WithUnit("By"),
+		metric.WithInt64Callback(observeLogShipperDropped),
+	)
+}
+
+// SetLogBufferDepth records the number of logs currently buffered for the given log type
+func SetLogBufferDepth(logType string, depth int) {
+	logBufferDepthsLock.Lock()
+	defer logBufferDepthsLock.Unlock()
+
+	logBufferDepths[logType] = int64(depth)
+}
+
+func observeLogBufferDepths(_ context.Context, o metric.Int64Observer) error {
+	logBufferDepthsLock.RLock()
+	defer logBufferDepthsLock.RUnlock()
+
+	for logType, depth := range logBufferDepths {
+		o.Observe(depth, metric.WithAttributes(attribute.String("log_type", logType)))
+	}
+
+	return nil
+}
+
+// SetLogShipperDroppedSource sets the function that reports the bytes the log shipper has dropped
+// so far, keyed by reason
+func SetLogShipperDroppedSource(source func() map[string]int64) {
+	logShipperDroppedSourceLock.Lock()
+	defer logShipperDroppedSourceLock.Unlock()
+
+	logShipperDroppedSource = source
+}
+
+func observeLogShipperDropped(_ context.Context, o metric.Int64Observer) error {
+	logShipperDroppedSourceLock.RLock()
+	defer logShipperDroppedSourceLock.RUnlock()
+
+	if logShipperDroppedSource == nil {
+		return nil
+	}
+
+	for reason, dropped := range logShipperDroppedSource() {
+		o.Observe(dropped, metric.WithAttributes(attribute.String("reason", reason)))
+	}
+
+	return nil
+}
+
+// RecordLogPublish records the duration and outcome of publishing a batch of logs of the given type
+func RecordLogPublish(ctx context.Context, logType string, duration time.Duration, err error) {
+	attrs := metric.WithAttributes(attribute.String("log_type", logType), attribute.Bool("success", err == nil))
+	logPublishDuration.Record(ctx, duration.Seconds(), attrs)
+
+	if err != nil {
+		logPublishFailures.Add(ctx, 1, metric.WithAttributes(attribute.String("log_type", logType)))
+	}
+}
+
+// AddReenrollment counts a required re-enrollment
+func AddReenrollment(ctx context.Context) {
+	reenrollments.Add(ctx, 1)
+}
+
+// AddOsqueryRestart counts a restart of the osquery instance. reason is e.g. "unexpected_exit" or "requested".
+func AddOsqueryRestart(ctx context.Context, reason string) {
+	osqueryRestarts.Add(ctx, 1, metric.WithAttributes(attribute.String("reason", reason)))
+}
+
+// AddControlFetch counts a fetch from the control server, and its outcome
+func AddControlFetch(ctx context.Context, err error) {
+	controlFetches.Add(ctx, 1, metric.WithAttributes(attribute.Bool("success", err == nil)))
+}
+
+// AddAutoupdateEvent counts an autoupdate event of the given type, and its outcome
+func AddAutoupdateEvent(ctx context.Context, eventType string, err error) {
+	autoupdateEvents.Add(ctx, 1, metric.WithAttributes(attribute.String("type", eventType), attribute.Bool("success", err == nil)))
+}
+
+// RecordTableGenerate records how long the given table took to generate its rows, and whether it succeeded
+func RecordTableGenerate(ctx context.Context, tableName string, duration time.Duration, success bool) {
+	tableGenerateDurations.Record(ctx, duration.Seconds(), metric.WithAttributes(attribute.String("table", tableName), attribute.Bool("success", success)))
+}
@@ -24,6 +24,7 @@ import (
 	"github.com/kolide/launcher/pkg/agent/storage"
 	"github.com/kolide/launcher/pkg/agent/types"
 	"github.com/kolide/launcher/pkg/backoff"
+	"github.com/kolide/launcher/pkg/metrics"
 	"github.com/kolide/launcher/pkg/service"
 	"github.com/kolide/launcher/pkg/traces"
 	"github.com/mixer/clock"
@@ -449,6 +450,7 @@ func (e *Extension) RequireReenroll(ctx context.Context) {
 	// Clear the node key such that reenrollment is required.
 	e.NodeKey = ""
 	e.knapsack.ConfigStore().Delete([]byte(nodeKeyKey))
+	metrics.AddReenrollment(ctx)
 }
 
 // GenerateConfigs will request the osquery configuration from the server. If
@@ -574,6 +576,10 @@ func (e *Extension) writeAndPurgeLogs() {
 				"err", fmt.Errorf("purging %v logs: %w", typ, err),
 			)
 		}
+
+		if count, err := e.numberOfBufferedLogs(typ); err == nil {
+			metrics.SetLogBufferDepth(typ.String(), count)
+		}
 	}
 }
 
@@ -681,7 +687,9 @@ func (e *Extension) writeBufferedLogsForType(typ logger.LogType) error {
 		return nil
 	}
 
+	publishStart := time.Now()
 	err = e.writeLogsWithReenroll(context.Background(), typ, logs, true)
+	metrics.RecordLogPublish(context.Background(), typ.String(), time.Since(publishStart), err)
 	if err != nil {
 		return fmt.Errorf("writing logs: %w", err)
 	}
@@ -9,12 +9,14 @@ import (
 	"runtime"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/go-kit/kit/log/level"
 	"github.com/kolide/launcher/pkg/autoupdate"
 	"github.com/kolide/launcher/pkg/backoff"
 	"github.com/kolide/launcher/pkg/contexts/ctxlog"
+	"github.com/kolide/launcher/pkg/metrics"
 	"github.com/kolide/launcher/pkg/osquery/runtime/history"
 	"github.com/kolide/launcher/pkg/osquery/table"
 	"github.com/osquery/osquery-go/plugin/config"
@@ -41,6 +43,8 @@ type Runner struct {
 	instance     *OsqueryInstance
 	instanceLock sync.Mutex
 	shutdown     chan struct{}
+	// restartRequested distinguishes restarts via Restart from unexpected exits
+	restartRequested atomic.Bool
 }
 
 // LaunchInstance will launch an instance of osqueryd via a very configurable
@@ -107,6 +111,12 @@ func (r *Runner) Start() error {
 				level.Info(r.instance.logger).Log("msg", "error recording osquery instance exit to history", "err", err)
 			}
 
+			restartReason := "unexpected_exit"
+			if r.restartRequested.Swap(false) {
+				restartReason = "requested"
+			}
+			metrics.AddOsqueryRestart(context.Background(), restartReason)
+
 			r.instanceLock.Lock()
 			opts := r.instance.opts
 			r.instance = newInstance()
@@ -153,6 +163,7 @@ func (r *Runner) Restart() error {
 	defer r.instanceLock.Unlock()
 	// Cancelling will cause all of the cleanup routines to execute, and a
 	// new instance will start.
+	r.restartRequested.Store(true)
 	r.instance.cancel()
 	r.instance.errgroup.Wait()
 
@@ -0,0 +1,87 @@
+package table
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/kolide/launcher/pkg/metrics"
+	"github.com/kolide/launcher/pkg/traces"
+	osquery "github.com/osquery/osquery-go"
+	osquerygen "github.com/osquery/osquery-go/gen/osquery"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// instrumentedTable wraps a table plugin, tracing and timing each of its generate calls
+type instrumentedTable struct {
+	osquery.OsqueryPlugin
+}
+
+// instrumentTables wraps all table plugins in the given list; other plugins are returned as they are.
+func instrumentTables(plugins []osquery.OsqueryPlugin) []osquery.OsqueryPlugin {
+	instrumented := make([]osquery.OsqueryPlugin, len(plugins))
+	for i, p := range plugins {
+		if p.RegistryName() == "table" {
+			instrumented[i] = &instrumentedTable{OsqueryPlugin: p}
+			continue
+		}
+		instrumented[i] = p
+	}
+
+	return instrumented
+}
+
+func (t *instrumentedTable) Call(ctx context.Context, request osquerygen.ExtensionPluginRequest) osquerygen.ExtensionResponse {
+	if request["action"] != "generate" {
+		return t.OsqueryPlugin.Call(ctx, request)
+	}
+
+	ctx, span := traces.StartSpan(ctx, "table_name", t.Name(), "constraints", constrainedColumns(request["context"]))
+	defer span.End()
+	span.SetName("table/" + t.Name())
+
+	start := time.Now()
+	response := t.OsqueryPlugin.Call(ctx, request)
+	duration := time.Since(start)
+
+	success := response.Status == nil || response.Status.Code == 0
+	span.SetAttributes(
+		attribute.Int("launcher.table.row_count", len(response.Response)),
+		attribute.Int64("launcher.table.exec_duration_ms", duration.Milliseconds()),
+	)
+	if !success {
+		traces.SetError(span, errors.New(response.Status.Message))
+	}
+
+	metrics.RecordTableGenerate(ctx, t.Name(), duration, success)
+
+	return response
+}
+
+// constrainedColumns returns the names of the columns the query constrains, from the query
+// context osquery sends with a generate request. osquery lists every column, with an empty
+// string rather than a list for those without constraints.
+func constrainedColumns(queryContextJSON string) []string {
+	var queryContext struct {
+		Constraints []struct {
+			Name string          `json:"name"`
+			List json.RawMessage `json:"list"`
+		} `json:"constraints"`
+	}
+
+	columns := make([]string, 0)
+	if err := json.Unmarshal([]byte(queryContextJSON), &queryContext); err != nil {
+		return columns
+	}
+
+	for _, c := range queryContext.Constraints {
+		var constraints []json.RawMessage
+		if err := json.Unmarshal(c.List, &constraints); err != nil || len(constraints) == 0 {
+			continue
+		}
+		columns = append(columns, c.Name)
+	}
+
+	return columns
+}
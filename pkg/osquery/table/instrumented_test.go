@@ -0,0 +1,100 @@
+package table
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	osquery "github.com/osquery/osquery-go"
+	"github.com/osquery/osquery-go/plugin/table"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func Test_constrainedColumns(t *testing.T) {
+	t.Parallel()
+
+	var tests = []struct {
+		name            string
+		queryContext    string
+		expectedColumns []string
+	}{
+		{
+			name:            "no constraints",
+			queryContext:    `{"constraints":[{"name":"path","list":"","affinity":"TEXT"},{"name":"size","list":"","affinity":"INTEGER"}]}`,
+			expectedColumns: []string{},
+		},
+		{
+			name:            "one constrained column",
+			queryContext:    `{"constraints":[{"name":"path","list":[{"op":2,"expr":"/tmp"}],"affinity":"TEXT"},{"name":"size","list":"","affinity":"INTEGER"}]}`,
+			expectedColumns: []string{"path"},
+		},
+		{
+			name:            "invalid context",
+			queryContext:    `not json`,
+			expectedColumns: []string{},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			require.Equal(t, tt.expectedColumns, constrainedColumns(tt.queryContext))
+		})
+	}
+}
+
+func TestInstrumentedTable_Call(t *testing.T) { //nolint:paralleltest
+	// Uses the global tracer provider, so should not be run in parallel
+	exp := tracetest.NewInMemoryExporter()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exp))
+	t.Cleanup(func() { provider.Shutdown(context.Background()) })
+	previousProvider := otel.GetTracerProvider()
+	otel.SetTracerProvider(provider)
+	t.Cleanup(func() { otel.SetTracerProvider(previousProvider) })
+
+	plugins := instrumentTables([]osquery.OsqueryPlugin{
+		table.NewPlugin("kolide_test_rows", []table.ColumnDefinition{table.TextColumn("name")}, func(_ context.Context, _ table.QueryContext) ([]map[string]string, error) {
+			return []map[string]string{{"name": "a"}, {"name": "b"}}, nil
+		}),
+		table.NewPlugin("kolide_test_error", []table.ColumnDefinition{table.TextColumn("name")}, func(_ context.Context, _ table.QueryContext) ([]map[string]string, error) {
+			return nil, errors.New("test error")
+		}),
+	})
+
+	queryContext := `{"constraints":[{"name":"name","list":[{"op":2,"expr":"a"}],"affinity":"TEXT"}]}`
+	for _, p := range plugins {
+		p.Call(context.Background(), map[string]string{"action": "generate", "context": queryContext})
+	}
+	// Other actions aren't traced
+	plugins[0].Call(context.Background(), map[string]string{"action": "columns"})
+
+	// osquery-go traces its own Table.Call spans too; only look at ours
+	spans := make([]tracetest.SpanStub, 0)
+	for _, s := range exp.GetSpans() {
+		if strings.HasPrefix(s.Name, "table/") {
+			spans = append(spans, s)
+		}
+	}
+	require.Equal(t, 2, len(spans))
+
+	attrs := make(map[string]string)
+	for _, kv := range spans[0].Attributes {
+		attrs[string(kv.Key)] = kv.Value.Emit()
+	}
+	require.Equal(t, "table/kolide_test_rows", spans[0].Name)
+	require.Equal(t, "kolide_test_rows", attrs["launcher.table.table_name"])
+	require.Equal(t, "[name]", attrs["launcher.table.constraints"])
+	require.Equal(t, "2", attrs["launcher.table.row_count"])
+	require.Contains(t, attrs, "launcher.table.exec_duration_ms")
+	require.Equal(t, codes.Unset, spans[0].Status.Code)
+
+	require.Equal(t, "table/kolide_test_error", spans[1].Name)
+	require.Equal(t, codes.Error, spans[1].Status.Code)
+	require.Contains(t, spans[1].Status.Description, "test error")
+}
@@ -1,13 +1,17 @@
 package table
 
 import (
+	"path/filepath"
+
 	"github.com/kolide/launcher/pkg/agent/types"
 	"github.com/kolide/launcher/pkg/osquery/tables/cryptoinfotable"
 	"github.com/kolide/launcher/pkg/osquery/tables/dataflattentable"
 	"github.com/kolide/launcher/pkg/osquery/tables/desktopprocs"
+	"github.com/kolide/launcher/pkg/osquery/tables/desktopprompts"
 	"github.com/kolide/launcher/pkg/osquery/tables/dev_table_tooling"
 	"github.com/kolide/launcher/pkg/osquery/tables/firefox_preferences"
 	"github.com/kolide/launcher/pkg/osquery/tables/launcher_db"
+	"github.com/kolide/launcher/pkg/osquery/tables/launcherlogs"
 	"github.com/kolide/launcher/pkg/osquery/tables/osquery_instance_history"
 	"github.com/kolide/launcher/pkg/osquery/tables/tdebug"
 	"github.com/kolide/launcher/pkg/osquery/tables/tufinfo"
@@ -19,8 +23,8 @@ import (
 
 // LauncherTables returns launcher-specific tables. They're based
 // around _launcher_ things thus do not make sense in tables.ext
-func LauncherTables(k types.Knapsack) []osquery.OsqueryPlugin {
-	return []osquery.OsqueryPlugin{
+func LauncherTables(k types.Knapsack, logger log.Logger) []osquery.OsqueryPlugin {
+	return instrumentTables([]osquery.OsqueryPlugin{
 		LauncherConfigTable(k.ConfigStore()),
 		LauncherDbInfo(k.BboltDB()),
 		LauncherInfoTable(k.ConfigStore()),
@@ -30,8 +34,11 @@ func LauncherTables(k types.Knapsack) []osquery.OsqueryPlugin {
 		osquery_instance_history.TablePlugin(),
 		tufinfo.TufReleaseVersionTable(k),
 		launcher_db.TablePlugin("kolide_tuf_autoupdater_errors", k.AutoupdateErrorsStore()),
+		tufinfo.TufAutoupdaterEventsTable(k.AutoupdateEventsStore(), logger),
 		desktopprocs.TablePlugin(),
-	}
+		desktopprompts.TablePlugin(k.DesktopPromptResponsesStore()),
+		launcherlogs.TablePlugin(filepath.Join(k.RootDirectory(), "debug.json")),
+	})
 }
 
 // PlatformTables returns all tables for the launcher build platform.
@@ -64,5 +71,5 @@ func PlatformTables(client *osquery.ExtensionManagerClient, logger log.Logger, c
 	// add in the platform specific ones (as denoted by build tags)
 	tables = append(tables, platformTables(client, logger, currentOsquerydBinaryPath)...)
 
-	return tables
+	return instrumentTables(tables)
 }
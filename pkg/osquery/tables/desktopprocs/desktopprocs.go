@@ -3,6 +3,8 @@ package desktopprocs
 import (
 	"context"
 	"fmt"
+	"strings"
+	"time"
 
 	"github.com/kolide/launcher/ee/desktop/runner"
 	"github.com/osquery/osquery-go/plugin/table"
@@ -14,6 +16,13 @@ func TablePlugin() *table.Plugin {
 		table.TextColumn("pid"),
 		table.TextColumn("start_time"),
 		table.TextColumn("last_health_check"),
+		table.IntegerColumn("crash_count"),
+		table.IntegerColumn("consecutive_failures"),
+		table.TextColumn("last_exit_status"),
+		table.TextColumn("last_exit_time"),
+		table.TextColumn("last_error"),
+		table.TextColumn("next_start_time"),
+		table.TextColumn("stderr_tail"),
 	}
 	return table.NewPlugin("kolide_desktop_procs", columns, generate())
 }
@@ -23,14 +32,34 @@ func generate() table.GenerateFunc {
 		results := []map[string]string{}
 
 		for k, v := range runner.InstanceDesktopProcessRecords() {
+			// Users whose desktop has never started have no process
+			pid := ""
+			if v.Process != nil {
+				pid = fmt.Sprint(v.Process.Pid)
+			}
+
 			results = append(results, map[string]string{
-				"uid":               k,
-				"pid":               fmt.Sprint(v.Process.Pid),
-				"start_time":        fmt.Sprint(v.StartTime),
-				"last_health_check": fmt.Sprint(v.LastHealthCheck),
+				"uid":                  k,
+				"pid":                  pid,
+				"start_time":           formatTime(v.StartTime),
+				"last_health_check":    formatTime(v.LastHealthCheck),
+				"crash_count":          fmt.Sprint(v.CrashCount),
+				"consecutive_failures": fmt.Sprint(v.ConsecutiveFailures),
+				"last_exit_status":     v.LastExitStatus,
+				"last_exit_time":       formatTime(v.LastExitTime),
+				"last_error":           v.LastError,
+				"next_start_time":      formatTime(v.NextStartTime),
+				"stderr_tail":          strings.Join(v.StderrTail, "\n"),
 			})
 		}
 
 		return results, nil
 	}
 }
+
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return fmt.Sprint(t)
+}
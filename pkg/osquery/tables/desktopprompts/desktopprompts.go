@@ -0,0 +1,65 @@
+package desktopprompts
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/kolide/launcher/ee/desktop/user/prompt"
+	"github.com/kolide/launcher/pkg/agent/types"
+	"github.com/osquery/osquery-go/plugin/table"
+)
+
+// TablePlugin provides an osquery table plugin that exposes console users' responses to prompts
+// sent from the control server.
+func TablePlugin(iterator types.Iterator) *table.Plugin {
+	columns := []table.ColumnDefinition{
+		table.TextColumn("prompt_id"),
+		table.TextColumn("uid"),
+		table.TextColumn("title"),
+		table.TextColumn("status"),
+		table.TextColumn("choice"),
+		table.TextColumn("error"),
+		table.IntegerColumn("sent_at"),
+		table.IntegerColumn("responded_at"),
+	}
+
+	return table.NewPlugin("kolide_desktop_prompt_responses", columns, generate(iterator))
+}
+
+func generate(iterator types.Iterator) table.GenerateFunc {
+	return func(ctx context.Context, queryContext table.QueryContext) ([]map[string]string, error) {
+		results := make([]map[string]string, 0)
+
+		if err := iterator.ForEach(func(k, v []byte) error {
+			var response prompt.Response
+			if err := json.Unmarshal(v, &response); err != nil {
+				return fmt.Errorf("unmarshaling prompt response %s: %w", string(k), err)
+			}
+
+			results = append(results, map[string]string{
+				"prompt_id":    response.PromptID,
+				"uid":          response.UID,
+				"title":        response.Title,
+				"status":       response.Status,
+				"choice":       response.Choice,
+				"error":        response.Error,
+				"sent_at":      unixTime(response.SentAt),
+				"responded_at": unixTime(response.RespondedAt),
+			})
+			return nil
+		}); err != nil {
+			return nil, fmt.Errorf("could not fetch prompt responses: %w", err)
+		}
+
+		return results, nil
+	}
+}
+
+func unixTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return fmt.Sprint(t.Unix())
+}
@@ -0,0 +1,58 @@
+package desktopprompts
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/kolide/launcher/ee/desktop/user/prompt"
+	"github.com/kolide/launcher/pkg/agent/storage"
+	storageci "github.com/kolide/launcher/pkg/agent/storage/ci"
+	"github.com/osquery/osquery-go/plugin/table"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_generate(t *testing.T) {
+	t.Parallel()
+
+	store, err := storageci.NewStore(t, log.NewNopLogger(), storage.DesktopPromptResponsesStore.String())
+	require.NoError(t, err)
+
+	sentAt := time.Unix(1700000000, 0)
+	responses := []prompt.Response{
+		{PromptID: "aup", UID: "501", Title: "Acceptable use", Status: prompt.StatusAnswered, Choice: "Yes", SentAt: sentAt, RespondedAt: sentAt.Add(time.Minute)},
+		{PromptID: "aup", UID: "502", Title: "Acceptable use", Status: prompt.StatusPending, SentAt: sentAt},
+	}
+	for _, r := range responses {
+		raw, err := json.Marshal(r)
+		require.NoError(t, err)
+		require.NoError(t, store.Set([]byte(r.PromptID+":"+r.UID), raw))
+	}
+
+	rows, err := generate(store)(context.TODO(), table.QueryContext{})
+	require.NoError(t, err)
+	require.ElementsMatch(t, []map[string]string{
+		{
+			"prompt_id":    "aup",
+			"uid":          "501",
+			"title":        "Acceptable use",
+			"status":       "answered",
+			"choice":       "Yes",
+			"error":        "",
+			"sent_at":      "1700000000",
+			"responded_at": "1700000060",
+		},
+		{
+			"prompt_id":    "aup",
+			"uid":          "502",
+			"title":        "Acceptable use",
+			"status":       "pending",
+			"choice":       "",
+			"error":        "",
+			"sent_at":      "1700000000",
+			"responded_at": "",
+		},
+	}, rows)
+}
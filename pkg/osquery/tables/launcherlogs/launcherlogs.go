@@ -0,0 +1,256 @@
+package launcherlogs
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kolide/launcher/pkg/osquery/tables/tablehelpers"
+	"github.com/osquery/osquery-go/plugin/table"
+)
+
+const (
+	// backupTimeFormat is the timestamp lumberjack puts in the names of rotated log files
+	backupTimeFormat = "2006-01-02T15-04-05.000"
+
+	// maxLineSize bounds a single log line; anything longer is skipped
+	maxLineSize = 1024 * 1024
+)
+
+// TablePlugin provides an osquery table plugin that exposes launcher's local debug log, including
+// rotated backups. Constraints on level, component and time are applied while reading, so that
+// queries for recent entries don't need to parse the whole history.
+func TablePlugin(logFilePath string) *table.Plugin {
+	columns := []table.ColumnDefinition{
+		table.IntegerColumn("time"),
+		table.TextColumn("ts"),
+		table.TextColumn("level"),
+		table.TextColumn("component"),
+		table.TextColumn("msg"),
+		table.TextColumn("caller"),
+		table.TextColumn("line"),
+	}
+
+	t := &launcherLogsTable{logFilePath: logFilePath}
+
+	return table.NewPlugin("kolide_launcher_logs", columns, t.generate)
+}
+
+type launcherLogsTable struct {
+	logFilePath string
+}
+
+// logFilter holds the constraints that can be checked before a row is returned
+type logFilter struct {
+	levels     map[string]struct{}
+	components map[string]struct{}
+	after      int64
+	before     int64
+}
+
+func (t *launcherLogsTable) generate(ctx context.Context, queryContext table.QueryContext) ([]map[string]string, error) {
+	filter := newLogFilter(queryContext)
+	results := make([]map[string]string, 0)
+
+	for _, logFile := range t.logFiles(filter.after) {
+		rows, err := readLogFile(logFile, filter)
+		if err != nil {
+			// Rotation may have moved the file out from under us
+			if os.IsNotExist(err) {
+				continue
+			}
+			return nil, fmt.Errorf("reading %s: %w", logFile, err)
+		}
+		results = append(results, rows...)
+	}
+
+	return results, nil
+}
+
+// logFiles returns the log files that may hold entries at or after the given unix time, oldest first.
+// A rotated file only holds entries from before it was rotated, so older backups can be skipped.
+func (t *launcherLogsTable) logFiles(after int64) []string {
+	ext := filepath.Ext(t.logFilePath)
+	prefix := strings.TrimSuffix(filepath.Base(t.logFilePath), ext) + "-"
+
+	backups, _ := filepath.Glob(filepath.Join(filepath.Dir(t.logFilePath), prefix+"*"+ext+"*"))
+
+	type backup struct {
+		path      string
+		rotatedAt time.Time
+	}
+	candidates := make([]backup, 0, len(backups))
+	for _, b := range backups {
+		ts := strings.TrimSuffix(strings.TrimSuffix(strings.TrimPrefix(filepath.Base(b), prefix), ".gz"), ext)
+		rotatedAt, err := time.Parse(backupTimeFormat, ts)
+		if err != nil {
+			continue
+		}
+		if rotatedAt.Unix() < after {
+			continue
+		}
+		candidates = append(candidates, backup{path: b, rotatedAt: rotatedAt})
+	}
+
+	sort.Slice(candidates, func(i, j int) bool {
+		return candidates[i].rotatedAt.Before(candidates[j].rotatedAt)
+	})
+
+	files := make([]string, 0, len(candidates)+1)
+	for _, c := range candidates {
+		files = append(files, c.path)
+	}
+
+	return append(files, t.logFilePath)
+}
+
+func newLogFilter(queryContext table.QueryContext) logFilter {
+	filter := logFilter{
+		after:  math.MinInt64,
+		before: math.MaxInt64,
+	}
+
+	if levels := tablehelpers.GetConstraints(queryContext, "level"); len(levels) > 0 {
+		filter.levels = make(map[string]struct{})
+		for _, l := range levels {
+			filter.levels[l] = struct{}{}
+		}
+	}
+
+	if components := tablehelpers.GetConstraints(queryContext, "component"); len(components) > 0 {
+		filter.components = make(map[string]struct{})
+		for _, c := range components {
+			filter.components[c] = struct{}{}
+		}
+	}
+
+	// GetConstraints ignores the operator, so the time range is built from the constraints directly.
+	// osquery applies the constraints again to the returned rows, so this only needs to be a superset.
+	if q, ok := queryContext.Constraints["time"]; ok {
+		for _, c := range q.Constraints {
+			val, err := strconv.ParseInt(c.Expression, 10, 64)
+			if err != nil {
+				continue
+			}
+
+			switch c.Operator {
+			case table.OperatorEquals:
+				filter.raiseAfter(val)
+				filter.lowerBefore(val)
+			case table.OperatorGreaterThan, table.OperatorGreaterThanOrEquals:
+				filter.raiseAfter(val)
+			case table.OperatorLessThan, table.OperatorLessThanOrEquals:
+				filter.lowerBefore(val)
+			}
+		}
+	}
+
+	return filter
+}
+
+func (filter *logFilter) raiseAfter(val int64) {
+	if val > filter.after {
+		filter.after = val
+	}
+}
+
+func (filter *logFilter) lowerBefore(val int64) {
+	if val < filter.before {
+		filter.before = val
+	}
+}
+
+func readLogFile(path string, filter logFilter) ([]map[string]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var r io.Reader = f
+	if strings.HasSuffix(path, ".gz") {
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			return nil, fmt.Errorf("opening gzip reader: %w", err)
+		}
+		defer gz.Close()
+		r = gz
+	}
+
+	results := make([]map[string]string, 0)
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxLineSize)
+	for scanner.Scan() {
+		if row, ok := filter.row(scanner.Bytes()); ok {
+			results = append(results, row)
+		}
+	}
+
+	// A truncated or oversized line ends the file early, but what was read is still useful
+	return results, nil
+}
+
+// row parses a log line, and returns it as a table row if it matches the filter
+func (filter logFilter) row(line []byte) (map[string]string, bool) {
+	var entry map[string]any
+	if err := json.Unmarshal(line, &entry); err != nil {
+		return nil, false
+	}
+
+	lvl := stringValue(entry["level"])
+	if filter.levels != nil {
+		if _, ok := filter.levels[lvl]; !ok {
+			return nil, false
+		}
+	}
+
+	component := stringValue(entry["component"])
+	if filter.components != nil {
+		if _, ok := filter.components[component]; !ok {
+			return nil, false
+		}
+	}
+
+	ts := stringValue(entry["ts"])
+	unixTime := ""
+	if parsed, err := time.Parse(time.RFC3339Nano, ts); err == nil {
+		if parsed.Unix() < filter.after || parsed.Unix() > filter.before {
+			return nil, false
+		}
+		unixTime = strconv.FormatInt(parsed.Unix(), 10)
+	} else if filter.after != math.MinInt64 || filter.before != math.MaxInt64 {
+		return nil, false
+	}
+
+	return map[string]string{
+		"time":      unixTime,
+		"ts":        ts,
+		"level":     lvl,
+		"component": component,
+		"msg":       stringValue(entry["msg"]),
+		"caller":    stringValue(entry["caller"]),
+		"line":      string(line),
+	}, true
+}
+
+func stringValue(v any) string {
+	switch val := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return val
+	default:
+		return fmt.Sprint(val)
+	}
+}
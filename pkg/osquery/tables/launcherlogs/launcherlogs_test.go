@@ -0,0 +1,121 @@
+package launcherlogs
+
+import (
+	"compress/gzip"
+	"context"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/osquery/osquery-go/plugin/table"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_generate(t *testing.T) {
+	t.Parallel()
+
+	rootDir := t.TempDir()
+	logFilePath := filepath.Join(rootDir, "debug.json")
+
+	now := time.Now().UTC()
+	longAgo := now.Add(-48 * time.Hour)
+
+	// A backup rotated long ago, which should be skipped entirely when querying recent entries
+	writeGzip(t, filepath.Join(rootDir, "debug-"+longAgo.Format(backupTimeFormat)+".json.gz"), []string{
+		`{"caller":"old.go:1","level":"error","msg":"old error","ts":"` + longAgo.Add(-time.Minute).Format(time.RFC3339Nano) + `"}`,
+	})
+
+	require.NoError(t, os.WriteFile(logFilePath, []byte(strings.Join([]string{
+		`{"caller":"a.go:1","component":"control","level":"error","msg":"control error","ts":"` + now.Add(-10*time.Minute).Format(time.RFC3339Nano) + `"}`,
+		`{"caller":"b.go:2","component":"control","level":"debug","msg":"control debug","ts":"` + now.Add(-5*time.Minute).Format(time.RFC3339Nano) + `"}`,
+		`{"caller":"c.go:3","component":"localserver","level":"error","msg":"localserver error","ts":"` + now.Add(-2*time.Minute).Format(time.RFC3339Nano) + `"}`,
+		`not json`,
+	}, "\n")+"\n"), 0600))
+
+	var tests = []struct {
+		name         string
+		constraints  map[string]table.ConstraintList
+		expectedMsgs []string
+	}{
+		{
+			name:         "no constraints",
+			expectedMsgs: []string{"old error", "control error", "control debug", "localserver error"},
+		},
+		{
+			name: "level",
+			constraints: map[string]table.ConstraintList{
+				"level": {Constraints: []table.Constraint{{Operator: table.OperatorEquals, Expression: "error"}}},
+			},
+			expectedMsgs: []string{"old error", "control error", "localserver error"},
+		},
+		{
+			name: "component",
+			constraints: map[string]table.ConstraintList{
+				"component": {Constraints: []table.Constraint{{Operator: table.OperatorEquals, Expression: "control"}}},
+			},
+			expectedMsgs: []string{"control error", "control debug"},
+		},
+		{
+			name: "errors in the last hour",
+			constraints: map[string]table.ConstraintList{
+				"level": {Constraints: []table.Constraint{{Operator: table.OperatorEquals, Expression: "error"}}},
+				"time":  {Constraints: []table.Constraint{{Operator: table.OperatorGreaterThan, Expression: unixString(now.Add(-time.Hour))}}},
+			},
+			expectedMsgs: []string{"control error", "localserver error"},
+		},
+		{
+			name: "time range",
+			constraints: map[string]table.ConstraintList{
+				"time": {Constraints: []table.Constraint{
+					{Operator: table.OperatorGreaterThanOrEquals, Expression: unixString(now.Add(-6 * time.Minute))},
+					{Operator: table.OperatorLessThan, Expression: unixString(now.Add(-3 * time.Minute))},
+				}},
+			},
+			expectedMsgs: []string{"control debug"},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			lt := &launcherLogsTable{logFilePath: logFilePath}
+			rows, err := lt.generate(context.TODO(), table.QueryContext{Constraints: tt.constraints})
+			require.NoError(t, err)
+
+			msgs := make([]string, 0, len(rows))
+			for _, row := range rows {
+				msgs = append(msgs, row["msg"])
+			}
+			require.Equal(t, tt.expectedMsgs, msgs)
+		})
+	}
+}
+
+func Test_generate_NoLogFile(t *testing.T) {
+	t.Parallel()
+
+	lt := &launcherLogsTable{logFilePath: filepath.Join(t.TempDir(), "debug.json")}
+	rows, err := lt.generate(context.TODO(), table.QueryContext{})
+	require.NoError(t, err)
+	require.Empty(t, rows)
+}
+
+func writeGzip(t *testing.T, path string, lines []string) {
+	f, err := os.Create(path)
+	require.NoError(t, err)
+	defer f.Close()
+
+	gz := gzip.NewWriter(f)
+	_, err = gz.Write([]byte(strings.Join(lines, "\n") + "\n"))
+	require.NoError(t, err)
+	require.NoError(t, gz.Close())
+}
+
+func unixString(t time.Time) string {
+	return strconv.FormatInt(t.Unix(), 10)
+}
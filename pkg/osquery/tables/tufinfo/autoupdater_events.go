@@ -0,0 +1,67 @@
+package tufinfo
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/go-kit/kit/log"
+	"github.com/osquery/osquery-go/plugin/table"
+
+	"github.com/kolide/launcher/pkg/agent/types"
+	"github.com/kolide/launcher/pkg/autoupdate/tuf"
+)
+
+const tufAutoupdaterEventsTableName = "kolide_tuf_autoupdater_events"
+
+// TufAutoupdaterEventsTable exposes the TUF autoupdater's event history -- checks, downloads,
+// verifications, installs, restarts and rollbacks -- oldest first. Events that can't be read
+// are logged and left out.
+func TufAutoupdaterEventsTable(store types.Iterator, logger log.Logger) *table.Plugin {
+	columns := []table.ColumnDefinition{
+		table.BigIntColumn("timestamp"),
+		table.TextColumn("type"),
+		table.TextColumn("binary"),
+		table.TextColumn("from_version"),
+		table.TextColumn("to_version"),
+		table.BigIntColumn("duration_ms"),
+		table.IntegerColumn("success"),
+		table.TextColumn("error"),
+	}
+
+	return table.NewPlugin(tufAutoupdaterEventsTableName, columns, generateTufAutoupdaterEventsTable(store, log.With(logger, "table", tufAutoupdaterEventsTableName)))
+}
+
+func generateTufAutoupdaterEventsTable(store types.Iterator, logger log.Logger) table.GenerateFunc {
+	return func(ctx context.Context, queryContext table.QueryContext) ([]map[string]string, error) {
+		if store == nil {
+			return nil, fmt.Errorf("no store available for %s", tufAutoupdaterEventsTableName)
+		}
+
+		events, err := tuf.AutoupdateEvents(store, logger)
+		if err != nil {
+			return nil, fmt.Errorf("could not fetch data for %s: %w", tufAutoupdaterEventsTableName, err)
+		}
+
+		results := make([]map[string]string, len(events))
+		for i, e := range events {
+			success := "0"
+			if e.Success {
+				success = "1"
+			}
+
+			results[i] = map[string]string{
+				"timestamp":    strconv.FormatInt(e.Timestamp.Unix(), 10),
+				"type":         e.Type,
+				"binary":       e.Binary,
+				"from_version": e.FromVersion,
+				"to_version":   e.ToVersion,
+				"duration_ms":  strconv.FormatInt(e.DurationMs, 10),
+				"success":      success,
+				"error":        e.Error,
+			}
+		}
+
+		return results, nil
+	}
+}
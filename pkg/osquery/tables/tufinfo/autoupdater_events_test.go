@@ -0,0 +1,76 @@
+package tufinfo
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/kolide/launcher/pkg/agent/storage"
+	storageci "github.com/kolide/launcher/pkg/agent/storage/ci"
+	"github.com/kolide/launcher/pkg/autoupdate/tuf"
+	"github.com/osquery/osquery-go/gen/osquery"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTufAutoupdaterEventsTable(t *testing.T) {
+	t.Parallel()
+
+	store, err := storageci.NewStore(t, log.NewNopLogger(), storage.AutoupdateEventsStore.String())
+	require.NoError(t, err)
+
+	// Seed some events, out of order
+	now := time.Now()
+	seededEvents := []tuf.AutoupdateEvent{
+		{
+			Timestamp:   now,
+			Type:        tuf.EventTypeInstall,
+			Binary:      "osqueryd",
+			FromVersion: "5.8.1",
+			ToVersion:   "5.8.2",
+			DurationMs:  150,
+			Success:     false,
+			Error:       "test error",
+		},
+		{
+			Timestamp:  now.Add(-1 * time.Minute),
+			Type:       tuf.EventTypeCheck,
+			DurationMs: 3000,
+			Success:    true,
+		},
+	}
+	for _, e := range seededEvents {
+		rawEvent, err := json.Marshal(e)
+		require.NoError(t, err)
+		require.NoError(t, store.Set([]byte(strconv.FormatInt(e.Timestamp.UnixNano(), 10)), rawEvent))
+	}
+
+	// A corrupt event should be skipped, rather than failing the table
+	require.NoError(t, store.Set([]byte(strconv.FormatInt(now.UnixNano()+1, 10)), []byte("not json")))
+
+	testTable := TufAutoupdaterEventsTable(store, log.NewNopLogger())
+	resp := testTable.Call(context.Background(), osquery.ExtensionPluginRequest{
+		"action":  "generate",
+		"context": "{}",
+	})
+
+	// Require success
+	require.Equal(t, int32(0), resp.Status.Code, fmt.Sprintf("unexpected failure generating table: %s", resp.Status.Message))
+
+	// Events should be returned oldest first
+	require.Equal(t, 2, len(resp.Response))
+	require.Equal(t, tuf.EventTypeCheck, resp.Response[0]["type"])
+	require.Equal(t, "1", resp.Response[0]["success"])
+	require.Equal(t, "3000", resp.Response[0]["duration_ms"])
+
+	require.Equal(t, tuf.EventTypeInstall, resp.Response[1]["type"])
+	require.Equal(t, "osqueryd", resp.Response[1]["binary"])
+	require.Equal(t, "5.8.1", resp.Response[1]["from_version"])
+	require.Equal(t, "5.8.2", resp.Response[1]["to_version"])
+	require.Equal(t, "0", resp.Response[1]["success"])
+	require.Equal(t, "test error", resp.Response[1]["error"])
+	require.Equal(t, strconv.FormatInt(now.Unix(), 10), resp.Response[1]["timestamp"])
+}
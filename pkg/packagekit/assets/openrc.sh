@@ -0,0 +1,26 @@
+#!/sbin/openrc-run
+
+name="{{.Common.Identifier}}"
+description="{{.Common.Description}}"
+
+{{- range $key, $value := .Common.Environment }}
+export {{$key}}={{$value}}
+{{- end }}
+
+command="{{.Common.Path}}"
+command_args="{{ StringsJoin .Common.Flags " \\\n" }}"
+
+# supervise-daemon restarts launcher if it exits, much like systemd's
+# Restart=on-failure
+supervisor="supervise-daemon"
+respawn_delay=3
+respawn_max=0
+
+output_log="/var/log/${RC_SVCNAME}.log"
+error_log="/var/log/${RC_SVCNAME}.log"
+
+depend() {
+    need net
+    use dns logger
+    after firewall
+}
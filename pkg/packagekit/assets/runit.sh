@@ -0,0 +1,12 @@
+#!/bin/sh
+#
+# runit service for {{.Common.Description}} ({{.Common.Identifier}})
+
+exec 2>&1
+
+{{- range $key, $value := .Common.Environment }}
+export {{$key}}={{$value}}
+{{- end }}
+
+exec {{.Common.Path}}{{ range .Common.Flags }} \
+    {{.}}{{ end }}
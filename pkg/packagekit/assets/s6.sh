@@ -0,0 +1,10 @@
+#!/bin/execlineb -P
+#
+# s6 service for {{.Common.Description}} ({{.Common.Identifier}})
+
+fdmove -c 2 1
+{{- range $key, $value := .Common.Environment }}
+export {{$key}} {{$value}}
+{{- end }}
+{{.Common.Path}}{{ range .Common.Flags }}
+    {{.}}{{ end }}
@@ -0,0 +1,286 @@
+package linuxrepo
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-kit/kit/log/level"
+	"github.com/kolide/launcher/pkg/contexts/ctxlog"
+	"go.opencensus.io/trace"
+)
+
+// AptOptions configures the generated apt repository.
+type AptOptions struct {
+	Suite         string   // The distribution name, e.g. `stable`. Clients reference this in their sources.list
+	Component     string   // The component within the suite, e.g. `main`
+	Origin        string   // Optional Origin field for the Release file
+	Label         string   // Optional Label field for the Release file
+	Architectures []string // Architectures to index. If empty, this is derived from the packages
+}
+
+// aptPackage is a single deb package, as it will appear in the Packages index.
+type aptPackage struct {
+	control  debControl
+	filename string // path relative to the repository root
+	digests  digests
+}
+
+// BuildAptRepo creates a signed apt repository in `outputDir` containing the given deb
+// packages. The packages are copied into the repository's pool, and the Packages, Release,
+// Release.gpg, and InRelease files are generated. The signer's public key is written to the
+// root of the repository as `public.asc`.
+//
+// Existing metadata in `outputDir` is overwritten. Packages already present in the
+// component's pool are kept, and indexed along with the new ones.
+func BuildAptRepo(ctx context.Context, outputDir string, debPaths []string, signer *SigningKey, opts AptOptions) error {
+	ctx, span := trace.StartSpan(ctx, "linuxrepo.BuildAptRepo")
+	defer span.End()
+
+	logger := ctxlog.FromContext(ctx)
+
+	if signer == nil {
+		return errors.New("a signing key is required")
+	}
+	if len(debPaths) == 0 {
+		return errors.New("no packages provided")
+	}
+	if opts.Suite == "" {
+		opts.Suite = "stable"
+	}
+	if opts.Component == "" {
+		opts.Component = "main"
+	}
+
+	for _, debPath := range debPaths {
+		control, err := readDebControl(debPath)
+		if err != nil {
+			return fmt.Errorf("reading package metadata: %w", err)
+		}
+
+		poolPath := path.Join("pool", opts.Component, poolPrefix(control.field("package")), control.field("package"), filepath.Base(debPath))
+		if err := os.MkdirAll(filepath.Join(outputDir, filepath.FromSlash(path.Dir(poolPath))), 0755); err != nil {
+			return fmt.Errorf("creating pool directory: %w", err)
+		}
+
+		if _, err := copyAndDigest(debPath, filepath.Join(outputDir, filepath.FromSlash(poolPath))); err != nil {
+			return fmt.Errorf("adding %s to pool: %w", debPath, err)
+		}
+
+		level.Debug(logger).Log("msg", "added package to apt pool", "package", control.field("package"), "path", poolPath)
+	}
+
+	// Index everything in the component's pool, so that packages added by earlier runs remain available
+	packages, err := poolPackages(outputDir, opts.Component)
+	if err != nil {
+		return fmt.Errorf("indexing pool: %w", err)
+	}
+
+	sort.SliceStable(packages, func(i, j int) bool {
+		if packages[i].control.field("package") != packages[j].control.field("package") {
+			return packages[i].control.field("package") < packages[j].control.field("package")
+		}
+		return packages[i].control.field("version") < packages[j].control.field("version")
+	})
+
+	architectures := opts.Architectures
+	if len(architectures) == 0 {
+		architectures = packageArchitectures(packages)
+	}
+
+	distDir := filepath.Join(outputDir, "dists", opts.Suite)
+
+	// indexFiles are the files that the Release file must list, relative to distDir
+	indexFiles := make(map[string]digests)
+	for _, arch := range architectures {
+		packagesIndex := packagesIndexFor(packages, arch)
+
+		relDir := path.Join(opts.Component, "binary-"+arch)
+		if err := os.MkdirAll(filepath.Join(distDir, filepath.FromSlash(relDir)), 0755); err != nil {
+			return fmt.Errorf("creating index directory: %w", err)
+		}
+
+		compressedIndex, err := gzipBytes(packagesIndex)
+		if err != nil {
+			return fmt.Errorf("compressing Packages for %s: %w", arch, err)
+		}
+
+		for filename, contents := range map[string][]byte{
+			"Packages":    packagesIndex,
+			"Packages.gz": compressedIndex,
+		} {
+			relPath := path.Join(relDir, filename)
+			if err := os.WriteFile(filepath.Join(distDir, filepath.FromSlash(relPath)), contents, 0644); err != nil {
+				return fmt.Errorf("writing %s: %w", relPath, err)
+			}
+			indexFiles[relPath] = digestBytes(contents)
+		}
+	}
+
+	release := releaseFile(opts, architectures, indexFiles, time.Now())
+	if err := os.WriteFile(filepath.Join(distDir, "Release"), release, 0644); err != nil {
+		return fmt.Errorf("writing Release: %w", err)
+	}
+	if err := writeDetachedSignature(signer, release, filepath.Join(distDir, "Release.gpg")); err != nil {
+		return fmt.Errorf("writing Release.gpg: %w", err)
+	}
+	if err := writeClearsigned(signer, release, filepath.Join(distDir, "InRelease")); err != nil {
+		return fmt.Errorf("writing InRelease: %w", err)
+	}
+	if err := writePublicKey(signer, filepath.Join(outputDir, "public.asc")); err != nil {
+		return fmt.Errorf("writing public key: %w", err)
+	}
+
+	return nil
+}
+
+// poolPackages reads the metadata of every deb package in the given component's pool.
+func poolPackages(outputDir string, component string) ([]aptPackage, error) {
+	poolDir := filepath.Join(outputDir, "pool", component)
+	debPaths, err := findPackages(poolDir, ".deb")
+	if err != nil {
+		return nil, err
+	}
+
+	packages := make([]aptPackage, 0, len(debPaths))
+	for _, debPath := range debPaths {
+		control, err := readDebControl(debPath)
+		if err != nil {
+			return nil, fmt.Errorf("reading package metadata: %w", err)
+		}
+
+		d, err := digestFile(debPath)
+		if err != nil {
+			return nil, fmt.Errorf("digesting %s: %w", debPath, err)
+		}
+
+		relPath, err := filepath.Rel(outputDir, debPath)
+		if err != nil {
+			return nil, fmt.Errorf("getting path of %s within repository: %w", debPath, err)
+		}
+
+		packages = append(packages, aptPackage{
+			control:  control,
+			filename: filepath.ToSlash(relPath),
+			digests:  d,
+		})
+	}
+
+	return packages, nil
+}
+
+// poolPrefix returns the pool subdirectory for a package, following Debian's convention of
+// using the first letter of the package name -- or the first four, for libraries.
+func poolPrefix(packageName string) string {
+	if strings.HasPrefix(packageName, "lib") && len(packageName) > 3 {
+		return packageName[:4]
+	}
+	return packageName[:1]
+}
+
+// packageArchitectures returns the sorted set of concrete architectures across all packages.
+// Architecture-independent packages (`all`) are included in every architecture's index, so
+// `all` is not itself an architecture. If there are only architecture-independent packages,
+// we default to amd64.
+func packageArchitectures(packages []aptPackage) []string {
+	found := make(map[string]bool)
+	for _, p := range packages {
+		if arch := p.control.field("architecture"); arch != "all" {
+			found[arch] = true
+		}
+	}
+
+	if len(found) == 0 {
+		return []string{"amd64"}
+	}
+
+	architectures := make([]string, 0, len(found))
+	for arch := range found {
+		architectures = append(architectures, arch)
+	}
+	sort.Strings(architectures)
+
+	return architectures
+}
+
+// packagesIndexFor generates the Packages index for the given architecture.
+func packagesIndexFor(packages []aptPackage, arch string) []byte {
+	var index bytes.Buffer
+	for _, p := range packages {
+		packageArch := p.control.field("architecture")
+		if packageArch != arch && packageArch != "all" {
+			continue
+		}
+
+		index.WriteString(p.control.raw)
+		index.WriteString("\n")
+		fmt.Fprintf(&index, "Filename: %s\n", p.filename)
+		fmt.Fprintf(&index, "Size: %d\n", p.digests.size)
+		fmt.Fprintf(&index, "MD5sum: %s\n", p.digests.md5)
+		fmt.Fprintf(&index, "SHA1: %s\n", p.digests.sha1)
+		fmt.Fprintf(&index, "SHA256: %s\n", p.digests.sha256)
+		index.WriteString("\n")
+	}
+
+	return index.Bytes()
+}
+
+// releaseFile generates the suite's Release file, which lists the checksums of every index.
+func releaseFile(opts AptOptions, architectures []string, indexFiles map[string]digests, now time.Time) []byte {
+	var release bytes.Buffer
+	if opts.Origin != "" {
+		fmt.Fprintf(&release, "Origin: %s\n", opts.Origin)
+	}
+	if opts.Label != "" {
+		fmt.Fprintf(&release, "Label: %s\n", opts.Label)
+	}
+	fmt.Fprintf(&release, "Suite: %s\n", opts.Suite)
+	fmt.Fprintf(&release, "Codename: %s\n", opts.Suite)
+	fmt.Fprintf(&release, "Date: %s\n", now.UTC().Format(time.RFC1123Z))
+	fmt.Fprintf(&release, "Architectures: %s\n", strings.Join(architectures, " "))
+	fmt.Fprintf(&release, "Components: %s\n", opts.Component)
+
+	relPaths := make([]string, 0, len(indexFiles))
+	for relPath := range indexFiles {
+		relPaths = append(relPaths, relPath)
+	}
+	sort.Strings(relPaths)
+
+	for _, section := range []struct {
+		name string
+		hash func(digests) string
+	}{
+		{"MD5Sum", func(d digests) string { return d.md5 }},
+		{"SHA1", func(d digests) string { return d.sha1 }},
+		{"SHA256", func(d digests) string { return d.sha256 }},
+	} {
+		fmt.Fprintf(&release, "%s:\n", section.name)
+		for _, relPath := range relPaths {
+			fmt.Fprintf(&release, " %s %16d %s\n", section.hash(indexFiles[relPath]), indexFiles[relPath].size, relPath)
+		}
+	}
+
+	return release.Bytes()
+}
+
+// gzipBytes compresses `b`. The gzip header is left without a timestamp, so that the output
+// is reproducible.
+func gzipBytes(b []byte) ([]byte, error) {
+	var compressed bytes.Buffer
+	w := gzip.NewWriter(&compressed)
+	if _, err := w.Write(b); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return compressed.Bytes(), nil
+}
@@ -0,0 +1,359 @@
+package linuxrepo
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/stretchr/testify/require"
+	"github.com/ulikunitz/xz"
+	"golang.org/x/crypto/openpgp"
+	"golang.org/x/crypto/openpgp/armor"
+	"golang.org/x/crypto/openpgp/clearsign"
+	"golang.org/x/crypto/openpgp/packet"
+)
+
+func TestBuildAptRepo(t *testing.T) {
+	t.Parallel()
+
+	signer := testSigningKey(t)
+
+	packageDir := t.TempDir()
+	debPaths := []string{
+		filepath.Join(packageDir, "launcher_1.2.3_amd64.deb"),
+		filepath.Join(packageDir, "launcher_1.2.3_arm64.deb"),
+		filepath.Join(packageDir, "launcher-docs_1.2.3_all.deb"),
+	}
+	buildTestDeb(t, debPaths[0], "launcher", "1.2.3", "amd64")
+	buildTestDeb(t, debPaths[1], "launcher", "1.2.3", "arm64")
+	buildTestDeb(t, debPaths[2], "launcher-docs", "1.2.3", "all")
+
+	repoDir := t.TempDir()
+	require.NoError(t, BuildAptRepo(context.TODO(), repoDir, debPaths, signer, AptOptions{
+		Suite:     "stable",
+		Component: "main",
+		Origin:    "Kolide",
+	}))
+
+	// Packages are copied into the pool
+	require.FileExists(t, filepath.Join(repoDir, "pool", "main", "l", "launcher", "launcher_1.2.3_amd64.deb"))
+	require.FileExists(t, filepath.Join(repoDir, "pool", "main", "l", "launcher-docs", "launcher-docs_1.2.3_all.deb"))
+	require.FileExists(t, filepath.Join(repoDir, "public.asc"))
+
+	distDir := filepath.Join(repoDir, "dists", "stable")
+	release, err := os.ReadFile(filepath.Join(distDir, "Release"))
+	require.NoError(t, err)
+	require.Contains(t, string(release), "Origin: Kolide\n")
+	require.Contains(t, string(release), "Architectures: amd64 arm64\n")
+	require.Contains(t, string(release), "Components: main\n")
+
+	// Release.gpg is a valid detached signature
+	releaseSignature, err := os.Open(filepath.Join(distDir, "Release.gpg"))
+	require.NoError(t, err)
+	defer releaseSignature.Close()
+	_, err = openpgp.CheckArmoredDetachedSignature(openpgp.EntityList{signer.entity}, bytes.NewReader(release), releaseSignature)
+	require.NoError(t, err)
+
+	// InRelease is the clearsigned Release file
+	inRelease, err := os.ReadFile(filepath.Join(distDir, "InRelease"))
+	require.NoError(t, err)
+	block, _ := clearsign.Decode(inRelease)
+	require.NotNil(t, block)
+	require.Equal(t, string(release), string(block.Plaintext))
+	_, err = openpgp.CheckDetachedSignature(openpgp.EntityList{signer.entity}, bytes.NewReader(block.Bytes), block.ArmoredSignature.Body)
+	require.NoError(t, err)
+
+	for _, arch := range []string{"amd64", "arm64"} {
+		packagesRelPath := fmt.Sprintf("main/binary-%s/Packages", arch)
+		packagesIndex, err := os.ReadFile(filepath.Join(distDir, filepath.FromSlash(packagesRelPath)))
+		require.NoError(t, err)
+
+		// The Release file has the correct checksum for the index
+		d := digestBytes(packagesIndex)
+		require.Contains(t, string(release), fmt.Sprintf(" %s %16d %s\n", d.sha256, d.size, packagesRelPath))
+
+		// The index contains the package for this architecture, plus the architecture-independent package
+		require.Contains(t, string(packagesIndex), fmt.Sprintf("Filename: pool/main/l/launcher/launcher_1.2.3_%s.deb\n", arch))
+		require.Contains(t, string(packagesIndex), "Filename: pool/main/l/launcher-docs/launcher-docs_1.2.3_all.deb\n")
+		require.Equal(t, 2, strings.Count(string(packagesIndex), "Package: "))
+
+		debBytes, err := os.ReadFile(filepath.Join(packageDir, fmt.Sprintf("launcher_1.2.3_%s.deb", arch)))
+		require.NoError(t, err)
+		require.Contains(t, string(packagesIndex), fmt.Sprintf("SHA256: %s\n", digestBytes(debBytes).sha256))
+	}
+}
+
+func TestBuildAptRepo_KeepsExistingPackages(t *testing.T) {
+	t.Parallel()
+
+	signer := testSigningKey(t)
+	repoDir := t.TempDir()
+
+	for _, version := range []string{"1.2.3", "1.2.4"} {
+		debPath := filepath.Join(t.TempDir(), fmt.Sprintf("launcher_%s_amd64.deb", version))
+		buildTestDeb(t, debPath, "launcher", version, "amd64")
+		require.NoError(t, BuildAptRepo(context.TODO(), repoDir, []string{debPath}, signer, AptOptions{}))
+	}
+
+	// Both versions are indexed, even though the second run was only given the newer package
+	packagesIndex, err := os.ReadFile(filepath.Join(repoDir, "dists", "stable", "main", "binary-amd64", "Packages"))
+	require.NoError(t, err)
+	require.Contains(t, string(packagesIndex), "Filename: pool/main/l/launcher/launcher_1.2.3_amd64.deb\n")
+	require.Contains(t, string(packagesIndex), "Filename: pool/main/l/launcher/launcher_1.2.4_amd64.deb\n")
+}
+
+func TestBuildAptRepo_SignsWithSigningSubkey(t *testing.T) {
+	t.Parallel()
+
+	entity, err := openpgp.NewEntity("Test Repository", "", "repo@example.com", nil)
+	require.NoError(t, err)
+	subkeyId := addTestSigningSubkey(t, entity)
+	signer, err := newSigningKey(entity)
+	require.NoError(t, err)
+
+	debPath := filepath.Join(t.TempDir(), "launcher_1.2.3_amd64.deb")
+	buildTestDeb(t, debPath, "launcher", "1.2.3", "amd64")
+
+	repoDir := t.TempDir()
+	require.NoError(t, BuildAptRepo(context.TODO(), repoDir, []string{debPath}, signer, AptOptions{}))
+
+	distDir := filepath.Join(repoDir, "dists", "stable")
+	release, err := os.ReadFile(filepath.Join(distDir, "Release"))
+	require.NoError(t, err)
+
+	// Release.gpg and InRelease are both signed by the subkey, and verify
+	releaseSignature, err := os.ReadFile(filepath.Join(distDir, "Release.gpg"))
+	require.NoError(t, err)
+	_, err = openpgp.CheckArmoredDetachedSignature(openpgp.EntityList{entity}, bytes.NewReader(release), bytes.NewReader(releaseSignature))
+	require.NoError(t, err)
+	armoredSignature, err := armor.Decode(bytes.NewReader(releaseSignature))
+	require.NoError(t, err)
+	require.Equal(t, subkeyId, signatureIssuer(t, armoredSignature.Body))
+
+	inRelease, err := os.ReadFile(filepath.Join(distDir, "InRelease"))
+	require.NoError(t, err)
+	block, _ := clearsign.Decode(inRelease)
+	require.NotNil(t, block)
+	_, err = openpgp.CheckDetachedSignature(openpgp.EntityList{entity}, bytes.NewReader(block.Bytes), block.ArmoredSignature.Body)
+	require.NoError(t, err)
+	block, _ = clearsign.Decode(inRelease)
+	require.Equal(t, subkeyId, signatureIssuer(t, block.ArmoredSignature.Body))
+}
+
+func Test_readDebControl_compression(t *testing.T) {
+	t.Parallel()
+
+	control := map[string]string{"./control": testDebControl("launcher", "1.2.3", "amd64")}
+
+	var xzArchive bytes.Buffer
+	xzw, err := xz.NewWriter(&xzArchive)
+	require.NoError(t, err)
+	writeTestTar(t, xzw, control)
+	require.NoError(t, xzw.Close())
+
+	var zstArchive bytes.Buffer
+	zw, err := zstd.NewWriter(&zstArchive)
+	require.NoError(t, err)
+	writeTestTar(t, zw, control)
+	require.NoError(t, zw.Close())
+
+	var tarArchive bytes.Buffer
+	writeTestTar(t, &tarArchive, control)
+
+	for archiveName, archive := range map[string][]byte{
+		"control.tar":     tarArchive.Bytes(),
+		"control.tar.gz":  testTarGz(t, control),
+		"control.tar.xz":  xzArchive.Bytes(),
+		"control.tar.zst": zstArchive.Bytes(),
+	} {
+		archiveName, archive := archiveName, archive
+		t.Run(archiveName, func(t *testing.T) {
+			t.Parallel()
+
+			debPath := filepath.Join(t.TempDir(), "launcher_1.2.3_amd64.deb")
+			buildTestDebWithControlArchive(t, debPath, archiveName, archive)
+
+			parsed, err := readDebControl(debPath)
+			require.NoError(t, err)
+			require.Equal(t, "launcher", parsed.field("Package"))
+			require.Equal(t, "1.2.3", parsed.field("Version"))
+		})
+	}
+}
+
+func TestBuildAptRepo_RequiresSigner(t *testing.T) {
+	t.Parallel()
+
+	debPath := filepath.Join(t.TempDir(), "launcher_1.2.3_amd64.deb")
+	buildTestDeb(t, debPath, "launcher", "1.2.3", "amd64")
+
+	require.Error(t, BuildAptRepo(context.TODO(), t.TempDir(), []string{debPath}, nil, AptOptions{}))
+}
+
+func Test_parseDebControl(t *testing.T) {
+	t.Parallel()
+
+	for _, tt := range []struct {
+		name        string
+		control     string
+		expectedErr bool
+		description string
+	}{
+		{
+			name:        "valid",
+			control:     "Package: launcher\nVersion: 1.2.3\nArchitecture: amd64\nDescription: Launcher\n manages osquery\n",
+			description: "Launcher\n manages osquery",
+		},
+		{
+			name:        "missing version",
+			control:     "Package: launcher\nArchitecture: amd64\n",
+			expectedErr: true,
+		},
+		{
+			name:        "multiple paragraphs",
+			control:     "Package: launcher\nVersion: 1.2.3\nArchitecture: amd64\n\nPackage: other\n",
+			expectedErr: true,
+		},
+		{
+			name:        "leading continuation",
+			control:     " manages osquery\nPackage: launcher\n",
+			expectedErr: true,
+		},
+	} {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			control, err := parseDebControl([]byte(tt.control))
+			if tt.expectedErr {
+				require.Error(t, err)
+				return
+			}
+
+			require.NoError(t, err)
+			require.Equal(t, tt.description, control.field("Description"))
+		})
+	}
+}
+
+func TestLoadSigningKey(t *testing.T) {
+	t.Parallel()
+
+	signer := testSigningKey(t)
+
+	var armored bytes.Buffer
+	w, err := armor.Encode(&armored, openpgp.PrivateKeyType, nil)
+	require.NoError(t, err)
+	require.NoError(t, signer.entity.SerializePrivate(w, nil))
+	require.NoError(t, w.Close())
+
+	keyPath := filepath.Join(t.TempDir(), "signing.asc")
+	require.NoError(t, os.WriteFile(keyPath, armored.Bytes(), 0600))
+
+	loaded, err := LoadSigningKey(keyPath, "")
+	require.NoError(t, err)
+	require.Equal(t, signer.entity.PrimaryKey.Fingerprint, loaded.entity.PrimaryKey.Fingerprint)
+	require.Equal(t, signer.entity.PrimaryKey.KeyId, loaded.privateKey.KeyId)
+}
+
+func testSigningKey(t *testing.T) *SigningKey {
+	entity, err := openpgp.NewEntity("Test Repository", "", "repo@example.com", nil)
+	require.NoError(t, err)
+	signer, err := newSigningKey(entity)
+	require.NoError(t, err)
+	return signer
+}
+
+// addTestSigningSubkey adds a signing subkey to the given entity, returning its key ID.
+func addTestSigningSubkey(t *testing.T, entity *openpgp.Entity) uint64 {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	now := time.Now()
+	subkey := openpgp.Subkey{
+		PublicKey:  packet.NewRSAPublicKey(now, &rsaKey.PublicKey),
+		PrivateKey: packet.NewRSAPrivateKey(now, rsaKey),
+		Sig: &packet.Signature{
+			CreationTime: now,
+			SigType:      packet.SigTypeSubkeyBinding,
+			PubKeyAlgo:   packet.PubKeyAlgoRSA,
+			Hash:         crypto.SHA256,
+			FlagsValid:   true,
+			FlagSign:     true,
+			IssuerKeyId:  &entity.PrimaryKey.KeyId,
+		},
+	}
+	subkey.PublicKey.IsSubkey = true
+	subkey.PrivateKey.IsSubkey = true
+	require.NoError(t, subkey.Sig.SignKey(subkey.PublicKey, entity.PrivateKey, nil))
+
+	entity.Subkeys = append(entity.Subkeys, subkey)
+	return subkey.PublicKey.KeyId
+}
+
+// signatureIssuer returns the ID of the key that made the given signature.
+func signatureIssuer(t *testing.T, signature io.Reader) uint64 {
+	p, err := packet.Read(signature)
+	require.NoError(t, err)
+	sig, ok := p.(*packet.Signature)
+	require.True(t, ok, "expected a signature packet")
+	require.NotNil(t, sig.IssuerKeyId)
+	return *sig.IssuerKeyId
+}
+
+// buildTestDeb writes a deb package with the given control fields and an empty data archive.
+func buildTestDeb(t *testing.T, debPath, name, version, arch string) {
+	buildTestDebWithControlArchive(t, debPath, "control.tar.gz", testTarGz(t, map[string]string{"./control": testDebControl(name, version, arch)}))
+}
+
+func buildTestDebWithControlArchive(t *testing.T, debPath string, controlArchiveName string, controlArchive []byte) {
+	var deb bytes.Buffer
+	deb.WriteString(arMagic)
+	writeArMember(&deb, "debian-binary", []byte("2.0\n"))
+	writeArMember(&deb, controlArchiveName, controlArchive)
+	writeArMember(&deb, "data.tar.gz", testTarGz(t, map[string]string{}))
+
+	require.NoError(t, os.WriteFile(debPath, deb.Bytes(), 0644))
+}
+
+func testDebControl(name, version, arch string) string {
+	return fmt.Sprintf("Package: %s\nVersion: %s\nArchitecture: %s\nMaintainer: Kolide <engineering@kolide.co>\nDescription: Test package\n test package for repository generation\n", name, version, arch)
+}
+
+func writeArMember(ar *bytes.Buffer, name string, contents []byte) {
+	fmt.Fprintf(ar, "%-16s%-12d%-6d%-6d%-8s%-10d`\n", name, 0, 0, 0, "100644", len(contents))
+	ar.Write(contents)
+	if len(contents)%2 != 0 {
+		ar.WriteByte('\n')
+	}
+}
+
+func testTarGz(t *testing.T, files map[string]string) []byte {
+	var buf bytes.Buffer
+	gzw := gzip.NewWriter(&buf)
+	writeTestTar(t, gzw, files)
+	require.NoError(t, gzw.Close())
+	return buf.Bytes()
+}
+
+func writeTestTar(t *testing.T, w io.Writer, files map[string]string) {
+	tw := tar.NewWriter(w)
+	for name, contents := range files {
+		require.NoError(t, tw.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(contents))}))
+		_, err := tw.Write([]byte(contents))
+		require.NoError(t, err)
+	}
+	require.NoError(t, tw.Close())
+}
@@ -0,0 +1,170 @@
+package linuxrepo
+
+import (
+	"archive/tar"
+	"bufio"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/ulikunitz/xz"
+)
+
+const (
+	arMagic        = "!<arch>\n"
+	arHeaderLength = 60
+)
+
+// debControl is the control file from a deb package. We keep the raw paragraph so that it
+// can be reproduced verbatim in the repository's Packages index.
+type debControl struct {
+	raw    string
+	fields map[string]string
+}
+
+func (c debControl) field(name string) string {
+	return c.fields[strings.ToLower(name)]
+}
+
+// readDebControl extracts and parses the control file from the deb package at `debPath`.
+func readDebControl(debPath string) (debControl, error) {
+	f, err := os.Open(debPath)
+	if err != nil {
+		return debControl{}, fmt.Errorf("opening %s: %w", debPath, err)
+	}
+	defer f.Close()
+
+	r := bufio.NewReader(f)
+	magic := make([]byte, len(arMagic))
+	if _, err := io.ReadFull(r, magic); err != nil || string(magic) != arMagic {
+		return debControl{}, fmt.Errorf("%s is not a deb package: missing ar header", debPath)
+	}
+
+	// Walk the ar archive looking for the control tarball
+	for {
+		header := make([]byte, arHeaderLength)
+		if _, err := io.ReadFull(r, header); err != nil {
+			if errors.Is(err, io.EOF) {
+				return debControl{}, fmt.Errorf("%s has no control archive", debPath)
+			}
+			return debControl{}, fmt.Errorf("reading ar header in %s: %w", debPath, err)
+		}
+
+		name := strings.TrimSuffix(strings.TrimSpace(string(header[0:16])), "/")
+		size, err := strconv.ParseInt(strings.TrimSpace(string(header[48:58])), 10, 64)
+		if err != nil {
+			return debControl{}, fmt.Errorf("parsing size of ar member %s in %s: %w", name, debPath, err)
+		}
+
+		if strings.HasPrefix(name, "control.tar") {
+			member := io.LimitReader(r, size)
+			control, err := controlFromTarball(name, member)
+			if err != nil {
+				return debControl{}, fmt.Errorf("reading %s in %s: %w", name, debPath, err)
+			}
+			return control, nil
+		}
+
+		// Members are padded to an even length
+		if _, err := r.Discard(int(size + size%2)); err != nil {
+			return debControl{}, fmt.Errorf("skipping ar member %s in %s: %w", name, debPath, err)
+		}
+	}
+}
+
+func controlFromTarball(name string, member io.Reader) (debControl, error) {
+	var tarStream io.Reader
+	switch name {
+	case "control.tar":
+		tarStream = member
+	case "control.tar.gz":
+		gzr, err := gzip.NewReader(member)
+		if err != nil {
+			return debControl{}, fmt.Errorf("creating gzip reader: %w", err)
+		}
+		defer gzr.Close()
+		tarStream = gzr
+	case "control.tar.xz":
+		xzr, err := xz.NewReader(member)
+		if err != nil {
+			return debControl{}, fmt.Errorf("creating xz reader: %w", err)
+		}
+		tarStream = xzr
+	case "control.tar.zst":
+		zr, err := zstd.NewReader(member)
+		if err != nil {
+			return debControl{}, fmt.Errorf("creating zstd reader: %w", err)
+		}
+		defer zr.Close()
+		tarStream = zr
+	default:
+		return debControl{}, fmt.Errorf("unsupported control archive compression: %s", name)
+	}
+
+	tr := tar.NewReader(tarStream)
+	for {
+		header, err := tr.Next()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return debControl{}, errors.New("no control file in control archive")
+			}
+			return debControl{}, fmt.Errorf("reading control archive: %w", err)
+		}
+
+		if path.Clean(header.Name) != "control" {
+			continue
+		}
+
+		rawControl, err := io.ReadAll(tr)
+		if err != nil {
+			return debControl{}, fmt.Errorf("reading control file: %w", err)
+		}
+
+		return parseDebControl(rawControl)
+	}
+}
+
+// parseDebControl parses a single control paragraph. Continuation lines (those starting with
+// whitespace) are appended to the preceding field.
+func parseDebControl(rawControl []byte) (debControl, error) {
+	control := debControl{
+		raw:    strings.TrimSpace(string(rawControl)),
+		fields: make(map[string]string),
+	}
+
+	lastField := ""
+	for _, line := range strings.Split(control.raw, "\n") {
+		if line == "" {
+			return debControl{}, errors.New("control file contains more than one paragraph")
+		}
+
+		if line[0] == ' ' || line[0] == '\t' {
+			if lastField == "" {
+				return debControl{}, fmt.Errorf("unexpected continuation line: %s", line)
+			}
+			control.fields[lastField] += "\n" + line
+			continue
+		}
+
+		name, value, found := strings.Cut(line, ":")
+		if !found {
+			return debControl{}, fmt.Errorf("malformed control line: %s", line)
+		}
+		lastField = strings.ToLower(strings.TrimSpace(name))
+		control.fields[lastField] = strings.TrimSpace(value)
+	}
+
+	for _, required := range []string{"package", "version", "architecture"} {
+		if control.fields[required] == "" {
+			return debControl{}, fmt.Errorf("control file missing required field %s", required)
+		}
+	}
+
+	return control, nil
+}
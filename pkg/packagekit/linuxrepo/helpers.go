@@ -0,0 +1,130 @@
+package linuxrepo
+
+import (
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// digests holds the checksums that repository metadata records for each file.
+type digests struct {
+	size   int64
+	md5    string
+	sha1   string
+	sha256 string
+}
+
+func digestBytes(b []byte) digests {
+	md5Sum := md5.Sum(b)
+	sha1Sum := sha1.Sum(b)
+	sha256Sum := sha256.Sum256(b)
+
+	return digests{
+		size:   int64(len(b)),
+		md5:    hex.EncodeToString(md5Sum[:]),
+		sha1:   hex.EncodeToString(sha1Sum[:]),
+		sha256: hex.EncodeToString(sha256Sum[:]),
+	}
+}
+
+// digestFile returns the digests of the file at `filePath`.
+func digestFile(filePath string) (digests, error) {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return digests{}, fmt.Errorf("opening %s: %w", filePath, err)
+	}
+	defer f.Close()
+
+	md5Hash := md5.New()
+	sha1Hash := sha1.New()
+	sha256Hash := sha256.New()
+
+	size, err := io.Copy(io.MultiWriter(md5Hash, sha1Hash, sha256Hash), f)
+	if err != nil {
+		return digests{}, fmt.Errorf("reading %s: %w", filePath, err)
+	}
+
+	return digests{
+		size:   size,
+		md5:    hex.EncodeToString(md5Hash.Sum(nil)),
+		sha1:   hex.EncodeToString(sha1Hash.Sum(nil)),
+		sha256: hex.EncodeToString(sha256Hash.Sum(nil)),
+	}, nil
+}
+
+// copyAndDigest copies `src` to `dest`, returning the digests of the copied contents. If `src`
+// is already at `dest`, it is left as is.
+func copyAndDigest(src string, dest string) (digests, error) {
+	in, err := os.Open(src)
+	if err != nil {
+		return digests{}, fmt.Errorf("opening %s: %w", src, err)
+	}
+	defer in.Close()
+
+	srcInfo, err := in.Stat()
+	if err != nil {
+		return digests{}, fmt.Errorf("checking %s: %w", src, err)
+	}
+	if destInfo, err := os.Stat(dest); err == nil && os.SameFile(srcInfo, destInfo) {
+		return digestFile(src)
+	}
+
+	out, err := os.Create(dest)
+	if err != nil {
+		return digests{}, fmt.Errorf("creating %s: %w", dest, err)
+	}
+
+	md5Hash := md5.New()
+	sha1Hash := sha1.New()
+	sha256Hash := sha256.New()
+
+	size, err := io.Copy(io.MultiWriter(out, md5Hash, sha1Hash, sha256Hash), in)
+	if err != nil {
+		out.Close()
+		return digests{}, fmt.Errorf("copying %s to %s: %w", src, dest, err)
+	}
+
+	if err := out.Close(); err != nil {
+		return digests{}, fmt.Errorf("closing %s: %w", dest, err)
+	}
+
+	return digests{
+		size:   size,
+		md5:    hex.EncodeToString(md5Hash.Sum(nil)),
+		sha1:   hex.EncodeToString(sha1Hash.Sum(nil)),
+		sha256: hex.EncodeToString(sha256Hash.Sum(nil)),
+	}, nil
+}
+
+// findPackages returns the paths of all files under `dir` with the given extension, in
+// lexical order. A missing `dir` has no packages.
+func findPackages(dir string, extension string) ([]string, error) {
+	packagePaths := make([]string, 0)
+	err := filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if p == dir && errors.Is(err, fs.ErrNotExist) {
+				return filepath.SkipDir
+			}
+			return err
+		}
+		if !d.IsDir() && strings.HasSuffix(d.Name(), extension) {
+			packagePaths = append(packagePaths, p)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("looking for packages in %s: %w", dir, err)
+	}
+
+	sort.Strings(packagePaths)
+	return packagePaths, nil
+}
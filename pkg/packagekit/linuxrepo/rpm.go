@@ -0,0 +1,360 @@
+package linuxrepo
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+const (
+	rpmLeadLength  = 96
+	rpmIndexLength = 16
+)
+
+var (
+	rpmLeadMagic   = []byte{0xed, 0xab, 0xee, 0xdb}
+	rpmHeaderMagic = []byte{0x8e, 0xad, 0xe8, 0x01}
+)
+
+// RPM header data types
+const (
+	rpmTypeInt16       = 3
+	rpmTypeInt32       = 4
+	rpmTypeInt64       = 5
+	rpmTypeString      = 6
+	rpmTypeBin         = 7
+	rpmTypeStringArray = 8
+	rpmTypeI18nString  = 9
+)
+
+// RPM header tags that we read
+const (
+	rpmTagName           = 1000
+	rpmTagVersion        = 1001
+	rpmTagRelease        = 1002
+	rpmTagEpoch          = 1003
+	rpmTagSummary        = 1004
+	rpmTagDescription    = 1005
+	rpmTagBuildTime      = 1006
+	rpmTagBuildHost      = 1007
+	rpmTagSize           = 1009
+	rpmTagVendor         = 1011
+	rpmTagLicense        = 1014
+	rpmTagPackager       = 1015
+	rpmTagGroup          = 1016
+	rpmTagUrl            = 1020
+	rpmTagArch           = 1022
+	rpmTagOldFilenames   = 1027
+	rpmTagSourceRpm      = 1044
+	rpmTagProvideName    = 1047
+	rpmTagRequireFlags   = 1048
+	rpmTagRequireName    = 1049
+	rpmTagRequireVersion = 1050
+	rpmTagProvideFlags   = 1112
+	rpmTagProvideVersion = 1113
+	rpmTagDirIndexes     = 1116
+	rpmTagBasenames      = 1117
+	rpmTagDirNames       = 1118
+	rpmTagLongSize       = 5009
+
+	// The signature header uses its own tag numbers
+	rpmSigTagPayloadSize = 1007
+)
+
+type rpmIndexEntry struct {
+	tag    int32
+	typ    int32
+	offset int32
+	count  int32
+}
+
+// rpmHeader is a parsed RPM header structure -- either the signature header or the main header.
+type rpmHeader struct {
+	entries map[int32]rpmIndexEntry
+	store   []byte
+	length  int64 // length of the header structure on disk, including the intro and index
+}
+
+// rpmPackage is the metadata from an RPM package needed to index it in a yum repository.
+type rpmPackage struct {
+	signature   *rpmHeader
+	header      *rpmHeader
+	headerStart int64 // byte offset of the main header within the package file
+	headerEnd   int64
+}
+
+// readRpmPackage reads the lead, signature header, and main header from the RPM package at `rpmPath`.
+func readRpmPackage(rpmPath string) (*rpmPackage, error) {
+	f, err := os.Open(rpmPath)
+	if err != nil {
+		return nil, fmt.Errorf("opening %s: %w", rpmPath, err)
+	}
+	defer f.Close()
+
+	r := bufio.NewReader(f)
+
+	lead := make([]byte, rpmLeadLength)
+	if _, err := io.ReadFull(r, lead); err != nil {
+		return nil, fmt.Errorf("reading lead from %s: %w", rpmPath, err)
+	}
+	if !bytes.Equal(lead[0:4], rpmLeadMagic) {
+		return nil, fmt.Errorf("%s is not an rpm package: bad lead magic", rpmPath)
+	}
+
+	signature, err := readRpmHeader(r)
+	if err != nil {
+		return nil, fmt.Errorf("reading signature header from %s: %w", rpmPath, err)
+	}
+
+	// The signature header is padded to an 8-byte boundary
+	padding := (8 - signature.length%8) % 8
+	if _, err := r.Discard(int(padding)); err != nil {
+		return nil, fmt.Errorf("reading signature padding from %s: %w", rpmPath, err)
+	}
+
+	headerStart := rpmLeadLength + signature.length + padding
+	header, err := readRpmHeader(r)
+	if err != nil {
+		return nil, fmt.Errorf("reading header from %s: %w", rpmPath, err)
+	}
+
+	return &rpmPackage{
+		signature:   signature,
+		header:      header,
+		headerStart: headerStart,
+		headerEnd:   headerStart + header.length,
+	}, nil
+}
+
+func readRpmHeader(r io.Reader) (*rpmHeader, error) {
+	intro := make([]byte, 16)
+	if _, err := io.ReadFull(r, intro); err != nil {
+		return nil, fmt.Errorf("reading header intro: %w", err)
+	}
+	if !bytes.Equal(intro[0:4], rpmHeaderMagic) {
+		return nil, errors.New("bad header magic")
+	}
+
+	indexCount := int64(binary.BigEndian.Uint32(intro[8:12]))
+	storeSize := int64(binary.BigEndian.Uint32(intro[12:16]))
+
+	// Sanity check the sizes before allocating -- rpm itself caps headers at 256MB
+	const maxHeaderSize = 256 * 1024 * 1024
+	if indexCount*rpmIndexLength+storeSize > maxHeaderSize {
+		return nil, fmt.Errorf("header too large: %d entries, %d bytes of data", indexCount, storeSize)
+	}
+
+	index := make([]byte, indexCount*rpmIndexLength)
+	if _, err := io.ReadFull(r, index); err != nil {
+		return nil, fmt.Errorf("reading header index: %w", err)
+	}
+
+	h := &rpmHeader{
+		entries: make(map[int32]rpmIndexEntry, indexCount),
+		store:   make([]byte, storeSize),
+		length:  16 + indexCount*rpmIndexLength + storeSize,
+	}
+	if _, err := io.ReadFull(r, h.store); err != nil {
+		return nil, fmt.Errorf("reading header data: %w", err)
+	}
+
+	for i := int64(0); i < indexCount; i += 1 {
+		entry := index[i*rpmIndexLength : (i+1)*rpmIndexLength]
+		e := rpmIndexEntry{
+			tag:    int32(binary.BigEndian.Uint32(entry[0:4])),
+			typ:    int32(binary.BigEndian.Uint32(entry[4:8])),
+			offset: int32(binary.BigEndian.Uint32(entry[8:12])),
+			count:  int32(binary.BigEndian.Uint32(entry[12:16])),
+		}
+		if e.offset < 0 || int64(e.offset) > storeSize {
+			return nil, fmt.Errorf("tag %d has out of bounds offset %d", e.tag, e.offset)
+		}
+		h.entries[e.tag] = e
+	}
+
+	return h, nil
+}
+
+// strings returns the values of a string, string array, or i18n string tag. For i18n strings,
+// only the first (default locale) value is meaningful.
+func (h *rpmHeader) strings(tag int32) []string {
+	e, ok := h.entries[tag]
+	if !ok {
+		return nil
+	}
+
+	switch e.typ {
+	case rpmTypeString, rpmTypeStringArray, rpmTypeI18nString:
+	default:
+		return nil
+	}
+
+	count := int(e.count)
+	if e.typ == rpmTypeString {
+		count = 1
+	}
+
+	values := make([]string, 0, count)
+	data := h.store[e.offset:]
+	for i := 0; i < count; i += 1 {
+		end := bytes.IndexByte(data, 0)
+		if end < 0 {
+			break
+		}
+		values = append(values, string(data[:end]))
+		data = data[end+1:]
+	}
+
+	return values
+}
+
+func (h *rpmHeader) string(tag int32) string {
+	values := h.strings(tag)
+	if len(values) == 0 {
+		return ""
+	}
+	return values[0]
+}
+
+// ints returns the values of an integer tag.
+func (h *rpmHeader) ints(tag int32) []int64 {
+	e, ok := h.entries[tag]
+	if !ok {
+		return nil
+	}
+
+	var width int
+	switch e.typ {
+	case rpmTypeInt16:
+		width = 2
+	case rpmTypeInt32:
+		width = 4
+	case rpmTypeInt64:
+		width = 8
+	default:
+		return nil
+	}
+
+	if int64(e.offset)+int64(e.count)*int64(width) > int64(len(h.store)) {
+		return nil
+	}
+
+	values := make([]int64, e.count)
+	for i := range values {
+		b := h.store[int(e.offset)+i*width:]
+		switch width {
+		case 2:
+			values[i] = int64(binary.BigEndian.Uint16(b))
+		case 4:
+			values[i] = int64(binary.BigEndian.Uint32(b))
+		case 8:
+			values[i] = int64(binary.BigEndian.Uint64(b))
+		}
+	}
+
+	return values
+}
+
+func (h *rpmHeader) int(tag int32) int64 {
+	values := h.ints(tag)
+	if len(values) == 0 {
+		return 0
+	}
+	return values[0]
+}
+
+// files returns all file paths in the package.
+func (p *rpmPackage) files() []string {
+	// Older packages list full paths; newer ones split them into directories and basenames
+	if oldFilenames := p.header.strings(rpmTagOldFilenames); len(oldFilenames) > 0 {
+		return oldFilenames
+	}
+
+	basenames := p.header.strings(rpmTagBasenames)
+	dirnames := p.header.strings(rpmTagDirNames)
+	dirIndexes := p.header.ints(rpmTagDirIndexes)
+
+	files := make([]string, 0, len(basenames))
+	for i, basename := range basenames {
+		if i >= len(dirIndexes) || int(dirIndexes[i]) >= len(dirnames) {
+			break
+		}
+		files = append(files, dirnames[dirIndexes[i]]+basename)
+	}
+
+	return files
+}
+
+// rpmDependency is a single provides or requires entry.
+type rpmDependency struct {
+	name    string
+	flags   int64
+	epoch   string
+	version string
+	release string
+}
+
+// dependencies returns the provides or requires entries for the package.
+func (p *rpmPackage) dependencies(nameTag, flagsTag, versionTag int32) []rpmDependency {
+	names := p.header.strings(nameTag)
+	flags := p.header.ints(flagsTag)
+	versions := p.header.strings(versionTag)
+
+	deps := make([]rpmDependency, 0, len(names))
+	for i, name := range names {
+		dep := rpmDependency{name: name}
+		if i < len(flags) {
+			dep.flags = flags[i]
+		}
+		if i < len(versions) {
+			dep.epoch, dep.version, dep.release = splitEVR(versions[i])
+		}
+		deps = append(deps, dep)
+	}
+
+	return deps
+}
+
+// comparison returns the yum metadata representation of the dependency's version comparison
+// flags, or an empty string if the dependency is unversioned.
+func (d rpmDependency) comparison() string {
+	const (
+		rpmSenseLess    = 0x02
+		rpmSenseGreater = 0x04
+		rpmSenseEqual   = 0x08
+	)
+
+	switch d.flags & (rpmSenseLess | rpmSenseGreater | rpmSenseEqual) {
+	case rpmSenseLess:
+		return "LT"
+	case rpmSenseGreater:
+		return "GT"
+	case rpmSenseEqual:
+		return "EQ"
+	case rpmSenseLess | rpmSenseEqual:
+		return "LE"
+	case rpmSenseGreater | rpmSenseEqual:
+		return "GE"
+	default:
+		return ""
+	}
+}
+
+// splitEVR splits a version string of the form [epoch:]version[-release].
+func splitEVR(evr string) (epoch, version, release string) {
+	if e, rest, found := strings.Cut(evr, ":"); found {
+		epoch = e
+		evr = rest
+	}
+
+	if i := strings.LastIndex(evr, "-"); i >= 0 {
+		return epoch, evr[:i], evr[i+1:]
+	}
+
+	return epoch, evr, ""
+}
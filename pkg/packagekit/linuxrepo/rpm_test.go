@@ -0,0 +1,177 @@
+package linuxrepo
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadRpmPackage(t *testing.T) {
+	t.Parallel()
+
+	rpmPath := filepath.Join(t.TempDir(), "launcher-1.2.3-1.x86_64.rpm")
+	buildTestRpm(t, rpmPath, testRpmTags("launcher", "1.2.3", "x86_64"))
+
+	rpm, err := readRpmPackage(rpmPath)
+	require.NoError(t, err)
+
+	require.Equal(t, "launcher", rpm.header.string(rpmTagName))
+	require.Equal(t, "1.2.3", rpm.header.string(rpmTagVersion))
+	require.Equal(t, "1", rpm.header.string(rpmTagRelease))
+	require.Equal(t, "x86_64", rpm.header.string(rpmTagArch))
+	require.Equal(t, "The osquery launcher", rpm.header.string(rpmTagSummary))
+	require.Equal(t, int64(1700000000), rpm.header.int(rpmTagBuildTime))
+	require.Equal(t, int64(4096), rpm.signature.int(rpmSigTagPayloadSize))
+
+	require.Equal(t, []string{"/etc/launcher/launcher.flags", "/usr/local/bin/launcher", "/usr/local/share/launcher/README"}, rpm.files())
+
+	requires := rpm.dependencies(rpmTagRequireName, rpmTagRequireFlags, rpmTagRequireVersion)
+	require.Len(t, requires, 3)
+	require.Equal(t, "glibc", requires[0].name)
+	require.Equal(t, "GE", requires[0].comparison())
+	require.Equal(t, "2.17", requires[0].version)
+	require.Equal(t, "/bin/sh", requires[1].name)
+	require.Equal(t, "", requires[1].comparison())
+
+	// The main header starts after the 96-byte lead and the 8-byte-aligned signature header
+	require.Equal(t, int64(0), rpm.headerStart%8)
+	require.Greater(t, rpm.headerEnd, rpm.headerStart)
+}
+
+func TestReadRpmPackage_NotAnRpm(t *testing.T) {
+	t.Parallel()
+
+	notAnRpm := filepath.Join(t.TempDir(), "not-an.rpm")
+	require.NoError(t, os.WriteFile(notAnRpm, bytes.Repeat([]byte("a"), 200), 0644))
+
+	_, err := readRpmPackage(notAnRpm)
+	require.Error(t, err)
+}
+
+func Test_splitEVR(t *testing.T) {
+	t.Parallel()
+
+	for _, tt := range []struct {
+		in      string
+		epoch   string
+		version string
+		release string
+	}{
+		{in: "1.2.3", version: "1.2.3"},
+		{in: "1.2.3-4.el8", version: "1.2.3", release: "4.el8"},
+		{in: "2:1.2.3-4", epoch: "2", version: "1.2.3", release: "4"},
+	} {
+		tt := tt
+		t.Run(tt.in, func(t *testing.T) {
+			t.Parallel()
+
+			epoch, version, release := splitEVR(tt.in)
+			require.Equal(t, tt.epoch, epoch)
+			require.Equal(t, tt.version, version)
+			require.Equal(t, tt.release, release)
+		})
+	}
+}
+
+// testRpmTags returns a minimal set of header tags for a test package.
+func testRpmTags(name, version, arch string) map[int32]interface{} {
+	return map[int32]interface{}{
+		rpmTagName:           name,
+		rpmTagVersion:        version,
+		rpmTagRelease:        "1",
+		rpmTagSummary:        "The osquery launcher",
+		rpmTagDescription:    "Launcher manages osquery",
+		rpmTagBuildTime:      int32(1700000000),
+		rpmTagBuildHost:      "builder.example.com",
+		rpmTagSize:           int32(12345),
+		rpmTagLicense:        "MIT",
+		rpmTagArch:           arch,
+		rpmTagProvideName:    []string{name, name + "(x86-64)"},
+		rpmTagProvideFlags:   []int32{8, 8},
+		rpmTagProvideVersion: []string{version + "-1", version + "-1"},
+		rpmTagRequireName:    []string{"glibc", "/bin/sh", "rpmlib(CompressedFileNames)"},
+		rpmTagRequireFlags:   []int32{12, 0, 16777226},
+		rpmTagRequireVersion: []string{"2.17", "", "3.0.4-1"},
+		rpmTagDirIndexes:     []int32{0, 1, 2},
+		rpmTagBasenames:      []string{"launcher.flags", "launcher", "README"},
+		rpmTagDirNames:       []string{"/etc/launcher/", "/usr/local/bin/", "/usr/local/share/launcher/"},
+	}
+}
+
+// buildTestRpm writes an rpm containing only a lead, headers, and a placeholder payload. It
+// is not installable, but it is sufficient for reading metadata.
+func buildTestRpm(t *testing.T, rpmPath string, tags map[int32]interface{}) {
+	var rpm bytes.Buffer
+
+	lead := make([]byte, rpmLeadLength)
+	copy(lead, rpmLeadMagic)
+	lead[4] = 3 // major version
+	rpm.Write(lead)
+
+	signature := encodeTestRpmHeader(map[int32]interface{}{
+		rpmSigTagPayloadSize: int32(4096),
+	})
+	rpm.Write(signature)
+	rpm.Write(make([]byte, (8-len(signature)%8)%8))
+
+	rpm.Write(encodeTestRpmHeader(tags))
+	rpm.Write(bytes.Repeat([]byte{0}, 64))
+
+	require.NoError(t, os.WriteFile(rpmPath, rpm.Bytes(), 0644))
+}
+
+func encodeTestRpmHeader(tags map[int32]interface{}) []byte {
+	sortedTags := make([]int32, 0, len(tags))
+	for tag := range tags {
+		sortedTags = append(sortedTags, tag)
+	}
+	sort.Slice(sortedTags, func(i, j int) bool { return sortedTags[i] < sortedTags[j] })
+
+	var index, store bytes.Buffer
+	for _, tag := range sortedTags {
+		var typ, count int32
+		switch v := tags[tag].(type) {
+		case string:
+			typ, count = rpmTypeString, 1
+			writeIndexEntry(&index, tag, typ, int32(store.Len()), count)
+			store.WriteString(v)
+			store.WriteByte(0)
+		case []string:
+			typ, count = rpmTypeStringArray, int32(len(v))
+			writeIndexEntry(&index, tag, typ, int32(store.Len()), count)
+			for _, s := range v {
+				store.WriteString(s)
+				store.WriteByte(0)
+			}
+		case int32:
+			store.Write(make([]byte, (4-store.Len()%4)%4))
+			writeIndexEntry(&index, tag, rpmTypeInt32, int32(store.Len()), 1)
+			_ = binary.Write(&store, binary.BigEndian, v)
+		case []int32:
+			store.Write(make([]byte, (4-store.Len()%4)%4))
+			writeIndexEntry(&index, tag, rpmTypeInt32, int32(store.Len()), int32(len(v)))
+			_ = binary.Write(&store, binary.BigEndian, v)
+		default:
+			panic("unsupported tag type in test")
+		}
+	}
+
+	var header bytes.Buffer
+	header.Write(rpmHeaderMagic)
+	header.Write(make([]byte, 4)) // reserved
+	_ = binary.Write(&header, binary.BigEndian, uint32(len(sortedTags)))
+	_ = binary.Write(&header, binary.BigEndian, uint32(store.Len()))
+	header.Write(index.Bytes())
+	header.Write(store.Bytes())
+
+	return header.Bytes()
+}
+
+func writeIndexEntry(index *bytes.Buffer, tag, typ, offset, count int32) {
+	_ = binary.Write(index, binary.BigEndian, []int32{tag, typ, offset, count})
+}
@@ -0,0 +1,178 @@
+// Package linuxrepo builds signed apt and yum package repositories from a set of
+// already-built deb and rpm packages.
+package linuxrepo
+
+import (
+	"bytes"
+	"crypto"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"golang.org/x/crypto/openpgp"
+	"golang.org/x/crypto/openpgp/armor"
+	"golang.org/x/crypto/openpgp/clearsign"
+	"golang.org/x/crypto/openpgp/packet"
+)
+
+// SigningKey is the GPG key that signs repository metadata. The private key that makes the
+// signatures is chosen once, when the key is loaded, so that every signature in a repository
+// comes from the same key.
+type SigningKey struct {
+	entity     *openpgp.Entity
+	privateKey *packet.PrivateKey
+}
+
+// newSigningKey picks the private key to sign with from the given entity: its newest valid
+// signing subkey, if it has one, and otherwise its primary key.
+func newSigningKey(entity *openpgp.Entity) (*SigningKey, error) {
+	now := time.Now()
+
+	var signingSubkey *openpgp.Subkey
+	for i, subkey := range entity.Subkeys {
+		if subkey.PrivateKey == nil || !subkey.Sig.FlagsValid || !subkey.Sig.FlagSign || !subkey.PublicKey.PubKeyAlgo.CanSign() {
+			continue
+		}
+		if subkey.Sig.KeyExpired(now) {
+			continue
+		}
+		if signingSubkey == nil || subkey.PublicKey.CreationTime.After(signingSubkey.PublicKey.CreationTime) {
+			signingSubkey = &entity.Subkeys[i]
+		}
+	}
+
+	if signingSubkey != nil {
+		return &SigningKey{entity: entity, privateKey: signingSubkey.PrivateKey}, nil
+	}
+
+	if entity.PrivateKey == nil || !entity.PrivateKey.PubKeyAlgo.CanSign() {
+		return nil, errors.New("key has no private key that can sign")
+	}
+
+	return &SigningKey{entity: entity, privateKey: entity.PrivateKey}, nil
+}
+
+// LoadSigningKey reads a GPG private key from disk. The key may be armored or binary. If the
+// key is encrypted, it will be decrypted with the given passphrase.
+func LoadSigningKey(keyPath string, passphrase string) (*SigningKey, error) {
+	keyBytes, err := os.ReadFile(keyPath)
+	if err != nil {
+		return nil, fmt.Errorf("reading signing key %s: %w", keyPath, err)
+	}
+
+	keyring, err := openpgp.ReadArmoredKeyRing(bytes.NewReader(keyBytes))
+	if err != nil {
+		keyring, err = openpgp.ReadKeyRing(bytes.NewReader(keyBytes))
+		if err != nil {
+			return nil, fmt.Errorf("parsing signing key %s: %w", keyPath, err)
+		}
+	}
+
+	for _, entity := range keyring {
+		if entity.PrivateKey == nil {
+			continue
+		}
+
+		if err := decryptEntity(entity, passphrase); err != nil {
+			return nil, fmt.Errorf("decrypting signing key %s: %w", keyPath, err)
+		}
+
+		signer, err := newSigningKey(entity)
+		if err != nil {
+			return nil, fmt.Errorf("choosing signing key from %s: %w", keyPath, err)
+		}
+
+		return signer, nil
+	}
+
+	return nil, fmt.Errorf("no private key found in %s", keyPath)
+}
+
+func decryptEntity(entity *openpgp.Entity, passphrase string) error {
+	if entity.PrivateKey.Encrypted {
+		if passphrase == "" {
+			return errors.New("key is encrypted, but no passphrase was provided")
+		}
+		if err := entity.PrivateKey.Decrypt([]byte(passphrase)); err != nil {
+			return fmt.Errorf("decrypting private key: %w", err)
+		}
+	}
+
+	for _, subkey := range entity.Subkeys {
+		if subkey.PrivateKey == nil || !subkey.PrivateKey.Encrypted {
+			continue
+		}
+		if err := subkey.PrivateKey.Decrypt([]byte(passphrase)); err != nil {
+			return fmt.Errorf("decrypting private subkey: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// writeDetachedSignature writes an armored, detached signature of `contents` to `signaturePath`.
+func writeDetachedSignature(signer *SigningKey, contents []byte, signaturePath string) error {
+	sig := &packet.Signature{
+		SigType:      packet.SigTypeBinary,
+		PubKeyAlgo:   signer.privateKey.PubKeyAlgo,
+		Hash:         crypto.SHA256,
+		CreationTime: time.Now(),
+		IssuerKeyId:  &signer.privateKey.KeyId,
+	}
+
+	h := sig.Hash.New()
+	h.Write(contents)
+	if err := sig.Sign(h, signer.privateKey, nil); err != nil {
+		return fmt.Errorf("signing: %w", err)
+	}
+
+	var signature bytes.Buffer
+	w, err := armor.Encode(&signature, openpgp.SignatureType, nil)
+	if err != nil {
+		return fmt.Errorf("creating armor encoder: %w", err)
+	}
+	if err := sig.Serialize(w); err != nil {
+		return fmt.Errorf("serializing signature: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("closing armor encoder: %w", err)
+	}
+
+	return os.WriteFile(signaturePath, signature.Bytes(), 0644)
+}
+
+// writeClearsigned writes `contents` to `outputPath`, wrapped in a cleartext signature.
+func writeClearsigned(signer *SigningKey, contents []byte, outputPath string) error {
+	var signed bytes.Buffer
+	w, err := clearsign.Encode(&signed, signer.privateKey, nil)
+	if err != nil {
+		return fmt.Errorf("creating clearsign encoder: %w", err)
+	}
+	if _, err := w.Write(contents); err != nil {
+		return fmt.Errorf("clearsigning: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("closing clearsign encoder: %w", err)
+	}
+
+	return os.WriteFile(outputPath, signed.Bytes(), 0644)
+}
+
+// writePublicKey writes the armored public key for `signer` to `keyPath`, so that clients
+// can be configured to trust the repository.
+func writePublicKey(signer *SigningKey, keyPath string) error {
+	var armored bytes.Buffer
+	w, err := armor.Encode(&armored, openpgp.PublicKeyType, nil)
+	if err != nil {
+		return fmt.Errorf("creating armor encoder: %w", err)
+	}
+	if err := signer.entity.Serialize(w); err != nil {
+		return fmt.Errorf("serializing public key: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("closing armor encoder: %w", err)
+	}
+
+	return os.WriteFile(keyPath, armored.Bytes(), 0644)
+}
@@ -0,0 +1,476 @@
+package linuxrepo
+
+import (
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-kit/kit/log/level"
+	"github.com/kolide/launcher/pkg/contexts/ctxlog"
+	"go.opencensus.io/trace"
+)
+
+const (
+	yumPackagesDirectory = "Packages"
+	yumRepodataDirectory = "repodata"
+
+	yumNamespaceCommon    = "http://linux.duke.edu/metadata/common"
+	yumNamespaceRpm       = "http://linux.duke.edu/metadata/rpm"
+	yumNamespaceFilelists = "http://linux.duke.edu/metadata/filelists"
+	yumNamespaceOther     = "http://linux.duke.edu/metadata/other"
+	yumNamespaceRepo      = "http://linux.duke.edu/metadata/repo"
+)
+
+// yumPackage is a single rpm package, as it will appear in the repository metadata.
+type yumPackage struct {
+	rpm      *rpmPackage
+	location string // path relative to the repository root
+	digests  digests
+	modTime  int64
+}
+
+// BuildYumRepo creates a signed yum repository in `outputDir` containing the given rpm
+// packages. The packages are copied into the repository's Packages directory, and the
+// primary, filelists, and other metadata are generated along with a signed repomd.xml.
+// The signer's public key is written to the root of the repository as `public.asc`.
+//
+// Existing metadata in `outputDir` is replaced. Packages already present in the
+// repository's Packages directory are kept, and indexed along with the new ones.
+func BuildYumRepo(ctx context.Context, outputDir string, rpmPaths []string, signer *SigningKey) error {
+	ctx, span := trace.StartSpan(ctx, "linuxrepo.BuildYumRepo")
+	defer span.End()
+
+	logger := ctxlog.FromContext(ctx)
+
+	if signer == nil {
+		return errors.New("a signing key is required")
+	}
+	if len(rpmPaths) == 0 {
+		return errors.New("no packages provided")
+	}
+
+	if err := os.MkdirAll(filepath.Join(outputDir, yumPackagesDirectory), 0755); err != nil {
+		return fmt.Errorf("creating packages directory: %w", err)
+	}
+
+	for _, rpmPath := range rpmPaths {
+		rpm, err := readRpmPackage(rpmPath)
+		if err != nil {
+			return fmt.Errorf("reading package metadata: %w", err)
+		}
+
+		location := path.Join(yumPackagesDirectory, filepath.Base(rpmPath))
+		if _, err := copyAndDigest(rpmPath, filepath.Join(outputDir, filepath.FromSlash(location))); err != nil {
+			return fmt.Errorf("adding %s to repository: %w", rpmPath, err)
+		}
+
+		level.Debug(logger).Log("msg", "added package to yum repository", "package", rpm.header.string(rpmTagName), "path", location)
+	}
+
+	// Index everything in the Packages directory, so that packages added by earlier runs remain available
+	packages, err := repositoryPackages(outputDir)
+	if err != nil {
+		return fmt.Errorf("indexing packages: %w", err)
+	}
+
+	// Remove any metadata from previous runs -- the filenames include their checksums, so
+	// old files would otherwise accumulate.
+	repodataDir := filepath.Join(outputDir, yumRepodataDirectory)
+	if err := os.RemoveAll(repodataDir); err != nil {
+		return fmt.Errorf("removing old repodata: %w", err)
+	}
+	if err := os.MkdirAll(repodataDir, 0755); err != nil {
+		return fmt.Errorf("creating repodata directory: %w", err)
+	}
+
+	now := time.Now().Unix()
+	repomd := yumRepomd{
+		Xmlns:    yumNamespaceRepo,
+		XmlnsRpm: yumNamespaceRpm,
+		Revision: strconv.FormatInt(now, 10),
+	}
+
+	for _, metadata := range []struct {
+		dataType string
+		document interface{}
+	}{
+		{"primary", primaryMetadata(packages)},
+		{"filelists", filelistsMetadata(packages)},
+		{"other", otherMetadata(packages)},
+	} {
+		data, err := writeRepodata(repodataDir, metadata.dataType, metadata.document, now)
+		if err != nil {
+			return fmt.Errorf("writing %s metadata: %w", metadata.dataType, err)
+		}
+		repomd.Data = append(repomd.Data, data)
+	}
+
+	repomdBytes, err := marshalXml(repomd)
+	if err != nil {
+		return fmt.Errorf("marshalling repomd.xml: %w", err)
+	}
+	repomdPath := filepath.Join(repodataDir, "repomd.xml")
+	if err := os.WriteFile(repomdPath, repomdBytes, 0644); err != nil {
+		return fmt.Errorf("writing repomd.xml: %w", err)
+	}
+	if err := writeDetachedSignature(signer, repomdBytes, repomdPath+".asc"); err != nil {
+		return fmt.Errorf("writing repomd.xml.asc: %w", err)
+	}
+	if err := writePublicKey(signer, filepath.Join(outputDir, "public.asc")); err != nil {
+		return fmt.Errorf("writing public key: %w", err)
+	}
+
+	return nil
+}
+
+// repositoryPackages reads the metadata of every rpm package in the repository's Packages directory.
+func repositoryPackages(outputDir string) ([]yumPackage, error) {
+	rpmPaths, err := findPackages(filepath.Join(outputDir, yumPackagesDirectory), ".rpm")
+	if err != nil {
+		return nil, err
+	}
+
+	packages := make([]yumPackage, 0, len(rpmPaths))
+	for _, rpmPath := range rpmPaths {
+		rpm, err := readRpmPackage(rpmPath)
+		if err != nil {
+			return nil, fmt.Errorf("reading package metadata: %w", err)
+		}
+
+		d, err := digestFile(rpmPath)
+		if err != nil {
+			return nil, fmt.Errorf("digesting %s: %w", rpmPath, err)
+		}
+
+		stat, err := os.Stat(rpmPath)
+		if err != nil {
+			return nil, fmt.Errorf("checking %s: %w", rpmPath, err)
+		}
+
+		relPath, err := filepath.Rel(outputDir, rpmPath)
+		if err != nil {
+			return nil, fmt.Errorf("getting path of %s within repository: %w", rpmPath, err)
+		}
+
+		packages = append(packages, yumPackage{
+			rpm:      rpm,
+			location: filepath.ToSlash(relPath),
+			digests:  d,
+			modTime:  stat.ModTime().Unix(),
+		})
+	}
+
+	return packages, nil
+}
+
+// writeRepodata compresses and writes a metadata document into the repodata directory,
+// returning its entry for repomd.xml.
+func writeRepodata(repodataDir string, dataType string, document interface{}, timestamp int64) (yumRepomdData, error) {
+	raw, err := marshalXml(document)
+	if err != nil {
+		return yumRepomdData{}, fmt.Errorf("marshalling: %w", err)
+	}
+
+	compressed, err := gzipBytes(raw)
+	if err != nil {
+		return yumRepomdData{}, fmt.Errorf("compressing: %w", err)
+	}
+
+	rawDigests := digestBytes(raw)
+	compressedDigests := digestBytes(compressed)
+
+	location := path.Join(yumRepodataDirectory, fmt.Sprintf("%s-%s.xml.gz", compressedDigests.sha256, dataType))
+	if err := os.WriteFile(filepath.Join(repodataDir, path.Base(location)), compressed, 0644); err != nil {
+		return yumRepomdData{}, fmt.Errorf("writing %s: %w", location, err)
+	}
+
+	return yumRepomdData{
+		Type:         dataType,
+		Checksum:     yumChecksum{Type: "sha256", Value: compressedDigests.sha256},
+		OpenChecksum: yumChecksum{Type: "sha256", Value: rawDigests.sha256},
+		Location:     yumLocation{Href: location},
+		Timestamp:    timestamp,
+		Size:         compressedDigests.size,
+		OpenSize:     rawDigests.size,
+	}, nil
+}
+
+func marshalXml(document interface{}) ([]byte, error) {
+	raw, err := xml.MarshalIndent(document, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), append(raw, '\n')...), nil
+}
+
+// The types below describe the createrepo metadata format. encoding/xml does not support
+// namespace prefixes, so prefixed elements and attributes are named literally.
+
+type yumRepomd struct {
+	XMLName  xml.Name        `xml:"repomd"`
+	Xmlns    string          `xml:"xmlns,attr"`
+	XmlnsRpm string          `xml:"xmlns:rpm,attr"`
+	Revision string          `xml:"revision"`
+	Data     []yumRepomdData `xml:"data"`
+}
+
+type yumRepomdData struct {
+	Type         string      `xml:"type,attr"`
+	Checksum     yumChecksum `xml:"checksum"`
+	OpenChecksum yumChecksum `xml:"open-checksum"`
+	Location     yumLocation `xml:"location"`
+	Timestamp    int64       `xml:"timestamp"`
+	Size         int64       `xml:"size"`
+	OpenSize     int64       `xml:"open-size"`
+}
+
+type yumChecksum struct {
+	Type  string `xml:"type,attr"`
+	PkgId string `xml:"pkgid,attr,omitempty"`
+	Value string `xml:",chardata"`
+}
+
+type yumLocation struct {
+	Href string `xml:"href,attr"`
+}
+
+type yumVersion struct {
+	Epoch string `xml:"epoch,attr"`
+	Ver   string `xml:"ver,attr"`
+	Rel   string `xml:"rel,attr"`
+}
+
+type yumPrimary struct {
+	XMLName  xml.Name            `xml:"metadata"`
+	Xmlns    string              `xml:"xmlns,attr"`
+	XmlnsRpm string              `xml:"xmlns:rpm,attr"`
+	Count    int                 `xml:"packages,attr"`
+	Packages []yumPrimaryPackage `xml:"package"`
+}
+
+type yumPrimaryPackage struct {
+	Type        string      `xml:"type,attr"`
+	Name        string      `xml:"name"`
+	Arch        string      `xml:"arch"`
+	Version     yumVersion  `xml:"version"`
+	Checksum    yumChecksum `xml:"checksum"`
+	Summary     string      `xml:"summary"`
+	Description string      `xml:"description"`
+	Packager    string      `xml:"packager"`
+	Url         string      `xml:"url"`
+	Time        struct {
+		File  int64 `xml:"file,attr"`
+		Build int64 `xml:"build,attr"`
+	} `xml:"time"`
+	Size struct {
+		Package   int64 `xml:"package,attr"`
+		Installed int64 `xml:"installed,attr"`
+		Archive   int64 `xml:"archive,attr"`
+	} `xml:"size"`
+	Location yumLocation      `xml:"location"`
+	Format   yumPrimaryFormat `xml:"format"`
+}
+
+type yumPrimaryFormat struct {
+	License     string `xml:"rpm:license"`
+	Vendor      string `xml:"rpm:vendor"`
+	Group       string `xml:"rpm:group"`
+	BuildHost   string `xml:"rpm:buildhost"`
+	SourceRpm   string `xml:"rpm:sourcerpm"`
+	HeaderRange struct {
+		Start int64 `xml:"start,attr"`
+		End   int64 `xml:"end,attr"`
+	} `xml:"rpm:header-range"`
+	Provides *yumEntries `xml:"rpm:provides,omitempty"`
+	Requires *yumEntries `xml:"rpm:requires,omitempty"`
+	Files    []string    `xml:"file"`
+}
+
+type yumEntries struct {
+	Entries []yumEntry `xml:"rpm:entry"`
+}
+
+type yumEntry struct {
+	Name  string `xml:"name,attr"`
+	Flags string `xml:"flags,attr,omitempty"`
+	Epoch string `xml:"epoch,attr,omitempty"`
+	Ver   string `xml:"ver,attr,omitempty"`
+	Rel   string `xml:"rel,attr,omitempty"`
+}
+
+type yumFilelists struct {
+	XMLName  xml.Name              `xml:"filelists"`
+	Xmlns    string                `xml:"xmlns,attr"`
+	Count    int                   `xml:"packages,attr"`
+	Packages []yumFilelistsPackage `xml:"package"`
+}
+
+type yumFilelistsPackage struct {
+	PkgId   string     `xml:"pkgid,attr"`
+	Name    string     `xml:"name,attr"`
+	Arch    string     `xml:"arch,attr"`
+	Version yumVersion `xml:"version"`
+	Files   []string   `xml:"file"`
+}
+
+type yumOther struct {
+	XMLName  xml.Name          `xml:"otherdata"`
+	Xmlns    string            `xml:"xmlns,attr"`
+	Count    int               `xml:"packages,attr"`
+	Packages []yumOtherPackage `xml:"package"`
+}
+
+type yumOtherPackage struct {
+	PkgId   string     `xml:"pkgid,attr"`
+	Name    string     `xml:"name,attr"`
+	Arch    string     `xml:"arch,attr"`
+	Version yumVersion `xml:"version"`
+}
+
+func (p yumPackage) version() yumVersion {
+	return yumVersion{
+		Epoch: strconv.FormatInt(p.rpm.header.int(rpmTagEpoch), 10),
+		Ver:   p.rpm.header.string(rpmTagVersion),
+		Rel:   p.rpm.header.string(rpmTagRelease),
+	}
+}
+
+func primaryMetadata(packages []yumPackage) yumPrimary {
+	primary := yumPrimary{
+		Xmlns:    yumNamespaceCommon,
+		XmlnsRpm: yumNamespaceRpm,
+		Count:    len(packages),
+		Packages: make([]yumPrimaryPackage, 0, len(packages)),
+	}
+
+	for _, p := range packages {
+		h := p.rpm.header
+
+		installedSize := h.int(rpmTagLongSize)
+		if installedSize == 0 {
+			installedSize = h.int(rpmTagSize)
+		}
+
+		entry := yumPrimaryPackage{
+			Type:        "rpm",
+			Name:        h.string(rpmTagName),
+			Arch:        h.string(rpmTagArch),
+			Version:     p.version(),
+			Checksum:    yumChecksum{Type: "sha256", PkgId: "YES", Value: p.digests.sha256},
+			Summary:     h.string(rpmTagSummary),
+			Description: h.string(rpmTagDescription),
+			Packager:    h.string(rpmTagPackager),
+			Url:         h.string(rpmTagUrl),
+			Location:    yumLocation{Href: p.location},
+		}
+		entry.Time.File = p.modTime
+		entry.Time.Build = h.int(rpmTagBuildTime)
+		entry.Size.Package = p.digests.size
+		entry.Size.Installed = installedSize
+		entry.Size.Archive = p.rpm.signature.int(rpmSigTagPayloadSize)
+
+		entry.Format = yumPrimaryFormat{
+			License:   h.string(rpmTagLicense),
+			Vendor:    h.string(rpmTagVendor),
+			Group:     h.string(rpmTagGroup),
+			BuildHost: h.string(rpmTagBuildHost),
+			SourceRpm: h.string(rpmTagSourceRpm),
+			Provides:  yumEntriesFrom(p.rpm.dependencies(rpmTagProvideName, rpmTagProvideFlags, rpmTagProvideVersion)),
+			Requires:  yumEntriesFrom(p.rpm.dependencies(rpmTagRequireName, rpmTagRequireFlags, rpmTagRequireVersion)),
+			Files:     primaryFiles(p.rpm.files()),
+		}
+		entry.Format.HeaderRange.Start = p.rpm.headerStart
+		entry.Format.HeaderRange.End = p.rpm.headerEnd
+
+		primary.Packages = append(primary.Packages, entry)
+	}
+
+	return primary
+}
+
+func yumEntriesFrom(deps []rpmDependency) *yumEntries {
+	if len(deps) == 0 {
+		return nil
+	}
+
+	entries := &yumEntries{Entries: make([]yumEntry, 0, len(deps))}
+	for _, dep := range deps {
+		// rpmlib dependencies are satisfied by rpm itself, and are never listed in repository metadata
+		if strings.HasPrefix(dep.name, "rpmlib(") {
+			continue
+		}
+
+		entry := yumEntry{Name: dep.name}
+		if flags := dep.comparison(); flags != "" {
+			entry.Flags = flags
+			entry.Epoch = dep.epoch
+			if entry.Epoch == "" {
+				entry.Epoch = "0"
+			}
+			entry.Ver = dep.version
+			entry.Rel = dep.release
+		}
+		entries.Entries = append(entries.Entries, entry)
+	}
+
+	return entries
+}
+
+// primaryFiles filters the package's files down to those that createrepo includes in the
+// primary metadata -- binaries and configuration -- so that yum can resolve file dependencies
+// without downloading the full file lists.
+func primaryFiles(files []string) []string {
+	filtered := make([]string, 0)
+	for _, f := range files {
+		if strings.HasPrefix(f, "/etc/") || strings.Contains(f, "bin/") || f == "/usr/lib/sendmail" {
+			filtered = append(filtered, f)
+		}
+	}
+	return filtered
+}
+
+func filelistsMetadata(packages []yumPackage) yumFilelists {
+	filelists := yumFilelists{
+		Xmlns:    yumNamespaceFilelists,
+		Count:    len(packages),
+		Packages: make([]yumFilelistsPackage, 0, len(packages)),
+	}
+
+	for _, p := range packages {
+		filelists.Packages = append(filelists.Packages, yumFilelistsPackage{
+			PkgId:   p.digests.sha256,
+			Name:    p.rpm.header.string(rpmTagName),
+			Arch:    p.rpm.header.string(rpmTagArch),
+			Version: p.version(),
+			Files:   p.rpm.files(),
+		})
+	}
+
+	return filelists
+}
+
+func otherMetadata(packages []yumPackage) yumOther {
+	other := yumOther{
+		Xmlns:    yumNamespaceOther,
+		Count:    len(packages),
+		Packages: make([]yumOtherPackage, 0, len(packages)),
+	}
+
+	for _, p := range packages {
+		other.Packages = append(other.Packages, yumOtherPackage{
+			PkgId:   p.digests.sha256,
+			Name:    p.rpm.header.string(rpmTagName),
+			Arch:    p.rpm.header.string(rpmTagArch),
+			Version: p.version(),
+		})
+	}
+
+	return other
+}
@@ -0,0 +1,169 @@
+package linuxrepo
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/openpgp"
+)
+
+func TestBuildYumRepo(t *testing.T) {
+	t.Parallel()
+
+	signer := testSigningKey(t)
+
+	packageDir := t.TempDir()
+	rpmPaths := []string{
+		filepath.Join(packageDir, "launcher-1.2.3-1.x86_64.rpm"),
+		filepath.Join(packageDir, "launcher-1.2.3-1.aarch64.rpm"),
+	}
+	buildTestRpm(t, rpmPaths[0], testRpmTags("launcher", "1.2.3", "x86_64"))
+	buildTestRpm(t, rpmPaths[1], testRpmTags("launcher", "1.2.3", "aarch64"))
+
+	repoDir := t.TempDir()
+	require.NoError(t, BuildYumRepo(context.TODO(), repoDir, rpmPaths, signer))
+
+	// Packages are copied in
+	require.FileExists(t, filepath.Join(repoDir, "Packages", "launcher-1.2.3-1.x86_64.rpm"))
+	require.FileExists(t, filepath.Join(repoDir, "Packages", "launcher-1.2.3-1.aarch64.rpm"))
+	require.FileExists(t, filepath.Join(repoDir, "public.asc"))
+
+	// repomd.xml is signed
+	repomdBytes, err := os.ReadFile(filepath.Join(repoDir, "repodata", "repomd.xml"))
+	require.NoError(t, err)
+	repomdSignature, err := os.Open(filepath.Join(repoDir, "repodata", "repomd.xml.asc"))
+	require.NoError(t, err)
+	defer repomdSignature.Close()
+	_, err = openpgp.CheckArmoredDetachedSignature(openpgp.EntityList{signer.entity}, bytes.NewReader(repomdBytes), repomdSignature)
+	require.NoError(t, err)
+
+	var repomd yumRepomd
+	require.NoError(t, xml.Unmarshal(repomdBytes, &repomd))
+	require.Len(t, repomd.Data, 3)
+
+	// Each metadata file's checksums match repomd.xml
+	var primaryBytes []byte
+	for _, data := range repomd.Data {
+		compressed, err := os.ReadFile(filepath.Join(repoDir, filepath.FromSlash(data.Location.Href)))
+		require.NoError(t, err)
+		require.Equal(t, digestBytes(compressed).sha256, data.Checksum.Value)
+		require.Equal(t, int64(len(compressed)), data.Size)
+
+		gzr, err := gzip.NewReader(bytes.NewReader(compressed))
+		require.NoError(t, err)
+		raw, err := io.ReadAll(gzr)
+		require.NoError(t, err)
+		require.Equal(t, digestBytes(raw).sha256, data.OpenChecksum.Value)
+
+		if data.Type == "primary" {
+			primaryBytes = raw
+		}
+	}
+	require.NotNil(t, primaryBytes, "no primary metadata in repomd.xml")
+
+	// Confirm the primary metadata describes our packages
+	var primary struct {
+		Count    int `xml:"packages,attr"`
+		Packages []struct {
+			Name     string      `xml:"name"`
+			Arch     string      `xml:"arch"`
+			Version  yumVersion  `xml:"version"`
+			Checksum yumChecksum `xml:"checksum"`
+			Location yumLocation `xml:"location"`
+			Format   struct {
+				Requires struct {
+					Entries []yumEntry `xml:"entry"`
+				} `xml:"requires"`
+				Files []string `xml:"file"`
+			} `xml:"format"`
+		} `xml:"package"`
+	}
+	require.NoError(t, xml.Unmarshal(primaryBytes, &primary))
+	require.Equal(t, 2, primary.Count)
+	require.Len(t, primary.Packages, 2)
+
+	for _, p := range primary.Packages {
+		require.Equal(t, "launcher", p.Name)
+		require.Equal(t, yumVersion{Epoch: "0", Ver: "1.2.3", Rel: "1"}, p.Version)
+
+		packageBytes, err := os.ReadFile(filepath.Join(repoDir, filepath.FromSlash(p.Location.Href)))
+		require.NoError(t, err)
+		require.Equal(t, digestBytes(packageBytes).sha256, p.Checksum.Value)
+
+		// rpmlib requirements are omitted
+		require.Equal(t, []yumEntry{{Name: "glibc", Flags: "GE", Epoch: "0", Ver: "2.17"}, {Name: "/bin/sh"}}, p.Format.Requires.Entries)
+
+		// Only binaries and config are listed in primary
+		require.Equal(t, []string{"/etc/launcher/launcher.flags", "/usr/local/bin/launcher"}, p.Format.Files)
+	}
+}
+
+func TestBuildYumRepo_ReplacesOldMetadata(t *testing.T) {
+	t.Parallel()
+
+	signer := testSigningKey(t)
+
+	rpmPath := filepath.Join(t.TempDir(), "launcher-1.2.3-1.x86_64.rpm")
+	buildTestRpm(t, rpmPath, testRpmTags("launcher", "1.2.3", "x86_64"))
+
+	repoDir := t.TempDir()
+	require.NoError(t, BuildYumRepo(context.TODO(), repoDir, []string{rpmPath}, signer))
+
+	updatedRpmPath := filepath.Join(t.TempDir(), "launcher-1.2.4-1.x86_64.rpm")
+	buildTestRpm(t, updatedRpmPath, testRpmTags("launcher", "1.2.4", "x86_64"))
+	require.NoError(t, BuildYumRepo(context.TODO(), repoDir, []string{updatedRpmPath}, signer))
+
+	repodata, err := os.ReadDir(filepath.Join(repoDir, "repodata"))
+	require.NoError(t, err)
+	require.Len(t, repodata, 5, "expected only repomd.xml, its signature, and three metadata files")
+}
+
+func TestBuildYumRepo_KeepsExistingPackages(t *testing.T) {
+	t.Parallel()
+
+	signer := testSigningKey(t)
+	repoDir := t.TempDir()
+
+	for _, version := range []string{"1.2.3", "1.2.4"} {
+		rpmPath := filepath.Join(t.TempDir(), fmt.Sprintf("launcher-%s-1.x86_64.rpm", version))
+		buildTestRpm(t, rpmPath, testRpmTags("launcher", version, "x86_64"))
+		require.NoError(t, BuildYumRepo(context.TODO(), repoDir, []string{rpmPath}, signer))
+	}
+
+	// Both versions are indexed, even though the second run was only given the newer package
+	primaryPaths, err := filepath.Glob(filepath.Join(repoDir, "repodata", "*primary.xml.gz"))
+	require.NoError(t, err)
+	require.Len(t, primaryPaths, 1)
+	primary := readGzippedFile(t, primaryPaths[0])
+	require.Contains(t, primary, `href="Packages/launcher-1.2.3-1.x86_64.rpm"`)
+	require.Contains(t, primary, `href="Packages/launcher-1.2.4-1.x86_64.rpm"`)
+}
+
+func TestBuildYumRepo_RequiresSigner(t *testing.T) {
+	t.Parallel()
+
+	rpmPath := filepath.Join(t.TempDir(), "launcher-1.2.3-1.x86_64.rpm")
+	buildTestRpm(t, rpmPath, testRpmTags("launcher", "1.2.3", "x86_64"))
+
+	require.Error(t, BuildYumRepo(context.TODO(), t.TempDir(), []string{rpmPath}, nil))
+}
+
+func readGzippedFile(t *testing.T, filePath string) string {
+	f, err := os.Open(filePath)
+	require.NoError(t, err)
+	defer f.Close()
+
+	gzr, err := gzip.NewReader(f)
+	require.NoError(t, err)
+	raw, err := io.ReadAll(gzr)
+	require.NoError(t, err)
+	return string(raw)
+}
@@ -0,0 +1,273 @@
+package packagekit
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+// debArchs maps our architecture names to Debian's
+var debArchs = map[string]string{
+	"amd64":   "amd64",
+	"x86_64":  "amd64",
+	"arm64":   "arm64",
+	"aarch64": "arm64",
+}
+
+// writeDeb writes a deb package to w. A deb is an ar archive containing
+// a version marker, a control tarball (metadata and maintainer
+// scripts), and a data tarball (the files to install).
+func writeDeb(w io.Writer, po *PackageOptions, f fpmOptions, files []packageFile) error {
+	arch, ok := debArchs[f.arch]
+	if !ok {
+		arch = f.arch
+	}
+
+	// The modification time recorded in the archives. We use the time
+	// of the build, as fpm does.
+	mtime := time.Now()
+
+	data, md5sums, installedSize, err := debDataTarball(files, mtime)
+	if err != nil {
+		return fmt.Errorf("creating data tarball: %w", err)
+	}
+
+	var control strings.Builder
+	fmt.Fprintf(&control, "Package: %s\n", packageName(po))
+	fmt.Fprintf(&control, "Version: %s\n", po.Version)
+	fmt.Fprintf(&control, "Architecture: %s\n", arch)
+	fmt.Fprintf(&control, "Maintainer: %s\n", nativeMaintainer)
+	fmt.Fprintf(&control, "Installed-Size: %d\n", (installedSize+1023)/1024)
+	if len(f.replaces) > 0 {
+		fmt.Fprintf(&control, "Replaces: %s\n", strings.Join(f.replaces, ", "))
+		fmt.Fprintf(&control, "Conflicts: %s\n", strings.Join(f.replaces, ", "))
+	}
+	fmt.Fprintf(&control, "Section: default\n")
+	fmt.Fprintf(&control, "Priority: optional\n")
+	fmt.Fprintf(&control, "Description: %s\n", packageDescription(po))
+
+	controlFiles := []debControlFile{
+		{name: "control", contents: []byte(control.String()), mode: 0644},
+		{name: "md5sums", contents: md5sums, mode: 0644},
+	}
+
+	var conffiles strings.Builder
+	for _, pf := range files {
+		if pf.info.Mode().IsRegular() && isConfigFile(pf.path) {
+			fmt.Fprintf(&conffiles, "%s\n", pf.path)
+		}
+	}
+	if conffiles.Len() > 0 {
+		controlFiles = append(controlFiles, debControlFile{name: "conffiles", contents: []byte(conffiles.String()), mode: 0644})
+	}
+
+	// Our script names are the ones pkgbuild uses. Map them to the debian equivalents.
+	for _, names := range [][2]string{{"postinstall", "postinst"}, {"prerm", "prerm"}} {
+		scriptName, debName := names[0], names[1]
+		script, err := readScript(po, scriptName)
+		if err != nil {
+			return err
+		}
+		if script != nil {
+			controlFiles = append(controlFiles, debControlFile{name: debName, contents: script, mode: 0755})
+		}
+	}
+
+	controlTarball, err := debControlTarball(controlFiles, mtime)
+	if err != nil {
+		return fmt.Errorf("creating control tarball: %w", err)
+	}
+
+	ar := newArWriter(w)
+	if err := ar.writeHeader(); err != nil {
+		return err
+	}
+	if err := ar.writeMember("debian-binary", []byte("2.0\n"), mtime); err != nil {
+		return err
+	}
+	if err := ar.writeMember("control.tar.gz", controlTarball, mtime); err != nil {
+		return err
+	}
+	if err := ar.writeMember("data.tar.gz", data, mtime); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+type debControlFile struct {
+	name     string
+	contents []byte
+	mode     int64
+}
+
+func debControlTarball(controlFiles []debControlFile, mtime time.Time) ([]byte, error) {
+	var buf bytes.Buffer
+	gzw := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gzw)
+
+	if err := tw.WriteHeader(&tar.Header{
+		Typeflag: tar.TypeDir,
+		Name:     "./",
+		Mode:     0755,
+		ModTime:  mtime,
+		Uname:    "root",
+		Gname:    "root",
+	}); err != nil {
+		return nil, fmt.Errorf("writing control directory header: %w", err)
+	}
+
+	for _, cf := range controlFiles {
+		if err := tw.WriteHeader(&tar.Header{
+			Typeflag: tar.TypeReg,
+			Name:     "./" + cf.name,
+			Mode:     cf.mode,
+			Size:     int64(len(cf.contents)),
+			ModTime:  mtime,
+			Uname:    "root",
+			Gname:    "root",
+		}); err != nil {
+			return nil, fmt.Errorf("writing header for %s: %w", cf.name, err)
+		}
+		if _, err := tw.Write(cf.contents); err != nil {
+			return nil, fmt.Errorf("writing %s: %w", cf.name, err)
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return nil, fmt.Errorf("closing tar: %w", err)
+	}
+	if err := gzw.Close(); err != nil {
+		return nil, fmt.Errorf("closing gzip: %w", err)
+	}
+
+	return buf.Bytes(), nil
+}
+
+// debDataTarball creates the data tarball, returning it along with the
+// contents of the md5sums control file, and the total size of the files.
+func debDataTarball(files []packageFile, mtime time.Time) ([]byte, []byte, int64, error) {
+	var buf bytes.Buffer
+	var md5sums bytes.Buffer
+	var installedSize int64
+
+	gzw := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gzw)
+
+	if err := tw.WriteHeader(&tar.Header{
+		Typeflag: tar.TypeDir,
+		Name:     "./",
+		Mode:     0755,
+		ModTime:  mtime,
+		Uname:    "root",
+		Gname:    "root",
+	}); err != nil {
+		return nil, nil, 0, fmt.Errorf("writing root directory header: %w", err)
+	}
+
+	for _, pf := range files {
+		header := &tar.Header{
+			Name:    "." + pf.path,
+			Mode:    int64(pf.info.Mode().Perm()),
+			ModTime: pf.info.ModTime(),
+			Uname:   "root",
+			Gname:   "root",
+		}
+
+		switch {
+		case pf.isDir():
+			header.Typeflag = tar.TypeDir
+			header.Name += "/"
+		case pf.isSymlink():
+			header.Typeflag = tar.TypeSymlink
+			header.Linkname = pf.linkname
+		default:
+			header.Typeflag = tar.TypeReg
+			header.Size = pf.info.Size()
+		}
+
+		if err := tw.WriteHeader(header); err != nil {
+			return nil, nil, 0, fmt.Errorf("writing header for %s: %w", pf.path, err)
+		}
+
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+
+		sum, err := copyFileWithMD5(tw, pf.source)
+		if err != nil {
+			return nil, nil, 0, err
+		}
+
+		fmt.Fprintf(&md5sums, "%s  %s\n", sum, strings.TrimPrefix(pf.path, "/"))
+		installedSize += pf.info.Size()
+	}
+
+	if err := tw.Close(); err != nil {
+		return nil, nil, 0, fmt.Errorf("closing tar: %w", err)
+	}
+	if err := gzw.Close(); err != nil {
+		return nil, nil, 0, fmt.Errorf("closing gzip: %w", err)
+	}
+
+	return buf.Bytes(), md5sums.Bytes(), installedSize, nil
+}
+
+// copyFileWithMD5 copies the file at source into w, returning its hex
+// encoded md5 sum.
+func copyFileWithMD5(w io.Writer, source string) (string, error) {
+	fh, err := os.Open(source)
+	if err != nil {
+		return "", fmt.Errorf("opening %s: %w", source, err)
+	}
+	defer fh.Close()
+
+	hash := md5.New()
+	if _, err := io.Copy(io.MultiWriter(w, hash), fh); err != nil {
+		return "", fmt.Errorf("copying %s: %w", source, err)
+	}
+
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// arWriter writes the common ar archive format used by deb packages
+type arWriter struct {
+	w io.Writer
+}
+
+func newArWriter(w io.Writer) *arWriter {
+	return &arWriter{w: w}
+}
+
+func (a *arWriter) writeHeader() error {
+	if _, err := io.WriteString(a.w, "!<arch>\n"); err != nil {
+		return fmt.Errorf("writing ar header: %w", err)
+	}
+	return nil
+}
+
+func (a *arWriter) writeMember(name string, contents []byte, mtime time.Time) error {
+	header := fmt.Sprintf("%-16s%-12d%-6d%-6d%-8o%-10d`\n", name, mtime.Unix(), 0, 0, 0100644, len(contents))
+	if _, err := io.WriteString(a.w, header); err != nil {
+		return fmt.Errorf("writing ar header for %s: %w", name, err)
+	}
+	if _, err := a.w.Write(contents); err != nil {
+		return fmt.Errorf("writing ar member %s: %w", name, err)
+	}
+
+	// Members are padded to an even length
+	if len(contents)%2 != 0 {
+		if _, err := io.WriteString(a.w, "\n"); err != nil {
+			return fmt.Errorf("writing ar padding for %s: %w", name, err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,183 @@
+package packagekit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+	"github.com/kolide/launcher/pkg/contexts/ctxlog"
+
+	"go.opencensus.io/trace"
+)
+
+// nativeMaintainer is used as the deb Maintainer and rpm Vendor and Packager
+const nativeMaintainer = "Kolide"
+
+// PackageNative builds a deb or rpm package without fpm. It accepts the
+// same options as PackageFPM, so callers can switch between the
+// two. Only AsDeb and AsRPM are supported.
+func PackageNative(ctx context.Context, w io.Writer, po *PackageOptions, fpmOpts ...FpmOpt) error {
+	ctx, span := trace.StartSpan(ctx, "packagekit.PackageNative")
+	defer span.End()
+	logger := log.With(ctxlog.FromContext(ctx), "caller", "packagekit.PackageNative")
+
+	f := fpmOptions{}
+	for _, opt := range fpmOpts {
+		opt(&f)
+	}
+
+	if f.arch == "" {
+		return errors.New("missing architecture")
+	}
+
+	if err := isDirectory(po.Root); err != nil {
+		return err
+	}
+
+	files, err := packageFiles(po.Root)
+	if err != nil {
+		return fmt.Errorf("walking package root: %w", err)
+	}
+
+	level.Debug(logger).Log(
+		"msg", "building native package",
+		"type", f.outputType,
+		"arch", f.arch,
+		"files", len(files),
+	)
+
+	switch f.outputType {
+	case Deb:
+		if err := writeDeb(w, po, f, files); err != nil {
+			return fmt.Errorf("creating deb package: %w", err)
+		}
+	case RPM:
+		if err := writeRPM(w, po, f, files); err != nil {
+			return fmt.Errorf("creating rpm package: %w", err)
+		}
+	case "":
+		return errors.New("Missing output type")
+	default:
+		return fmt.Errorf("native packaging does not support %s", f.outputType)
+	}
+
+	setInContext(ctx, ContextLauncherVersionKey, po.Version)
+
+	return nil
+}
+
+// packageFile is a single entry in the package root
+type packageFile struct {
+	path     string // absolute path, as installed. eg: /usr/local/bin/launcher
+	source   string // path on the build machine
+	info     fs.FileInfo
+	linkname string // symlink target, if this is a symlink
+}
+
+func (pf packageFile) isDir() bool {
+	return pf.info.IsDir()
+}
+
+func (pf packageFile) isSymlink() bool {
+	return pf.info.Mode()&fs.ModeSymlink != 0
+}
+
+// packageFiles returns every file, directory and symlink under root,
+// sorted by their installed path. Parent directories always sort
+// before their children.
+func packageFiles(root string) ([]packageFile, error) {
+	var files []packageFile
+
+	err := filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if p == root {
+			return nil
+		}
+
+		info, err := os.Lstat(p)
+		if err != nil {
+			return fmt.Errorf("stat %s: %w", p, err)
+		}
+
+		rel, err := filepath.Rel(root, p)
+		if err != nil {
+			return fmt.Errorf("relative path for %s: %w", p, err)
+		}
+
+		pf := packageFile{
+			path:   "/" + filepath.ToSlash(rel),
+			source: p,
+			info:   info,
+		}
+
+		switch {
+		case info.Mode().IsRegular(), info.IsDir():
+		case pf.isSymlink():
+			if pf.linkname, err = os.Readlink(p); err != nil {
+				return fmt.Errorf("readlink %s: %w", p, err)
+			}
+		default:
+			return fmt.Errorf("unsupported file type for %s: %s", p, info.Mode().Type())
+		}
+
+		files = append(files, pf)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(files, func(i, j int) bool {
+		return files[i].path < files[j].path
+	})
+
+	return files, nil
+}
+
+// packageName returns the name of the package, matching what we pass to fpm.
+func packageName(po *PackageOptions) string {
+	return fmt.Sprintf("%s-%s", po.Name, po.Identifier)
+}
+
+// packageDescription returns a description for the package metadata.
+func packageDescription(po *PackageOptions) string {
+	if po.Title != "" {
+		return po.Title
+	}
+	return "The Kolide osquery launcher"
+}
+
+// readScript returns the contents of the named script from
+// po.Scripts, or nil if it does not exist.
+func readScript(po *PackageOptions, name string) ([]byte, error) {
+	if po.Scripts == "" {
+		return nil, nil
+	}
+
+	contents, err := os.ReadFile(filepath.Join(po.Scripts, name))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading %s script: %w", name, err)
+	}
+
+	return contents, nil
+}
+
+// isConfigFile reports whether the installed path is a config file. We
+// follow fpm's default of treating everything under /etc as config.
+func isConfigFile(installedPath string) bool {
+	return strings.HasPrefix(installedPath, "/etc/")
+}
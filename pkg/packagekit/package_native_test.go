@@ -0,0 +1,261 @@
+package packagekit
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPackageNative_Deb(t *testing.T) {
+	t.Parallel()
+
+	po := nativeTestPackageOptions(t)
+
+	var out bytes.Buffer
+	require.NoError(t, PackageNative(context.TODO(), &out, po, AsDeb(), WithArch("amd64"), WithReplaces([]string{"launcher"})))
+
+	members := readArMembers(t, out.Bytes())
+	require.Equal(t, []string{"debian-binary", "control.tar.gz", "data.tar.gz"}, []string{members[0].name, members[1].name, members[2].name})
+	require.Equal(t, "2.0\n", string(members[0].contents))
+
+	control := readTarGz(t, members[1].contents)
+	require.Contains(t, control["./control"], "Package: launcher-kolide-app\n")
+	require.Contains(t, control["./control"], "Version: 1.2.3-4-gabcdef\n")
+	require.Contains(t, control["./control"], "Architecture: amd64\n")
+	require.Contains(t, control["./control"], "Replaces: launcher\n")
+	require.Contains(t, control["./control"], "Conflicts: launcher\n")
+	require.Equal(t, "/etc/kolide-app/launcher.flags\n", control["./conffiles"])
+	require.Equal(t, "#!/bin/sh\necho postinstall\n", control["./postinst"])
+	require.Equal(t, "#!/bin/sh\necho prerm\n", control["./prerm"])
+	require.Contains(t, control["./md5sums"], "  usr/local/kolide-app/bin/launcher\n")
+
+	data := readTarGz(t, members[2].contents)
+	require.Equal(t, "launcher binary", data["./usr/local/kolide-app/bin/launcher"])
+	require.Equal(t, "hostname example.com", data["./etc/kolide-app/launcher.flags"])
+	require.Contains(t, data, "./usr/local/kolide-app/bin/")
+	require.Equal(t, "symlink -> launcher", data["./usr/local/kolide-app/bin/launcher-link"])
+
+	// If dpkg-deb is available, confirm it agrees
+	if _, err := exec.LookPath("dpkg-deb"); err != nil {
+		return
+	}
+	debPath := filepath.Join(t.TempDir(), "test.deb")
+	require.NoError(t, os.WriteFile(debPath, out.Bytes(), 0644))
+
+	info, err := exec.Command("dpkg-deb", "--info", debPath).CombinedOutput()
+	require.NoError(t, err, string(info))
+	require.Contains(t, string(info), "Package: launcher-kolide-app")
+
+	contents, err := exec.Command("dpkg-deb", "--contents", debPath).CombinedOutput()
+	require.NoError(t, err, string(contents))
+	require.Contains(t, string(contents), "./usr/local/kolide-app/bin/launcher\n")
+}
+
+func TestPackageNative_RPM(t *testing.T) {
+	t.Parallel()
+
+	po := nativeTestPackageOptions(t)
+
+	var out bytes.Buffer
+	require.NoError(t, PackageNative(context.TODO(), &out, po, AsRPM(), WithArch("amd64"), WithReplaces([]string{"launcher"})))
+
+	rpm := out.Bytes()
+	require.Equal(t, []byte{0xed, 0xab, 0xee, 0xdb}, rpm[0:4])
+
+	// Signature header, padded to 8 bytes, followed by the main header
+	sigLength := rpmHeaderLength(t, rpm[96:])
+	headerStart := 96 + sigLength + (8-sigLength%8)%8
+	headerLength := rpmHeaderLength(t, rpm[headerStart:])
+	header := rpm[headerStart : headerStart+headerLength]
+	payload := rpm[headerStart+headerLength:]
+
+	// The signature's SHA256 digest covers the main header
+	headerSHA256 := sha256.Sum256(header)
+	require.Contains(t, string(rpm[96:96+sigLength]), hex.EncodeToString(headerSHA256[:]))
+
+	require.Contains(t, string(header), "launcher-kolide-app\x00")
+	require.Contains(t, string(header), "1.2.3_4_gabcdef\x00")
+	require.Contains(t, string(header), "x86_64\x00")
+	require.Contains(t, string(header), "echo postinstall")
+
+	gzr, err := gzip.NewReader(bytes.NewReader(payload))
+	require.NoError(t, err)
+	files := readCpio(t, gzr)
+	require.Equal(t, "launcher binary", files["./usr/local/kolide-app/bin/launcher"])
+	require.Equal(t, "hostname example.com", files["./etc/kolide-app/launcher.flags"])
+	require.Equal(t, "launcher", files["./usr/local/kolide-app/bin/launcher-link"])
+	require.NotContains(t, files, "./usr/local/kolide-app/bin", "directories should not be in the payload")
+
+	// If rpm is available, confirm it agrees
+	if _, err := exec.LookPath("rpm"); err != nil {
+		return
+	}
+	rpmPath := filepath.Join(t.TempDir(), "test.rpm")
+	require.NoError(t, os.WriteFile(rpmPath, rpm, 0644))
+
+	info, err := exec.Command("rpm", "-qip", rpmPath).CombinedOutput()
+	require.NoError(t, err, string(info))
+	require.Contains(t, string(info), "launcher-kolide-app")
+
+	list, err := exec.Command("rpm", "-qlp", rpmPath).CombinedOutput()
+	require.NoError(t, err, string(list))
+	require.Contains(t, string(list), "/usr/local/kolide-app/bin/launcher\n")
+}
+
+func TestPackageNative_Unsupported(t *testing.T) {
+	t.Parallel()
+
+	po := nativeTestPackageOptions(t)
+
+	require.Error(t, PackageNative(context.TODO(), io.Discard, po, AsPacman(), WithArch("amd64")))
+	require.Error(t, PackageNative(context.TODO(), io.Discard, po, AsDeb()))
+}
+
+func nativeTestPackageOptions(t *testing.T) *PackageOptions {
+	root := t.TempDir()
+	binDir := filepath.Join(root, "usr", "local", "kolide-app", "bin")
+	confDir := filepath.Join(root, "etc", "kolide-app")
+	require.NoError(t, os.MkdirAll(binDir, 0755))
+	require.NoError(t, os.MkdirAll(confDir, 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(binDir, "launcher"), []byte("launcher binary"), 0755))
+	require.NoError(t, os.Symlink("launcher", filepath.Join(binDir, "launcher-link")))
+	require.NoError(t, os.WriteFile(filepath.Join(confDir, "launcher.flags"), []byte("hostname example.com"), 0644))
+
+	scripts := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(scripts, "postinstall"), []byte("#!/bin/sh\necho postinstall\n"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(scripts, "prerm"), []byte("#!/bin/sh\necho prerm\n"), 0755))
+
+	return &PackageOptions{
+		Name:       "launcher",
+		Identifier: "kolide-app",
+		Version:    "1.2.3-4-gabcdef",
+		Root:       root,
+		Scripts:    scripts,
+	}
+}
+
+type arMember struct {
+	name     string
+	contents []byte
+}
+
+func readArMembers(t *testing.T, ar []byte) []arMember {
+	require.Equal(t, "!<arch>\n", string(ar[0:8]))
+	r := bufio.NewReader(bytes.NewReader(ar[8:]))
+
+	var members []arMember
+	for {
+		header := make([]byte, 60)
+		if _, err := io.ReadFull(r, header); err != nil {
+			require.ErrorIs(t, err, io.EOF)
+			return members
+		}
+		size, err := strconv.Atoi(strings.TrimSpace(string(header[48:58])))
+		require.NoError(t, err)
+
+		contents := make([]byte, size)
+		_, err = io.ReadFull(r, contents)
+		require.NoError(t, err)
+		if size%2 != 0 {
+			_, err = r.Discard(1)
+			require.NoError(t, err)
+		}
+
+		members = append(members, arMember{name: strings.TrimSpace(string(header[0:16])), contents: contents})
+	}
+}
+
+// readTarGz returns the contents of each entry, by name. Symlinks are
+// recorded as "symlink -> target", and directories as empty strings.
+func readTarGz(t *testing.T, tarball []byte) map[string]string {
+	gzr, err := gzip.NewReader(bytes.NewReader(tarball))
+	require.NoError(t, err)
+	tr := tar.NewReader(gzr)
+
+	files := make(map[string]string)
+	for {
+		header, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return files
+		}
+		require.NoError(t, err)
+
+		switch header.Typeflag {
+		case tar.TypeSymlink:
+			files[header.Name] = "symlink -> " + header.Linkname
+		default:
+			contents, err := io.ReadAll(tr)
+			require.NoError(t, err)
+			files[header.Name] = string(contents)
+		}
+	}
+}
+
+func rpmHeaderLength(t *testing.T, b []byte) int {
+	require.Equal(t, []byte{0x8e, 0xad, 0xe8, 0x01}, b[0:4])
+	indexCount := int(binary.BigEndian.Uint32(b[8:12]))
+	storeSize := int(binary.BigEndian.Uint32(b[12:16]))
+	return 16 + 16*indexCount + storeSize
+}
+
+// readCpio returns the contents of each entry in a newc cpio archive, by name.
+func readCpio(t *testing.T, r io.Reader) map[string]string {
+	br := bufio.NewReader(r)
+	files := make(map[string]string)
+	var offset int
+
+	skip := func(n int) {
+		_, err := br.Discard(n)
+		require.NoError(t, err)
+		offset += n
+	}
+
+	for {
+		header := make([]byte, 110)
+		_, err := io.ReadFull(br, header)
+		require.NoError(t, err)
+		offset += 110
+		require.Equal(t, "070701", string(header[0:6]))
+
+		field := func(i int) int {
+			v, err := strconv.ParseUint(string(header[6+8*i:6+8*(i+1)]), 16, 32)
+			require.NoError(t, err)
+			return int(v)
+		}
+		size, nameSize := field(6), field(11)
+
+		name := make([]byte, nameSize)
+		_, err = io.ReadFull(br, name)
+		require.NoError(t, err)
+		offset += nameSize
+		skip((4 - offset%4) % 4)
+
+		filename := strings.TrimSuffix(string(name), "\x00")
+		if filename == "TRAILER!!!" {
+			return files
+		}
+
+		contents := make([]byte, size)
+		_, err = io.ReadFull(br, contents)
+		require.NoError(t, err)
+		offset += size
+		skip((4 - offset%4) % 4)
+
+		files[filename] = string(contents)
+	}
+}
@@ -0,0 +1,343 @@
+package packagekit
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+	"github.com/kolide/launcher/pkg/contexts/ctxlog"
+	"go.opencensus.io/trace"
+)
+
+// OCI media types. See https://github.com/opencontainers/image-spec
+const (
+	ociIndexMediaType    = "application/vnd.oci.image.index.v1+json"
+	ociManifestMediaType = "application/vnd.oci.image.manifest.v1+json"
+	ociConfigMediaType   = "application/vnd.oci.image.config.v1+json"
+	ociLayerMediaType    = "application/vnd.oci.image.layer.v1.tar+gzip"
+)
+
+// ociArchs maps our architectures to the GOARCH style names OCI uses
+var ociArchs = map[string]string{
+	"amd64": "amd64",
+	"arm64": "arm64",
+}
+
+type ociDescriptor struct {
+	MediaType   string            `json:"mediaType"`
+	Digest      string            `json:"digest"`
+	Size        int64             `json:"size"`
+	Platform    *ociPlatform      `json:"platform,omitempty"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+type ociPlatform struct {
+	Architecture string `json:"architecture"`
+	OS           string `json:"os"`
+}
+
+type ociIndex struct {
+	SchemaVersion int             `json:"schemaVersion"`
+	MediaType     string          `json:"mediaType"`
+	Manifests     []ociDescriptor `json:"manifests"`
+}
+
+type ociManifest struct {
+	SchemaVersion int               `json:"schemaVersion"`
+	MediaType     string            `json:"mediaType"`
+	Config        ociDescriptor     `json:"config"`
+	Layers        []ociDescriptor   `json:"layers"`
+	Annotations   map[string]string `json:"annotations,omitempty"`
+}
+
+type ociImageConfig struct {
+	Created      string             `json:"created"`
+	Architecture string             `json:"architecture"`
+	OS           string             `json:"os"`
+	Config       ociContainerConfig `json:"config"`
+	RootFS       ociRootFS          `json:"rootfs"`
+}
+
+type ociContainerConfig struct {
+	Entrypoint []string          `json:"Entrypoint"`
+	Env        []string          `json:"Env,omitempty"`
+	Labels     map[string]string `json:"Labels,omitempty"`
+}
+
+type ociRootFS struct {
+	Type    string   `json:"type"`
+	DiffIDs []string `json:"diff_ids"`
+}
+
+// PackageOCI writes an OCI image layout, as a tarball, containing
+// the package root as a single layer on top of an empty base. The
+// result can be loaded with `podman load`, or pushed with `skopeo
+// copy oci-archive:<file> docker://<registry>`. No container runtime
+// is needed to build it.
+//
+// entrypoint is the command the container runs, generally launcher
+// and its flags.
+func PackageOCI(ctx context.Context, w io.Writer, po *PackageOptions, arch string, entrypoint []string) error {
+	ctx, span := trace.StartSpan(ctx, "packagekit.PackageOCI")
+	defer span.End()
+	logger := log.With(ctxlog.FromContext(ctx), "caller", "packagekit.PackageOCI")
+
+	ociArch, ok := ociArchs[arch]
+	if !ok {
+		return fmt.Errorf("unsupported architecture for OCI image: %s", arch)
+	}
+
+	if len(entrypoint) == 0 {
+		return errors.New("missing entrypoint")
+	}
+
+	if err := isDirectory(po.Root); err != nil {
+		return err
+	}
+
+	files, err := packageFiles(po.Root)
+	if err != nil {
+		return fmt.Errorf("walking package root: %w", err)
+	}
+
+	layer, diffID, err := ociLayer(files)
+	if err != nil {
+		return fmt.Errorf("creating image layer: %w", err)
+	}
+
+	level.Debug(logger).Log(
+		"msg", "building oci image",
+		"arch", ociArch,
+		"files", len(files),
+		"layer_size", len(layer),
+	)
+
+	labels := map[string]string{
+		"org.opencontainers.image.title":   packageName(po),
+		"org.opencontainers.image.version": po.Version,
+		"org.opencontainers.image.vendor":  nativeMaintainer,
+	}
+
+	config, err := json.Marshal(ociImageConfig{
+		Created:      time.Now().UTC().Format(time.RFC3339),
+		Architecture: ociArch,
+		OS:           "linux",
+		Config: ociContainerConfig{
+			Entrypoint: entrypoint,
+			Env:        []string{"PATH=/usr/local/sbin:/usr/local/bin:/usr/sbin:/usr/bin:/sbin:/bin"},
+			Labels:     labels,
+		},
+		RootFS: ociRootFS{
+			Type:    "layers",
+			DiffIDs: []string{diffID},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("marshaling image config: %w", err)
+	}
+
+	manifest, err := json.Marshal(ociManifest{
+		SchemaVersion: 2,
+		MediaType:     ociManifestMediaType,
+		Config:        ociDescriptorFor(ociConfigMediaType, config),
+		Layers:        []ociDescriptor{ociDescriptorFor(ociLayerMediaType, layer)},
+		Annotations:   labels,
+	})
+	if err != nil {
+		return fmt.Errorf("marshaling image manifest: %w", err)
+	}
+
+	manifestDescriptor := ociDescriptorFor(ociManifestMediaType, manifest)
+	manifestDescriptor.Platform = &ociPlatform{Architecture: ociArch, OS: "linux"}
+	manifestDescriptor.Annotations = map[string]string{
+		"org.opencontainers.image.ref.name": ociRefName(po.Version),
+	}
+
+	index, err := json.Marshal(ociIndex{
+		SchemaVersion: 2,
+		MediaType:     ociIndexMediaType,
+		Manifests:     []ociDescriptor{manifestDescriptor},
+	})
+	if err != nil {
+		return fmt.Errorf("marshaling image index: %w", err)
+	}
+
+	tw := tar.NewWriter(w)
+	mtime := time.Now()
+
+	for _, dir := range []string{"blobs/", "blobs/sha256/"} {
+		if err := tw.WriteHeader(&tar.Header{Typeflag: tar.TypeDir, Name: dir, Mode: 0755, ModTime: mtime}); err != nil {
+			return fmt.Errorf("writing %s: %w", dir, err)
+		}
+	}
+
+	entries := []struct {
+		name     string
+		contents []byte
+	}{
+		{"oci-layout", []byte(`{"imageLayoutVersion":"1.0.0"}`)},
+		{"index.json", index},
+		{ociBlobPath(manifest), manifest},
+		{ociBlobPath(config), config},
+		{ociBlobPath(layer), layer},
+	}
+
+	for _, entry := range entries {
+		if err := tw.WriteHeader(&tar.Header{
+			Typeflag: tar.TypeReg,
+			Name:     entry.name,
+			Mode:     0644,
+			Size:     int64(len(entry.contents)),
+			ModTime:  mtime,
+		}); err != nil {
+			return fmt.Errorf("writing header for %s: %w", entry.name, err)
+		}
+		if _, err := tw.Write(entry.contents); err != nil {
+			return fmt.Errorf("writing %s: %w", entry.name, err)
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return fmt.Errorf("closing image tarball: %w", err)
+	}
+
+	setInContext(ctx, ContextLauncherVersionKey, po.Version)
+
+	return nil
+}
+
+// ociLayer creates a gzipped layer tarball of files. It returns the
+// layer, and the digest of the uncompressed tarball, which is what
+// the image config refers to.
+func ociLayer(files []packageFile) ([]byte, string, error) {
+	var buf bytes.Buffer
+	diffHash := sha256.New()
+
+	gzw := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(io.MultiWriter(gzw, diffHash))
+
+	// The base is empty, so launcher and osquery need a /tmp
+	if err := tw.WriteHeader(&tar.Header{
+		Typeflag: tar.TypeDir,
+		Name:     "tmp/",
+		Mode:     01777,
+		ModTime:  time.Now(),
+		Uname:    "root",
+		Gname:    "root",
+	}); err != nil {
+		return nil, "", fmt.Errorf("writing tmp header: %w", err)
+	}
+
+	for _, pf := range files {
+		if pf.path == "/tmp" {
+			continue
+		}
+
+		header := &tar.Header{
+			Name:    strings.TrimPrefix(pf.path, "/"),
+			Mode:    int64(pf.info.Mode().Perm()),
+			ModTime: pf.info.ModTime(),
+			Uname:   "root",
+			Gname:   "root",
+		}
+
+		switch {
+		case pf.isDir():
+			header.Typeflag = tar.TypeDir
+			header.Name += "/"
+		case pf.isSymlink():
+			header.Typeflag = tar.TypeSymlink
+			header.Linkname = pf.linkname
+		default:
+			header.Typeflag = tar.TypeReg
+			header.Size = pf.info.Size()
+		}
+
+		if err := tw.WriteHeader(header); err != nil {
+			return nil, "", fmt.Errorf("writing header for %s: %w", pf.path, err)
+		}
+
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+
+		if err := copyFile(tw, pf.source); err != nil {
+			return nil, "", err
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return nil, "", fmt.Errorf("closing tar: %w", err)
+	}
+	if err := gzw.Close(); err != nil {
+		return nil, "", fmt.Errorf("closing gzip: %w", err)
+	}
+
+	return buf.Bytes(), "sha256:" + hex.EncodeToString(diffHash.Sum(nil)), nil
+}
+
+func copyFile(w io.Writer, source string) error {
+	fh, err := os.Open(source)
+	if err != nil {
+		return fmt.Errorf("opening %s: %w", source, err)
+	}
+	defer fh.Close()
+
+	if _, err := io.Copy(w, fh); err != nil {
+		return fmt.Errorf("copying %s: %w", source, err)
+	}
+
+	return nil
+}
+
+func ociDescriptorFor(mediaType string, blob []byte) ociDescriptor {
+	sum := sha256.Sum256(blob)
+	return ociDescriptor{
+		MediaType: mediaType,
+		Digest:    "sha256:" + hex.EncodeToString(sum[:]),
+		Size:      int64(len(blob)),
+	}
+}
+
+func ociBlobPath(blob []byte) string {
+	sum := sha256.Sum256(blob)
+	return "blobs/sha256/" + hex.EncodeToString(sum[:])
+}
+
+// ociRefName converts a version into a valid image tag. Tags may only
+// contain letters, digits, '_', '.' and '-', and must be at most 128
+// characters.
+func ociRefName(version string) string {
+	ref := strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '_', r == '.', r == '-':
+			return r
+		default:
+			return '_'
+		}
+	}, version)
+
+	if ref == "" {
+		return "latest"
+	}
+	if ref[0] == '.' || ref[0] == '-' {
+		ref = "v" + ref
+	}
+	if len(ref) > 128 {
+		ref = ref[:128]
+	}
+
+	return ref
+}
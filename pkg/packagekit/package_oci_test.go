@@ -0,0 +1,104 @@
+package packagekit
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPackageOCI(t *testing.T) {
+	t.Parallel()
+
+	po := nativeTestPackageOptions(t)
+	entrypoint := []string{"/usr/local/kolide-app/bin/launcher", "-config", "/etc/kolide-app/launcher.flags"}
+
+	var out bytes.Buffer
+	require.NoError(t, PackageOCI(context.TODO(), &out, po, "arm64", entrypoint))
+
+	layout := readTar(t, &out)
+	require.JSONEq(t, `{"imageLayoutVersion":"1.0.0"}`, string(layout["oci-layout"]))
+
+	// Every blob should be stored under its digest
+	blob := func(d ociDescriptor) []byte {
+		contents, ok := layout["blobs/sha256/"+d.Digest[len("sha256:"):]]
+		require.True(t, ok, "missing blob %s", d.Digest)
+		require.Equal(t, d.Digest, sha256Digest(contents))
+		require.Equal(t, d.Size, int64(len(contents)))
+		return contents
+	}
+
+	var index ociIndex
+	require.NoError(t, json.Unmarshal(layout["index.json"], &index))
+	require.Len(t, index.Manifests, 1)
+	require.Equal(t, "arm64", index.Manifests[0].Platform.Architecture)
+	require.Equal(t, "1.2.3-4-gabcdef", index.Manifests[0].Annotations["org.opencontainers.image.ref.name"])
+
+	var manifest ociManifest
+	require.NoError(t, json.Unmarshal(blob(index.Manifests[0]), &manifest))
+	require.Len(t, manifest.Layers, 1)
+
+	var config ociImageConfig
+	require.NoError(t, json.Unmarshal(blob(manifest.Config), &config))
+	require.Equal(t, entrypoint, config.Config.Entrypoint)
+	require.Equal(t, "linux", config.OS)
+
+	// The config refers to the uncompressed layer
+	gzr, err := gzip.NewReader(bytes.NewReader(blob(manifest.Layers[0])))
+	require.NoError(t, err)
+	uncompressed, err := io.ReadAll(gzr)
+	require.NoError(t, err)
+	require.Equal(t, []string{sha256Digest(uncompressed)}, config.RootFS.DiffIDs)
+
+	files := readTar(t, bytes.NewReader(uncompressed))
+	require.Equal(t, "launcher binary", string(files["usr/local/kolide-app/bin/launcher"]))
+	require.Equal(t, "hostname example.com", string(files["etc/kolide-app/launcher.flags"]))
+	require.Contains(t, files, "tmp/")
+}
+
+func TestPackageOCI_Invalid(t *testing.T) {
+	t.Parallel()
+
+	po := nativeTestPackageOptions(t)
+	require.Error(t, PackageOCI(context.TODO(), io.Discard, po, "386", []string{"/bin/launcher"}))
+	require.Error(t, PackageOCI(context.TODO(), io.Discard, po, "amd64", nil))
+}
+
+func TestOCIRefName(t *testing.T) {
+	t.Parallel()
+
+	require.Equal(t, "1.2.3-4-gabcdef", ociRefName("1.2.3-4-gabcdef"))
+	require.Equal(t, "1.2.3_dirty", ociRefName("1.2.3+dirty"))
+	require.Equal(t, "v-1", ociRefName("-1"))
+	require.Equal(t, "latest", ociRefName(""))
+}
+
+// readTar returns the contents of each entry, by name. Directories are empty.
+func readTar(t *testing.T, r io.Reader) map[string][]byte {
+	tr := tar.NewReader(r)
+	files := make(map[string][]byte)
+	for {
+		header, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return files
+		}
+		require.NoError(t, err)
+
+		contents, err := io.ReadAll(tr)
+		require.NoError(t, err)
+		files[header.Name] = contents
+	}
+}
+
+func sha256Digest(b []byte) string {
+	sum := sha256.Sum256(b)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
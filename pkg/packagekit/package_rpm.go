@@ -0,0 +1,578 @@
+package packagekit
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+// rpmArchs maps our architecture names to rpm's, and to the (mostly
+// vestigial) architecture number recorded in the rpm lead.
+var rpmArchs = map[string]struct {
+	name string
+	num  uint16
+}{
+	"amd64":   {"x86_64", 1},
+	"x86_64":  {"x86_64", 1},
+	"arm64":   {"aarch64", 19},
+	"aarch64": {"aarch64", 19},
+}
+
+// rpm header data types
+const (
+	rpmTypeInt16       = 3
+	rpmTypeInt32       = 4
+	rpmTypeString      = 6
+	rpmTypeBin         = 7
+	rpmTypeStringArray = 8
+	rpmTypeI18nString  = 9
+)
+
+// rpm header tags. See rpmtag.h in the rpm sources.
+const (
+	rpmTagHeaderSignatures = 62
+	rpmTagHeaderImmutable  = 63
+	rpmTagHeaderI18nTable  = 100
+
+	rpmSigTagSHA1        = 269
+	rpmSigTagSHA256      = 273
+	rpmSigTagSize        = 1000
+	rpmSigTagMD5         = 1004
+	rpmSigTagPayloadSize = 1007
+
+	rpmTagName              = 1000
+	rpmTagVersion           = 1001
+	rpmTagRelease           = 1002
+	rpmTagSummary           = 1004
+	rpmTagDescription       = 1005
+	rpmTagBuildTime         = 1006
+	rpmTagBuildHost         = 1007
+	rpmTagSize              = 1009
+	rpmTagVendor            = 1011
+	rpmTagLicense           = 1014
+	rpmTagPackager          = 1015
+	rpmTagGroup             = 1016
+	rpmTagOs                = 1021
+	rpmTagArch              = 1022
+	rpmTagPostIn            = 1024
+	rpmTagPreUn             = 1025
+	rpmTagFileSizes         = 1028
+	rpmTagFileModes         = 1030
+	rpmTagFileRdevs         = 1033
+	rpmTagFileMtimes        = 1034
+	rpmTagFileDigests       = 1035
+	rpmTagFileLinkTos       = 1036
+	rpmTagFileFlags         = 1037
+	rpmTagFileUserName      = 1039
+	rpmTagFileGroupName     = 1040
+	rpmTagSourceRpm         = 1044
+	rpmTagFileVerifyFlags   = 1045
+	rpmTagProvideName       = 1047
+	rpmTagRequireFlags      = 1048
+	rpmTagRequireName       = 1049
+	rpmTagRequireVersion    = 1050
+	rpmTagConflictFlags     = 1053
+	rpmTagConflictName      = 1054
+	rpmTagConflictVersion   = 1055
+	rpmTagPostInProg        = 1086
+	rpmTagPreUnProg         = 1087
+	rpmTagObsoleteName      = 1090
+	rpmTagFileDevices       = 1095
+	rpmTagFileInodes        = 1096
+	rpmTagFileLangs         = 1097
+	rpmTagProvideFlags      = 1112
+	rpmTagProvideVersion    = 1113
+	rpmTagObsoleteFlags     = 1114
+	rpmTagObsoleteVersion   = 1115
+	rpmTagDirIndexes        = 1116
+	rpmTagBasenames         = 1117
+	rpmTagDirNames          = 1118
+	rpmTagPayloadFormat     = 1124
+	rpmTagPayloadCompressor = 1125
+	rpmTagPayloadFlags      = 1126
+)
+
+// rpm dependency flags
+const (
+	rpmSenseLess    = 0x02
+	rpmSenseEqual   = 0x08
+	rpmSenseRpmlib  = 0x1000000
+	rpmSenseLessEq  = rpmSenseLess | rpmSenseEqual
+	rpmLibDepFlags  = rpmSenseLessEq | rpmSenseRpmlib
+	rpmDefaultShell = "/bin/sh"
+)
+
+// writeRPM writes an rpm package to w. An rpm consists of a lead, a
+// signature header holding digests of the rest of the file, the main
+// header with the package metadata and file list, and a compressed
+// cpio archive of the files.
+func writeRPM(w io.Writer, po *PackageOptions, f fpmOptions, files []packageFile) error {
+	arch, ok := rpmArchs[f.arch]
+	if !ok {
+		arch.name = f.arch
+	}
+
+	// rpm versions may not contain hyphens. fpm makes the same substitution.
+	version := strings.ReplaceAll(po.Version, "-", "_")
+	release := "1"
+	name := packageName(po)
+
+	// rpm does not own directories unless asked to. Directories
+	// like /usr/local are shared with other packages, and owning them
+	// leads to conflicts. Like fpm, we only package files and links.
+	var contents []packageFile
+	for _, pf := range files {
+		if !pf.isDir() {
+			contents = append(contents, pf)
+		}
+	}
+
+	payload, payloadSize, fileDigests, err := rpmPayload(contents)
+	if err != nil {
+		return fmt.Errorf("creating payload: %w", err)
+	}
+
+	buildHost, err := os.Hostname()
+	if err != nil {
+		buildHost = "localhost"
+	}
+
+	h := &rpmHeaderBuilder{}
+	h.addStringArray(rpmTagHeaderI18nTable, []string{"C"})
+	h.addString(rpmTagName, name)
+	h.addString(rpmTagVersion, version)
+	h.addString(rpmTagRelease, release)
+	h.addI18nString(rpmTagSummary, packageDescription(po))
+	h.addI18nString(rpmTagDescription, packageDescription(po))
+	h.addInt32(rpmTagBuildTime, int32(time.Now().Unix()))
+	h.addString(rpmTagBuildHost, buildHost)
+	h.addString(rpmTagVendor, nativeMaintainer)
+	h.addString(rpmTagLicense, "Proprietary")
+	h.addString(rpmTagPackager, nativeMaintainer)
+	h.addI18nString(rpmTagGroup, "default")
+	h.addString(rpmTagOs, "linux")
+	h.addString(rpmTagArch, arch.name)
+	h.addString(rpmTagSourceRpm, fmt.Sprintf("%s-%s-%s.src.rpm", name, version, release))
+	h.addString(rpmTagPayloadFormat, "cpio")
+	h.addString(rpmTagPayloadCompressor, "gzip")
+	h.addString(rpmTagPayloadFlags, "9")
+
+	h.addStringArray(rpmTagProvideName, []string{name})
+	h.addInt32(rpmTagProvideFlags, rpmSenseEqual)
+	h.addStringArray(rpmTagProvideVersion, []string{version + "-" + release})
+
+	requireNames := []string{"rpmlib(CompressedFileNames)", "rpmlib(PayloadFilesHavePrefix)"}
+	requireFlags := []int32{rpmLibDepFlags, rpmLibDepFlags}
+	requireVersions := []string{"3.0.4-1", "4.0-1"}
+
+	// Our script names are the ones pkgbuild uses. Map them to rpm's equivalents.
+	for _, script := range []struct {
+		name    string
+		tag     int32
+		progTag int32
+	}{
+		{"postinstall", rpmTagPostIn, rpmTagPostInProg},
+		{"prerm", rpmTagPreUn, rpmTagPreUnProg},
+	} {
+		contents, err := readScript(po, script.name)
+		if err != nil {
+			return err
+		}
+		if contents == nil {
+			continue
+		}
+		h.addString(script.tag, string(contents))
+		h.addString(script.progTag, rpmDefaultShell)
+		if len(requireNames) == 2 {
+			requireNames = append(requireNames, rpmDefaultShell)
+			requireFlags = append(requireFlags, 0)
+			requireVersions = append(requireVersions, "")
+		}
+	}
+
+	h.addStringArray(rpmTagRequireName, requireNames)
+	h.addInt32(rpmTagRequireFlags, requireFlags...)
+	h.addStringArray(rpmTagRequireVersion, requireVersions)
+
+	if len(f.replaces) > 0 {
+		emptyVersions := make([]string, len(f.replaces))
+		emptyFlags := make([]int32, len(f.replaces))
+		h.addStringArray(rpmTagConflictName, f.replaces)
+		h.addInt32(rpmTagConflictFlags, emptyFlags...)
+		h.addStringArray(rpmTagConflictVersion, emptyVersions)
+		h.addStringArray(rpmTagObsoleteName, f.replaces)
+		h.addInt32(rpmTagObsoleteFlags, emptyFlags...)
+		h.addStringArray(rpmTagObsoleteVersion, emptyVersions)
+	}
+
+	var installedSize int64
+	for _, pf := range contents {
+		if !pf.isSymlink() {
+			installedSize += pf.info.Size()
+		}
+	}
+	h.addInt32(rpmTagSize, int32(installedSize))
+
+	if len(contents) > 0 {
+		addRpmFileTags(h, contents, fileDigests)
+	}
+
+	header := h.bytes(rpmTagHeaderImmutable)
+
+	headerSHA1 := sha1.Sum(header)
+	headerSHA256 := sha256.Sum256(header)
+	headerAndPayloadMD5 := md5.New()
+	headerAndPayloadMD5.Write(header)
+	headerAndPayloadMD5.Write(payload)
+
+	sig := &rpmHeaderBuilder{}
+	sig.addString(rpmSigTagSHA1, hex.EncodeToString(headerSHA1[:]))
+	sig.addString(rpmSigTagSHA256, hex.EncodeToString(headerSHA256[:]))
+	sig.addInt32(rpmSigTagSize, int32(len(header)+len(payload)))
+	sig.addBin(rpmSigTagMD5, headerAndPayloadMD5.Sum(nil))
+	sig.addInt32(rpmSigTagPayloadSize, int32(payloadSize))
+	signature := sig.bytes(rpmTagHeaderSignatures)
+
+	// The signature header is padded to an 8 byte boundary
+	signature = append(signature, make([]byte, (8-len(signature)%8)%8)...)
+
+	for _, b := range [][]byte{rpmLead(name, version, release, arch.num), signature, header, payload} {
+		if _, err := w.Write(b); err != nil {
+			return fmt.Errorf("writing rpm: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// addRpmFileTags adds the file list to the header. rpm stores this as
+// parallel arrays, indexed by file.
+func addRpmFileTags(h *rpmHeaderBuilder, contents []packageFile, fileDigests []string) {
+	var (
+		sizes, mtimes, flags, verifyFlags, devices, inodes, dirIndexes []int32
+		modes, rdevs                                                   []int16
+		linkTos, users, groups, langs, basenames, dirNames             []string
+	)
+
+	dirIndex := make(map[string]int32)
+	for i, pf := range contents {
+		size := pf.info.Size()
+		mode := int16(rpmFileMode(pf.info.Mode()))
+		if pf.isSymlink() {
+			size = int64(len(pf.linkname))
+		}
+
+		dir, base := path.Split(pf.path)
+		if _, ok := dirIndex[dir]; !ok {
+			dirIndex[dir] = int32(len(dirNames))
+			dirNames = append(dirNames, dir)
+		}
+
+		sizes = append(sizes, int32(size))
+		mtimes = append(mtimes, int32(pf.info.ModTime().Unix()))
+		flags = append(flags, 0)
+		verifyFlags = append(verifyFlags, -1)
+		devices = append(devices, 1)
+		inodes = append(inodes, int32(i+1))
+		dirIndexes = append(dirIndexes, dirIndex[dir])
+		modes = append(modes, mode)
+		rdevs = append(rdevs, 0)
+		linkTos = append(linkTos, pf.linkname)
+		users = append(users, "root")
+		groups = append(groups, "root")
+		langs = append(langs, "")
+		basenames = append(basenames, base)
+	}
+
+	h.addInt32(rpmTagFileSizes, sizes...)
+	h.addInt16(rpmTagFileModes, modes...)
+	h.addInt16(rpmTagFileRdevs, rdevs...)
+	h.addInt32(rpmTagFileMtimes, mtimes...)
+	h.addStringArray(rpmTagFileDigests, fileDigests)
+	h.addStringArray(rpmTagFileLinkTos, linkTos)
+	h.addInt32(rpmTagFileFlags, flags...)
+	h.addStringArray(rpmTagFileUserName, users)
+	h.addStringArray(rpmTagFileGroupName, groups)
+	h.addInt32(rpmTagFileVerifyFlags, verifyFlags...)
+	h.addInt32(rpmTagFileDevices, devices...)
+	h.addInt32(rpmTagFileInodes, inodes...)
+	h.addStringArray(rpmTagFileLangs, langs)
+	h.addInt32(rpmTagDirIndexes, dirIndexes...)
+	h.addStringArray(rpmTagBasenames, basenames)
+	h.addStringArray(rpmTagDirNames, dirNames)
+}
+
+// rpmFileMode converts a go file mode to a unix st_mode, as used by
+// both the rpm header and cpio.
+func rpmFileMode(mode fs.FileMode) uint32 {
+	const (
+		unixRegular = 0100000
+		unixSymlink = 0120000
+	)
+
+	if mode&fs.ModeSymlink != 0 {
+		return unixSymlink | uint32(mode.Perm())
+	}
+	return unixRegular | uint32(mode.Perm())
+}
+
+// rpmPayload builds the gzipped cpio payload, returning it along with
+// its uncompressed size and the md5 digest of each file. Links have an
+// empty digest.
+func rpmPayload(contents []packageFile) ([]byte, int64, []string, error) {
+	var buf bytes.Buffer
+	gzw, err := gzip.NewWriterLevel(&buf, gzip.BestCompression)
+	if err != nil {
+		return nil, 0, nil, fmt.Errorf("creating gzip writer: %w", err)
+	}
+
+	counter := &countingWriter{w: gzw}
+	cw := &cpioWriter{w: counter}
+
+	digests := make([]string, len(contents))
+	for i, pf := range contents {
+		entry := cpioEntry{
+			name:  "." + pf.path,
+			ino:   uint32(i + 1),
+			mode:  rpmFileMode(pf.info.Mode()),
+			mtime: uint32(pf.info.ModTime().Unix()),
+		}
+
+		if pf.isSymlink() {
+			entry.size = uint32(len(pf.linkname))
+			if err := cw.writeEntry(entry, strings.NewReader(pf.linkname)); err != nil {
+				return nil, 0, nil, err
+			}
+			continue
+		}
+
+		entry.size = uint32(pf.info.Size())
+		fh, err := os.Open(pf.source)
+		if err != nil {
+			return nil, 0, nil, fmt.Errorf("opening %s: %w", pf.source, err)
+		}
+
+		hash := md5.New()
+		err = cw.writeEntry(entry, io.TeeReader(fh, hash))
+		fh.Close()
+		if err != nil {
+			return nil, 0, nil, err
+		}
+		digests[i] = hex.EncodeToString(hash.Sum(nil))
+	}
+
+	if err := cw.close(); err != nil {
+		return nil, 0, nil, err
+	}
+	if err := gzw.Close(); err != nil {
+		return nil, 0, nil, fmt.Errorf("closing gzip: %w", err)
+	}
+
+	return buf.Bytes(), counter.n, digests, nil
+}
+
+// rpmLead returns the 96 byte lead that begins every rpm. Modern rpm
+// ignores most of it, but it must be present and well formed.
+func rpmLead(name, version, release string, archNum uint16) []byte {
+	lead := make([]byte, 96)
+	copy(lead[0:4], []byte{0xed, 0xab, 0xee, 0xdb})
+	lead[4] = 3                                     // major version
+	lead[5] = 0                                     // minor version
+	binary.BigEndian.PutUint16(lead[6:8], 0)        // binary package
+	binary.BigEndian.PutUint16(lead[8:10], archNum) // architecture
+	copy(lead[10:75], fmt.Sprintf("%s-%s-%s", name, version, release))
+	binary.BigEndian.PutUint16(lead[76:78], 1) // linux
+	binary.BigEndian.PutUint16(lead[78:80], 5) // signature type: header-style
+	return lead
+}
+
+type rpmHeaderEntry struct {
+	tag   int32
+	typ   int32
+	count int32
+	data  []byte
+}
+
+// rpmHeaderBuilder accumulates tags, and serializes them into an rpm header structure.
+type rpmHeaderBuilder struct {
+	entries []rpmHeaderEntry
+}
+
+func (h *rpmHeaderBuilder) addString(tag int32, s string) {
+	h.entries = append(h.entries, rpmHeaderEntry{tag: tag, typ: rpmTypeString, count: 1, data: append([]byte(s), 0)})
+}
+
+func (h *rpmHeaderBuilder) addI18nString(tag int32, s string) {
+	h.entries = append(h.entries, rpmHeaderEntry{tag: tag, typ: rpmTypeI18nString, count: 1, data: append([]byte(s), 0)})
+}
+
+func (h *rpmHeaderBuilder) addStringArray(tag int32, values []string) {
+	var data []byte
+	for _, s := range values {
+		data = append(data, s...)
+		data = append(data, 0)
+	}
+	h.entries = append(h.entries, rpmHeaderEntry{tag: tag, typ: rpmTypeStringArray, count: int32(len(values)), data: data})
+}
+
+func (h *rpmHeaderBuilder) addInt16(tag int32, values ...int16) {
+	data := make([]byte, 2*len(values))
+	for i, v := range values {
+		binary.BigEndian.PutUint16(data[2*i:], uint16(v))
+	}
+	h.entries = append(h.entries, rpmHeaderEntry{tag: tag, typ: rpmTypeInt16, count: int32(len(values)), data: data})
+}
+
+func (h *rpmHeaderBuilder) addInt32(tag int32, values ...int32) {
+	data := make([]byte, 4*len(values))
+	for i, v := range values {
+		binary.BigEndian.PutUint32(data[4*i:], uint32(v))
+	}
+	h.entries = append(h.entries, rpmHeaderEntry{tag: tag, typ: rpmTypeInt32, count: int32(len(values)), data: data})
+}
+
+func (h *rpmHeaderBuilder) addBin(tag int32, b []byte) {
+	h.entries = append(h.entries, rpmHeaderEntry{tag: tag, typ: rpmTypeBin, count: int32(len(b)), data: b})
+}
+
+// bytes serializes the header. The entries are wrapped in a region,
+// identified by regionTag, which rpm uses to determine what parts of
+// the header are covered by the signature.
+func (h *rpmHeaderBuilder) bytes(regionTag int32) []byte {
+	entries := make([]rpmHeaderEntry, len(h.entries))
+	copy(entries, h.entries)
+	sort.SliceStable(entries, func(i, j int) bool { return entries[i].tag < entries[j].tag })
+
+	var index, store bytes.Buffer
+	writeIndex := func(tag, typ, offset, count int32) {
+		_ = binary.Write(&index, binary.BigEndian, []int32{tag, typ, offset, count})
+	}
+
+	for _, e := range entries {
+		// Numeric types are aligned to their size
+		alignment := 1
+		switch e.typ {
+		case rpmTypeInt16:
+			alignment = 2
+		case rpmTypeInt32:
+			alignment = 4
+		}
+		if pad := (alignment - store.Len()%alignment) % alignment; pad > 0 {
+			store.Write(make([]byte, pad))
+		}
+
+		writeIndex(e.tag, e.typ, int32(store.Len()), e.count)
+		store.Write(e.data)
+	}
+
+	// The region trailer goes at the end of the store, and points
+	// back to the start of the index.
+	trailerOffset := int32(store.Len())
+	_ = binary.Write(&store, binary.BigEndian, []int32{regionTag, rpmTypeBin, -16 * int32(len(entries)+1), 16})
+
+	var region bytes.Buffer
+	_ = binary.Write(&region, binary.BigEndian, []int32{regionTag, rpmTypeBin, trailerOffset, 16})
+
+	var out bytes.Buffer
+	out.Write([]byte{0x8e, 0xad, 0xe8, 0x01, 0, 0, 0, 0})
+	_ = binary.Write(&out, binary.BigEndian, uint32(len(entries)+1))
+	_ = binary.Write(&out, binary.BigEndian, uint32(store.Len()))
+	out.Write(region.Bytes())
+	out.Write(index.Bytes())
+	out.Write(store.Bytes())
+
+	return out.Bytes()
+}
+
+type cpioEntry struct {
+	name  string
+	ino   uint32
+	mode  uint32
+	mtime uint32
+	size  uint32
+}
+
+// cpioWriter writes the "new ascii" (newc) cpio format that rpm uses for its payload
+type cpioWriter struct {
+	w       io.Writer
+	written int64
+}
+
+func (c *cpioWriter) write(b []byte) error {
+	n, err := c.w.Write(b)
+	c.written += int64(n)
+	return err
+}
+
+func (c *cpioWriter) pad() error {
+	if pad := (4 - c.written%4) % 4; pad > 0 {
+		return c.write(make([]byte, pad))
+	}
+	return nil
+}
+
+func (c *cpioWriter) writeHeader(e cpioEntry, nlink uint32) error {
+	header := fmt.Sprintf("070701%08x%08x%08x%08x%08x%08x%08x%08x%08x%08x%08x%08x%08x",
+		e.ino, e.mode, 0, 0, nlink, e.mtime, e.size, 0, 0, 0, 0, len(e.name)+1, 0)
+	if err := c.write([]byte(header)); err != nil {
+		return fmt.Errorf("writing cpio header for %s: %w", e.name, err)
+	}
+	if err := c.write(append([]byte(e.name), 0)); err != nil {
+		return fmt.Errorf("writing cpio name for %s: %w", e.name, err)
+	}
+	return c.pad()
+}
+
+func (c *cpioWriter) writeEntry(e cpioEntry, contents io.Reader) error {
+	if err := c.writeHeader(e, 1); err != nil {
+		return err
+	}
+
+	n, err := io.Copy(writerFunc(c.write), contents)
+	if err != nil {
+		return fmt.Errorf("writing cpio contents for %s: %w", e.name, err)
+	}
+	if n != int64(e.size) {
+		return fmt.Errorf("%s changed size while packaging: expected %d bytes, got %d", e.name, e.size, n)
+	}
+
+	return c.pad()
+}
+
+func (c *cpioWriter) close() error {
+	return c.writeHeader(cpioEntry{name: "TRAILER!!!"}, 1)
+}
+
+// writerFunc adapts a function to an io.Writer
+type writerFunc func([]byte) error
+
+func (f writerFunc) Write(b []byte) (int, error) {
+	if err := f(b); err != nil {
+		return 0, err
+	}
+	return len(b), nil
+}
+
+// countingWriter counts the bytes written through it
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(b []byte) (int, error) {
+	n, err := c.w.Write(b)
+	c.n += int64(n)
+	return n, err
+}
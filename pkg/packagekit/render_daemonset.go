@@ -0,0 +1,59 @@
+package packagekit
+
+import (
+	"context"
+	_ "embed"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"text/template"
+
+	"go.opencensus.io/trace"
+)
+
+//go:embed assets/daemonset.yaml
+var daemonSetTemplate []byte
+
+// DaemonSetOptions describe a Kubernetes DaemonSet running a launcher
+// image, as built by PackageOCI.
+type DaemonSetOptions struct {
+	Name             string // DaemonSet name (eg: launcher-kolide-app)
+	Namespace        string
+	Image            string // image reference (eg: registry.example.com/launcher:1.2.3)
+	RootDir          string // launcher's root directory. It's persisted on the host.
+	EnrollSecretName string // optional Kubernetes secret with the enroll secret, in the key `secret`
+	EnrollSecretPath string // where launcher reads the enroll secret
+	MemoryLimit      string // optional (eg: 512Mi)
+	CPULimit         string // optional (eg: 500m)
+}
+
+// RenderDaemonSet renders a DaemonSet manifest that runs launcher on
+// every node, with the host access osquery needs.
+func RenderDaemonSet(ctx context.Context, w io.Writer, opts *DaemonSetOptions) error {
+	_, span := trace.StartSpan(ctx, "packagekit.RenderDaemonSet")
+	defer span.End()
+
+	if opts.Name == "" || opts.Image == "" || opts.RootDir == "" {
+		return errors.New("DaemonSet requires a name, an image, and a root directory")
+	}
+	if opts.EnrollSecretName != "" && opts.EnrollSecretPath == "" {
+		return errors.New("DaemonSet enroll secret requires a path")
+	}
+
+	data := *opts
+	if data.Namespace == "" {
+		data.Namespace = "default"
+	}
+
+	funcsMap := template.FuncMap{
+		// JSON strings are valid YAML, and strconv.Quote is close enough for our values
+		"Quote": strconv.Quote,
+	}
+
+	t, err := template.New("daemonset").Funcs(funcsMap).Parse(string(daemonSetTemplate))
+	if err != nil {
+		return fmt.Errorf("not able to parse DaemonSet template: %w", err)
+	}
+	return t.ExecuteTemplate(w, "daemonset", data)
+}
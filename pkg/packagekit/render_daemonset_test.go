@@ -0,0 +1,97 @@
+package packagekit
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/ghodss/yaml"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRenderDaemonSet(t *testing.T) {
+	t.Parallel()
+
+	var output bytes.Buffer
+	err := RenderDaemonSet(context.TODO(), &output, &DaemonSetOptions{
+		Name:             "launcher-kolide-app",
+		Namespace:        "kolide",
+		Image:            "registry.example.com/launcher:1.2.3",
+		RootDir:          "/var/kolide-app/device.kolide.com-443",
+		EnrollSecretName: "kolide-enroll",
+		EnrollSecretPath: "/etc/kolide-app/secret",
+		MemoryLimit:      "512Mi",
+	})
+	require.NoError(t, err)
+
+	var ds struct {
+		Kind     string
+		Metadata struct {
+			Name      string
+			Namespace string
+		}
+		Spec struct {
+			Template struct {
+				Spec struct {
+					HostPID    bool `json:"hostPID"`
+					Containers []struct {
+						Image           string
+						SecurityContext struct {
+							Privileged bool
+						} `json:"securityContext"`
+						Resources struct {
+							Limits map[string]string
+						}
+						VolumeMounts []struct {
+							Name      string
+							MountPath string `json:"mountPath"`
+						} `json:"volumeMounts"`
+					}
+					Volumes []struct {
+						Name   string
+						Secret *struct {
+							SecretName string `json:"secretName"`
+						}
+					}
+				}
+			}
+		}
+	}
+	require.NoError(t, yaml.Unmarshal(output.Bytes(), &ds), output.String())
+
+	require.Equal(t, "DaemonSet", ds.Kind)
+	require.Equal(t, "kolide", ds.Metadata.Namespace)
+
+	podSpec := ds.Spec.Template.Spec
+	require.True(t, podSpec.HostPID)
+	require.Len(t, podSpec.Containers, 1)
+	require.Equal(t, "registry.example.com/launcher:1.2.3", podSpec.Containers[0].Image)
+	require.True(t, podSpec.Containers[0].SecurityContext.Privileged)
+	require.Equal(t, map[string]string{"memory": "512Mi"}, podSpec.Containers[0].Resources.Limits)
+
+	mounts := make(map[string]string)
+	for _, m := range podSpec.Containers[0].VolumeMounts {
+		mounts[m.Name] = m.MountPath
+	}
+	require.Equal(t, "/var/kolide-app/device.kolide.com-443", mounts["launcher-root"])
+	require.Equal(t, "/host", mounts["host-root"])
+	require.Equal(t, "/etc/kolide-app/secret", mounts["enroll-secret"])
+
+	require.Equal(t, "kolide-enroll", podSpec.Volumes[len(podSpec.Volumes)-1].Secret.SecretName)
+}
+
+func TestRenderDaemonSet_Minimal(t *testing.T) {
+	t.Parallel()
+
+	var output bytes.Buffer
+	require.NoError(t, RenderDaemonSet(context.TODO(), &output, &DaemonSetOptions{
+		Name:    "launcher",
+		Image:   "launcher:latest",
+		RootDir: "/var/launcher",
+	}))
+	require.Contains(t, output.String(), `namespace: "default"`)
+	require.NotContains(t, output.String(), "resources:")
+	require.NotContains(t, output.String(), "enroll-secret")
+
+	require.Error(t, RenderDaemonSet(context.TODO(), &output, &DaemonSetOptions{Name: "launcher"}))
+}
@@ -0,0 +1,69 @@
+package packagekit
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRenderOpenRCEmpty(t *testing.T) {
+	t.Parallel()
+
+	expectedOutputStrings := []string{
+		`name="empty"`,
+	}
+
+	var output bytes.Buffer
+	err := RenderOpenRC(context.TODO(), &output, emptyInitOptions())
+	require.NoError(t, err)
+
+	for _, s := range expectedOutputStrings {
+		require.Contains(t, output.String(), s)
+	}
+}
+
+func TestRenderOpenRCComplex(t *testing.T) {
+	t.Parallel()
+
+	var output bytes.Buffer
+	err := RenderOpenRC(context.TODO(), &output, complexInitOptions())
+	require.NoError(t, err)
+
+	require.Equal(t, expectedComplexOpenRC(), output.String())
+}
+
+func expectedComplexOpenRC() string {
+
+	return `#!/sbin/openrc-run
+
+name="kolide-app"
+description="The Kolide Launcher"
+export KOLIDE_LAUNCHER_ENROLL_SECRET_PATH=/etc/kolide-app/secret
+export KOLIDE_LAUNCHER_HOSTNAME=device.kolide.com:443
+export KOLIDE_LAUNCHER_OSQUERYD_PATH=/usr/local/kolide-app/bin/osqueryd
+export KOLIDE_LAUNCHER_ROOT_DIRECTORY=/var/kolide-app/device.kolide.com-443
+export KOLIDE_LAUNCHER_UPDATE_CHANNEL=nightly
+
+command="/usr/local/kolide-app/bin/launcher"
+command_args="--autoupdate \
+--with_initial_runner"
+
+# supervise-daemon restarts launcher if it exits, much like systemd's
+# Restart=on-failure
+supervisor="supervise-daemon"
+respawn_delay=3
+respawn_max=0
+
+output_log="/var/log/${RC_SVCNAME}.log"
+error_log="/var/log/${RC_SVCNAME}.log"
+
+depend() {
+    need net
+    use dns logger
+    after firewall
+}
+`
+
+}
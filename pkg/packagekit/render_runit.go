@@ -0,0 +1,34 @@
+package packagekit
+
+import (
+	"context"
+	_ "embed"
+	"fmt"
+	"io"
+	"text/template"
+
+	"go.opencensus.io/trace"
+)
+
+//go:embed assets/runit.sh
+var runitTemplate []byte
+
+// RenderRunit renders the `run` script for a runit service
+// directory, as used by Void Linux. runsv supervises the process, so
+// the script must exec launcher in the foreground.
+func RenderRunit(ctx context.Context, w io.Writer, initOptions *InitOptions) error {
+	_, span := trace.StartSpan(ctx, "packagekit.RenderRunit")
+	defer span.End()
+
+	var data = struct {
+		Common InitOptions
+	}{
+		Common: *initOptions,
+	}
+
+	t, err := template.New("runit").Parse(string(runitTemplate))
+	if err != nil {
+		return fmt.Errorf("not able to parse runit template: %w", err)
+	}
+	return t.ExecuteTemplate(w, "runit", data)
+}
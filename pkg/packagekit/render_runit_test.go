@@ -0,0 +1,55 @@
+package packagekit
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRenderRunitEmpty(t *testing.T) {
+	t.Parallel()
+
+	expectedOutputStrings := []string{
+		`exec /dev/null`,
+	}
+
+	var output bytes.Buffer
+	err := RenderRunit(context.TODO(), &output, emptyInitOptions())
+	require.NoError(t, err)
+
+	for _, s := range expectedOutputStrings {
+		require.Contains(t, output.String(), s)
+	}
+}
+
+func TestRenderRunitComplex(t *testing.T) {
+	t.Parallel()
+
+	var output bytes.Buffer
+	err := RenderRunit(context.TODO(), &output, complexInitOptions())
+	require.NoError(t, err)
+
+	require.Equal(t, expectedComplexRunit(), output.String())
+}
+
+func expectedComplexRunit() string {
+
+	return `#!/bin/sh
+#
+# runit service for The Kolide Launcher (kolide-app)
+
+exec 2>&1
+export KOLIDE_LAUNCHER_ENROLL_SECRET_PATH=/etc/kolide-app/secret
+export KOLIDE_LAUNCHER_HOSTNAME=device.kolide.com:443
+export KOLIDE_LAUNCHER_OSQUERYD_PATH=/usr/local/kolide-app/bin/osqueryd
+export KOLIDE_LAUNCHER_ROOT_DIRECTORY=/var/kolide-app/device.kolide.com-443
+export KOLIDE_LAUNCHER_UPDATE_CHANNEL=nightly
+
+exec /usr/local/kolide-app/bin/launcher \
+    --autoupdate \
+    --with_initial_runner
+`
+
+}
@@ -0,0 +1,34 @@
+package packagekit
+
+import (
+	"context"
+	_ "embed"
+	"fmt"
+	"io"
+	"text/template"
+
+	"go.opencensus.io/trace"
+)
+
+//go:embed assets/s6.sh
+var s6Template []byte
+
+// RenderS6 renders the execline `run` script for an s6 service
+// directory. The same directory works with a plain s6-svscan scan
+// directory, and as an s6-rc longrun source definition.
+func RenderS6(ctx context.Context, w io.Writer, initOptions *InitOptions) error {
+	_, span := trace.StartSpan(ctx, "packagekit.RenderS6")
+	defer span.End()
+
+	var data = struct {
+		Common InitOptions
+	}{
+		Common: *initOptions,
+	}
+
+	t, err := template.New("s6").Parse(string(s6Template))
+	if err != nil {
+		return fmt.Errorf("not able to parse s6 template: %w", err)
+	}
+	return t.ExecuteTemplate(w, "s6", data)
+}
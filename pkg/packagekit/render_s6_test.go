@@ -0,0 +1,54 @@
+package packagekit
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRenderS6Empty(t *testing.T) {
+	t.Parallel()
+
+	expectedOutputStrings := []string{
+		`#!/bin/execlineb -P`,
+	}
+
+	var output bytes.Buffer
+	err := RenderS6(context.TODO(), &output, emptyInitOptions())
+	require.NoError(t, err)
+
+	for _, s := range expectedOutputStrings {
+		require.Contains(t, output.String(), s)
+	}
+}
+
+func TestRenderS6Complex(t *testing.T) {
+	t.Parallel()
+
+	var output bytes.Buffer
+	err := RenderS6(context.TODO(), &output, complexInitOptions())
+	require.NoError(t, err)
+
+	require.Equal(t, expectedComplexS6(), output.String())
+}
+
+func expectedComplexS6() string {
+
+	return `#!/bin/execlineb -P
+#
+# s6 service for The Kolide Launcher (kolide-app)
+
+fdmove -c 2 1
+export KOLIDE_LAUNCHER_ENROLL_SECRET_PATH /etc/kolide-app/secret
+export KOLIDE_LAUNCHER_HOSTNAME device.kolide.com:443
+export KOLIDE_LAUNCHER_OSQUERYD_PATH /usr/local/kolide-app/bin/osqueryd
+export KOLIDE_LAUNCHER_ROOT_DIRECTORY /var/kolide-app/device.kolide.com-443
+export KOLIDE_LAUNCHER_UPDATE_CHANNEL nightly
+/usr/local/kolide-app/bin/launcher
+    --autoupdate
+    --with_initial_runner
+`
+
+}
@@ -10,19 +10,132 @@ import (
 	"go.opencensus.io/trace"
 )
 
+// defaultCapabilityBoundingSet is the set of capabilities launcher
+// and osquery need when hardening is enabled. osquery reads other
+// processes' state, and launcher drops to the console user to run
+// desktop, so this is still fairly broad.
+var defaultCapabilityBoundingSet = []string{
+	"CAP_AUDIT_CONTROL",
+	"CAP_AUDIT_READ",
+	"CAP_CHOWN",
+	"CAP_DAC_OVERRIDE",
+	"CAP_DAC_READ_SEARCH",
+	"CAP_FOWNER",
+	"CAP_KILL",
+	"CAP_NET_ADMIN",
+	"CAP_NET_RAW",
+	"CAP_SETGID",
+	"CAP_SETUID",
+	"CAP_SYS_ADMIN",
+	"CAP_SYS_PTRACE",
+	"CAP_SYS_RESOURCE",
+}
+
 type systemdOptions struct {
 	Restart    string
 	RestartSec int
+
+	Hardening             bool
+	CapabilityBoundingSet []string
+	ReadWritePaths        []string
+
+	MemoryMax string
+	CPUQuota  string
+	IOWeight  int
+
+	WatchdogSec int
+}
+
+type SystemdOption func(*systemdOptions)
+
+// WithHardening enables systemd's sandboxing options. /usr, /boot
+// and /etc are mounted read only (ProtectSystem=full), except for
+// readWritePaths, which must include any of launcher's directories
+// under them, such as the ones autoupdate writes to. /var, /tmp and
+// home directories stay writable: autoupdate stages updates in the
+// temp directory, and launcher desktop runs as the console user and
+// needs its session.
+func WithHardening(readWritePaths []string) SystemdOption {
+	return func(so *systemdOptions) {
+		so.Hardening = true
+		so.ReadWritePaths = readWritePaths
+	}
+}
+
+// WithCapabilityBoundingSet replaces the default capability bounding
+// set used when hardening is enabled.
+func WithCapabilityBoundingSet(caps []string) SystemdOption {
+	return func(so *systemdOptions) {
+		so.CapabilityBoundingSet = caps
+	}
+}
+
+// WithMemoryMax sets MemoryMax, eg: `512M`.
+func WithMemoryMax(s string) SystemdOption {
+	return func(so *systemdOptions) {
+		so.MemoryMax = s
+	}
 }
 
-func RenderSystemd(ctx context.Context, w io.Writer, initOptions *InitOptions) error {
+// WithCPUQuota sets CPUQuota, eg: `20%`.
+func WithCPUQuota(s string) SystemdOption {
+	return func(so *systemdOptions) {
+		so.CPUQuota = s
+	}
+}
+
+// WithIOWeight sets IOWeight, between 1 and 10000. The systemd default is 100.
+func WithIOWeight(weight int) SystemdOption {
+	return func(so *systemdOptions) {
+		so.IOWeight = weight
+	}
+}
+
+// WithWatchdog sets WatchdogSec. launcher pings the watchdog over
+// sd_notify, and systemd restarts it if the pings stop.
+func WithWatchdog(seconds int) SystemdOption {
+	return func(so *systemdOptions) {
+		so.WatchdogSec = seconds
+	}
+}
+
+// systemdServiceTemplate holds the tunable [Service] directives. It's
+// shared between the unit and drop-ins.
+const systemdServiceTemplate = `
+{{- define "tunables" }}
+{{- if .Opts.WatchdogSec }}
+NotifyAccess=main
+WatchdogSec={{.Opts.WatchdogSec}}
+{{- end }}
+{{- if .Opts.MemoryMax }}
+MemoryMax={{.Opts.MemoryMax}}
+{{- end }}
+{{- if .Opts.CPUQuota }}
+CPUQuota={{.Opts.CPUQuota}}
+{{- end }}
+{{- if .Opts.IOWeight }}
+IOWeight={{.Opts.IOWeight}}
+{{- end }}
+{{- if .Opts.Hardening }}
+ProtectSystem=full
+{{- if .Opts.ReadWritePaths }}
+ReadWritePaths={{ StringsJoin .Opts.ReadWritePaths " " }}
+{{- end }}
+NoNewPrivileges=true
+ProtectKernelTunables=true
+ProtectControlGroups=true
+RestrictRealtime=true
+RestrictSUIDSGID=true
+LockPersonality=true
+CapabilityBoundingSet={{ StringsJoin .Opts.CapabilityBoundingSet " " }}
+{{- end }}
+{{- end }}`
+
+func RenderSystemd(ctx context.Context, w io.Writer, initOptions *InitOptions, opts ...SystemdOption) error {
 	_, span := trace.StartSpan(ctx, "packagekit.Systemd")
 	defer span.End()
 
-	sOpts := &systemdOptions{
-		Restart:    "on-failure",
-		RestartSec: 3,
-	}
+	sOpts := newSystemdOptions(opts...)
 
 	// Prepend a "" so that the merged output looks a bit cleaner in the systemd file
 	if len(initOptions.Flags) > 0 {
@@ -41,6 +154,7 @@ Environment={{$key}}={{$value}}
 ExecStart={{.Common.Path}}{{ StringsJoin .Common.Flags " \\\n" }}
 Restart={{.Opts.Restart}}
 RestartSec={{.Opts.RestartSec}}
+{{- template "tunables" . }}
 
 [Install]
 WantedBy=multi-user.target`
@@ -53,14 +167,58 @@ WantedBy=multi-user.target`
 		Opts:   *sOpts,
 	}
 
+	t, err := parseSystemdTemplate("SystemdUnit", systemdTemplate)
+	if err != nil {
+		return fmt.Errorf("not able to parse Systemd Unit template: %w", err)
+	}
+	return t.ExecuteTemplate(w, "SystemdUnit", data)
+
+}
+
+// RenderSystemdDropIn renders a drop-in containing only the tunable
+// [Service] directives. It's suitable for
+// `/etc/systemd/system/<unit>.service.d/`, where an administrator
+// can adjust it without replacing the packaged unit.
+func RenderSystemdDropIn(ctx context.Context, w io.Writer, opts ...SystemdOption) error {
+	_, span := trace.StartSpan(ctx, "packagekit.SystemdDropIn")
+	defer span.End()
+
+	var data = struct {
+		Opts systemdOptions
+	}{
+		Opts: *newSystemdOptions(opts...),
+	}
+
+	t, err := parseSystemdTemplate("SystemdDropIn", "[Service]{{- template \"tunables\" . }}\n")
+	if err != nil {
+		return fmt.Errorf("not able to parse Systemd drop-in template: %w", err)
+	}
+	return t.ExecuteTemplate(w, "SystemdDropIn", data)
+}
+
+// parseSystemdTemplate parses text along with the shared tunables template.
+func parseSystemdTemplate(name string, text string) (*template.Template, error) {
 	funcsMap := template.FuncMap{
 		"StringsJoin": strings.Join,
 	}
 
-	t, err := template.New("SystemdUnit").Funcs(funcsMap).Parse(systemdTemplate)
+	t, err := template.New(name).Funcs(funcsMap).Parse(systemdServiceTemplate)
 	if err != nil {
-		return fmt.Errorf("not able to parse Systemd Unit template: %w", err)
+		return nil, err
+	}
+	return t.Parse(text)
+}
+
+func newSystemdOptions(opts ...SystemdOption) *systemdOptions {
+	sOpts := &systemdOptions{
+		Restart:               "on-failure",
+		RestartSec:            3,
+		CapabilityBoundingSet: defaultCapabilityBoundingSet,
+	}
+
+	for _, opt := range opts {
+		opt(sOpts)
 	}
-	return t.ExecuteTemplate(w, "SystemdUnit", data)
 
+	return sOpts
 }
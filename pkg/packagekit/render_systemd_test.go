@@ -45,6 +45,33 @@ func TestRenderSystemdComplex(t *testing.T) {
 	require.Equal(t, expectedComplexUnit(), output.String())
 }
 
+func TestRenderSystemdHardened(t *testing.T) {
+	t.Parallel()
+
+	var output bytes.Buffer
+	err := RenderSystemd(context.TODO(), &output, complexInitOptions(),
+		WithHardening([]string{"/var/kolide-app", "/usr/local/kolide-app"}),
+		WithCapabilityBoundingSet([]string{"CAP_DAC_READ_SEARCH", "CAP_SYS_PTRACE"}),
+		WithMemoryMax("512M"),
+		WithCPUQuota("20%"),
+		WithIOWeight(50),
+		WithWatchdog(60),
+	)
+	require.NoError(t, err)
+
+	require.Equal(t, expectedHardenedUnit(), output.String())
+}
+
+func TestRenderSystemdDropIn(t *testing.T) {
+	t.Parallel()
+
+	var output bytes.Buffer
+	err := RenderSystemdDropIn(context.TODO(), &output, WithMemoryMax("1G"), WithIOWeight(10))
+	require.NoError(t, err)
+
+	require.Equal(t, "[Service]\nMemoryMax=1G\nIOWeight=10\n", output.String())
+}
+
 func expectedComplexUnit() string {
 
 	return `[Unit]
@@ -67,3 +94,40 @@ RestartSec=3
 WantedBy=multi-user.target`
 
 }
+
+func expectedHardenedUnit() string {
+
+	return `[Unit]
+Description=The Kolide Launcher
+After=network.service syslog.service
+
+[Service]
+Environment=KOLIDE_LAUNCHER_ENROLL_SECRET_PATH=/etc/kolide-app/secret
+Environment=KOLIDE_LAUNCHER_HOSTNAME=device.kolide.com:443
+Environment=KOLIDE_LAUNCHER_OSQUERYD_PATH=/usr/local/kolide-app/bin/osqueryd
+Environment=KOLIDE_LAUNCHER_ROOT_DIRECTORY=/var/kolide-app/device.kolide.com-443
+Environment=KOLIDE_LAUNCHER_UPDATE_CHANNEL=nightly
+ExecStart=/usr/local/kolide-app/bin/launcher \
+--autoupdate \
+--with_initial_runner
+Restart=on-failure
+RestartSec=3
+NotifyAccess=main
+WatchdogSec=60
+MemoryMax=512M
+CPUQuota=20%
+IOWeight=50
+ProtectSystem=full
+ReadWritePaths=/var/kolide-app /usr/local/kolide-app
+NoNewPrivileges=true
+ProtectKernelTunables=true
+ProtectControlGroups=true
+RestrictRealtime=true
+RestrictSUIDSGID=true
+LockPersonality=true
+CapabilityBoundingSet=CAP_DAC_READ_SEARCH CAP_SYS_PTRACE
+
+[Install]
+WantedBy=multi-user.target`
+
+}
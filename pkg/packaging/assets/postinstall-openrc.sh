@@ -0,0 +1,12 @@
+#!/bin/sh
+
+if [ ! -z "{{.InfoFilename}}" ]; then
+    cat <<EOF > "{{.InfoFilename}}"
+{{.InfoJson}}
+EOF
+fi
+
+set -e
+
+rc-update add launcher.{{.Identifier}} default
+rc-service launcher.{{.Identifier}} restart
@@ -0,0 +1,21 @@
+#!/bin/sh
+
+if [ ! -z "{{.InfoFilename}}" ]; then
+    cat <<EOF > "{{.InfoFilename}}"
+{{.InfoJson}}
+EOF
+fi
+
+# Distros disagree on where runsvdir looks for enabled services. Void
+# uses /var/service, Artix /etc/runit/runsvdir/default, and most others
+# /etc/service. Link into the first one that exists.
+for svdir in /var/service /etc/runit/runsvdir/default /etc/service; do
+    if [ -d "$svdir" ]; then
+        ln -sfn "/etc/sv/launcher.{{.Identifier}}" "$svdir/launcher.{{.Identifier}}"
+        break
+    fi
+done
+
+# runsvdir picks up new services within 5 seconds. If it already has,
+# restart so an upgrade runs the new binary.
+sv restart launcher.{{.Identifier}} >/dev/null 2>&1 || true
@@ -0,0 +1,21 @@
+#!/bin/sh
+
+if [ ! -z "{{.InfoFilename}}" ]; then
+    cat <<EOF > "{{.InfoFilename}}"
+{{.InfoJson}}
+EOF
+fi
+
+# Link into a running s6-svscan's scan directory, if we can find one.
+# s6-rc users should instead add /etc/s6/sv/launcher.{{.Identifier}} to
+# their source directory and recompile.
+for scandir in /run/service /service; do
+    if [ -d "$scandir" ]; then
+        ln -sfn "/etc/s6/sv/launcher.{{.Identifier}}" "$scandir/launcher.{{.Identifier}}"
+        s6-svscanctl -an "$scandir" || true
+        s6-svc -r "$scandir/launcher.{{.Identifier}}" || true
+        exit 0
+    fi
+done
+
+echo "No s6 scan directory found, enable /etc/s6/sv/launcher.{{.Identifier}} manually"
@@ -0,0 +1,57 @@
+package packaging
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+
+	"github.com/kolide/launcher/pkg/packagekit"
+)
+
+// DaemonSetOptions are the deployment specific settings for a
+// Kubernetes DaemonSet running a launcher image.
+type DaemonSetOptions struct {
+	Image            string // image reference, as pushed to a registry
+	Namespace        string
+	EnrollSecretName string // Kubernetes secret holding the enroll secret. Use with OmitSecret.
+	MemoryLimit      string
+	CPULimit         string
+}
+
+// RenderDaemonSet writes a Kubernetes DaemonSet manifest for an image
+// built from these PackageOptions, with the linux-none-oci target.
+// It needs the same Hostname and Identifier the image was built with,
+// so that the host paths match the baked in flags.
+func (p *PackageOptions) RenderDaemonSet(ctx context.Context, w io.Writer, opts DaemonSetOptions) error {
+	if p.Hostname == "" {
+		return errors.New("hostname is required")
+	}
+
+	identifier := p.Identifier
+	if identifier == "" {
+		identifier = "launcher"
+	}
+
+	// These must match setupDirectories for linux
+	rootDir := filepath.Join("/var", identifier, sanitizeHostname(p.Hostname))
+	confDir := filepath.Join("/etc", identifier)
+
+	dsOpts := &packagekit.DaemonSetOptions{
+		Name:             fmt.Sprintf("launcher-%s", identifier),
+		Namespace:        opts.Namespace,
+		Image:            opts.Image,
+		RootDir:          filepath.ToSlash(rootDir),
+		EnrollSecretName: opts.EnrollSecretName,
+		EnrollSecretPath: filepath.ToSlash(filepath.Join(confDir, "secret")),
+		MemoryLimit:      opts.MemoryLimit,
+		CPULimit:         opts.CPULimit,
+	}
+
+	if err := packagekit.RenderDaemonSet(ctx, w, dsOpts); err != nil {
+		return fmt.Errorf("rendering DaemonSet: %w", err)
+	}
+
+	return nil
+}
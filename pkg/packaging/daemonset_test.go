@@ -0,0 +1,27 @@
+package packaging
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRenderDaemonSet(t *testing.T) {
+	t.Parallel()
+
+	p := &PackageOptions{Hostname: "device.kolide.com:443", Identifier: "kolide-app"}
+
+	var out bytes.Buffer
+	require.NoError(t, p.RenderDaemonSet(context.TODO(), &out, DaemonSetOptions{
+		Image:            "registry.example.com/launcher:1.2.3",
+		EnrollSecretName: "kolide-enroll",
+	}))
+
+	require.Contains(t, out.String(), `name: "launcher-kolide-app"`)
+	require.Contains(t, out.String(), `mountPath: "/var/kolide-app/device.kolide.com-443"`)
+	require.Contains(t, out.String(), `mountPath: "/etc/kolide-app/secret"`)
+
+	require.Error(t, (&PackageOptions{}).RenderDaemonSet(context.TODO(), &out, DaemonSetOptions{Image: "launcher"}))
+}
@@ -43,11 +43,8 @@ func FetchBinary(ctx context.Context, localCacheDir, name, binaryName, version s
 		return localBinaryPath, nil
 	}
 
-	// If not we have to download the package. First, create download
-	// URI. Notary stores things by name, sans extension. So just strip
-	// it off.
-	baseName := strings.TrimSuffix(name, filepath.Ext(name))
-	url := fmt.Sprintf("https://dl.kolide.co/%s", dlTarPath(baseName, version, string(target.Platform)))
+	// If not we have to download the package.
+	url := dlURL(name, version, target)
 
 	level.Debug(logger).Log(
 		"msg", "starting download",
@@ -108,6 +105,13 @@ func FetchBinary(ctx context.Context, localCacheDir, name, binaryName, version s
 	return localBinaryPath, nil
 }
 
+// dlURL returns the download URL for a binary. Notary stores things
+// by name, sans extension.
+func dlURL(name, version string, target Target) string {
+	baseName := strings.TrimSuffix(name, filepath.Ext(name))
+	return fmt.Sprintf("https://dl.kolide.co/%s", dlTarPath(baseName, version, string(target.Platform)))
+}
+
 func dlTarPath(name, version, platform string) string {
 	return path.Join("kolide", name, platform, fmt.Sprintf("%s-%s.tar.gz", name, version))
 }
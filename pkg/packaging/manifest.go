@@ -0,0 +1,418 @@
+package packaging
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/ghodss/yaml"
+)
+
+// Manifest describes a set of packages to build in a single run. It
+// is read from YAML or JSON, and is the declarative equivalent of
+// invoking `package-builder make` once per build.
+type Manifest struct {
+	OutputDir string          `json:"output_dir"`
+	CacheDir  string          `json:"cache_dir"`
+	Builds    []BuildManifest `json:"builds"`
+}
+
+// BuildManifest is a single build within a manifest. It produces one
+// package per target. Overrides are keyed by either a full target
+// (eg: `linux-systemd-deb`) or a platform (eg: `linux`), and replace
+// any options they set. Platform overrides are applied before
+// target overrides.
+type BuildManifest struct {
+	Name      string                     `json:"name"`
+	Targets   []string                   `json:"targets"`
+	Overrides map[string]json.RawMessage `json:"overrides"`
+	BuildOptions
+}
+
+// BuildOptions are the options for a build that can be overridden
+// per target.
+type BuildOptions struct {
+	Hostname          string         `json:"hostname"`
+	EnrollSecret      string         `json:"enroll_secret"`
+	OmitSecret        bool           `json:"omit_secret"`
+	Identifier        string         `json:"identifier"`
+	Title             string         `json:"title"`
+	PackageVersion    string         `json:"package_version"`
+	OsqueryVersion    string         `json:"osquery_version"`
+	LauncherVersion   string         `json:"launcher_version"`
+	ExtensionVersion  string         `json:"extension_version"`
+	OsqueryFlags      []string       `json:"osquery_flags"`
+	Transport         string         `json:"transport"`
+	Insecure          bool           `json:"insecure"`
+	InsecureTransport bool           `json:"insecure_transport"`
+	UpdateChannel     string         `json:"update_channel"`
+	InitialRunner     bool           `json:"initial_runner"`
+	CertPins          string         `json:"cert_pins"`
+	RootPEM           string         `json:"root_pem"`
+	NotaryURL         string         `json:"notary_url"`
+	MirrorURL         string         `json:"mirror_url"`
+	NotaryPrefix      string         `json:"notary_prefix"`
+	DisableService    bool           `json:"disable_service"`
+	NativePackaging   bool           `json:"native_packaging"`
+	WixPath           string         `json:"wix_path"`
+	MSIUI             bool           `json:"msi_ui"`
+	WixSkipCleanup    bool           `json:"wix_skip_cleanup"`
+	Signing           SigningConfig  `json:"signing"`
+	ExtraFiles        []ExtraFile    `json:"extra_files"`
+	Systemd           SystemdOptions `json:"systemd"`
+}
+
+// SigningConfig holds the signing identities for a build.
+type SigningConfig struct {
+	AppleSigningKey          string   `json:"apple_signing_key"`
+	AppleNotarizeAccountId   string   `json:"apple_notarize_account_id"`
+	AppleNotarizeUserId      string   `json:"apple_notarize_user_id"`
+	AppleNotarizeAppPassword string   `json:"apple_notarize_app_password"`
+	WindowsUseSigntool       bool     `json:"windows_use_signtool"`
+	WindowsSigntoolArgs      []string `json:"windows_signtool_args"`
+	ProvenanceSigningKey     string   `json:"provenance_signing_key"` // PEM private key to sign build provenance with
+}
+
+// ExtraFile is an additional file to include in the package.
+// Destination is the path within the package root. For linux and
+// darwin, this is the path it will be installed to.
+type ExtraFile struct {
+	Source      string `json:"source"`
+	Destination string `json:"destination"`
+	Mode        string `json:"mode,omitempty"` // octal, defaults to 0644
+}
+
+var (
+	buildNameRegex   = regexp.MustCompile(`^[A-Za-z0-9._-]+$`)
+	manifestEnvRegex = regexp.MustCompile(`\$\{[A-Za-z_][A-Za-z0-9_]*\}`)
+)
+
+// LoadManifest reads and validates a manifest from disk. Both YAML and
+// JSON are accepted. Environment variables in the manifest's string
+// values, written as `${VAR}`, are expanded after parsing, so that
+// secrets need not be stored in it.
+func LoadManifest(manifestPath string) (*Manifest, error) {
+	raw, err := os.ReadFile(manifestPath)
+	if err != nil {
+		return nil, fmt.Errorf("reading manifest: %w", err)
+	}
+
+	return ParseManifest(raw)
+}
+
+// ParseManifest parses and validates a YAML or JSON manifest.
+func ParseManifest(raw []byte) (*Manifest, error) {
+	// YAML is a superset of JSON, so this handles both
+	jsonBytes, err := yaml.YAMLToJSON(raw)
+	if err != nil {
+		return nil, fmt.Errorf("parsing manifest: %w", err)
+	}
+
+	// Expand environment variables only once the manifest is parsed, so that their values
+	// can't change its structure
+	expandedBytes, err := expandManifestEnv(jsonBytes)
+	if err != nil {
+		return nil, fmt.Errorf("expanding environment variables in manifest: %w", err)
+	}
+
+	var m Manifest
+	if err := strictUnmarshal(expandedBytes, &m); err != nil {
+		return nil, fmt.Errorf("parsing manifest: %w", err)
+	}
+
+	if err := m.validate(); err != nil {
+		return nil, fmt.Errorf("invalid manifest: %w", err)
+	}
+
+	return &m, nil
+}
+
+// expandManifestEnv expands `${VAR}` in every string value of the given JSON document. Only
+// the braced form is expanded, so that a bare `$` (eg: in an osquery flag) is left alone. It's
+// an error to reference an unset variable, since that would otherwise quietly produce, eg, a
+// package without an enroll secret.
+func expandManifestEnv(jsonBytes []byte) ([]byte, error) {
+	decoder := json.NewDecoder(bytes.NewReader(jsonBytes))
+	decoder.UseNumber()
+
+	var doc interface{}
+	if err := decoder.Decode(&doc); err != nil {
+		return nil, err
+	}
+
+	unset := make(map[string]bool)
+	doc = expandEnvInValues(doc, unset)
+
+	if len(unset) > 0 {
+		unsetNames := make([]string, 0, len(unset))
+		for name := range unset {
+			unsetNames = append(unsetNames, name)
+		}
+		sort.Strings(unsetNames)
+		return nil, fmt.Errorf("environment variables not set: %s", strings.Join(unsetNames, ", "))
+	}
+
+	return json.Marshal(doc)
+}
+
+// expandEnvInValues expands environment variables in v, recording the names of any that are unset
+func expandEnvInValues(v interface{}, unset map[string]bool) interface{} {
+	switch typed := v.(type) {
+	case string:
+		return manifestEnvRegex.ReplaceAllStringFunc(typed, func(match string) string {
+			name := match[2 : len(match)-1]
+			val, ok := os.LookupEnv(name)
+			if !ok {
+				unset[name] = true
+			}
+			return val
+		})
+	case []interface{}:
+		for i := range typed {
+			typed[i] = expandEnvInValues(typed[i], unset)
+		}
+		return typed
+	case map[string]interface{}:
+		for k := range typed {
+			typed[k] = expandEnvInValues(typed[k], unset)
+		}
+		return typed
+	default:
+		return v
+	}
+}
+
+func (m *Manifest) validate() error {
+	if len(m.Builds) == 0 {
+		return errors.New("no builds defined")
+	}
+
+	seenNames := make(map[string]bool)
+	for i, b := range m.Builds {
+		if b.Name == "" {
+			return fmt.Errorf("build %d: name is required", i)
+		}
+		if !buildNameRegex.MatchString(b.Name) {
+			return fmt.Errorf("build %s: name may only contain letters, numbers, '.', '_' and '-'", b.Name)
+		}
+		if seenNames[b.Name] {
+			return fmt.Errorf("build %s: duplicate build name", b.Name)
+		}
+		seenNames[b.Name] = true
+
+		if err := b.validate(); err != nil {
+			return fmt.Errorf("build %s: %w", b.Name, err)
+		}
+	}
+
+	return nil
+}
+
+func (b *BuildManifest) validate() error {
+	if len(b.Targets) == 0 {
+		return errors.New("no targets defined")
+	}
+
+	platforms := make(map[string]bool)
+	targets := make(map[string]bool)
+	for _, t := range b.Targets {
+		target := Target{}
+		if err := target.Parse(t); err != nil {
+			return fmt.Errorf("target %s: %w", t, err)
+		}
+		if targets[target.String()] {
+			return fmt.Errorf("target %s: duplicate target", t)
+		}
+		targets[target.String()] = true
+		platforms[string(target.Platform)] = true
+	}
+
+	for key, override := range b.Overrides {
+		if !targets[key] && !platforms[key] {
+			return fmt.Errorf("override %s does not match any target or platform in this build", key)
+		}
+
+		var overrideOpts BuildOptions
+		if err := strictUnmarshal(override, &overrideOpts); err != nil {
+			return fmt.Errorf("override %s: %w", key, err)
+		}
+	}
+
+	// Validate the options as they'll be used for each target, so that
+	// overrides are checked too.
+	for _, t := range b.Targets {
+		opts, err := b.OptionsFor(t)
+		if err != nil {
+			return err
+		}
+		if err := opts.validate(); err != nil {
+			return fmt.Errorf("target %s: %w", t, err)
+		}
+	}
+
+	return nil
+}
+
+func (o *BuildOptions) validate() error {
+	if o.Hostname == "" {
+		return errors.New("hostname is required")
+	}
+
+	if o.CertPins != "" {
+		for _, pin := range strings.Split(o.CertPins, ",") {
+			if _, err := hex.DecodeString(pin); err != nil {
+				return fmt.Errorf("unable to parse cert pins: %w", err)
+			}
+		}
+	}
+
+	for _, f := range o.ExtraFiles {
+		if f.Source == "" || f.Destination == "" {
+			return errors.New("extra files require both a source and a destination")
+		}
+		if _, err := os.Stat(f.Source); err != nil {
+			return fmt.Errorf("extra file %s: %w", f.Source, err)
+		}
+		if path.Clean("/"+f.Destination) != "/"+strings.TrimPrefix(f.Destination, "/") {
+			return fmt.Errorf("extra file destination %s must be a clean path within the package", f.Destination)
+		}
+		if _, err := f.fileMode(); err != nil {
+			return fmt.Errorf("extra file %s: %w", f.Source, err)
+		}
+	}
+
+	if o.RootPEM != "" {
+		if _, err := os.Stat(o.RootPEM); err != nil {
+			return fmt.Errorf("root_pem: %w", err)
+		}
+	}
+
+	if err := o.Systemd.validate(); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// OptionsFor returns the build options for the given target, with
+// platform and target overrides applied.
+func (b *BuildManifest) OptionsFor(targetString string) (BuildOptions, error) {
+	target := Target{}
+	if err := target.Parse(targetString); err != nil {
+		return BuildOptions{}, fmt.Errorf("parsing target %s: %w", targetString, err)
+	}
+
+	// Round trip through json to get a deep copy that overrides can be unmarshalled onto
+	base, err := json.Marshal(b.BuildOptions)
+	if err != nil {
+		return BuildOptions{}, fmt.Errorf("copying build options: %w", err)
+	}
+	var opts BuildOptions
+	if err := json.Unmarshal(base, &opts); err != nil {
+		return BuildOptions{}, fmt.Errorf("copying build options: %w", err)
+	}
+
+	for _, key := range []string{string(target.Platform), target.String()} {
+		override, ok := b.Overrides[key]
+		if !ok {
+			continue
+		}
+
+		// Fields set in the override replace the existing values. Slices are replaced, not appended to.
+		if err := json.Unmarshal(override, &opts); err != nil {
+			return BuildOptions{}, fmt.Errorf("applying override %s: %w", key, err)
+		}
+	}
+
+	return opts, nil
+}
+
+// PackageOptionsFor returns the PackageOptions to build the given target.
+func (b *BuildManifest) PackageOptionsFor(targetString string, cacheDir string) (*PackageOptions, error) {
+	opts, err := b.OptionsFor(targetString)
+	if err != nil {
+		return nil, err
+	}
+
+	// Match the defaults of `package-builder make`
+	defaultString := func(v, d string) string {
+		if v == "" {
+			return d
+		}
+		return v
+	}
+
+	extraFiles := make([]ExtraFile, len(opts.ExtraFiles))
+	copy(extraFiles, opts.ExtraFiles)
+
+	return &PackageOptions{
+		PackageVersion:           opts.PackageVersion,
+		OsqueryVersion:           defaultString(opts.OsqueryVersion, "stable"),
+		OsqueryFlags:             opts.OsqueryFlags,
+		LauncherVersion:          defaultString(opts.LauncherVersion, "stable"),
+		ExtensionVersion:         defaultString(opts.ExtensionVersion, "stable"),
+		Hostname:                 opts.Hostname,
+		Secret:                   opts.EnrollSecret,
+		Transport:                opts.Transport,
+		Insecure:                 opts.Insecure,
+		InsecureTransport:        opts.InsecureTransport,
+		UpdateChannel:            opts.UpdateChannel,
+		InitialRunner:            opts.InitialRunner,
+		Identifier:               defaultString(opts.Identifier, "launcher"),
+		Title:                    opts.Title,
+		OmitSecret:               opts.OmitSecret,
+		CertPins:                 opts.CertPins,
+		RootPEM:                  opts.RootPEM,
+		CacheDir:                 cacheDir,
+		NotaryURL:                opts.NotaryURL,
+		MirrorURL:                opts.MirrorURL,
+		NotaryPrefix:             opts.NotaryPrefix,
+		WixPath:                  opts.WixPath,
+		MSIUI:                    opts.MSIUI,
+		WixSkipCleanup:           opts.WixSkipCleanup,
+		DisableService:           opts.DisableService,
+		NativePackaging:          opts.NativePackaging,
+		ExtraFiles:               extraFiles,
+		Systemd:                  opts.Systemd,
+		AppleSigningKey:          opts.Signing.AppleSigningKey,
+		AppleNotarizeAccountId:   opts.Signing.AppleNotarizeAccountId,
+		AppleNotarizeUserId:      opts.Signing.AppleNotarizeUserId,
+		AppleNotarizeAppPassword: opts.Signing.AppleNotarizeAppPassword,
+		WindowsUseSigntool:       opts.Signing.WindowsUseSigntool,
+		WindowsSigntoolArgs:      opts.Signing.WindowsSigntoolArgs,
+		ProvenanceSigningKey:     opts.Signing.ProvenanceSigningKey,
+	}, nil
+}
+
+// fileMode parses the extra file's octal mode, defaulting to 0644.
+func (f ExtraFile) fileMode() (os.FileMode, error) {
+	if f.Mode == "" {
+		return 0644, nil
+	}
+
+	mode, err := strconv.ParseUint(f.Mode, 8, 32)
+	if err != nil {
+		return 0, fmt.Errorf("parsing mode %s: %w", f.Mode, err)
+	}
+	if mode > 0777 {
+		return 0, fmt.Errorf("mode %s is out of range", f.Mode)
+	}
+
+	return os.FileMode(mode), nil
+}
+
+// strictUnmarshal is json.Unmarshal, but unknown fields are an
+// error. This catches typos in manifests.
+func strictUnmarshal(data []byte, v interface{}) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	return dec.Decode(v)
+}
@@ -0,0 +1,246 @@
+package packaging
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+const testManifest = `
+output_dir: /tmp/launcher-packages
+builds:
+  - name: acme
+    hostname: acme.example.com:443
+    enroll_secret: ${TEST_MANIFEST_SECRET}
+    identifier: acme
+    update_channel: stable
+    osquery_flags:
+      - host_identifier=uuid
+    signing:
+      apple_signing_key: "Developer ID Installer: Acme (ABC123)"
+    systemd:
+      hardening: true
+      memory_max: 512M
+    targets:
+      - darwin-launchd-pkg
+      - linux-systemd-deb
+      - linux-systemd-rpm
+    overrides:
+      linux:
+        native_packaging: true
+        osquery_flags:
+          - host_identifier=instance
+      linux-systemd-rpm:
+        update_channel: beta
+        systemd:
+          memory_max: 256M
+  - name: other
+    hostname: other.example.com:443
+    targets: [windows-service-msi]
+`
+
+func TestParseManifest(t *testing.T) {
+	t.Setenv("TEST_MANIFEST_SECRET", "super-secret")
+
+	m, err := ParseManifest([]byte(testManifest))
+	require.NoError(t, err)
+	require.Len(t, m.Builds, 2)
+	require.Equal(t, "/tmp/launcher-packages", m.OutputDir)
+
+	acme := m.Builds[0]
+	require.Equal(t, "super-secret", acme.EnrollSecret, "environment variables should be expanded")
+
+	// No overrides apply to darwin
+	darwin, err := acme.PackageOptionsFor("darwin-launchd-pkg", "/cache")
+	require.NoError(t, err)
+	require.Equal(t, []string{"host_identifier=uuid"}, darwin.OsqueryFlags)
+	require.Equal(t, "stable", darwin.UpdateChannel)
+	require.False(t, darwin.NativePackaging)
+	require.Equal(t, "Developer ID Installer: Acme (ABC123)", darwin.AppleSigningKey)
+	require.Equal(t, "/cache", darwin.CacheDir)
+	require.Equal(t, "stable", darwin.OsqueryVersion, "versions should default to stable")
+
+	// Platform override applies to deb
+	deb, err := acme.PackageOptionsFor("linux-systemd-deb", "/cache")
+	require.NoError(t, err)
+	require.Equal(t, []string{"host_identifier=instance"}, deb.OsqueryFlags)
+	require.Equal(t, "stable", deb.UpdateChannel)
+	require.True(t, deb.NativePackaging)
+
+	// Platform and target overrides both apply to rpm
+	rpm, err := acme.PackageOptionsFor("linux-systemd-rpm", "/cache")
+	require.NoError(t, err)
+	require.Equal(t, []string{"host_identifier=instance"}, rpm.OsqueryFlags)
+	require.Equal(t, "beta", rpm.UpdateChannel)
+	require.True(t, rpm.NativePackaging)
+	require.True(t, rpm.Systemd.Hardening, "nested options should be merged, not replaced")
+	require.Equal(t, "256M", rpm.Systemd.MemoryMax)
+	require.Equal(t, "512M", deb.Systemd.MemoryMax)
+
+	// Overrides must not leak back into the build
+	require.Equal(t, []string{"host_identifier=uuid"}, acme.OsqueryFlags)
+
+	other, err := m.Builds[1].PackageOptionsFor("windows-service-msi", "")
+	require.NoError(t, err)
+	require.Equal(t, "launcher", other.Identifier, "identifier should default to launcher")
+}
+
+func TestParseManifest_EnvValuesAreLiteral(t *testing.T) {
+	// A value that looks like YAML or JSON must not change the manifest's structure
+	t.Setenv("TEST_MANIFEST_SECRET", "secret\"\n    hostname: evil.example.com\n    identifier: [evil")
+
+	m, err := ParseManifest([]byte(testManifest))
+	require.NoError(t, err)
+
+	acme := m.Builds[0]
+	require.Equal(t, "secret\"\n    hostname: evil.example.com\n    identifier: [evil", acme.EnrollSecret)
+	require.Equal(t, "acme.example.com:443", acme.Hostname)
+	require.Equal(t, "acme", acme.Identifier)
+}
+
+func TestParseManifest_UnsetEnv(t *testing.T) {
+	t.Setenv("TEST_MANIFEST_HOSTNAME", "acme.example.com:443")
+	t.Setenv("TEST_MANIFEST_UNSET_SECRET", "")
+	os.Unsetenv("TEST_MANIFEST_UNSET_SECRET")
+
+	_, err := ParseManifest([]byte(`
+builds:
+  - name: acme
+    hostname: ${TEST_MANIFEST_HOSTNAME}
+    enroll_secret: ${TEST_MANIFEST_UNSET_SECRET}
+    targets: [linux-systemd-deb]
+`))
+	require.Error(t, err, "referencing an unset environment variable should be an error")
+	require.Contains(t, err.Error(), "TEST_MANIFEST_UNSET_SECRET")
+	require.NotContains(t, err.Error(), "TEST_MANIFEST_HOSTNAME")
+
+	// A variable that is set, even to an empty value, is expanded
+	t.Setenv("TEST_MANIFEST_UNSET_SECRET", "")
+	_, err = ParseManifest([]byte(`
+builds:
+  - name: acme
+    hostname: ${TEST_MANIFEST_HOSTNAME}
+    enroll_secret: ${TEST_MANIFEST_UNSET_SECRET}
+    targets: [linux-systemd-deb]
+`))
+	require.NoError(t, err)
+}
+
+func TestParseManifest_JSON(t *testing.T) {
+	t.Parallel()
+
+	m, err := ParseManifest([]byte(`{"builds": [{"name": "acme", "hostname": "acme.example.com:443", "targets": ["linux-systemd-deb"]}]}`))
+	require.NoError(t, err)
+	require.Len(t, m.Builds, 1)
+	require.Equal(t, "acme.example.com:443", m.Builds[0].Hostname)
+}
+
+func TestParseManifest_Invalid(t *testing.T) {
+	t.Parallel()
+
+	extraFile := filepath.Join(t.TempDir(), "extra.txt")
+	require.NoError(t, os.WriteFile(extraFile, []byte("extra"), 0644))
+
+	var tests = []struct {
+		name     string
+		manifest string
+	}{
+		{
+			name:     "no builds",
+			manifest: `output_dir: /tmp`,
+		},
+		{
+			name:     "unknown field",
+			manifest: "builds:\n- name: a\n  hostnme: a.example.com\n  targets: [linux-systemd-deb]",
+		},
+		{
+			name:     "missing name",
+			manifest: "builds:\n- hostname: a.example.com\n  targets: [linux-systemd-deb]",
+		},
+		{
+			name:     "bad name",
+			manifest: "builds:\n- name: a/b\n  hostname: a.example.com\n  targets: [linux-systemd-deb]",
+		},
+		{
+			name:     "duplicate names",
+			manifest: "builds:\n- name: a\n  hostname: a.example.com\n  targets: [linux-systemd-deb]\n- name: a\n  hostname: a.example.com\n  targets: [linux-systemd-rpm]",
+		},
+		{
+			name:     "missing hostname",
+			manifest: "builds:\n- name: a\n  targets: [linux-systemd-deb]",
+		},
+		{
+			name:     "no targets",
+			manifest: "builds:\n- name: a\n  hostname: a.example.com",
+		},
+		{
+			name:     "bad target",
+			manifest: "builds:\n- name: a\n  hostname: a.example.com\n  targets: [linux-systemd]",
+		},
+		{
+			name:     "override for unknown target",
+			manifest: "builds:\n- name: a\n  hostname: a.example.com\n  targets: [linux-systemd-deb]\n  overrides:\n    darwin:\n      insecure: true",
+		},
+		{
+			name:     "override with unknown field",
+			manifest: "builds:\n- name: a\n  hostname: a.example.com\n  targets: [linux-systemd-deb]\n  overrides:\n    linux:\n      insecur: true",
+		},
+		{
+			name:     "override clears hostname",
+			manifest: "builds:\n- name: a\n  hostname: a.example.com\n  targets: [linux-systemd-deb]\n  overrides:\n    linux:\n      hostname: \"\"",
+		},
+		{
+			name:     "bad cert pins",
+			manifest: "builds:\n- name: a\n  hostname: a.example.com\n  cert_pins: nothex\n  targets: [linux-systemd-deb]",
+		},
+		{
+			name:     "missing extra file",
+			manifest: "builds:\n- name: a\n  hostname: a.example.com\n  targets: [linux-systemd-deb]\n  extra_files:\n  - source: /does/not/exist\n    destination: /etc/a/b",
+		},
+		{
+			name:     "extra file escapes package",
+			manifest: "builds:\n- name: a\n  hostname: a.example.com\n  targets: [linux-systemd-deb]\n  extra_files:\n  - source: " + extraFile + "\n    destination: /etc/../../b",
+		},
+		{
+			name:     "bad systemd io weight",
+			manifest: "builds:\n- name: a\n  hostname: a.example.com\n  targets: [linux-systemd-deb]\n  systemd:\n    io_weight: 20000",
+		},
+		{
+			name:     "extra file bad mode",
+			manifest: "builds:\n- name: a\n  hostname: a.example.com\n  targets: [linux-systemd-deb]\n  extra_files:\n  - source: " + extraFile + "\n    destination: /etc/a/b\n    mode: \"999\"",
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			_, err := ParseManifest([]byte(tt.manifest))
+			require.Error(t, err)
+		})
+	}
+}
+
+func TestAddExtraFile(t *testing.T) {
+	t.Parallel()
+
+	source := filepath.Join(t.TempDir(), "ca.pem")
+	require.NoError(t, os.WriteFile(source, []byte("certificate"), 0600))
+
+	p := &PackageOptions{packageRoot: t.TempDir()}
+	require.NoError(t, p.addExtraFile(ExtraFile{Source: source, Destination: "/etc/acme/ca.pem", Mode: "0640"}))
+
+	dest := filepath.Join(p.packageRoot, "etc", "acme", "ca.pem")
+	contents, err := os.ReadFile(dest)
+	require.NoError(t, err)
+	require.Equal(t, "certificate", string(contents))
+
+	if os.PathSeparator == '/' {
+		stat, err := os.Stat(dest)
+		require.NoError(t, err)
+		require.Equal(t, os.FileMode(0640), stat.Mode().Perm())
+	}
+}
@@ -3,7 +3,9 @@ package packaging
 import (
 	"bytes"
 	"context"
+	"crypto/sha256"
 	"embed"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -14,6 +16,7 @@ import (
 	"path/filepath"
 	"strings"
 	"text/template"
+	"time"
 
 	"github.com/kolide/kit/fsutil"
 	"github.com/kolide/launcher/pkg/packagekit"
@@ -57,6 +60,13 @@ type PackageOptions struct {
 	MSIUI             bool
 	WixSkipCleanup    bool
 	DisableService    bool
+	NativePackaging   bool           // Build deb and rpm packages in go, instead of with fpm
+	ExtraFiles        []ExtraFile    // Additional files to copy into the package
+	Systemd           SystemdOptions // Hardening and resource limits for systemd targets
+
+	// ProvenanceSigningKey is the path to a PEM encoded private key. If
+	// set, WriteAttestations also writes signed build provenance.
+	ProvenanceSigningKey string
 
 	// Normally we'd download the same version we bake into the
 	// autoupdate. But occasionally, it's handy to make a package
@@ -86,6 +96,12 @@ type PackageOptions struct {
 	confDir  string // where to place configs (eg: /etc/<name>)
 	initFile string // init file, the path is used in the various scripts.
 
+	// Recorded during Build, for the SBOM and provenance
+	bundled        []bundledBinary // binaries copied into the package
+	artifactSHA256 string          // hex sha256 of the package written to packageWriter
+	buildStarted   time.Time
+	buildFinished  time.Time
+
 	execCC func(context.Context, string, ...string) *exec.Cmd
 }
 
@@ -98,7 +114,13 @@ func NewPackager() *PackageOptions {
 func (p *PackageOptions) Build(ctx context.Context, packageWriter io.Writer, target Target) error {
 
 	p.target = target
-	p.packageWriter = packageWriter
+	p.bundled = nil
+	p.artifactSHA256 = ""
+	p.buildStarted = time.Now()
+
+	// Hash the package as it's written, so it can be attested to
+	artifactHash := sha256.New()
+	p.packageWriter = io.MultiWriter(packageWriter, artifactHash)
 
 	var err error
 
@@ -182,6 +204,12 @@ func (p *PackageOptions) Build(ctx context.Context, packageWriter io.Writer, tar
 		}
 	}
 
+	for _, extraFile := range p.ExtraFiles {
+		if err := p.addExtraFile(extraFile); err != nil {
+			return fmt.Errorf("adding extra file %s: %w", extraFile.Source, err)
+		}
+	}
+
 	// Write the flags to the flagFile
 	for _, k := range launcherBoolFlags {
 		if _, err := flagFile.WriteString(fmt.Sprintf("%s\n", k)); err != nil {
@@ -316,6 +344,9 @@ func (p *PackageOptions) Build(ctx context.Context, packageWriter io.Writer, tar
 		return fmt.Errorf("making package: %w", err)
 	}
 
+	p.artifactSHA256 = hex.EncodeToString(artifactHash.Sum(nil))
+	p.buildFinished = time.Now()
+
 	return nil
 }
 
@@ -331,17 +362,20 @@ func (p *PackageOptions) getBinary(ctx context.Context, symbolicName, binaryName
 	defer span.End()
 
 	var err error
-	var localPath string
+	var localPath, source, channel string
 
 	switch {
 	case strings.HasPrefix(binaryVersion, "./"), strings.HasPrefix(binaryVersion, "/"), strings.HasPrefix(binaryVersion, `\`),
 		strings.HasPrefix(binaryVersion, "C:"), strings.HasPrefix(binaryVersion, "D:"):
 		localPath = binaryVersion
+		source = binaryVersion
 	default:
 		localPath, err = FetchBinary(ctx, p.CacheDir, symbolicName, binaryName, binaryVersion, p.target)
 		if err != nil {
 			return fmt.Errorf("could not fetch path to binary %s %s: %w", binaryName, binaryVersion, err)
 		}
+		source = dlURL(symbolicName, binaryVersion, p.target)
+		channel = binaryVersion
 	}
 
 	// Check to see if we fetched an app bundle. If so, copy over the app bundle directory.
@@ -363,6 +397,11 @@ func (p *PackageOptions) getBinary(ctx context.Context, symbolicName, binaryName
 			return fmt.Errorf("could not create symlink after copying app bundle: %w", err)
 		}
 
+		bundledPath := filepath.Join(filepath.Dir(p.binDir), "Kolide.app", "Contents", "MacOS", binaryName)
+		if err := p.recordBinary(ctx, symbolicName, channel, source, bundledPath, filepath.Join(p.packageRoot, bundledPath)); err != nil {
+			return fmt.Errorf("recording binary %s: %w", binaryName, err)
+		}
+
 		return nil
 	}
 
@@ -373,6 +412,35 @@ func (p *PackageOptions) getBinary(ctx context.Context, symbolicName, binaryName
 	); err != nil {
 		return fmt.Errorf("could not copy binary %s: %w", binaryName, err)
 	}
+
+	installedPath := filepath.Join(p.binDir, binaryName)
+	if err := p.recordBinary(ctx, symbolicName, channel, source, installedPath, filepath.Join(p.packageRoot, installedPath)); err != nil {
+		return fmt.Errorf("recording binary %s: %w", binaryName, err)
+	}
+
+	return nil
+}
+
+// addExtraFile copies an extra file into the package root
+func (p *PackageOptions) addExtraFile(extraFile ExtraFile) error {
+	mode, err := extraFile.fileMode()
+	if err != nil {
+		return err
+	}
+
+	dest := filepath.Join(p.packageRoot, filepath.FromSlash(path.Clean("/"+extraFile.Destination)))
+	if err := os.MkdirAll(filepath.Dir(dest), fsutil.DirMode); err != nil {
+		return fmt.Errorf("create dir for %s: %w", extraFile.Destination, err)
+	}
+
+	if err := fsutil.CopyFile(extraFile.Source, dest); err != nil {
+		return fmt.Errorf("copy to %s: %w", extraFile.Destination, err)
+	}
+
+	if err := os.Chmod(dest, mode); err != nil {
+		return fmt.Errorf("chmod %s: %w", extraFile.Destination, err)
+	}
+
 	return nil
 }
 
@@ -386,13 +454,19 @@ func (p *PackageOptions) makePackage(ctx context.Context) error {
 	// packaging systems.
 	oldPackageNames := []string{"launcher"}
 
+	// deb and rpm can be built without fpm, and therefore without docker.
+	packageLinux := packagekit.PackageFPM
+	if p.NativePackaging {
+		packageLinux = packagekit.PackageNative
+	}
+
 	switch {
 	case p.target.Package == Deb:
-		if err := packagekit.PackageFPM(ctx, p.packageWriter, p.packagekitops, packagekit.AsDeb(), packagekit.WithReplaces(oldPackageNames), packagekit.WithArch(string(p.target.Arch))); err != nil {
+		if err := packageLinux(ctx, p.packageWriter, p.packagekitops, packagekit.AsDeb(), packagekit.WithReplaces(oldPackageNames), packagekit.WithArch(string(p.target.Arch))); err != nil {
 			return fmt.Errorf("packaging, target %s: %w", p.target.String(), err)
 		}
 	case p.target.Package == Rpm:
-		if err := packagekit.PackageFPM(ctx, p.packageWriter, p.packagekitops, packagekit.AsRPM(), packagekit.WithReplaces(oldPackageNames), packagekit.WithArch(string(p.target.Arch))); err != nil {
+		if err := packageLinux(ctx, p.packageWriter, p.packagekitops, packagekit.AsRPM(), packagekit.WithReplaces(oldPackageNames), packagekit.WithArch(string(p.target.Arch))); err != nil {
 			return fmt.Errorf("packaging, target %s: %w", p.target.String(), err)
 		}
 
@@ -404,6 +478,15 @@ func (p *PackageOptions) makePackage(ctx context.Context) error {
 		if err := packagekit.PackageFPM(ctx, p.packageWriter, p.packagekitops, packagekit.AsPacman(), packagekit.WithReplaces(oldPackageNames), packagekit.WithArch(string(p.target.Arch))); err != nil {
 			return fmt.Errorf("packaging, target %s: %w", p.target.String(), err)
 		}
+	case p.target.Package == OCI:
+		// The container runtime supervises launcher, so there's no init system
+		if p.target.Platform != Linux || p.target.Init != NoInit {
+			return fmt.Errorf("OCI images are only supported as linux-none-oci, not %s", p.target.String())
+		}
+		entrypoint := append([]string{p.initOptions.Path}, p.initOptions.Flags...)
+		if err := packagekit.PackageOCI(ctx, p.packageWriter, p.packagekitops, string(p.target.Arch), entrypoint); err != nil {
+			return fmt.Errorf("packaging, target %s: %w", p.target.String(), err)
+		}
 	case p.target.Package == Pkg:
 		if err := packagekit.PackagePkg(ctx, p.packageWriter, p.packagekitops, string(p.target.Arch)); err != nil {
 			return fmt.Errorf("packaging, target %s: %w", p.target.String(), err)
@@ -513,6 +596,10 @@ func (p *PackageOptions) setupInit(ctx context.Context) error {
 	var file string
 	var renderFunc func(context.Context, io.Writer, *packagekit.InitOptions) error
 
+	// OpenRC, runit and s6 all exec the init file directly, so it needs
+	// to be executable in the package.
+	executable := false
+
 	switch {
 	case p.target.Platform == Darwin && p.target.Init == LaunchD:
 		dir = "/Library/LaunchDaemons"
@@ -529,7 +616,12 @@ func (p *PackageOptions) setupInit(ctx context.Context) error {
 			dir = "/usr/lib/systemd/system"
 		}
 		file = fmt.Sprintf("launcher.%s.service", p.Identifier)
-		renderFunc = packagekit.RenderSystemd
+
+		var err error
+		renderFunc, err = p.systemdRenderFunc(ctx, dir)
+		if err != nil {
+			return fmt.Errorf("setting up systemd unit, target %s: %w", p.target.String(), err)
+		}
 	case p.target.Platform == Linux && p.target.Init == Upstart:
 		dir = "/etc/init"
 		file = fmt.Sprintf("launcher-%s.conf", p.Identifier)
@@ -546,6 +638,31 @@ func (p *PackageOptions) setupInit(ctx context.Context) error {
 		dir = "/etc/init.d"
 		file = fmt.Sprintf("%s-launcher", p.Identifier)
 		renderFunc = packagekit.RenderInit
+	case p.target.Platform == Linux && p.target.Init == OpenRC:
+		dir = "/etc/init.d"
+		file = fmt.Sprintf("launcher.%s", p.Identifier)
+		renderFunc = packagekit.RenderOpenRC
+		executable = true
+	case p.target.Platform == Linux && p.target.Init == Runit:
+		// runit services are a directory containing a run script. The
+		// postinst links the directory into the distro's service dir.
+		dir = fmt.Sprintf("/etc/sv/launcher.%s", p.Identifier)
+		file = "run"
+		renderFunc = packagekit.RenderRunit
+		executable = true
+	case p.target.Platform == Linux && p.target.Init == S6:
+		dir = fmt.Sprintf("/etc/s6/sv/launcher.%s", p.Identifier)
+		file = "run"
+		renderFunc = packagekit.RenderS6
+		executable = true
+
+		// s6-rc needs to know the service type. Plain s6-svscan ignores it.
+		if err := os.MkdirAll(filepath.Join(p.packageRoot, dir), fsutil.DirMode); err != nil {
+			return fmt.Errorf("mkdir failed, target %s: %w", p.target.String(), err)
+		}
+		if err := os.WriteFile(filepath.Join(p.packageRoot, dir, "type"), []byte("longrun\n"), 0644); err != nil {
+			return fmt.Errorf("writing s6 type file: %w", err)
+		}
 	case p.target.Platform == Windows && p.target.Init == WindowsService:
 		// Do nothing, this is handled in the packaging step.
 		return nil
@@ -569,6 +686,12 @@ func (p *PackageOptions) setupInit(ctx context.Context) error {
 		return fmt.Errorf("rendering init file (%s), target %s: %w", p.initFile, p.target.String(), err)
 	}
 
+	if executable {
+		if err := fh.Chmod(0755); err != nil {
+			return fmt.Errorf("chmod init file (%s): %w", p.initFile, err)
+		}
+	}
+
 	return nil
 }
 
@@ -583,6 +706,12 @@ func (p *PackageOptions) setupPrerm(ctx context.Context) error {
 	switch {
 	case p.target.Platform == Linux && p.target.Init == Systemd:
 		prermTemplate = prermSystemdTemplate()
+	case p.target.Platform == Linux && p.target.Init == OpenRC:
+		prermTemplate = prermOpenRCTemplate()
+	case p.target.Platform == Linux && p.target.Init == Runit:
+		prermTemplate = prermRunitTemplate()
+	case p.target.Platform == Linux && p.target.Init == S6:
+		prermTemplate = prermS6Template()
 	default:
 		// If we don't match in the case statement, log that we're ignoring
 		// the setup, and move on. Don't throw an error.
@@ -636,6 +765,12 @@ func (p *PackageOptions) setupPostinst(ctx context.Context) error {
 		postinstTemplateName = "postinstall-upstart.sh"
 	case p.target.Platform == Linux && p.target.Init == Init:
 		postinstTemplateName = "postinstall-init.sh"
+	case p.target.Platform == Linux && p.target.Init == OpenRC:
+		postinstTemplateName = "postinstall-openrc.sh"
+	case p.target.Platform == Linux && p.target.Init == Runit:
+		postinstTemplateName = "postinstall-runit.sh"
+	case p.target.Platform == Linux && p.target.Init == S6:
+		postinstTemplateName = "postinstall-s6.sh"
 	default:
 		// If we don't match in the case statement, log that we're ignoring
 		// the setup, and move on. Don't throw an error.
@@ -719,6 +854,47 @@ if [ "$1" = remove -o "$1" = "0" ] ; then
 fi`
 }
 
+// prermOpenRCTemplate returns a template suitable for stopping and
+// removing launcher from the OpenRC default runlevel. Like the
+// systemd template, it only acts on removal, not upgrade.
+func prermOpenRCTemplate() string {
+	return `#!/bin/sh
+if [ "$1" = remove -o "$1" = "0" ] ; then
+  rc-service launcher.{{.Identifier}} stop || true
+  rc-update del launcher.{{.Identifier}} default || true
+fi`
+}
+
+// prermRunitTemplate returns a template suitable for stopping
+// launcher and unlinking it from runit's service directory. runsvdir
+// notices the missing link, and stops supervising it.
+func prermRunitTemplate() string {
+	return `#!/bin/sh
+if [ "$1" = remove -o "$1" = "0" ] ; then
+  sv stop launcher.{{.Identifier}} || true
+  for svdir in /var/service /etc/service /etc/runit/runsvdir/default; do
+    if [ -L "$svdir/launcher.{{.Identifier}}" ]; then
+      rm -f "$svdir/launcher.{{.Identifier}}"
+    fi
+  done
+fi`
+}
+
+// prermS6Template returns a template suitable for stopping launcher
+// and unlinking it from the s6 scan directory.
+func prermS6Template() string {
+	return `#!/bin/sh
+if [ "$1" = remove -o "$1" = "0" ] ; then
+  for scandir in /run/service /service; do
+    if [ -L "$scandir/launcher.{{.Identifier}}" ]; then
+      s6-svc -d "$scandir/launcher.{{.Identifier}}" || true
+      rm -f "$scandir/launcher.{{.Identifier}}"
+      s6-svscanctl -an "$scandir" || true
+    fi
+  done
+fi`
+}
+
 func (p *PackageOptions) setupDirectories() error {
 	switch p.target.Platform {
 	case Linux, Darwin:
@@ -84,6 +84,66 @@ func TestInitStuff(t *testing.T) {
 	}
 }
 
+func TestInitServiceDirectories(t *testing.T) {
+	t.Parallel()
+
+	var tests = []struct {
+		init          InitFlavor
+		expectedFiles []string
+	}{
+		{
+			init:          OpenRC,
+			expectedFiles: []string{"/etc/init.d/launcher.test"},
+		},
+		{
+			init:          Runit,
+			expectedFiles: []string{"/etc/sv/launcher.test/run"},
+		},
+		{
+			init:          S6,
+			expectedFiles: []string{"/etc/s6/sv/launcher.test/run", "/etc/s6/sv/launcher.test/type"},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(string(tt.init), func(t *testing.T) {
+			t.Parallel()
+
+			p := &PackageOptions{
+				target:      Target{Platform: Linux, Init: tt.init, Package: Tar},
+				Identifier:  "test",
+				scriptRoot:  t.TempDir(),
+				packageRoot: t.TempDir(),
+				initOptions: &packagekit.InitOptions{Name: "test", Identifier: "test", Path: "/a/path"},
+			}
+
+			require.NoError(t, p.setupInit(context.TODO()))
+			require.NoError(t, p.setupPostinst(context.TODO()))
+			require.NoError(t, p.setupPrerm(context.TODO()))
+
+			require.Equal(t, tt.expectedFiles[0], p.initFile)
+			for _, f := range tt.expectedFiles {
+				require.FileExists(t, filepath.Join(p.packageRoot, f))
+			}
+
+			if runtime.GOOS != "windows" {
+				stat, err := os.Stat(filepath.Join(p.packageRoot, p.initFile))
+				require.NoError(t, err)
+				require.Equal(t, os.FileMode(0755), stat.Mode().Perm(), "init file should be executable")
+			}
+
+			postinst, err := os.ReadFile(filepath.Join(p.scriptRoot, "postinstall"))
+			require.NoError(t, err)
+			require.Contains(t, string(postinst), "launcher.test")
+
+			prerm, err := os.ReadFile(filepath.Join(p.scriptRoot, "prerm"))
+			require.NoError(t, err)
+			require.Contains(t, string(prerm), "launcher.test")
+		})
+	}
+}
+
 // TestHelperProcess isn't a real test. It's used as a helper process
 // for TestParameterRun. It's comes from both
 // https://github.com/golang/go/blob/master/src/os/exec/exec_test.go#L724
@@ -132,6 +192,8 @@ func TestHelperProcess(t *testing.T) {
   build date: 	2018-11-09T15:31:10Z
   build user: 	seph
   go version: 	go1.11`)
+	case strings.HasSuffix(cmd, "osqueryd") && args[0] == "--version":
+		fmt.Println("osqueryd version 5.9.1")
 	default:
 		fmt.Fprintf(os.Stderr, "Can't mock, unknown command(%q) args(%q) -- Fix TestHelperProcess", cmd, args)
 		os.Exit(2)
@@ -255,5 +317,20 @@ func testedTargets() []Target {
 			Init:     NoInit,
 			Package:  Deb,
 		},
+		{
+			Platform: Linux,
+			Init:     OpenRC,
+			Package:  Tar,
+		},
+		{
+			Platform: Linux,
+			Init:     Runit,
+			Package:  Tar,
+		},
+		{
+			Platform: Linux,
+			Init:     S6,
+			Package:  Tar,
+		},
 	}
 }
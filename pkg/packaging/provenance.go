@@ -0,0 +1,276 @@
+package packaging
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/kolide/kit/version"
+)
+
+const (
+	inTotoStatementType = "https://in-toto.io/Statement/v1"
+	slsaProvenanceType  = "https://slsa.dev/provenance/v1"
+	inTotoPayloadType   = "application/vnd.in-toto+json"
+	provenanceBuildType = "https://github.com/kolide/launcher/package-builder@v1"
+	provenanceBuilderID = "https://github.com/kolide/launcher/cmd/package-builder"
+)
+
+// in-toto statement, with a SLSA provenance predicate. See
+// https://github.com/in-toto/attestation and https://slsa.dev/provenance/v1
+type inTotoStatement struct {
+	Type          string          `json:"_type"`
+	Subject       []inTotoSubject `json:"subject"`
+	PredicateType string          `json:"predicateType"`
+	Predicate     slsaProvenance  `json:"predicate"`
+}
+
+type inTotoSubject struct {
+	Name   string            `json:"name"`
+	Digest map[string]string `json:"digest"`
+}
+
+type slsaProvenance struct {
+	BuildDefinition slsaBuildDefinition `json:"buildDefinition"`
+	RunDetails      slsaRunDetails      `json:"runDetails"`
+}
+
+type slsaBuildDefinition struct {
+	BuildType            string                   `json:"buildType"`
+	ExternalParameters   map[string]interface{}   `json:"externalParameters"`
+	ResolvedDependencies []slsaResourceDescriptor `json:"resolvedDependencies"`
+}
+
+type slsaResourceDescriptor struct {
+	Name   string            `json:"name"`
+	URI    string            `json:"uri,omitempty"`
+	Digest map[string]string `json:"digest"`
+}
+
+type slsaRunDetails struct {
+	Builder  slsaBuilder  `json:"builder"`
+	Metadata slsaMetadata `json:"metadata"`
+}
+
+type slsaBuilder struct {
+	ID      string            `json:"id"`
+	Version map[string]string `json:"version"`
+}
+
+type slsaMetadata struct {
+	StartedOn  string `json:"startedOn"`
+	FinishedOn string `json:"finishedOn"`
+}
+
+// dsseEnvelope is a signed envelope. See
+// https://github.com/secure-systems-lab/dsse
+type dsseEnvelope struct {
+	PayloadType string          `json:"payloadType"`
+	Payload     string          `json:"payload"`
+	Signatures  []dsseSignature `json:"signatures"`
+}
+
+type dsseSignature struct {
+	KeyID string `json:"keyid"`
+	Sig   string `json:"sig"`
+}
+
+// LoadProvenanceKey reads a PEM encoded PKCS8, EC or PKCS1 private
+// key, for signing provenance. ed25519, ECDSA and RSA keys are
+// supported.
+func LoadProvenanceKey(keyPath string) (crypto.Signer, error) {
+	raw, err := os.ReadFile(keyPath)
+	if err != nil {
+		return nil, fmt.Errorf("reading provenance key: %w", err)
+	}
+
+	block, _ := pem.Decode(raw)
+	if block == nil {
+		return nil, errors.New("provenance key is not PEM encoded")
+	}
+
+	var key interface{}
+	switch block.Type {
+	case "EC PRIVATE KEY":
+		key, err = x509.ParseECPrivateKey(block.Bytes)
+	case "RSA PRIVATE KEY":
+		key, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	default:
+		key, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("parsing provenance key: %w", err)
+	}
+
+	switch k := key.(type) {
+	case ed25519.PrivateKey, *ecdsa.PrivateKey, *rsa.PrivateKey:
+		return k.(crypto.Signer), nil
+	default:
+		return nil, fmt.Errorf("unsupported provenance key type %T", key)
+	}
+}
+
+// WriteProvenance writes a DSSE envelope, containing a signed in-toto
+// statement of how the last package was built. artifactName is the
+// name of the package file, which is the statement's subject.
+func (p *PackageOptions) WriteProvenance(w io.Writer, artifactName string, signer crypto.Signer) error {
+	if p.artifactSHA256 == "" {
+		return errors.New("no package has been built")
+	}
+
+	// Secrets, and anything else sensitive, must not be included here
+	externalParameters := map[string]interface{}{
+		"target":          p.target.String(),
+		"identifier":      p.Identifier,
+		"hostname":        p.Hostname,
+		"package_version": p.PackageVersion,
+		"update_channel":  p.UpdateChannel,
+		"omit_secret":     p.OmitSecret,
+	}
+
+	dependencies := make([]slsaResourceDescriptor, len(p.bundled))
+	for i, b := range p.bundled {
+		dependencies[i] = slsaResourceDescriptor{
+			Name:   b.name,
+			URI:    b.source,
+			Digest: map[string]string{"sha256": b.sha256},
+		}
+	}
+
+	statement := inTotoStatement{
+		Type: inTotoStatementType,
+		Subject: []inTotoSubject{{
+			Name:   artifactName,
+			Digest: map[string]string{"sha256": p.artifactSHA256},
+		}},
+		PredicateType: slsaProvenanceType,
+		Predicate: slsaProvenance{
+			BuildDefinition: slsaBuildDefinition{
+				BuildType:            provenanceBuildType,
+				ExternalParameters:   externalParameters,
+				ResolvedDependencies: dependencies,
+			},
+			RunDetails: slsaRunDetails{
+				Builder: slsaBuilder{
+					ID:      provenanceBuilderID,
+					Version: map[string]string{"package-builder": version.Version().Version},
+				},
+				Metadata: slsaMetadata{
+					StartedOn:  p.buildStarted.UTC().Format(time.RFC3339),
+					FinishedOn: p.buildFinished.UTC().Format(time.RFC3339),
+				},
+			},
+		},
+	}
+
+	payload, err := json.Marshal(statement)
+	if err != nil {
+		return fmt.Errorf("marshaling provenance statement: %w", err)
+	}
+
+	envelope, err := signDSSE(inTotoPayloadType, payload, signer)
+	if err != nil {
+		return fmt.Errorf("signing provenance: %w", err)
+	}
+
+	if err := json.NewEncoder(w).Encode(envelope); err != nil {
+		return fmt.Errorf("encoding provenance: %w", err)
+	}
+
+	return nil
+}
+
+// signDSSE signs payload using the DSSE pre-authentication encoding.
+// The key id is the hex sha256 of the public key's PKIX encoding.
+func signDSSE(payloadType string, payload []byte, signer crypto.Signer) (*dsseEnvelope, error) {
+	pubKey, err := x509.MarshalPKIXPublicKey(signer.Public())
+	if err != nil {
+		return nil, fmt.Errorf("marshaling public key: %w", err)
+	}
+	keyID := sha256.Sum256(pubKey)
+
+	message := dssePAE(payloadType, payload)
+
+	var sig []byte
+	switch signer.(type) {
+	case ed25519.PrivateKey:
+		// ed25519 signs the message itself
+		sig, err = signer.Sign(rand.Reader, message, crypto.Hash(0))
+	default:
+		digest := sha256.Sum256(message)
+		sig, err = signer.Sign(rand.Reader, digest[:], crypto.SHA256)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &dsseEnvelope{
+		PayloadType: payloadType,
+		Payload:     base64.StdEncoding.EncodeToString(payload),
+		Signatures: []dsseSignature{{
+			KeyID: hex.EncodeToString(keyID[:]),
+			Sig:   base64.StdEncoding.EncodeToString(sig),
+		}},
+	}, nil
+}
+
+// dssePAE is the DSSE pre-authentication encoding
+func dssePAE(payloadType string, payload []byte) []byte {
+	return []byte(fmt.Sprintf("DSSEv1 %d %s %d %s", len(payloadType), payloadType, len(payload), payload))
+}
+
+// WriteAttestations writes attestations for the package just built
+// to artifactPath, next to it. A CycloneDX SBOM is always written to
+// `<artifact>.cdx.json`. If ProvenanceSigningKey is set, signed
+// provenance is written to `<artifact>.intoto.json`.
+func (p *PackageOptions) WriteAttestations(artifactPath string) error {
+	if err := writeAttestation(artifactPath+".cdx.json", p.WriteSBOM); err != nil {
+		return fmt.Errorf("writing sbom: %w", err)
+	}
+
+	if p.ProvenanceSigningKey == "" {
+		return nil
+	}
+
+	signer, err := LoadProvenanceKey(p.ProvenanceSigningKey)
+	if err != nil {
+		return err
+	}
+
+	artifactName := filepath.Base(artifactPath)
+	if err := writeAttestation(artifactPath+".intoto.json", func(w io.Writer) error {
+		return p.WriteProvenance(w, artifactName, signer)
+	}); err != nil {
+		return fmt.Errorf("writing provenance: %w", err)
+	}
+
+	return nil
+}
+
+func writeAttestation(filePath string, write func(io.Writer) error) error {
+	fh, err := os.Create(filePath)
+	if err != nil {
+		return err
+	}
+	defer fh.Close()
+
+	if err := write(fh); err != nil {
+		return err
+	}
+
+	return fh.Close()
+}
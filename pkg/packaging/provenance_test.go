@@ -0,0 +1,81 @@
+package packaging
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteAttestations(t *testing.T) {
+	t.Parallel()
+
+	pubKey, privKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	der, err := x509.MarshalPKCS8PrivateKey(privKey)
+	require.NoError(t, err)
+
+	dir := t.TempDir()
+	keyPath := filepath.Join(dir, "provenance.pem")
+	require.NoError(t, os.WriteFile(keyPath, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0600))
+
+	p := &PackageOptions{
+		Identifier:           "test",
+		Secret:               "hunter2",
+		ProvenanceSigningKey: keyPath,
+		target:               Target{Platform: Linux, Init: Systemd, Package: Rpm},
+		bundled: []bundledBinary{
+			{name: "osqueryd", source: "https://dl.kolide.co/kolide/osqueryd/linux/amd64/osqueryd-stable.tar.gz", sha256: "def456"},
+		},
+		artifactSHA256: "abc123",
+		buildStarted:   time.Now(),
+		buildFinished:  time.Now(),
+	}
+
+	artifactPath := filepath.Join(dir, "launcher.linux-systemd-rpm.rpm")
+	require.NoError(t, p.WriteAttestations(artifactPath))
+	require.FileExists(t, artifactPath+".cdx.json")
+
+	raw, err := os.ReadFile(artifactPath + ".intoto.json")
+	require.NoError(t, err)
+	require.NotContains(t, string(raw), "hunter2", "secrets must not be in provenance")
+
+	var envelope dsseEnvelope
+	require.NoError(t, json.Unmarshal(raw, &envelope))
+	require.Equal(t, inTotoPayloadType, envelope.PayloadType)
+	require.Len(t, envelope.Signatures, 1)
+
+	payload, err := base64.StdEncoding.DecodeString(envelope.Payload)
+	require.NoError(t, err)
+	sig, err := base64.StdEncoding.DecodeString(envelope.Signatures[0].Sig)
+	require.NoError(t, err)
+	require.True(t, ed25519.Verify(pubKey, dssePAE(envelope.PayloadType, payload), sig))
+
+	var statement inTotoStatement
+	require.NoError(t, json.Unmarshal(payload, &statement))
+	require.Equal(t, inTotoStatementType, statement.Type)
+	require.Equal(t, slsaProvenanceType, statement.PredicateType)
+	require.Equal(t, "launcher.linux-systemd-rpm.rpm", statement.Subject[0].Name)
+	require.Equal(t, "abc123", statement.Subject[0].Digest["sha256"])
+	require.Equal(t, "linux-systemd-rpm", statement.Predicate.BuildDefinition.ExternalParameters["target"])
+	require.Equal(t, "def456", statement.Predicate.BuildDefinition.ResolvedDependencies[0].Digest["sha256"])
+}
+
+func TestWriteAttestations_NoKey(t *testing.T) {
+	t.Parallel()
+
+	p := &PackageOptions{artifactSHA256: "abc123"}
+
+	artifactPath := filepath.Join(t.TempDir(), "launcher.pkg")
+	require.NoError(t, p.WriteAttestations(artifactPath))
+	require.FileExists(t, artifactPath+".cdx.json")
+	require.NoFileExists(t, artifactPath+".intoto.json")
+}
@@ -0,0 +1,287 @@
+package packaging
+
+import (
+	"context"
+	"crypto/sha256"
+	"debug/buildinfo"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-kit/kit/log/level"
+	"github.com/google/uuid"
+	"github.com/kolide/kit/version"
+	"github.com/kolide/launcher/pkg/contexts/ctxlog"
+)
+
+// bundledBinary records a binary fetched into the package, for the
+// SBOM and provenance.
+type bundledBinary struct {
+	name           string   // symbolic name, eg: osqueryd
+	version        string   // the version reported by the binary, eg: 1.0.3
+	channel        string   // the requested channel or version, eg: stable. Empty for local binaries
+	source         string   // download URL, or local path
+	path           string   // path as installed, eg: /usr/local/kolide/bin/osqueryd
+	sha256         string   // hex encoded
+	goVersion      string   // for go binaries, the toolchain version
+	ldflagsVersion string   // for go binaries, the version set at link time
+	modules        []module // for go binaries, the module dependencies
+}
+
+type module struct {
+	path    string
+	version string
+	sum     string // go.sum style h1: hash
+}
+
+// recordBinary hashes a binary copied into the package root, detects
+// its version, and reads its go module information, if any.
+func (p *PackageOptions) recordBinary(ctx context.Context, name, channel, source, installedPath, localPath string) error {
+	fh, err := os.Open(localPath)
+	if err != nil {
+		return fmt.Errorf("opening %s: %w", localPath, err)
+	}
+	defer fh.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, fh); err != nil {
+		return fmt.Errorf("hashing %s: %w", localPath, err)
+	}
+
+	b := bundledBinary{
+		name:    name,
+		channel: channel,
+		source:  source,
+		path:    installedPath,
+		sha256:  hex.EncodeToString(hash.Sum(nil)),
+	}
+
+	// osqueryd isn't a go binary, so it's fine for this to fail
+	if info, err := buildinfo.ReadFile(localPath); err == nil {
+		b.goVersion = info.GoVersion
+		b.ldflagsVersion = ldflagsVersion(info)
+		for _, dep := range info.Deps {
+			if dep.Replace != nil {
+				dep = dep.Replace
+			}
+			b.modules = append(b.modules, module{path: dep.Path, version: dep.Version, sum: dep.Sum})
+		}
+	}
+
+	// Detection runs the binary, which isn't possible when cross compiling. Fall back
+	// to the version go binaries were linked with.
+	detected, err := p.detectBinaryVersion(ctx, name, localPath)
+	switch {
+	case err == nil:
+		b.version = detected
+	case b.ldflagsVersion != "":
+		b.version = b.ldflagsVersion
+	default:
+		level.Debug(ctxlog.FromContext(ctx)).Log("msg", "could not detect binary version", "binary", name, "err", err)
+	}
+
+	p.bundled = append(p.bundled, b)
+	return nil
+}
+
+// detectBinaryVersion runs a bundled binary to ask for its version. Both launcher and
+// osqueryd print it as the last word of the first line, eg: `osqueryd version 5.9.1`.
+func (p *PackageOptions) detectBinaryVersion(ctx context.Context, name, localPath string) (string, error) {
+	versionFlag := "-version"
+	if name == "osqueryd" {
+		versionFlag = "--version"
+	}
+
+	stdout, err := p.execOut(ctx, localPath, versionFlag)
+	if err != nil {
+		return "", fmt.Errorf("running %s: %w", name, err)
+	}
+
+	firstLine, _, _ := strings.Cut(stdout, "\n")
+	fields := strings.Fields(firstLine)
+	if len(fields) == 0 {
+		return "", fmt.Errorf("no version in %s output", name)
+	}
+
+	return fields[len(fields)-1], nil
+}
+
+// ldflagsVersion returns the version stamped into a go binary with
+// `-X github.com/kolide/kit/version.version=`, if any.
+func ldflagsVersion(info *buildinfo.BuildInfo) string {
+	const versionVar = "github.com/kolide/kit/version.version="
+
+	for _, setting := range info.Settings {
+		if setting.Key != "-ldflags" {
+			continue
+		}
+		for _, flag := range strings.Fields(setting.Value) {
+			if v, ok := strings.CutPrefix(flag, versionVar); ok {
+				return strings.Trim(v, `"'`)
+			}
+		}
+	}
+
+	return ""
+}
+
+// CycloneDX 1.5 types. Only the fields we use are included. See
+// https://cyclonedx.org/docs/1.5/json/
+type cdxBOM struct {
+	BOMFormat    string          `json:"bomFormat"`
+	SpecVersion  string          `json:"specVersion"`
+	SerialNumber string          `json:"serialNumber"`
+	Version      int             `json:"version"`
+	Metadata     cdxMetadata     `json:"metadata"`
+	Components   []cdxComponent  `json:"components"`
+	Dependencies []cdxDependency `json:"dependencies"`
+}
+
+type cdxMetadata struct {
+	Timestamp string       `json:"timestamp"`
+	Tools     cdxTools     `json:"tools"`
+	Component cdxComponent `json:"component"`
+}
+
+type cdxTools struct {
+	Components []cdxComponent `json:"components"`
+}
+
+type cdxComponent struct {
+	Type       string        `json:"type"`
+	BOMRef     string        `json:"bom-ref,omitempty"`
+	Name       string        `json:"name"`
+	Version    string        `json:"version,omitempty"`
+	PURL       string        `json:"purl,omitempty"`
+	Hashes     []cdxHash     `json:"hashes,omitempty"`
+	Properties []cdxProperty `json:"properties,omitempty"`
+}
+
+type cdxHash struct {
+	Alg     string `json:"alg"`
+	Content string `json:"content"`
+}
+
+type cdxProperty struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+type cdxDependency struct {
+	Ref       string   `json:"ref"`
+	DependsOn []string `json:"dependsOn"`
+}
+
+// WriteSBOM writes a CycloneDX SBOM describing the last package
+// built. It lists the bundled binaries, with their hashes, and the go
+// modules compiled into them.
+func (p *PackageOptions) WriteSBOM(w io.Writer) error {
+	if p.artifactSHA256 == "" {
+		return errors.New("no package has been built")
+	}
+
+	packageRef := "package"
+	bom := cdxBOM{
+		BOMFormat:    "CycloneDX",
+		SpecVersion:  "1.5",
+		SerialNumber: "urn:uuid:" + uuid.NewString(),
+		Version:      1,
+		Metadata: cdxMetadata{
+			Timestamp: p.buildFinished.UTC().Format(time.RFC3339),
+			Tools: cdxTools{
+				Components: []cdxComponent{{
+					Type:    "application",
+					Name:    "package-builder",
+					Version: version.Version().Version,
+				}},
+			},
+			Component: cdxComponent{
+				Type:    "application",
+				BOMRef:  packageRef,
+				Name:    fmt.Sprintf("launcher-%s", p.Identifier),
+				Version: p.PackageVersion,
+				Hashes:  []cdxHash{{Alg: "SHA-256", Content: p.artifactSHA256}},
+				Properties: []cdxProperty{
+					{Name: "kolide:target", Value: p.target.String()},
+				},
+			},
+		},
+		Components:   []cdxComponent{},
+		Dependencies: []cdxDependency{},
+	}
+
+	packageDeps := cdxDependency{Ref: packageRef, DependsOn: []string{}}
+	seenModules := make(map[string]bool)
+
+	for _, b := range p.bundled {
+		binaryRef := "binary:" + b.name
+		component := cdxComponent{
+			Type:    "application",
+			BOMRef:  binaryRef,
+			Name:    b.name,
+			Version: b.version,
+			Hashes:  []cdxHash{{Alg: "SHA-256", Content: b.sha256}},
+			Properties: []cdxProperty{
+				{Name: "kolide:path", Value: b.path},
+				{Name: "kolide:source", Value: b.source},
+			},
+		}
+		if b.channel != "" {
+			component.Properties = append(component.Properties, cdxProperty{Name: "kolide:channel", Value: b.channel})
+		}
+		if b.goVersion != "" {
+			component.Properties = append(component.Properties, cdxProperty{Name: "kolide:go_version", Value: b.goVersion})
+		}
+		bom.Components = append(bom.Components, component)
+		packageDeps.DependsOn = append(packageDeps.DependsOn, binaryRef)
+
+		binaryDeps := cdxDependency{Ref: binaryRef, DependsOn: []string{}}
+		for _, m := range b.modules {
+			purl := fmt.Sprintf("pkg:golang/%s@%s", m.path, m.version)
+			binaryDeps.DependsOn = append(binaryDeps.DependsOn, purl)
+
+			// Modules are often shared between binaries, but may only be listed once
+			if seenModules[purl] {
+				continue
+			}
+			seenModules[purl] = true
+
+			moduleComponent := cdxComponent{
+				Type:    "library",
+				BOMRef:  purl,
+				Name:    m.path,
+				Version: m.version,
+				PURL:    purl,
+			}
+			if m.sum != "" {
+				moduleComponent.Properties = []cdxProperty{{Name: "kolide:go_sum", Value: m.sum}}
+			}
+			bom.Components = append(bom.Components, moduleComponent)
+		}
+		bom.Dependencies = append(bom.Dependencies, binaryDeps)
+	}
+
+	bom.Dependencies = append([]cdxDependency{packageDeps}, bom.Dependencies...)
+
+	// Keep the libraries in a stable order, after the binaries
+	sort.SliceStable(bom.Components, func(i, j int) bool {
+		if bom.Components[i].Type != bom.Components[j].Type {
+			return bom.Components[i].Type == "application"
+		}
+		return bom.Components[i].Type == "library" && bom.Components[i].BOMRef < bom.Components[j].BOMRef
+	})
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(bom); err != nil {
+		return fmt.Errorf("encoding sbom: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,83 @@
+package packaging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteSBOM(t *testing.T) {
+	t.Parallel()
+
+	p := &PackageOptions{
+		Identifier:     "test",
+		PackageVersion: "1.2.3",
+		packageRoot:    t.TempDir(),
+		binDir:         "bin",
+		target:         Target{Platform: Linux, Init: Systemd, Package: Deb},
+		execCC:         helperCommandContext,
+	}
+	require.NoError(t, os.Mkdir(filepath.Join(p.packageRoot, "bin"), 0755))
+
+	var buf bytes.Buffer
+	require.Error(t, p.WriteSBOM(&buf), "no package built yet")
+
+	// The test binary is a convenient go binary, with module information
+	testBinary, err := os.Executable()
+	require.NoError(t, err)
+	require.NoError(t, p.getBinary(context.TODO(), "launcher", "launcher", testBinary))
+
+	p.artifactSHA256 = "abc123"
+	p.buildFinished = time.Now()
+	require.NoError(t, p.WriteSBOM(&buf))
+
+	var bom cdxBOM
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &bom))
+
+	require.Equal(t, "CycloneDX", bom.BOMFormat)
+	require.Equal(t, "1.5", bom.SpecVersion)
+	require.Equal(t, "launcher-test", bom.Metadata.Component.Name)
+	require.Equal(t, "abc123", bom.Metadata.Component.Hashes[0].Content)
+
+	require.Equal(t, "application", bom.Components[0].Type)
+	require.Equal(t, "launcher", bom.Components[0].Name)
+	require.Equal(t, "0.5.6-19-g17c8589", bom.Components[0].Version, "expected the version launcher reports")
+	require.Contains(t, bom.Components[0].Properties, cdxProperty{Name: "kolide:path", Value: filepath.Join("bin", "launcher")})
+	require.Contains(t, bom.Components[0].Properties, cdxProperty{Name: "kolide:source", Value: testBinary})
+	for _, prop := range bom.Components[0].Properties {
+		require.NotEqual(t, "kolide:channel", prop.Name, "local binaries have no channel")
+	}
+
+	var modules []string
+	for _, c := range bom.Components[1:] {
+		require.Equal(t, "library", c.Type)
+		modules = append(modules, c.Name)
+	}
+	require.Contains(t, modules, "github.com/stretchr/testify")
+
+	require.Equal(t, "package", bom.Dependencies[0].Ref)
+	require.Equal(t, []string{"binary:launcher"}, bom.Dependencies[0].DependsOn)
+}
+
+func Test_detectBinaryVersion(t *testing.T) {
+	t.Parallel()
+
+	p := &PackageOptions{execCC: helperCommandContext}
+
+	v, err := p.detectBinaryVersion(context.TODO(), "osqueryd", filepath.Join("bin", "osqueryd"))
+	require.NoError(t, err)
+	require.Equal(t, "5.9.1", v)
+
+	v, err = p.detectBinaryVersion(context.TODO(), "launcher", filepath.Join("bin", "launcher"))
+	require.NoError(t, err)
+	require.Equal(t, "0.5.6-19-g17c8589", v)
+
+	_, err = p.detectBinaryVersion(context.TODO(), "other", "not mocked")
+	require.Error(t, err)
+}
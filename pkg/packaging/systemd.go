@@ -0,0 +1,149 @@
+package packaging
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/kolide/kit/fsutil"
+	"github.com/kolide/launcher/pkg/packagekit"
+)
+
+// SystemdOptions tune the unit generated for systemd targets. They're
+// ignored for other init flavors.
+type SystemdOptions struct {
+	Hardening             bool     `json:"hardening"`               // Enable ProtectSystem, NoNewPrivileges and friends
+	CapabilityBoundingSet []string `json:"capability_bounding_set"` // Replaces the default set, when hardening
+	MemoryMax             string   `json:"memory_max"`              // eg: 512M
+	CPUQuota              string   `json:"cpu_quota"`               // eg: 20%
+	IOWeight              int      `json:"io_weight"`               // 1-10000, or 0 for the systemd default of 100
+	WatchdogSec           int      `json:"watchdog_sec"`            // Restart launcher if osquery stays unhealthy this long
+
+	// DropInDir, if set, moves the above into a drop-in in this
+	// directory, instead of the unit. This lets admins adjust them
+	// without replacing the unit.
+	DropInDir string `json:"drop_in_dir"`
+
+	// DropIns are additional drop-in files, by name, to install. They
+	// go in DropInDir, or the unit's vendor drop-in directory if unset.
+	DropIns map[string]string `json:"drop_ins"`
+}
+
+// tuningDropInName is the drop-in the tuning options are written to,
+// when DropInDir is set.
+const tuningDropInName = "10-launcher-tuning.conf"
+
+func (s SystemdOptions) validate() error {
+	if s.IOWeight < 0 || s.IOWeight > 10000 {
+		return fmt.Errorf("systemd io_weight must be between 1 and 10000, or 0 to use the systemd default, got %d", s.IOWeight)
+	}
+
+	if s.WatchdogSec < 0 {
+		return errors.New("systemd watchdog_sec must not be negative")
+	}
+
+	if s.DropInDir != "" && !path.IsAbs(s.DropInDir) {
+		return fmt.Errorf("systemd drop_in_dir %s must be absolute", s.DropInDir)
+	}
+
+	for name := range s.DropIns {
+		if !strings.HasSuffix(name, ".conf") || strings.ContainsAny(name, `/\`) {
+			return fmt.Errorf("systemd drop-in %s must be a file name ending in .conf", name)
+		}
+		if s.DropInDir != "" && name == tuningDropInName {
+			return fmt.Errorf("systemd drop-in %s is reserved", name)
+		}
+	}
+
+	return nil
+}
+
+// unitOptions returns the packagekit options to render the tuning
+// options. readWritePaths are the directories launcher needs to write
+// to, when hardening, that would otherwise be read only.
+func (s SystemdOptions) unitOptions(readWritePaths []string) []packagekit.SystemdOption {
+	var opts []packagekit.SystemdOption
+
+	if s.Hardening {
+		opts = append(opts, packagekit.WithHardening(readWritePaths))
+		if len(s.CapabilityBoundingSet) > 0 {
+			opts = append(opts, packagekit.WithCapabilityBoundingSet(s.CapabilityBoundingSet))
+		}
+	}
+	if s.MemoryMax != "" {
+		opts = append(opts, packagekit.WithMemoryMax(s.MemoryMax))
+	}
+	if s.CPUQuota != "" {
+		opts = append(opts, packagekit.WithCPUQuota(s.CPUQuota))
+	}
+	if s.IOWeight != 0 {
+		opts = append(opts, packagekit.WithIOWeight(s.IOWeight))
+	}
+	if s.WatchdogSec != 0 {
+		opts = append(opts, packagekit.WithWatchdog(s.WatchdogSec))
+	}
+
+	return opts
+}
+
+// systemdRenderFunc returns the function to render launcher's unit.
+// If a drop-in directory is configured, the tuning options are
+// written there, and the unit itself is left minimal.
+func (p *PackageOptions) systemdRenderFunc(ctx context.Context, unitDir string) (func(context.Context, io.Writer, *packagekit.InitOptions) error, error) {
+	if err := p.Systemd.validate(); err != nil {
+		return nil, err
+	}
+
+	readWritePaths := []string{p.rootDir, filepath.Dir(p.binDir), p.confDir}
+	unitOpts := p.Systemd.unitOptions(readWritePaths)
+
+	dropInDir := p.Systemd.DropInDir
+	if dropInDir == "" {
+		dropInDir = filepath.Join(unitDir, fmt.Sprintf("launcher.%s.service.d", p.Identifier))
+	}
+
+	dropIns := make(map[string]string, len(p.Systemd.DropIns)+1)
+	for name, contents := range p.Systemd.DropIns {
+		dropIns[name] = contents
+	}
+
+	if p.Systemd.DropInDir != "" && len(unitOpts) > 0 {
+		var tuning strings.Builder
+		if err := packagekit.RenderSystemdDropIn(ctx, &tuning, unitOpts...); err != nil {
+			return nil, fmt.Errorf("rendering systemd drop-in: %w", err)
+		}
+		dropIns[tuningDropInName] = tuning.String()
+		unitOpts = nil
+	}
+
+	if err := p.writeSystemdDropIns(dropInDir, dropIns); err != nil {
+		return nil, err
+	}
+
+	return func(ctx context.Context, w io.Writer, io *packagekit.InitOptions) error {
+		return packagekit.RenderSystemd(ctx, w, io, unitOpts...)
+	}, nil
+}
+
+func (p *PackageOptions) writeSystemdDropIns(dropInDir string, dropIns map[string]string) error {
+	if len(dropIns) == 0 {
+		return nil
+	}
+
+	if err := os.MkdirAll(filepath.Join(p.packageRoot, dropInDir), fsutil.DirMode); err != nil {
+		return fmt.Errorf("making systemd drop-in dir: %w", err)
+	}
+
+	for name, contents := range dropIns {
+		if err := os.WriteFile(filepath.Join(p.packageRoot, dropInDir, name), []byte(contents), 0644); err != nil {
+			return fmt.Errorf("writing systemd drop-in %s: %w", name, err)
+		}
+	}
+
+	return nil
+}
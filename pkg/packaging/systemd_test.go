@@ -0,0 +1,119 @@
+package packaging
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/kolide/launcher/pkg/packagekit"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSetupInitSystemd(t *testing.T) {
+	t.Parallel()
+
+	var tests = []struct {
+		name            string
+		systemd         SystemdOptions
+		unitContains    []string
+		unitNotContains []string
+		dropIns         map[string][]string
+	}{
+		{
+			name:            "default",
+			unitNotContains: []string{"ProtectSystem", "MemoryMax", "WatchdogSec"},
+		},
+		{
+			name: "tuned unit",
+			systemd: SystemdOptions{
+				Hardening:   true,
+				MemoryMax:   "512M",
+				CPUQuota:    "20%",
+				WatchdogSec: 30,
+			},
+			unitContains: []string{
+				"ProtectSystem=full",
+				"ReadWritePaths=/var/test/example.com /usr/local/test /etc/test",
+				"MemoryMax=512M",
+				"CPUQuota=20%",
+				"WatchdogSec=30",
+			},
+			// Autoupdate stages updates in the temp directory, and desktop needs the user's home
+			unitNotContains: []string{"ProtectSystem=strict", "ProtectHome", "PrivateTmp"},
+		},
+		{
+			name: "tuning in drop-in",
+			systemd: SystemdOptions{
+				IOWeight:  20,
+				DropInDir: "/etc/systemd/system/launcher.test.service.d",
+				DropIns:   map[string]string{"20-env.conf": "[Service]\nEnvironment=FOO=bar\n"},
+			},
+			unitNotContains: []string{"IOWeight"},
+			dropIns: map[string][]string{
+				"/etc/systemd/system/launcher.test.service.d/10-launcher-tuning.conf": {"IOWeight=20"},
+				"/etc/systemd/system/launcher.test.service.d/20-env.conf":             {"Environment=FOO=bar"},
+			},
+		},
+		{
+			name: "extra drop-in in vendor dir",
+			systemd: SystemdOptions{
+				MemoryMax: "1G",
+				DropIns:   map[string]string{"20-env.conf": "[Service]\nEnvironment=FOO=bar\n"},
+			},
+			unitContains: []string{"MemoryMax=1G"},
+			dropIns: map[string][]string{
+				"/lib/systemd/system/launcher.test.service.d/20-env.conf": {"Environment=FOO=bar"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			p := &PackageOptions{
+				target:      Target{Platform: Linux, Init: Systemd, Package: Deb},
+				Identifier:  "test",
+				Systemd:     tt.systemd,
+				packageRoot: t.TempDir(),
+				rootDir:     "/var/test/example.com",
+				binDir:      "/usr/local/test/bin",
+				confDir:     "/etc/test",
+				initOptions: &packagekit.InitOptions{Name: "test", Identifier: "test", Path: "/usr/local/test/bin/launcher"},
+			}
+
+			require.NoError(t, p.setupInit(context.TODO()))
+
+			unit, err := os.ReadFile(filepath.Join(p.packageRoot, "/lib/systemd/system/launcher.test.service"))
+			require.NoError(t, err)
+			for _, s := range tt.unitContains {
+				require.Contains(t, string(unit), s)
+			}
+			for _, s := range tt.unitNotContains {
+				require.NotContains(t, string(unit), s)
+			}
+
+			for dropIn, expected := range tt.dropIns {
+				contents, err := os.ReadFile(filepath.Join(p.packageRoot, dropIn))
+				require.NoError(t, err)
+				for _, s := range expected {
+					require.Contains(t, string(contents), s)
+				}
+			}
+		})
+	}
+}
+
+func TestSystemdOptionsValidate(t *testing.T) {
+	t.Parallel()
+
+	require.NoError(t, SystemdOptions{IOWeight: 100, DropIns: map[string]string{"a.conf": ""}}.validate())
+	require.Error(t, SystemdOptions{IOWeight: 10001}.validate())
+	require.Error(t, SystemdOptions{WatchdogSec: -1}.validate())
+	require.Error(t, SystemdOptions{DropInDir: "relative/dir"}.validate())
+	require.Error(t, SystemdOptions{DropIns: map[string]string{"../a.conf": ""}}.validate())
+	require.Error(t, SystemdOptions{DropIns: map[string]string{"a.txt": ""}}.validate())
+	require.Error(t, SystemdOptions{DropInDir: "/etc/x", DropIns: map[string]string{tuningDropInName: ""}}.validate())
+}
@@ -25,9 +25,12 @@ const (
 	WindowsService              = "service"
 	NoInit                      = "none"
 	UpstartAmazonAMI            = "upstart_amazon_ami"
+	OpenRC                      = "openrc"
+	Runit                       = "runit"
+	S6                          = "s6"
 )
 
-var knownInitFlavors = [...]InitFlavor{LaunchD, Systemd, Init, Upstart, WindowsService, NoInit, UpstartAmazonAMI}
+var knownInitFlavors = [...]InitFlavor{LaunchD, Systemd, Init, Upstart, WindowsService, NoInit, UpstartAmazonAMI, OpenRC, Runit, S6}
 
 type PlatformFlavor string
 
@@ -48,9 +51,10 @@ const (
 	Rpm                  = "rpm"
 	Msi                  = "msi"
 	Pacman               = "pacman"
+	OCI                  = "oci" // An OCI image layout, as a tarball
 )
 
-var knownPackageFlavors = [...]PackageFlavor{Pkg, Tar, Deb, Rpm, Msi, Pacman}
+var knownPackageFlavors = [...]PackageFlavor{Pkg, Tar, Deb, Rpm, Msi, Pacman, OCI}
 
 type ArchFlavor string
 
@@ -35,6 +35,18 @@ func TestInitFromString(t *testing.T) {
 			in:  "none",
 			out: NoInit,
 		},
+		{
+			in:  "openrc",
+			out: OpenRC,
+		},
+		{
+			in:  "runit",
+			out: Runit,
+		},
+		{
+			in:  "s6",
+			out: S6,
+		},
 	}
 
 	// Test error case
@@ -115,6 +127,10 @@ func TestPackageStrings(t *testing.T) {
 			in:  "pacman",
 			out: Pacman,
 		},
+		{
+			in:  "oci",
+			out: OCI,
+		},
 	}
 
 	// Test error case
@@ -153,6 +169,10 @@ func TestTargetParse(t *testing.T) {
 			in:  "linux-systemd-rpm",
 			out: &Target{Platform: Linux, Init: Systemd, Package: Rpm},
 		},
+		{
+			in:  "linux-none-oci",
+			out: &Target{Platform: Linux, Init: NoInit, Package: OCI},
+		},
 		{
 			in:         "windows-msi",
 			shouldFail: true,
@@ -0,0 +1,119 @@
+// Package sdnotify implements the part of systemd's sd_notify
+// protocol launcher needs: pinging the service watchdog. See
+// https://www.freedesktop.org/software/systemd/man/sd_notify.html
+package sdnotify
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+)
+
+var ErrWatchdogDisabled = errors.New("systemd watchdog is not enabled for this process")
+
+// startupGracePeriod is how long after startup the watchdog is pinged regardless of the health
+// check, since the things it checks take a while to come up.
+var startupGracePeriod = 2 * time.Minute
+
+type watchdog struct {
+	logger      log.Logger
+	socket      string
+	interval    time.Duration
+	healthCheck func() error
+	startedAt   time.Time
+	interrupt   chan struct{}
+}
+
+// NewWatchdog returns an actor that pings systemd's watchdog at half
+// the unit's WatchdogSec, as long as healthCheck passes. Once pings
+// stop, systemd restarts launcher. If the unit has no watchdog, it
+// returns ErrWatchdogDisabled.
+func NewWatchdog(logger log.Logger, healthCheck func() error) (*watchdog, error) {
+	socket := os.Getenv("NOTIFY_SOCKET")
+	usec := os.Getenv("WATCHDOG_USEC")
+	if socket == "" || usec == "" {
+		return nil, ErrWatchdogDisabled
+	}
+
+	// If WATCHDOG_PID is set, the watchdog is for that process only
+	if pid := os.Getenv("WATCHDOG_PID"); pid != "" && pid != strconv.Itoa(os.Getpid()) {
+		return nil, ErrWatchdogDisabled
+	}
+
+	timeout, err := strconv.ParseInt(usec, 10, 64)
+	if err != nil || timeout <= 0 {
+		return nil, fmt.Errorf("invalid WATCHDOG_USEC %q", usec)
+	}
+
+	return &watchdog{
+		logger:      logger,
+		socket:      socket,
+		interval:    time.Duration(timeout) * time.Microsecond / 2,
+		healthCheck: healthCheck,
+		interrupt:   make(chan struct{}, 1),
+	}, nil
+}
+
+func (w *watchdog) Execute() error {
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	w.startedAt = time.Now()
+	level.Debug(w.logger).Log("msg", "pinging systemd watchdog", "interval", w.interval.String())
+
+	for {
+		w.ping()
+
+		select {
+		case <-w.interrupt:
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// ping pings the watchdog if launcher is healthy, or still starting up
+func (w *watchdog) ping() {
+	if err := w.healthCheck(); err != nil {
+		if time.Since(w.startedAt) > startupGracePeriod {
+			level.Info(w.logger).Log("msg", "health check failed, not pinging systemd watchdog", "err", err)
+			return
+		}
+		level.Debug(w.logger).Log("msg", "health check failed during startup, pinging systemd watchdog anyway", "err", err)
+	}
+
+	if err := Notify(w.socket, "WATCHDOG=1"); err != nil {
+		level.Info(w.logger).Log("msg", "could not ping systemd watchdog", "err", err)
+	}
+}
+
+func (w *watchdog) Interrupt(_ error) {
+	w.interrupt <- struct{}{}
+}
+
+// Notify sends state, eg: `READY=1`, to systemd's notification socket.
+func Notify(socket string, state string) error {
+	// Abstract sockets are given with a leading @
+	if strings.HasPrefix(socket, "@") {
+		socket = "\x00" + socket[1:]
+	}
+
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: socket, Net: "unixgram"})
+	if err != nil {
+		return fmt.Errorf("dialing notify socket: %w", err)
+	}
+	defer conn.Close()
+
+	if _, err := conn.Write([]byte(state)); err != nil {
+		return fmt.Errorf("writing to notify socket: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,103 @@
+//go:build !windows
+// +build !windows
+
+package sdnotify
+
+import (
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/stretchr/testify/require"
+)
+
+func healthy() error { return nil }
+
+func TestNewWatchdog_Disabled(t *testing.T) {
+	t.Setenv("NOTIFY_SOCKET", "")
+	t.Setenv("WATCHDOG_USEC", "")
+
+	_, err := NewWatchdog(log.NewNopLogger(), healthy)
+	require.ErrorIs(t, err, ErrWatchdogDisabled)
+
+	t.Setenv("NOTIFY_SOCKET", "/run/systemd/notify")
+	t.Setenv("WATCHDOG_USEC", "1000000")
+	t.Setenv("WATCHDOG_PID", "1")
+	_, err = NewWatchdog(log.NewNopLogger(), healthy)
+	require.ErrorIs(t, err, ErrWatchdogDisabled, "watchdog for another process should be ignored")
+
+	t.Setenv("WATCHDOG_PID", "")
+	t.Setenv("WATCHDOG_USEC", "soon")
+	_, err = NewWatchdog(log.NewNopLogger(), healthy)
+	require.Error(t, err)
+}
+
+func TestWatchdog(t *testing.T) {
+	socketPath := filepath.Join(t.TempDir(), "notify.sock")
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: socketPath, Net: "unixgram"})
+	require.NoError(t, err)
+	defer conn.Close()
+
+	t.Setenv("NOTIFY_SOCKET", socketPath)
+	t.Setenv("WATCHDOG_USEC", "20000")
+	t.Setenv("WATCHDOG_PID", strconv.Itoa(os.Getpid()))
+
+	w, err := NewWatchdog(log.NewNopLogger(), healthy)
+	require.NoError(t, err)
+	require.Equal(t, 10*time.Millisecond, w.interval)
+
+	done := make(chan error)
+	go func() { done <- w.Execute() }()
+
+	// Expect a couple of pings
+	buf := make([]byte, 64)
+	for i := 0; i < 2; i++ {
+		require.NoError(t, conn.SetReadDeadline(time.Now().Add(5*time.Second)))
+		n, err := conn.Read(buf)
+		require.NoError(t, err)
+		require.Equal(t, "WATCHDOG=1", string(buf[:n]))
+	}
+
+	w.Interrupt(nil)
+	select {
+	case err := <-done:
+		require.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("watchdog did not exit after interrupt")
+	}
+}
+
+func TestWatchdog_Unhealthy(t *testing.T) {
+	socketPath := filepath.Join(t.TempDir(), "notify.sock")
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: socketPath, Net: "unixgram"})
+	require.NoError(t, err)
+	defer conn.Close()
+
+	t.Setenv("NOTIFY_SOCKET", socketPath)
+	t.Setenv("WATCHDOG_USEC", "20000")
+	t.Setenv("WATCHDOG_PID", strconv.Itoa(os.Getpid()))
+
+	w, err := NewWatchdog(log.NewNopLogger(), func() error { return errors.New("osquery is down") })
+	require.NoError(t, err)
+
+	// During startup, the watchdog is pinged even though the health check fails
+	w.startedAt = time.Now()
+	w.ping()
+	buf := make([]byte, 64)
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(5*time.Second)))
+	n, err := conn.Read(buf)
+	require.NoError(t, err)
+	require.Equal(t, "WATCHDOG=1", string(buf[:n]))
+
+	// After startup, it isn't
+	w.startedAt = time.Now().Add(-2 * startupGracePeriod)
+	w.ping()
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(100*time.Millisecond)))
+	_, err = conn.Read(buf)
+	require.Error(t, err, "expected no ping while unhealthy")
+}
@@ -3,12 +3,16 @@ package sendbuffer
 import (
 	"bytes"
 	"context"
+	"encoding/binary"
 	"errors"
 	"io"
+	"sort"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/go-kit/kit/log"
+	"github.com/kolide/launcher/pkg/agent/types"
 )
 
 type sender interface {
@@ -16,8 +20,9 @@ type sender interface {
 }
 
 var (
-	defaultMaxSize     = 128 * 1024
-	defaultMaxSendSize = 8 * 1024
+	defaultMaxSize         = 128 * 1024
+	defaultMaxSendSize     = 8 * 1024
+	defaultPersistInterval = 10 * time.Second
 )
 
 type SendBuffer struct {
@@ -28,6 +33,28 @@ type SendBuffer struct {
 	sender                            sender
 	sendInterval                      time.Duration
 	isSending                         bool
+
+	// store, if set, holds a copy of the buffer, so that logs survive restarts. Each log is stored
+	// as soon as it's written, under the key in logKeys at the same index. Removing sent and dropped
+	// logs from the store is coalesced, and done every persistInterval, and after each send, if the
+	// buffer has changed. logKeys, nextKey and dirty are guarded by writeMutex.
+	store           types.GetterSetterDeleterIteratorUpdater
+	persistInterval time.Duration
+	logKeys         []uint64
+	nextKey         uint64
+	dirty           bool
+
+	droppedOversizeBytes, droppedOverflowBytes, droppedSendFailureBytes atomic.Int64
+}
+
+// DroppedBytes counts the data the buffer has dropped, by reason
+type DroppedBytes struct {
+	// Oversize is data dropped because a single write was larger than the max send size
+	Oversize int64
+	// Overflow is data dropped because the buffer reached its max storage size
+	Overflow int64
+	// SendFailure is data dropped because it could not be sent
+	SendFailure int64
 }
 
 type option func(*SendBuffer)
@@ -50,6 +77,22 @@ func WithLogger(logger log.Logger) option {
 	}
 }
 
+// WithStore persists buffered data to the given store, so that it survives restarts. Any data
+// already in the store is loaded into the buffer, oldest first.
+func WithStore(store types.GetterSetterDeleterIteratorUpdater) option {
+	return func(sb *SendBuffer) {
+		sb.store = store
+	}
+}
+
+// WithPersistInterval sets how often data that has been sent or dropped is removed from the store.
+// Data sent since then is sent again if launcher exits uncleanly.
+func WithPersistInterval(persistInterval time.Duration) option {
+	return func(sb *SendBuffer) {
+		sb.persistInterval = persistInterval
+	}
+}
+
 // WithSendInterval sets the interval at which the buffer will send data.
 func WithSendInterval(sendInterval time.Duration) option {
 	return func(sb *SendBuffer) {
@@ -59,12 +102,13 @@ func WithSendInterval(sendInterval time.Duration) option {
 
 func New(sender sender, opts ...option) *SendBuffer {
 	sb := &SendBuffer{
-		maxStorageSize: defaultMaxSize,
-		maxSendSize:    defaultMaxSendSize,
-		sender:         sender,
-		sendInterval:   1 * time.Minute,
-		logger:         log.NewNopLogger(),
-		isSending:      false,
+		maxStorageSize:  defaultMaxSize,
+		maxSendSize:     defaultMaxSendSize,
+		sender:          sender,
+		sendInterval:    1 * time.Minute,
+		persistInterval: defaultPersistInterval,
+		logger:          log.NewNopLogger(),
+		isSending:       false,
 	}
 
 	for _, opt := range opts {
@@ -73,9 +117,81 @@ func New(sender sender, opts ...option) *SendBuffer {
 
 	sb.logger = log.With(sb.logger, "component", "sendbuffer")
 
+	if sb.store != nil {
+		sb.loadFromStore()
+	}
+
 	return sb
 }
 
+// DroppedBytes returns the totals of the data dropped so far
+func (sb *SendBuffer) DroppedBytes() DroppedBytes {
+	return DroppedBytes{
+		Oversize:    sb.droppedOversizeBytes.Load(),
+		Overflow:    sb.droppedOverflowBytes.Load(),
+		SendFailure: sb.droppedSendFailureBytes.Load(),
+	}
+}
+
+// loadFromStore reads persisted data back into the buffer. Anything that wouldn't fit, within
+// the same limits as Write, is dropped -- oldest first.
+func (sb *SendBuffer) loadFromStore() {
+	type storedLog struct {
+		key, data []byte
+	}
+
+	stored := make([]storedLog, 0)
+	if err := sb.store.ForEach(func(k, v []byte) error {
+		// The store owns k and v, so they must be copied
+		stored = append(stored, storedLog{key: append([]byte{}, k...), data: append([]byte{}, v...)})
+		return nil
+	}); err != nil {
+		sb.logger.Log("msg", "could not load persisted data", "err", err)
+		return
+	}
+
+	sort.Slice(stored, func(i, j int) bool {
+		return bytes.Compare(stored[i].key, stored[j].key) < 0
+	})
+
+	// Keep the newest data that fits
+	keep := len(stored)
+	size := 0
+	for keep > 0 {
+		next := stored[keep-1]
+		if len(next.data) > sb.maxSendSize || len(next.data)+size > sb.maxStorageSize {
+			break
+		}
+		size += len(next.data)
+		keep--
+	}
+
+	for _, dropped := range stored[:keep] {
+		sb.droppedOverflowBytes.Add(int64(len(dropped.data)))
+	}
+
+	for _, s := range stored[keep:] {
+		sb.logs = append(sb.logs, s.data)
+		sb.logKeys = append(sb.logKeys, logKey(s.key))
+		sb.size += len(s.data)
+	}
+
+	if len(stored) > 0 {
+		// New writes must sort after everything already stored, including data that was just
+		// dropped but hasn't been cleared from the store yet
+		sb.nextKey = logKey(stored[len(stored)-1].key) + 1
+
+		// The dropped data will be cleared from the store when it's next persisted
+		sb.dirty = keep > 0
+		sb.logger.Log(
+			"msg", "loaded persisted data",
+			"loaded_count", len(stored)-keep,
+			"dropped_count", keep,
+			"buffer_size", sb.size,
+		)
+	}
+}
+
 func (sb *SendBuffer) Write(in []byte) (int, error) {
 	sb.writeMutex.Lock()
 	defer sb.writeMutex.Unlock()
@@ -86,6 +202,7 @@ func (sb *SendBuffer) Write(in []byte) (int, error) {
 
 	// if the single data piece is larger than the max send size, drop it and log
 	if len(in) > sb.maxSendSize {
+		sb.droppedOversizeBytes.Add(int64(len(in)))
 		sb.logger.Log(
 			"msg", "dropped data because element greater than max send size",
 			"size_of_data", len(in),
@@ -98,6 +215,7 @@ func (sb *SendBuffer) Write(in []byte) (int, error) {
 	// if we are full, something has backed up
 	// purge everything
 	if len(in)+sb.size > sb.maxStorageSize {
+		sb.droppedOverflowBytes.Add(int64(sb.size))
 		sb.deleteLogs(len(sb.logs))
 
 		sb.logger.Log(
@@ -114,8 +232,22 @@ func (sb *SendBuffer) Write(in []byte) (int, error) {
 	data := make([]byte, len(in))
 	copy(data, in)
 
+	key := sb.nextKey
+	sb.nextKey++
+
 	sb.logs = append(sb.logs, data)
+	sb.logKeys = append(sb.logKeys, key)
 	sb.size += len(data)
+
+	// Store the data right away, so that it isn't lost if launcher exits uncleanly. If it can't be
+	// stored, it's still buffered in memory, and the next persist will try again.
+	if sb.store != nil {
+		if err := sb.store.Set(storeKey(key), data); err != nil {
+			sb.logger.Log("msg", "could not persist data", "err", err)
+			sb.dirty = true
+		}
+	}
+
 	return len(in), nil
 }
 
@@ -129,18 +261,29 @@ func (sb *SendBuffer) Run(ctx context.Context) error {
 		sb.isSending = false
 	}()
 
-	ticker := time.NewTicker(sb.sendInterval)
-	defer ticker.Stop()
+	sendTicker := time.NewTicker(sb.sendInterval)
+	defer sendTicker.Stop()
 
-	for {
-		if err := sb.sendAndPurge(); err != nil {
-			sb.logger.Log("msg", "failed to send and purge", "err", err)
-		}
+	persistTicker := time.NewTicker(sb.persistInterval)
+	defer persistTicker.Stop()
+
+	if err := sb.sendAndPurge(); err != nil {
+		sb.logger.Log("msg", "failed to send and purge", "err", err)
+	}
+	sb.persist()
 
+	for {
 		select {
-		case <-ticker.C:
-			continue
+		case <-sendTicker.C:
+			if err := sb.sendAndPurge(); err != nil {
+				sb.logger.Log("msg", "failed to send and purge", "err", err)
+			}
+			sb.persist()
+		case <-persistTicker.C:
+			sb.persist()
 		case <-ctx.Done():
+			// Keep whatever hasn't been sent yet, to send after a restart
+			sb.persist()
 			return nil
 		}
 	}
@@ -148,9 +291,61 @@ func (sb *SendBuffer) Run(ctx context.Context) error {
 
 func (sb *SendBuffer) DeleteAllData() {
 	sb.writeMutex.Lock()
-	defer sb.writeMutex.Unlock()
 	sb.logs = nil
+	sb.logKeys = nil
 	sb.size = 0
+	sb.dirty = true
+	sb.writeMutex.Unlock()
+
+	sb.persist()
+}
+
+// persist replaces the stored copy of the buffer with its current contents, in a single update.
+// It waits for any send in progress, so that data that's being sent stays in the store until the
+// send is done, and is sent again after a restart if the process exits mid-send. It holds the write
+// lock for the update, so that it can't remove data that's written in the meantime.
+func (sb *SendBuffer) persist() {
+	if sb.store == nil {
+		return
+	}
+
+	sb.sendMutex.Lock()
+	defer sb.sendMutex.Unlock()
+
+	sb.writeMutex.Lock()
+	defer sb.writeMutex.Unlock()
+
+	if !sb.dirty {
+		return
+	}
+
+	kvPairs := make(map[string]string, len(sb.logs))
+	for i, data := range sb.logs {
+		kvPairs[string(storeKey(sb.logKeys[i]))] = string(data)
+	}
+
+	// If the data can't be persisted, it's still buffered in memory
+	if _, err := sb.store.Update(kvPairs); err != nil {
+		sb.logger.Log("msg", "could not persist data", "err", err)
+		return
+	}
+
+	sb.dirty = false
+}
+
+// storeKey encodes a log's key big endian, so that stored logs sort in the order they were written
+func storeKey(key uint64) []byte {
+	b := make([]byte, 8)
+	binary.BigEndian.PutUint64(b, key)
+	return b
+}
+
+// logKey decodes a key made by storeKey. Keys of any other length decode to zero.
+func logKey(b []byte) uint64 {
+	if len(b) != 8 {
+		return 0
+	}
+	return binary.BigEndian.Uint64(b)
 }
 
 func (sb *SendBuffer) sendAndPurge() error {
@@ -169,13 +364,16 @@ func (sb *SendBuffer) sendAndPurge() error {
 		return nil
 	}
 
+	sendSize := toSendBuff.Len()
 	if err := sb.sender.Send(toSendBuff); err != nil {
+		sb.droppedSendFailureBytes.Add(int64(sendSize))
 		sb.logger.Log("msg", "failed to send, dropping data", "err", err)
 	}
 
 	return nil
 }
 
+// flushToWriter writes up to the max send size of data to w, and removes it from the buffer
 func (sb *SendBuffer) flushToWriter(w io.Writer) error {
 	sb.writeMutex.Lock()
 	defer sb.writeMutex.Unlock()
@@ -200,6 +398,7 @@ func (sb *SendBuffer) flushToWriter(w io.Writer) error {
 	return nil
 }
 
+// deleteLogs removes logs up to toIndex from the buffer
 func (sb *SendBuffer) deleteLogs(toIndex int) {
 	sizeDeleted := 0
 	for i := 0; i < toIndex; i++ {
@@ -207,7 +406,12 @@ func (sb *SendBuffer) deleteLogs(toIndex int) {
 	}
 
 	sb.logs = sb.logs[toIndex:]
+	sb.logKeys = sb.logKeys[toIndex:]
 	sb.size -= sizeDeleted
+
+	if toIndex > 0 {
+		sb.dirty = true
+	}
 }
 
 func minInt(a, b int) int {
@@ -3,12 +3,16 @@ package sendbuffer
 import (
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"sync"
 	"testing"
 	"time"
 
+	"github.com/go-kit/kit/log"
+	"github.com/kolide/launcher/pkg/agent/storage/inmemory"
+	"github.com/kolide/launcher/pkg/agent/types"
 	"github.com/stretchr/testify/require"
 )
 
@@ -220,6 +224,168 @@ func TestSendBuffer_DeleteAllData(t *testing.T) {
 	require.Zero(t, sb.size)
 }
 
+func TestSendBuffer_WithStore(t *testing.T) {
+	t.Parallel()
+
+	store := inmemory.NewStore(log.NewNopLogger())
+
+	// Data written, and persisted, before a restart...
+	sb := New(&testSender{lastReceived: &bytes.Buffer{}, t: t}, WithStore(store))
+	for _, write := range []string{"one", "two", "three"} {
+		_, err := sb.Write([]byte(write))
+		require.NoError(t, err)
+	}
+	require.Equal(t, 3, storeCount(t, store), "writes should be persisted right away")
+
+	// ...is sent after it, in order
+	sender := &testSender{lastReceived: &bytes.Buffer{}, t: t}
+	sb = New(sender, WithStore(store))
+	requireStoreSizeEqualsHttpBufferReportedSize(t, sb)
+
+	_, err := sb.Write([]byte("four"))
+	require.NoError(t, err)
+
+	require.NoError(t, sb.sendAndPurge())
+	require.Equal(t, "onetwothreefour", string(sender.aggregateAllReceived()))
+	sb.persist()
+	require.Zero(t, storeCount(t, store), "sent data should be removed from the store")
+
+	// Deleting all data clears the store too
+	_, err = sb.Write([]byte("five"))
+	require.NoError(t, err)
+	require.Equal(t, 1, storeCount(t, store))
+	sb.DeleteAllData()
+	require.Zero(t, storeCount(t, store))
+}
+
+func TestSendBuffer_WithStore_DropsOldestOverMaxSize(t *testing.T) {
+	t.Parallel()
+
+	store := inmemory.NewStore(log.NewNopLogger())
+
+	sb := New(&testSender{lastReceived: &bytes.Buffer{}, t: t}, WithStore(store))
+	for _, write := range []string{"01", "23", "45"} {
+		_, err := sb.Write([]byte(write))
+		require.NoError(t, err)
+	}
+	sb.persist()
+
+	sender := &testSender{lastReceived: &bytes.Buffer{}, t: t}
+	sb = New(sender, WithStore(store), WithMaxStorageSize(4))
+	require.Equal(t, int64(2), sb.DroppedBytes().Overflow)
+	sb.persist()
+	require.Equal(t, 2, storeCount(t, store))
+
+	require.NoError(t, sb.sendAndPurge())
+	require.Equal(t, "2345", string(sender.aggregateAllReceived()))
+}
+
+func TestSendBuffer_WithStore_PersistsAfterSendAndOnExit(t *testing.T) {
+	t.Parallel()
+
+	store := inmemory.NewStore(log.NewNopLogger())
+
+	// The first write is taken by the initial send, which is held until the second write is done
+	sender := &gatedSender{gate: make(chan struct{}), sending: make(chan struct{})}
+	sb := New(sender, WithStore(store), WithSendInterval(time.Hour), WithPersistInterval(time.Hour))
+
+	_, err := sb.Write([]byte("one"))
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	runDone := make(chan struct{})
+	go func() {
+		defer close(runDone)
+		require.NoError(t, sb.Run(ctx))
+	}()
+
+	// Data that's being sent stays in the store until the send is done
+	<-sender.sending
+	_, err = sb.Write([]byte("two"))
+	require.NoError(t, err)
+	require.Equal(t, 2, storeCount(t, store))
+	close(sender.gate)
+
+	require.Eventually(t, func() bool { return storeCount(t, store) == 1 }, 5*time.Second, 10*time.Millisecond)
+
+	cancel()
+	<-runDone
+
+	require.Equal(t, 1, storeCount(t, store))
+	persisted, err := store.Get([]byte{0, 0, 0, 0, 0, 0, 0, 1})
+	require.NoError(t, err)
+	require.Equal(t, "two", string(persisted))
+}
+
+func TestSendBuffer_WithStore_NewWritesSortAfterLoaded(t *testing.T) {
+	t.Parallel()
+
+	store := inmemory.NewStore(log.NewNopLogger())
+
+	sb := New(&testSender{lastReceived: &bytes.Buffer{}, t: t}, WithStore(store))
+	for _, write := range []string{"one", "two"} {
+		_, err := sb.Write([]byte(write))
+		require.NoError(t, err)
+	}
+
+	// After a restart, new writes don't overwrite the data that was loaded
+	sender := &testSender{lastReceived: &bytes.Buffer{}, t: t}
+	sb = New(sender, WithStore(store))
+	_, err := sb.Write([]byte("three"))
+	require.NoError(t, err)
+	require.Equal(t, 3, storeCount(t, store))
+
+	sb = New(sender, WithStore(store))
+	require.NoError(t, sb.sendAndPurge())
+	require.Equal(t, "onetwothree", string(sender.aggregateAllReceived()))
+}
+
+func TestSendBuffer_DroppedBytes(t *testing.T) {
+	t.Parallel()
+
+	sb := New(&failingSender{}, WithMaxStorageSize(6), WithMaxSendSize(4))
+
+	// Larger than the max send size
+	_, err := sb.Write([]byte("hello"))
+	require.NoError(t, err)
+
+	// Fills the buffer, which is then purged by the next write
+	for _, write := range []string{"abcd", "ef", "g"} {
+		_, err := sb.Write([]byte(write))
+		require.NoError(t, err)
+	}
+
+	require.NoError(t, sb.sendAndPurge())
+
+	require.Equal(t, DroppedBytes{Oversize: 5, Overflow: 6, SendFailure: 1}, sb.DroppedBytes())
+}
+
+func storeCount(t *testing.T, store types.Iterator) int {
+	count := 0
+	require.NoError(t, store.ForEach(func(_, _ []byte) error {
+		count++
+		return nil
+	}))
+	return count
+}
+
+type failingSender struct{}
+
+// gatedSender signals when a send starts, then fails it once the gate is closed
+type gatedSender struct {
+	gate, sending chan struct{}
+}
+
+func (s *gatedSender) Send(r io.Reader) error {
+	close(s.sending)
+	<-s.gate
+	return errors.New("test error")
+}
+
+func (s *failingSender) Send(r io.Reader) error {
+	return errors.New("test error")
+}
+
 func requireStoreSizeEqualsHttpBufferReportedSize(t *testing.T, sb *SendBuffer) {
 	sb.writeMutex.Lock()
 	defer sb.writeMutex.Unlock()
@@ -14,6 +14,7 @@ import (
 	"github.com/kolide/launcher/pkg/agent/storage"
 	"github.com/kolide/launcher/pkg/agent/types"
 	"github.com/kolide/launcher/pkg/backoff"
+	"github.com/kolide/launcher/pkg/ingestauth"
 	"github.com/kolide/launcher/pkg/osquery"
 	osquerygotraces "github.com/osquery/osquery-go/traces"
 	"go.opentelemetry.io/otel"
@@ -44,18 +45,19 @@ type querier interface {
 
 type TraceExporter struct {
 	provider                  *sdktrace.TracerProvider
-	providerLock              sync.Mutex
+	providerLock              sync.Mutex // guards provider and samplingRules
 	knapsack                  types.Knapsack
 	osqueryClient             querier
 	logger                    log.Logger
 	attrs                     []attribute.KeyValue // resource attributes, identifying this device + installation
 	attrLock                  sync.RWMutex
-	ingestClientAuthenticator *clientAuthenticator
+	ingestClientAuthenticator *ingestauth.ClientAuthenticator
 	ingestAuthToken           string
 	ingestUrl                 string
 	disableIngestTLS          bool
 	enabled                   bool
 	traceSamplingRate         float64
+	samplingRules             []samplingRule
 	interrupt                 chan struct{}
 }
 
@@ -81,7 +83,7 @@ func NewTraceExporter(ctx context.Context, k types.Knapsack, client osquery.Quer
 		logger:                    log.With(logger, "component", "trace_exporter"),
 		attrs:                     attrs,
 		attrLock:                  sync.RWMutex{},
-		ingestClientAuthenticator: newClientAuthenticator(string(currentToken), k.DisableTraceIngestTLS()),
+		ingestClientAuthenticator: ingestauth.NewClientAuthenticator(string(currentToken), k.DisableTraceIngestTLS()),
 		ingestAuthToken:           string(currentToken),
 		ingestUrl:                 k.TraceIngestServerURL(),
 		disableIngestTLS:          k.DisableTraceIngestTLS(),
@@ -90,6 +92,8 @@ func NewTraceExporter(ctx context.Context, k types.Knapsack, client osquery.Quer
 		interrupt:                 make(chan struct{}),
 	}
 
+	t.samplingRules = t.loadSamplingRules()
+
 	// Observe ExportTraces and IngestServerURL changes to know when to start/stop exporting, and where
 	// to export to
 	t.knapsack.RegisterChangeObserver(t, keys.ExportTraces, keys.TraceSamplingRate, keys.TraceIngestServerURL, keys.DisableTraceIngestTLS)
@@ -230,9 +234,17 @@ func (t *TraceExporter) setNewGlobalProvider() {
 	// decision made for their parent: if parent is sampled, then children should be as well;
 	// otherwise, do not sample child spans.
 	parentBasedSampler := sdktrace.ParentBased(sdktrace.TraceIDRatioBased(t.traceSamplingRate))
+	var spanProcessor sdktrace.SpanProcessor = sdktrace.NewBatchSpanProcessor(exp)
+
+	// With sampling rules, we record every trace, and decide which to keep once each is complete --
+	// falling back to t.traceSamplingRate for traces that don't match any rule.
+	if len(t.samplingRules) > 0 {
+		parentBasedSampler = sdktrace.ParentBased(sdktrace.AlwaysSample())
+		spanProcessor = newTailSamplingProcessor(spanProcessor, t.samplingRules, t.traceSamplingRate)
+	}
 
 	newProvider := sdktrace.NewTracerProvider(
-		sdktrace.WithBatcher(exp),
+		sdktrace.WithSpanProcessor(spanProcessor),
 		sdktrace.WithResource(r),
 		sdktrace.WithSampler(parentBasedSampler),
 	)
@@ -240,11 +252,23 @@ func (t *TraceExporter) setNewGlobalProvider() {
 	otel.SetTracerProvider(newProvider)
 	osquerygotraces.SetTracerProvider(newProvider)
 
+	// Shut down the previous provider once it's been replaced, so that it exports whatever it
+	// still holds -- including traces pending a tail sampling decision
+	oldProvider := t.provider
+	t.provider = newProvider
+	if oldProvider != nil {
+		oldProvider.Shutdown(context.Background())
+	}
+}
+
+// shutdownProvider shuts down the current provider, if there is one
+func (t *TraceExporter) shutdownProvider() {
+	t.providerLock.Lock()
+	defer t.providerLock.Unlock()
+
 	if t.provider != nil {
 		t.provider.Shutdown(context.Background())
 	}
-
-	t.provider = newProvider
 }
 
 // Execute is a no-op -- the exporter is already running in the background. The TraceExporter
@@ -255,26 +279,57 @@ func (t *TraceExporter) Execute() error {
 }
 
 func (t *TraceExporter) Interrupt(_ error) {
-	if t.provider != nil {
-		t.provider.Shutdown(context.Background())
-	}
+	t.shutdownProvider()
 
 	t.interrupt <- struct{}{}
 }
 
 // Update satisfies control.subscriber interface -- looks at changes to the `observability_ingest` subsystem,
-// which amounts to a new bearer auth token being provided.
+// which amounts to a new bearer auth token being provided, and to the `trace_sampling_rules` subsystem.
 func (t *TraceExporter) Ping() {
 	newToken, err := t.knapsack.TokenStore().Get(storage.ObservabilityIngestAuthTokenKey)
 	if err != nil {
 		level.Debug(t.logger).Log("msg", "could not get new token from token store", "err", err)
+	} else {
+		// No need to replace the entire global provider on token update -- we can swap
+		// to the new token in place.
+		t.ingestAuthToken = string(newToken)
+		t.ingestClientAuthenticator.SetToken(t.ingestAuthToken)
+	}
+
+	if !t.updateSamplingRules() || !t.enabled {
 		return
 	}
 
-	// No need to replace the entire global provider on token update -- we can swap
-	// to the new token in place.
-	t.ingestAuthToken = string(newToken)
-	t.ingestClientAuthenticator.setToken(t.ingestAuthToken)
+	t.setNewGlobalProvider()
+}
+
+// updateSamplingRules replaces the sampling rules with the current ones, returning whether they changed
+func (t *TraceExporter) updateSamplingRules() bool {
+	newRules := t.loadSamplingRules()
+
+	t.providerLock.Lock()
+	defer t.providerLock.Unlock()
+
+	if slices.Equal(t.samplingRules, newRules) {
+		return false
+	}
+
+	t.samplingRules = newRules
+	level.Debug(t.logger).Log("msg", "updating trace sampling rules", "rule_count", len(newRules))
+
+	return true
+}
+
+// loadSamplingRules reads the current sampling rules. Rules that can't be read are skipped, so
+// that one bad rule doesn't stop the rest from applying.
+func (t *TraceExporter) loadSamplingRules() []samplingRule {
+	rules, err := loadSamplingRules(t.knapsack.TraceSamplingRulesStore())
+	if err != nil {
+		level.Debug(t.logger).Log("msg", "could not load all trace sampling rules", "err", err)
+	}
+
+	return rules
 }
 
 // FlagsChanged satisfies the types.FlagsChangeObserver interface -- handles updates to flags
@@ -294,9 +349,7 @@ func (t *TraceExporter) FlagsChanged(flagKeys ...keys.FlagKey) {
 			level.Debug(t.logger).Log("msg", "enabling trace export")
 		} else if t.enabled && !t.knapsack.ExportTraces() {
 			// Newly disabled
-			if t.provider != nil {
-				t.provider.Shutdown(context.Background())
-			}
+			t.shutdownProvider()
 			t.enabled = false
 			level.Debug(t.logger).Log("msg", "disabling trace export")
 		}
@@ -323,7 +376,7 @@ func (t *TraceExporter) FlagsChanged(flagKeys ...keys.FlagKey) {
 	// Handle disable_trace_ingest_tls updates
 	if slices.Contains(flagKeys, keys.DisableTraceIngestTLS) {
 		if t.disableIngestTLS != t.knapsack.DisableTraceIngestTLS() {
-			t.ingestClientAuthenticator.setDisableTLS(t.knapsack.DisableTraceIngestTLS())
+			t.ingestClientAuthenticator.SetDisableTLS(t.knapsack.DisableTraceIngestTLS())
 			t.disableIngestTLS = t.knapsack.DisableTraceIngestTLS()
 			needsNewProvider = true
 			level.Debug(t.logger).Log("msg", "updating ingest server config", "new_disable_trace_ingest_tls", t.disableIngestTLS)
@@ -2,6 +2,7 @@ package exporter
 
 import (
 	"context"
+	"fmt"
 	"runtime"
 	"sync"
 	"testing"
@@ -15,6 +16,7 @@ import (
 	storageci "github.com/kolide/launcher/pkg/agent/storage/ci"
 	"github.com/kolide/launcher/pkg/agent/types"
 	typesmocks "github.com/kolide/launcher/pkg/agent/types/mocks"
+	"github.com/kolide/launcher/pkg/ingestauth"
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
 	"go.opentelemetry.io/otel/attribute"
@@ -28,6 +30,7 @@ func TestNewTraceExporter(t *testing.T) { //nolint:paralleltest
 
 	tokenStore := testTokenStore(t)
 	mockKnapsack.On("TokenStore").Return(tokenStore)
+	mockKnapsack.On("TraceSamplingRulesStore").Return(testTraceSamplingRulesStore(t))
 	tokenStore.Set(storage.ObservabilityIngestAuthTokenKey, []byte("test token"))
 
 	serverProvidedDataStore := testServerProvidedDataStore(t)
@@ -79,6 +82,7 @@ func TestNewTraceExporter_exportNotEnabled(t *testing.T) {
 	tokenStore := testTokenStore(t)
 	mockKnapsack := typesmocks.NewKnapsack(t)
 	mockKnapsack.On("TokenStore").Return(tokenStore)
+	mockKnapsack.On("TraceSamplingRulesStore").Return(testTraceSamplingRulesStore(t))
 	tokenStore.Set(storage.ObservabilityIngestAuthTokenKey, []byte("test token"))
 	mockKnapsack.On("TraceIngestServerURL").Return("localhost:3417")
 	mockKnapsack.On("DisableTraceIngestTLS").Return(false)
@@ -136,7 +140,7 @@ func Test_addDeviceIdentifyingAttributes(t *testing.T) {
 		logger:                    log.NewNopLogger(),
 		attrs:                     make([]attribute.KeyValue, 0),
 		attrLock:                  sync.RWMutex{},
-		ingestClientAuthenticator: newClientAuthenticator("test token", false),
+		ingestClientAuthenticator: ingestauth.NewClientAuthenticator("test token", false),
 		ingestAuthToken:           "test token",
 		ingestUrl:                 "localhost:4317",
 		disableIngestTLS:          false,
@@ -190,7 +194,7 @@ func Test_addAttributesFromOsquery(t *testing.T) {
 		logger:                    log.NewNopLogger(),
 		attrs:                     make([]attribute.KeyValue, 0),
 		attrLock:                  sync.RWMutex{},
-		ingestClientAuthenticator: newClientAuthenticator("test token", false),
+		ingestClientAuthenticator: ingestauth.NewClientAuthenticator("test token", false),
 		ingestAuthToken:           "test token",
 		ingestUrl:                 "localhost:4317",
 		disableIngestTLS:          false,
@@ -225,11 +229,12 @@ func TestPing(t *testing.T) {
 
 	// Set up the client authenticator + exporter with an initial token
 	initialTestToken := "test token A"
-	clientAuthenticator := newClientAuthenticator(initialTestToken, false)
+	clientAuthenticator := ingestauth.NewClientAuthenticator(initialTestToken, false)
 
 	s := testTokenStore(t)
 	mockKnapsack := typesmocks.NewKnapsack(t)
 	mockKnapsack.On("TokenStore").Return(s)
+	mockKnapsack.On("TraceSamplingRulesStore").Return(testTraceSamplingRulesStore(t))
 
 	traceExporter := &TraceExporter{
 		knapsack:                  mockKnapsack,
@@ -256,11 +261,66 @@ func TestPing(t *testing.T) {
 	require.Equal(t, newToken, traceExporter.ingestAuthToken)
 
 	// Confirm that the token was replaced in the client authenticator
-	require.Equal(t, newToken, clientAuthenticator.token)
+	require.Equal(t, newToken, clientAuthenticator.Token())
 
 	mockKnapsack.AssertExpectations(t)
 }
 
+func TestPing_SamplingRules(t *testing.T) {
+	t.Parallel()
+
+	rulesStore := testTraceSamplingRulesStore(t)
+	mockKnapsack := typesmocks.NewKnapsack(t)
+	mockKnapsack.On("TokenStore").Return(testTokenStore(t))
+	mockKnapsack.On("TraceSamplingRulesStore").Return(rulesStore)
+
+	traceExporter := &TraceExporter{
+		knapsack:                  mockKnapsack,
+		osqueryClient:             mocks.NewQuerier(t),
+		logger:                    log.NewNopLogger(),
+		attrs:                     make([]attribute.KeyValue, 0),
+		attrLock:                  sync.RWMutex{},
+		ingestClientAuthenticator: ingestauth.NewClientAuthenticator("test token", true),
+		ingestUrl:                 "localhost:4317",
+		disableIngestTLS:          true,
+		enabled:                   true,
+		traceSamplingRate:         1.0,
+	}
+	traceExporter.setNewGlobalProvider()
+	oldProvider := traceExporter.provider
+
+	// A new rule replaces the provider, and shuts down the old one
+	require.NoError(t, rulesStore.Set([]byte("errors"), []byte(`{"error":true}`)))
+	traceExporter.Ping()
+
+	require.Len(t, traceExporter.samplingRules, 1)
+	require.NotSame(t, oldProvider, traceExporter.provider)
+	_, span := oldProvider.Tracer("test").Start(context.Background(), "test")
+	require.False(t, span.IsRecording(), "old provider should have been shut down")
+	_, span = traceExporter.provider.Tracer("test").Start(context.Background(), "test")
+	require.True(t, span.IsRecording(), "new provider should be running")
+
+	// Rule updates and provider replacements from other goroutines, e.g. on flag changes, don't race
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		i := i
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			require.NoError(t, rulesStore.Set([]byte(fmt.Sprintf("rule_%d", i)), []byte(`{"span_name":"Table.Call"}`)))
+			traceExporter.Ping()
+		}()
+		go func() {
+			defer wg.Done()
+			traceExporter.setNewGlobalProvider()
+		}()
+	}
+	wg.Wait()
+
+	require.Len(t, traceExporter.samplingRules, 6)
+	traceExporter.shutdownProvider()
+}
+
 func TestFlagsChanged_ExportTraces(t *testing.T) { //nolint:paralleltest
 	tests := []struct {
 		testName              string
@@ -320,7 +380,7 @@ func TestFlagsChanged_ExportTraces(t *testing.T) { //nolint:paralleltest
 				logger:                    log.NewNopLogger(),
 				attrs:                     make([]attribute.KeyValue, 0),
 				attrLock:                  sync.RWMutex{},
-				ingestClientAuthenticator: newClientAuthenticator("test token", false),
+				ingestClientAuthenticator: ingestauth.NewClientAuthenticator("test token", false),
 				ingestAuthToken:           "test token",
 				ingestUrl:                 "localhost:4317",
 				disableIngestTLS:          false,
@@ -386,7 +446,7 @@ func TestFlagsChanged_TraceSamplingRate(t *testing.T) { //nolint:paralleltest
 				logger:                    log.NewNopLogger(),
 				attrs:                     make([]attribute.KeyValue, 0),
 				attrLock:                  sync.RWMutex{},
-				ingestClientAuthenticator: newClientAuthenticator("test token", false),
+				ingestClientAuthenticator: ingestauth.NewClientAuthenticator("test token", false),
 				ingestAuthToken:           "test token",
 				ingestUrl:                 "localhost:4317",
 				disableIngestTLS:          false,
@@ -451,7 +511,7 @@ func TestFlagsChanged_TraceIngestServerURL(t *testing.T) { //nolint:paralleltest
 				logger:                    log.NewNopLogger(),
 				attrs:                     make([]attribute.KeyValue, 0),
 				attrLock:                  sync.RWMutex{},
-				ingestClientAuthenticator: newClientAuthenticator("test token", false),
+				ingestClientAuthenticator: ingestauth.NewClientAuthenticator("test token", false),
 				ingestAuthToken:           "test token",
 				ingestUrl:                 tt.currentTraceIngestServerURL,
 				disableIngestTLS:          false,
@@ -510,7 +570,7 @@ func TestFlagsChanged_DisableTraceIngestTLS(t *testing.T) { //nolint:paralleltes
 			mockKnapsack.On("DisableTraceIngestTLS").Return(tt.newDisableTraceIngestTLS)
 			osqueryClient := mocks.NewQuerier(t)
 
-			clientAuthenticator := newClientAuthenticator("test token", tt.currentDisableTraceIngestTLS)
+			clientAuthenticator := ingestauth.NewClientAuthenticator("test token", tt.currentDisableTraceIngestTLS)
 
 			traceExporter := &TraceExporter{
 				knapsack:                  mockKnapsack,
@@ -529,7 +589,7 @@ func TestFlagsChanged_DisableTraceIngestTLS(t *testing.T) { //nolint:paralleltes
 			traceExporter.FlagsChanged(keys.DisableTraceIngestTLS)
 
 			require.Equal(t, tt.newDisableTraceIngestTLS, traceExporter.disableIngestTLS, "ingest TLS value not updated")
-			require.Equal(t, tt.newDisableTraceIngestTLS, clientAuthenticator.disableTLS, "ingest TLS value not updated for client authenticator")
+			require.Equal(t, !tt.newDisableTraceIngestTLS, clientAuthenticator.RequireTransportSecurity(), "ingest TLS value not updated for client authenticator")
 
 			if tt.shouldReplaceProvider {
 				require.NotNil(t, traceExporter.provider)
@@ -551,3 +611,9 @@ func testTokenStore(t *testing.T) types.KVStore {
 	require.NoError(t, err)
 	return s
 }
+
+func testTraceSamplingRulesStore(t *testing.T) types.KVStore {
+	s, err := storageci.NewStore(t, log.NewNopLogger(), storage.TraceSamplingRulesStore.String())
+	require.NoError(t, err)
+	return s
+}
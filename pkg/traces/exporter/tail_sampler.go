@@ -0,0 +1,308 @@
+package exporter
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"path"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/kolide/launcher/pkg/agent/types"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.17.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var (
+	// maxPendingTraceAge bounds how long we wait for a trace's root span to end before deciding
+	// on the spans we have. Decisions are remembered for as long, for spans ending after their root.
+	maxPendingTraceAge = 5 * time.Minute
+
+	// maxPendingSpans bounds how many ended spans we hold while waiting on their traces to complete
+	maxPendingSpans = 10000
+
+	// pendingSweepInterval is how often we look for traces that have been pending too long
+	pendingSweepInterval = 30 * time.Second
+)
+
+// samplingRule describes spans whose traces should be kept. All criteria set on a rule must match
+// the same span; a trace is kept if any of its spans matches any rule. Rules are delivered by the
+// control server, as JSON values keyed by rule name.
+type samplingRule struct {
+	Name string `json:"-"`
+	// SpanName is a glob matched against the span name, e.g. `Table.Call` or `localserver.*`
+	SpanName string `json:"span_name,omitempty"`
+	// Component is the launcher package the span was started in, e.g. `localserver`
+	Component string `json:"component,omitempty"`
+	// Error matches spans with an error status
+	Error bool `json:"error,omitempty"`
+	// MinDurationMs matches spans that took at least this long
+	MinDurationMs int64 `json:"min_duration_ms,omitempty"`
+	// SampleRate is the fraction of matching traces to keep, defaulting to all of them
+	SampleRate float64 `json:"sample_rate,omitempty"`
+}
+
+// loadSamplingRules reads the sampling rules from the given store, sorted by name. Rules that
+// can't be parsed are returned as an error, and skipped.
+func loadSamplingRules(store types.Iterator) ([]samplingRule, error) {
+	rules := make([]samplingRule, 0)
+	var invalid []string
+
+	if err := store.ForEach(func(k, v []byte) error {
+		var rule samplingRule
+		if err := json.Unmarshal(v, &rule); err != nil {
+			invalid = append(invalid, string(k))
+			return nil
+		}
+		rule.Name = string(k)
+		if rule.SampleRate <= 0 || rule.SampleRate > 1 {
+			rule.SampleRate = 1
+		}
+		rules = append(rules, rule)
+		return nil
+	}); err != nil {
+		return nil, fmt.Errorf("iterating over sampling rules: %w", err)
+	}
+
+	sort.Slice(rules, func(i, j int) bool {
+		return rules[i].Name < rules[j].Name
+	})
+
+	if len(invalid) > 0 {
+		return rules, fmt.Errorf("could not parse sampling rules %v", invalid)
+	}
+
+	return rules, nil
+}
+
+// matches reports whether the given span meets all the criteria of the rule
+func (r samplingRule) matches(s sdktrace.ReadOnlySpan) bool {
+	if r.SpanName != "" {
+		if matched, err := path.Match(r.SpanName, s.Name()); err != nil || !matched {
+			return false
+		}
+	}
+
+	if r.Component != "" && r.Component != spanComponent(s) {
+		return false
+	}
+
+	if r.Error && s.Status().Code != codes.Error {
+		return false
+	}
+
+	if r.MinDurationMs > 0 && s.EndTime().Sub(s.StartTime()) < time.Duration(r.MinDurationMs)*time.Millisecond {
+		return false
+	}
+
+	return true
+}
+
+// spanComponent returns the launcher package a span was started in -- the same namespace
+// traces.StartSpan uses for the span's attributes.
+func spanComponent(s sdktrace.ReadOnlySpan) string {
+	for _, attr := range s.Attributes() {
+		if attr.Key == semconv.CodeFilepathKey {
+			return filepath.Base(filepath.Dir(attr.Value.AsString()))
+		}
+	}
+
+	return ""
+}
+
+// sampledByRate makes the same decision for a trace ID and rate as sdktrace.TraceIDRatioBased,
+// so that all of a trace's spans share it.
+func sampledByRate(traceID trace.TraceID, rate float64) bool {
+	if rate >= 1 {
+		return true
+	}
+	if rate <= 0 {
+		return false
+	}
+
+	upperBound := uint64(rate * (1 << 63))
+	return binary.BigEndian.Uint64(traceID[8:16])>>1 < upperBound
+}
+
+type pendingTrace struct {
+	spans     []sdktrace.ReadOnlySpan
+	firstSeen time.Time
+}
+
+type samplingDecision struct {
+	keep      bool
+	decidedAt time.Time
+}
+
+// tailSamplingProcessor implements sdktrace.SpanProcessor. It holds ended spans until their trace's
+// local root span ends, then decides whether to keep the whole trace according to the sampling rules,
+// falling back to the sampling rate. Kept spans are passed on to the next processor.
+type tailSamplingProcessor struct {
+	next         sdktrace.SpanProcessor
+	rules        []samplingRule
+	samplingRate float64
+	pending      map[trace.TraceID]*pendingTrace
+	pendingSpans int
+	decided      map[trace.TraceID]samplingDecision
+	lastSweep    time.Time
+	lock         sync.Mutex
+}
+
+func newTailSamplingProcessor(next sdktrace.SpanProcessor, rules []samplingRule, samplingRate float64) *tailSamplingProcessor {
+	return &tailSamplingProcessor{
+		next:         next,
+		rules:        rules,
+		samplingRate: samplingRate,
+		pending:      make(map[trace.TraceID]*pendingTrace),
+		decided:      make(map[trace.TraceID]samplingDecision),
+		lastSweep:    time.Now(),
+	}
+}
+
+// OnStart is a no-op -- sampling decisions can only be made once spans have ended.
+func (p *tailSamplingProcessor) OnStart(_ context.Context, _ sdktrace.ReadWriteSpan) {}
+
+func (p *tailSamplingProcessor) OnEnd(s sdktrace.ReadOnlySpan) {
+	toExport := p.process(s, time.Now())
+
+	for _, span := range toExport {
+		p.next.OnEnd(span)
+	}
+}
+
+// process records the ended span, and returns any spans that are now ready to be exported
+func (p *tailSamplingProcessor) process(s sdktrace.ReadOnlySpan, now time.Time) []sdktrace.ReadOnlySpan {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	toExport := make([]sdktrace.ReadOnlySpan, 0)
+	if now.Sub(p.lastSweep) > pendingSweepInterval {
+		toExport = append(toExport, p.sweep(now)...)
+	}
+
+	traceID := s.SpanContext().TraceID()
+
+	// The trace was already decided, e.g. this span outlived its parent
+	if decision, ok := p.decided[traceID]; ok {
+		if decision.keep {
+			toExport = append(toExport, s)
+		}
+		return toExport
+	}
+
+	pt, ok := p.pending[traceID]
+	if !ok {
+		pt = &pendingTrace{firstSeen: now}
+		p.pending[traceID] = pt
+	}
+	pt.spans = append(pt.spans, s)
+	p.pendingSpans += 1
+
+	// Once the local root ends, the trace is as complete as it will get
+	if !s.Parent().IsValid() || s.Parent().IsRemote() {
+		toExport = append(toExport, p.decide(traceID, now)...)
+	}
+
+	// Don't hold on to an unbounded number of spans -- decide on the oldest traces early
+	for p.pendingSpans > maxPendingSpans {
+		toExport = append(toExport, p.decide(p.oldestPendingTrace(), now)...)
+	}
+
+	return toExport
+}
+
+// decide makes the sampling decision for a pending trace, returning its spans if it should be kept.
+// The caller must hold p.lock.
+func (p *tailSamplingProcessor) decide(traceID trace.TraceID, now time.Time) []sdktrace.ReadOnlySpan {
+	pt, ok := p.pending[traceID]
+	if !ok {
+		return nil
+	}
+	delete(p.pending, traceID)
+	p.pendingSpans -= len(pt.spans)
+
+	keep := p.shouldKeep(traceID, pt.spans)
+	p.decided[traceID] = samplingDecision{keep: keep, decidedAt: now}
+
+	if !keep {
+		return nil
+	}
+
+	return pt.spans
+}
+
+// shouldKeep checks the spans of a trace against the rules, falling back to the sampling rate
+func (p *tailSamplingProcessor) shouldKeep(traceID trace.TraceID, spans []sdktrace.ReadOnlySpan) bool {
+	for _, rule := range p.rules {
+		for _, s := range spans {
+			if rule.matches(s) && sampledByRate(traceID, rule.SampleRate) {
+				return true
+			}
+		}
+	}
+
+	return sampledByRate(traceID, p.samplingRate)
+}
+
+// oldestPendingTrace returns the ID of the trace we've been waiting on longest. The caller must hold p.lock.
+func (p *tailSamplingProcessor) oldestPendingTrace() trace.TraceID {
+	var oldestID trace.TraceID
+	var oldest time.Time
+	for traceID, pt := range p.pending {
+		if oldest.IsZero() || pt.firstSeen.Before(oldest) {
+			oldestID = traceID
+			oldest = pt.firstSeen
+		}
+	}
+
+	return oldestID
+}
+
+// sweep decides on traces that have been pending too long, e.g. because their root span is
+// long-running, and forgets old decisions. The caller must hold p.lock.
+func (p *tailSamplingProcessor) sweep(now time.Time) []sdktrace.ReadOnlySpan {
+	p.lastSweep = now
+
+	toExport := make([]sdktrace.ReadOnlySpan, 0)
+	for traceID, pt := range p.pending {
+		if now.Sub(pt.firstSeen) > maxPendingTraceAge {
+			toExport = append(toExport, p.decide(traceID, now)...)
+		}
+	}
+
+	for traceID, decision := range p.decided {
+		if now.Sub(decision.decidedAt) > maxPendingTraceAge {
+			delete(p.decided, traceID)
+		}
+	}
+
+	return toExport
+}
+
+// Shutdown decides on all pending traces, so that kept spans are exported, then shuts down the next processor.
+func (p *tailSamplingProcessor) Shutdown(ctx context.Context) error {
+	p.lock.Lock()
+	now := time.Now()
+	toExport := make([]sdktrace.ReadOnlySpan, 0)
+	for traceID := range p.pending {
+		toExport = append(toExport, p.decide(traceID, now)...)
+	}
+	p.lock.Unlock()
+
+	for _, s := range toExport {
+		p.next.OnEnd(s)
+	}
+
+	return p.next.Shutdown(ctx)
+}
+
+// ForceFlush exports the spans the next processor holds. Pending traces are left pending, since
+// they aren't complete yet.
+func (p *tailSamplingProcessor) ForceFlush(ctx context.Context) error {
+	return p.next.ForceFlush(ctx)
+}
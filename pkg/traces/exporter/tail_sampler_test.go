@@ -0,0 +1,137 @@
+package exporter
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	storageci "github.com/kolide/launcher/pkg/agent/storage/ci"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	semconv "go.opentelemetry.io/otel/semconv/v1.17.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func Test_loadSamplingRules(t *testing.T) {
+	t.Parallel()
+
+	store, err := storageci.NewStore(t, log.NewNopLogger(), "trace_sampling_rules")
+	require.NoError(t, err)
+	require.NoError(t, store.Set([]byte("slow_tables"), []byte(`{"span_name":"Table.Call","min_duration_ms":500,"sample_rate":0.5}`)))
+	require.NoError(t, store.Set([]byte("errors"), []byte(`{"error":true}`)))
+	require.NoError(t, store.Set([]byte("broken"), []byte(`not json`)))
+
+	rules, err := loadSamplingRules(store)
+	require.Error(t, err, "expected error for the rule that can't be parsed")
+	require.Equal(t, []samplingRule{
+		{Name: "errors", Error: true, SampleRate: 1},
+		{Name: "slow_tables", SpanName: "Table.Call", MinDurationMs: 500, SampleRate: 0.5},
+	}, rules)
+}
+
+func Test_sampledByRate(t *testing.T) {
+	t.Parallel()
+
+	lowTraceID := trace.TraceID{0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 1}
+	highTraceID := trace.TraceID{0, 0, 0, 0, 0, 0, 0, 0, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}
+
+	require.True(t, sampledByRate(highTraceID, 1))
+	require.False(t, sampledByRate(lowTraceID, 0))
+	require.True(t, sampledByRate(lowTraceID, 0.5))
+	require.False(t, sampledByRate(highTraceID, 0.5))
+}
+
+func TestTailSamplingProcessor(t *testing.T) {
+	t.Parallel()
+
+	rules := []samplingRule{
+		{Name: "errors", Error: true, SampleRate: 1},
+		{Name: "slow_tables", SpanName: "Table.*", MinDurationMs: 500, SampleRate: 1},
+		{Name: "localserver", Component: "localserver", SampleRate: 1},
+	}
+
+	exp := tracetest.NewInMemoryExporter()
+	processor := newTailSamplingProcessor(sdktrace.NewSimpleSpanProcessor(exp), rules, 0)
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithSampler(sdktrace.AlwaysSample()),
+		sdktrace.WithSpanProcessor(processor),
+	)
+	t.Cleanup(func() { provider.Shutdown(context.Background()) })
+	tracer := provider.Tracer("test")
+
+	exportedNames := func() []string {
+		names := make([]string, 0)
+		for _, s := range exp.GetSpans() {
+			names = append(names, s.Name)
+		}
+		return names
+	}
+
+	// No rules match, and the fallback rate is 0 -- nothing is kept
+	ctx, root := tracer.Start(context.Background(), "uninteresting_root")
+	_, child := tracer.Start(ctx, "Table.Call")
+	child.End()
+	root.End()
+	require.Empty(t, exportedNames())
+
+	// A child span with an error keeps the whole trace, once the root ends
+	ctx, root = tracer.Start(context.Background(), "error_root")
+	_, child = tracer.Start(ctx, "error_child")
+	child.SetStatus(codes.Error, "test error")
+	child.End()
+	require.Empty(t, exportedNames(), "trace should be held until the root span ends")
+	root.End()
+	require.ElementsMatch(t, []string{"error_child", "error_root"}, exportedNames())
+	exp.Reset()
+
+	// A slow table generation keeps the trace, while a fast one doesn't
+	start := time.Now()
+	ctx, root = tracer.Start(context.Background(), "slow_root", trace.WithTimestamp(start))
+	_, child = tracer.Start(ctx, "Table.Call", trace.WithTimestamp(start))
+	child.End(trace.WithTimestamp(start.Add(time.Second)))
+	root.End(trace.WithTimestamp(start.Add(time.Second)))
+	ctx, root = tracer.Start(context.Background(), "fast_root", trace.WithTimestamp(start))
+	_, child = tracer.Start(ctx, "Table.Call", trace.WithTimestamp(start))
+	child.End(trace.WithTimestamp(start.Add(10 * time.Millisecond)))
+	root.End(trace.WithTimestamp(start.Add(10 * time.Millisecond)))
+	require.ElementsMatch(t, []string{"Table.Call", "slow_root"}, exportedNames())
+	exp.Reset()
+
+	// Spans are matched on the launcher package they were started in, and spans ending after
+	// the decision follow it
+	ctx, root = tracer.Start(context.Background(), "localserver_root", trace.WithAttributes(semconv.CodeFilepath("/src/launcher/ee/localserver/server.go")))
+	_, child = tracer.Start(ctx, "late_child")
+	root.End()
+	child.End()
+	require.ElementsMatch(t, []string{"localserver_root", "late_child"}, exportedNames())
+}
+
+func TestTailSamplingProcessor_DecidesOnOldTraces(t *testing.T) {
+	t.Parallel()
+
+	exp := tracetest.NewInMemoryExporter()
+	processor := newTailSamplingProcessor(sdktrace.NewSimpleSpanProcessor(exp), []samplingRule{{Name: "errors", Error: true, SampleRate: 1}}, 0)
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithSampler(sdktrace.AlwaysSample()),
+		sdktrace.WithSpanProcessor(processor),
+	)
+	tracer := provider.Tracer("test")
+
+	// The root span of this trace never ends
+	ctx, _ := tracer.Start(context.Background(), "long_running_root")
+	_, child := tracer.Start(ctx, "error_child")
+	child.SetStatus(codes.Error, "test error")
+	child.End()
+
+	processor.lock.Lock()
+	require.Equal(t, 1, processor.pendingSpans)
+	toExport := processor.sweep(time.Now().Add(maxPendingTraceAge + time.Minute))
+	require.Equal(t, 0, processor.pendingSpans)
+	processor.lock.Unlock()
+
+	require.Equal(t, 1, len(toExport))
+	require.Equal(t, "error_child", toExport[0].Name())
+}